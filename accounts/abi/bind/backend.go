@@ -39,6 +39,10 @@ var (
 	// ErrNoCodeAfterDeploy is returned by WaitDeployed if contract creation leaves
 	// an empty contract behind.
 	ErrNoCodeAfterDeploy = errors.New("no contract code after deployment")
+
+	// ErrUnknownEvent is returned by the generated ParseLog methods if the log
+	// doesn't correspond to any of the non-anonymous events of the contract.
+	ErrUnknownEvent = errors.New("no event found matching log signature")
 )
 
 // ContractCaller defines the methods needed to allow operating with a contract on a read
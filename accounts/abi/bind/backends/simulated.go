@@ -22,9 +22,11 @@ import (
 	"fmt"
 	"math/big"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -35,14 +37,17 @@ import (
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/state/fork"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/eth/filters"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/miner"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
 )
 
 // This nil assignment ensures at compile time that SimulatedBackend implements bind.ContractBackend.
@@ -52,6 +57,8 @@ var (
 	errBlockNumberUnsupported  = errors.New("simulatedBackend cannot access blocks other than the latest block")
 	errBlockDoesNotExist       = errors.New("block does not exist in blockchain")
 	errTransactionDoesNotExist = errors.New("transaction does not exist")
+	errSnapshotDoesNotExist    = errors.New("snapshot does not exist")
+	errSnapshotNotCanonical    = errors.New("snapshot block is no longer canonical")
 )
 
 // SimulatedBackend implements bind.ContractBackend, simulating a blockchain in
@@ -62,13 +69,23 @@ var (
 type SimulatedBackend struct {
 	database   ethdb.Database   // In memory database to store our testing data
 	blockchain *core.BlockChain // Ethereum blockchain to handle the consensus
+	chain      *simulatedChain  // Chain view of the transaction pool, headed by the pending block's parent
+	txpool     *core.TxPool     // Transaction pool tracking pending and queued transactions
+	miner      *miner.Miner     // Block producer assembling the pending block from the pool
 
-	mu           sync.Mutex
-	pendingBlock *types.Block   // Currently pending block that will be imported on request
-	pendingState *state.StateDB // Currently pending state that will be the active on request
+	mu              sync.Mutex
+	pendingBlock    *types.Block   // Currently pending block that will be imported on request
+	pendingReceipts types.Receipts // Receipts of the currently pending block
+	pendingState    *state.StateDB // Currently pending state that will be the active on request
+	pendingTime     uint64         // Timestamp of the currently pending block
+	snapshots       []common.Hash  // Chain heads recorded by Snapshot, indexed by snapshot id
 
 	events *filters.EventSystem // Event system for filtering log events live
 
+	server *rpc.Server       // In-process RPC server exposing the simulated node
+	client *rpc.Client       // RPC client attached to the in-process server
+	accman *accounts.Manager // Empty account manager backing the RPC APIs
+
 	config *params.ChainConfig
 }
 
@@ -76,16 +93,77 @@ type SimulatedBackend struct {
 // and uses a simulated blockchain for testing purposes.
 // A simulated backend always uses chainID 1337.
 func NewSimulatedBackendWithDatabase(database ethdb.Database, alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBackend {
+	// Run the chain in archive mode, keeping the state of every block on disk for
+	// tracing and for chain generators operating on the raw database.
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyLimit:    256,
+		TrieDirtyDisabled: true,
+		TrieTimeLimit:     5 * time.Minute,
+		SnapshotLimit:     256,
+		SnapshotWait:      true,
+	}
 	genesis := core.Genesis{Config: params.AllEthashProtocolChanges, GasLimit: gasLimit, Alloc: alloc}
+	return newSimulatedBackend(database, cacheConfig, genesis)
+}
+
+// NewForkedSimulatedBackend creates a new binding backend whose state lazily
+// mirrors the state of another chain, as retrieved through the given fetcher.
+// The accounts in alloc override the ones of the forked chain.
+//
+// The genesis block of the simulated chain stands in for the fork block: the EVM
+// sees the number and hash of the fork block for it and numbers the following
+// blocks as its successors, while the chain itself is numbered from zero.
+// A simulated backend always uses chainID 1337.
+func NewForkedSimulatedBackend(fetcher fork.Fetcher, alloc core.GenesisAlloc, gasLimit uint64) (*SimulatedBackend, error) {
+	history, err := fork.NewHistory(context.Background(), fetcher)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve fork block: %v", err)
+	}
+	// Snapshots would only cover the locally modified state, disable them
+	cacheConfig := &core.CacheConfig{
+		TrieCleanLimit:    256,
+		TrieDirtyLimit:    256,
+		TrieDirtyDisabled: true,
+		TrieTimeLimit:     5 * time.Minute,
+		StateDatabase: func(db ethdb.Database, config *trie.Config) state.Database {
+			return fork.New(db, config, fetcher)
+		},
+		Fork: history,
+	}
+	genesis := core.Genesis{
+		Config:    params.AllEthashProtocolChanges,
+		Timestamp: history.ForkHeader().Time,
+		GasLimit:  gasLimit,
+		Alloc:     alloc,
+	}
+	return newSimulatedBackend(rawdb.NewMemoryDatabase(), cacheConfig, genesis), nil
+}
+
+// newSimulatedBackend creates a new binding backend based on the given database,
+// chain cache configuration and genesis.
+func newSimulatedBackend(database ethdb.Database, cacheConfig *core.CacheConfig, genesis core.Genesis) *SimulatedBackend {
+	gasLimit := genesis.GasLimit
 	genesis.MustCommit(database)
-	blockchain, _ := core.NewBlockChain(database, nil, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	blockchain, _ := core.NewBlockChain(database, cacheConfig, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
 
 	backend := &SimulatedBackend{
 		database:   database,
 		blockchain: blockchain,
+		chain:      newSimulatedChain(blockchain),
 		config:     genesis.Config,
-		events:     filters.NewEventSystem(&filterBackend{database, blockchain}, false),
 	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+	backend.txpool = core.NewTxPool(poolConfig, genesis.Config, backend.chain)
+
+	minerConfig := &miner.Config{GasCeil: gasLimit, Recommit: 3 * time.Second}
+	backend.miner = miner.New(&minerBackend{blockchain, backend.txpool}, minerConfig, genesis.Config, new(event.TypeMux), ethash.NewFaker(), nil)
+
+	backend.events = filters.NewEventSystem(&filterBackend{database, blockchain, backend}, false)
+	backend.server, backend.accman = newRPCServer(backend)
+	backend.client = rpc.DialInProc(backend.server)
+
 	backend.rollback(blockchain.CurrentBlock())
 	return backend
 }
@@ -97,12 +175,25 @@ func NewSimulatedBackend(alloc core.GenesisAlloc, gasLimit uint64) *SimulatedBac
 	return NewSimulatedBackendWithDatabase(rawdb.NewMemoryDatabase(), alloc, gasLimit)
 }
 
-// Close terminates the underlying blockchain's update loop.
+// Close terminates the RPC server, the miner, the transaction pool and the
+// underlying blockchain's update loop.
 func (b *SimulatedBackend) Close() error {
+	b.client.Close()
+	b.server.Stop()
+	b.accman.Close()
+	b.miner.Close()
+	b.txpool.Stop()
 	b.blockchain.Stop()
 	return nil
 }
 
+// Client returns an RPC client attached to the simulated node, serving the eth,
+// txpool and debug namespaces. It can be wrapped into an ethclient.Client to
+// run tests against the simulator the same way as against a live node.
+func (b *SimulatedBackend) Client() *rpc.Client {
+	return b.client
+}
+
 // Commit imports all the pending transactions as a single block and starts a
 // fresh new state.
 func (b *SimulatedBackend) Commit() {
@@ -117,26 +208,82 @@ func (b *SimulatedBackend) Commit() {
 	b.rollback(b.pendingBlock)
 }
 
-// Rollback aborts all pending transactions, reverting to the last committed state.
+// Rollback aborts all pending transactions, dropping them from the transaction
+// pool and reverting to the last committed state.
 func (b *SimulatedBackend) Rollback() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	b.txpool.Clear()
 	b.rollback(b.blockchain.CurrentBlock())
 }
 
+// rollback moves the pending block on top of the given parent, resetting the
+// transaction pool to it and refilling the block from the pool's contents.
 func (b *SimulatedBackend) rollback(parent *types.Block) {
-	blocks, _ := core.GenerateChain(b.config, parent, ethash.NewFaker(), b.database, 1, func(int, *core.BlockGen) {})
+	b.chain.setHead(parent)
+	b.txpool.Sync()
 
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), b.blockchain.StateCache(), nil)
+	b.pendingTime = parent.Time() + 10
+	b.refresh()
+}
+
+// refresh regenerates the pending block from the executable transactions of
+// the pool.
+func (b *SimulatedBackend) refresh() {
+	block, receipts, statedb, err := b.miner.GenerateBlock(b.chain.CurrentBlock(), b.pendingTime, common.Address{})
+	if err != nil {
+		panic(err) // This cannot happen unless the simulator is wrong, fail in that case
+	}
+	b.pendingBlock = block
+	b.pendingReceipts = receipts
+	b.pendingState = statedb
+}
+
+// Snapshot records the current head of the chain, returning an id which can be
+// used to revert back to it.
+func (b *SimulatedBackend) Snapshot() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.snapshots = append(b.snapshots, b.blockchain.CurrentBlock().Hash())
+	return len(b.snapshots) - 1
+}
+
+// Revert rewinds the chain to the head recorded by the given snapshot, dropping
+// all pending transactions. The snapshot and all the ones taken after it are
+// invalidated.
+func (b *SimulatedBackend) Revert(id int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if id < 0 || id >= len(b.snapshots) {
+		return errSnapshotDoesNotExist
+	}
+	hash := b.snapshots[id]
+
+	block := b.blockchain.GetBlockByHash(hash)
+	if block == nil || b.blockchain.GetCanonicalHash(block.NumberU64()) != hash {
+		return errSnapshotNotCanonical
+	}
+	if err := b.blockchain.SetHead(block.NumberU64()); err != nil {
+		return err
+	}
+	if head := b.blockchain.CurrentBlock(); head.Hash() != hash {
+		return fmt.Errorf("failed to rewind to snapshot: head #%d [%x]", head.NumberU64(), head.Hash().Bytes()[:4])
+	}
+	b.snapshots = b.snapshots[:id]
+
+	b.txpool.Clear()
+	b.rollback(block)
+	return nil
 }
 
 // Fork creates a side-chain that can be used to simulate reorgs.
 //
 // This function should be called with the ancestor block where the new side
-// chain should be started. Transactions (old and new) can then be applied on
-// top and Commit-ed.
+// chain should be started. Any transactions queued in the pool are dropped.
+// Transactions (old and new) can then be applied on top and Commit-ed.
 //
 // Note, the side-chain will only become canonical (and trigger the events) when
 // it becomes longer. Until then CallContract will still operate on the current
@@ -155,6 +302,7 @@ func (b *SimulatedBackend) Fork(ctx context.Context, parent common.Hash) error {
 	if err != nil {
 		return err
 	}
+	b.txpool.Clear()
 	b.rollback(block)
 	return nil
 }
@@ -245,6 +393,9 @@ func (b *SimulatedBackend) TransactionByHash(ctx context.Context, txHash common.
 	if tx != nil {
 		return tx, true, nil
 	}
+	if tx = b.txpool.Get(txHash); tx != nil {
+		return tx, true, nil
+	}
 	tx, _, _, _ = rawdb.ReadTransaction(b.database, txHash)
 	if tx != nil {
 		return tx, false, nil
@@ -451,12 +602,9 @@ func (b *SimulatedBackend) PendingCallContract(ctx context.Context, call ethereu
 }
 
 // PendingNonceAt implements PendingStateReader.PendingNonceAt, retrieving
-// the nonce currently pending for the account.
+// the next nonce of the account as tracked by the transaction pool.
 func (b *SimulatedBackend) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
-	b.mu.Lock()
-	defer b.mu.Unlock()
-
-	return b.pendingState.GetOrNewStateObject(account).Nonce(), nil
+	return b.txpool.Nonce(account), nil
 }
 
 // SuggestGasPrice implements ContractTransactor.SuggestGasPrice. Since the simulated
@@ -638,38 +786,22 @@ func (b *SimulatedBackend) callContract(ctx context.Context, call ethereum.CallM
 	return core.NewStateTransition(vmEnv, msg, gasPool).TransitionDb()
 }
 
-// SendTransaction updates the pending block to include the given transaction.
-// It panics if the transaction is invalid.
+// SendTransaction adds the given transaction to the transaction pool and
+// rebuilds the pending block from the pool's executable transactions. Same as
+// on a live node, transactions with a nonce gap are queued until the gap is
+// filled and pooled transactions can be replaced by ones paying a higher price.
+//
+// Note, the pending block is rebuilt with its default timestamp, discarding any
+// previous AdjustTime shift.
 func (b *SimulatedBackend) SendTransaction(ctx context.Context, tx *types.Transaction) error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	// Get the last block
-	block, err := b.blockByHash(ctx, b.pendingBlock.ParentHash())
-	if err != nil {
-		panic("could not fetch parent")
-	}
-	// Check transaction validity
-	signer := types.MakeSigner(b.blockchain.Config(), block.Number())
-	sender, err := types.Sender(signer, tx)
-	if err != nil {
-		panic(fmt.Errorf("invalid transaction: %v", err))
-	}
-	nonce := b.pendingState.GetNonce(sender)
-	if tx.Nonce() != nonce {
-		panic(fmt.Errorf("invalid transaction nonce: got %d, want %d", tx.Nonce(), nonce))
+	if err := b.txpool.AddLocal(tx); err != nil {
+		return err
 	}
-	// Include tx in chain
-	blocks, _ := core.GenerateChain(b.config, block, ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		for _, tx := range b.pendingBlock.Transactions() {
-			block.AddTxWithChain(b.blockchain, tx)
-		}
-		block.AddTxWithChain(b.blockchain, tx)
-	})
-	stateDB, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), stateDB.Database(), nil)
+	b.pendingTime = b.chain.CurrentBlock().Time() + 10
+	b.refresh()
 	return nil
 }
 
@@ -681,7 +813,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 	var filter *filters.Filter
 	if query.BlockHash != nil {
 		// Block filter requested, construct a single-shot filter
-		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain}, *query.BlockHash, query.Addresses, query.Topics)
+		filter = filters.NewBlockFilter(&filterBackend{b.database, b.blockchain, b}, *query.BlockHash, query.Addresses, query.Topics)
 	} else {
 		// Initialize unset filter boundaries to run from genesis to chain head
 		from := int64(0)
@@ -693,7 +825,7 @@ func (b *SimulatedBackend) FilterLogs(ctx context.Context, query ethereum.Filter
 			to = query.ToBlock.Int64()
 		}
 		// Construct the range filter
-		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain}, from, to, query.Addresses, query.Topics)
+		filter = filters.NewRangeFilter(&filterBackend{b.database, b.blockchain, b}, from, to, query.Addresses, query.Topics)
 	}
 	// Run the filter and return all the logs
 	logs, err := filter.Logs(ctx)
@@ -777,14 +909,14 @@ func (b *SimulatedBackend) AdjustTime(adjustment time.Duration) error {
 	if len(b.pendingBlock.Transactions()) != 0 {
 		return errors.New("Could not adjust time on non-empty block")
 	}
+	parent := b.chain.CurrentBlock()
 
-	blocks, _ := core.GenerateChain(b.config, b.blockchain.CurrentBlock(), ethash.NewFaker(), b.database, 1, func(number int, block *core.BlockGen) {
-		block.OffsetTime(int64(adjustment.Seconds()))
-	})
-	stateDB, _ := b.blockchain.State()
-
-	b.pendingBlock = blocks[0]
-	b.pendingState, _ = state.New(b.pendingBlock.Root(), stateDB.Database(), nil)
+	timestamp := int64(parent.Time()) + 10 + int64(adjustment.Seconds())
+	if timestamp <= int64(parent.Time()) {
+		return errors.New("Could not adjust time before parent block")
+	}
+	b.pendingTime = uint64(timestamp)
+	b.refresh()
 
 	return nil
 }
@@ -814,8 +946,9 @@ func (m callMsg) AccessList() types.AccessList { return m.CallMsg.AccessList }
 // filterBackend implements filters.Backend to support filtering for logs without
 // taking bloom-bits acceleration structures into account.
 type filterBackend struct {
-	db ethdb.Database
-	bc *core.BlockChain
+	db      ethdb.Database
+	bc      *core.BlockChain
+	backend *SimulatedBackend
 }
 
 func (fb *filterBackend) ChainDb() ethdb.Database  { return fb.db }
@@ -857,7 +990,7 @@ func (fb *filterBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*ty
 }
 
 func (fb *filterBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
-	return nullSubscription()
+	return fb.backend.txpool.SubscribeNewTxsEvent(ch)
 }
 
 func (fb *filterBackend) SubscribeChainEvent(ch chan<- core.ChainEvent) event.Subscription {
@@ -873,7 +1006,7 @@ func (fb *filterBackend) SubscribeLogsEvent(ch chan<- []*types.Log) event.Subscr
 }
 
 func (fb *filterBackend) SubscribePendingLogsEvent(ch chan<- []*types.Log) event.Subscription {
-	return nullSubscription()
+	return fb.backend.miner.SubscribePendingLogs(ch)
 }
 
 func (fb *filterBackend) BloomStatus() (uint64, uint64) { return 4096, 0 }
@@ -882,9 +1015,36 @@ func (fb *filterBackend) ServiceFilter(ctx context.Context, ms *bloombits.Matche
 	panic("not supported")
 }
 
-func nullSubscription() event.Subscription {
-	return event.NewSubscription(func(quit <-chan struct{}) error {
-		<-quit
-		return nil
-	})
+// simulatedChain is the view of the blockchain used by the transaction pool. Its
+// head is the parent of the pending block instead of the canonical head, so the
+// pool follows side chains created by Fork. Head changes are not announced, the
+// pool is synced explicitly by the simulator instead.
+type simulatedChain struct {
+	*core.BlockChain
+
+	head atomic.Value // Parent of the pending block
+	feed event.Feed   // Never fired, pool resets are driven by the simulator
 }
+
+func newSimulatedChain(bc *core.BlockChain) *simulatedChain {
+	chain := &simulatedChain{BlockChain: bc}
+	chain.head.Store(bc.CurrentBlock())
+	return chain
+}
+
+func (c *simulatedChain) setHead(block *types.Block) { c.head.Store(block) }
+func (c *simulatedChain) CurrentBlock() *types.Block { return c.head.Load().(*types.Block) }
+
+func (c *simulatedChain) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return c.feed.Subscribe(ch)
+}
+
+// minerBackend implements miner.Backend, feeding the simulated chain and pool
+// to the block producer.
+type minerBackend struct {
+	bc   *core.BlockChain
+	pool *core.TxPool
+}
+
+func (mb *minerBackend) BlockChain() *core.BlockChain { return mb.bc }
+func (mb *minerBackend) TxPool() *core.TxPool         { return mb.pool }
@@ -0,0 +1,327 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package backends
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/filters"
+	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+
+	// Make the JavaScript and native tracers available to debug_trace*
+	_ "github.com/ethereum/go-ethereum/eth/tracers/js"
+	_ "github.com/ethereum/go-ethereum/eth/tracers/native"
+)
+
+// apiBackend implements ethapi.Backend and tracers.Backend on top of the
+// simulated backend, allowing it to serve the same RPC APIs as a full node.
+type apiBackend struct {
+	*filterBackend
+
+	sim    *SimulatedBackend
+	accman *accounts.Manager
+	gpo    *gasprice.Oracle
+}
+
+// newRPCServer creates an RPC server exposing the eth, txpool and debug
+// namespaces of the simulated backend.
+func newRPCServer(sim *SimulatedBackend) (*rpc.Server, *accounts.Manager) {
+	backend := &apiBackend{
+		filterBackend: &filterBackend{sim.database, sim.blockchain, sim},
+		sim:           sim,
+		accman:        accounts.NewManager(&accounts.Config{}),
+	}
+	backend.gpo = gasprice.NewOracle(backend, gasprice.Config{
+		Blocks:           20,
+		Percentile:       60,
+		MaxHeaderHistory: 1024,
+		MaxBlockHistory:  1024,
+		Default:          big.NewInt(1),
+	})
+	apis := append(ethapi.GetAPIs(backend), tracers.APIs(backend)...)
+	apis = append(apis, rpc.API{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service:   filters.NewPublicFilterAPI(backend, false, 5*time.Minute),
+		Public:    true,
+	})
+	server := rpc.NewServer()
+	for _, api := range apis {
+		switch api.Namespace {
+		case "eth", "txpool", "debug":
+			if err := server.RegisterName(api.Namespace, api.Service); err != nil {
+				panic(err) // This cannot happen unless the APIs are malformed
+			}
+		}
+	}
+	return server, backend.accman
+}
+
+func (b *apiBackend) SyncProgress() ethereum.SyncProgress { return ethereum.SyncProgress{} }
+func (b *apiBackend) AccountManager() *accounts.Manager   { return b.accman }
+func (b *apiBackend) ExtRPCEnabled() bool                 { return false }
+func (b *apiBackend) RPCGasCap() uint64                   { return 50000000 }
+func (b *apiBackend) RPCEVMTimeout() time.Duration        { return 5 * time.Second }
+func (b *apiBackend) RPCTxFeeCap() float64                { return 0 }
+func (b *apiBackend) UnprotectedAllowed() bool            { return true }
+func (b *apiBackend) ChainConfig() *params.ChainConfig    { return b.sim.config }
+func (b *apiBackend) Engine() consensus.Engine            { return b.sim.blockchain.Engine() }
+func (b *apiBackend) CurrentHeader() *types.Header        { return b.sim.blockchain.CurrentHeader() }
+func (b *apiBackend) CurrentBlock() *types.Block          { return b.sim.blockchain.CurrentBlock() }
+
+func (b *apiBackend) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return b.sim.SuggestGasTipCap(ctx)
+}
+
+func (b *apiBackend) FeeHistory(ctx context.Context, blockCount int, lastBlock rpc.BlockNumber, rewardPercentiles []float64) (*big.Int, [][]*big.Int, []*big.Int, []float64, error) {
+	return b.gpo.FeeHistory(ctx, blockCount, lastBlock, rewardPercentiles)
+}
+
+func (b *apiBackend) SetHead(number uint64) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	b.sim.blockchain.SetHead(number)
+	b.sim.txpool.Clear()
+	b.sim.rollback(b.sim.blockchain.CurrentBlock())
+}
+
+func (b *apiBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	switch number {
+	case rpc.PendingBlockNumber:
+		b.sim.mu.Lock()
+		defer b.sim.mu.Unlock()
+
+		return b.sim.pendingBlock.Header(), nil
+	case rpc.LatestBlockNumber:
+		return b.sim.blockchain.CurrentHeader(), nil
+	}
+	return b.sim.blockchain.GetHeaderByNumber(uint64(number)), nil
+}
+
+func (b *apiBackend) HeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Header, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.HeaderByNumber(ctx, blockNr)
+	}
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header := b.sim.blockchain.GetHeaderByHash(hash)
+		if header == nil {
+			return nil, errors.New("header for hash not found")
+		}
+		if blockNrOrHash.RequireCanonical && b.sim.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
+			return nil, errors.New("hash is not currently canonical")
+		}
+		return header, nil
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+func (b *apiBackend) BlockByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Block, error) {
+	switch number {
+	case rpc.PendingBlockNumber:
+		b.sim.mu.Lock()
+		defer b.sim.mu.Unlock()
+
+		return b.sim.pendingBlock, nil
+	case rpc.LatestBlockNumber:
+		return b.sim.blockchain.CurrentBlock(), nil
+	}
+	return b.sim.blockchain.GetBlockByNumber(uint64(number)), nil
+}
+
+func (b *apiBackend) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return b.sim.blockchain.GetBlockByHash(hash), nil
+}
+
+func (b *apiBackend) BlockByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*types.Block, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.BlockByNumber(ctx, blockNr)
+	}
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header := b.sim.blockchain.GetHeaderByHash(hash)
+		if header == nil {
+			return nil, errors.New("header for hash not found")
+		}
+		if blockNrOrHash.RequireCanonical && b.sim.blockchain.GetCanonicalHash(header.Number.Uint64()) != hash {
+			return nil, errors.New("hash is not currently canonical")
+		}
+		block := b.sim.blockchain.GetBlock(hash, header.Number.Uint64())
+		if block == nil {
+			return nil, errors.New("header found, but block body is missing")
+		}
+		return block, nil
+	}
+	return nil, errors.New("invalid arguments; neither block nor hash specified")
+}
+
+func (b *apiBackend) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
+	b.sim.mu.Lock()
+	defer b.sim.mu.Unlock()
+
+	return b.sim.pendingBlock, b.sim.pendingReceipts
+}
+
+func (b *apiBackend) StateAndHeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*state.StateDB, *types.Header, error) {
+	// Pending state is only known by the simulator
+	if number == rpc.PendingBlockNumber {
+		b.sim.mu.Lock()
+		defer b.sim.mu.Unlock()
+
+		return b.sim.pendingState.Copy(), b.sim.pendingBlock.Header(), nil
+	}
+	// Otherwise resolve the block number and return its state
+	header, err := b.HeaderByNumber(ctx, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header == nil {
+		return nil, nil, errors.New("header not found")
+	}
+	stateDb, err := b.sim.blockchain.StateAt(header.Root)
+	return stateDb, header, err
+}
+
+func (b *apiBackend) StateAndHeaderByNumberOrHash(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*state.StateDB, *types.Header, error) {
+	if blockNr, ok := blockNrOrHash.Number(); ok {
+		return b.StateAndHeaderByNumber(ctx, blockNr)
+	}
+	header, err := b.HeaderByNumberOrHash(ctx, blockNrOrHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	stateDb, err := b.sim.blockchain.StateAt(header.Root)
+	return stateDb, header, err
+}
+
+func (b *apiBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int {
+	if header := b.sim.blockchain.GetHeaderByHash(hash); header != nil {
+		return b.sim.blockchain.GetTd(hash, header.Number.Uint64())
+	}
+	return nil
+}
+
+func (b *apiBackend) GetEVM(ctx context.Context, msg core.Message, state *state.StateDB, header *types.Header, vmConfig *vm.Config) (*vm.EVM, func() error, error) {
+	vmError := func() error { return nil }
+	if vmConfig == nil {
+		vmConfig = b.sim.blockchain.GetVMConfig()
+	}
+	txContext := core.NewEVMTxContext(msg)
+	context := core.NewEVMBlockContext(header, b.sim.blockchain, nil)
+	return vm.NewEVM(context, txContext, state, b.sim.config, *vmConfig), vmError, nil
+}
+
+func (b *apiBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.sim.blockchain.SubscribeChainHeadEvent(ch)
+}
+
+func (b *apiBackend) SubscribeChainSideEvent(ch chan<- core.ChainSideEvent) event.Subscription {
+	return b.sim.blockchain.SubscribeChainSideEvent(ch)
+}
+
+func (b *apiBackend) SendTx(ctx context.Context, signedTx *types.Transaction) error {
+	return b.sim.SendTransaction(ctx, signedTx)
+}
+
+func (b *apiBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	tx, blockHash, blockNumber, index := rawdb.ReadTransaction(b.sim.database, txHash)
+	return tx, blockHash, blockNumber, index, nil
+}
+
+func (b *apiBackend) GetPoolTransactions() (types.Transactions, error) {
+	var txs types.Transactions
+	for _, batch := range b.sim.txpool.Pending(false) {
+		txs = append(txs, batch...)
+	}
+	return txs, nil
+}
+
+func (b *apiBackend) GetPoolTransaction(hash common.Hash) *types.Transaction {
+	return b.sim.txpool.Get(hash)
+}
+
+func (b *apiBackend) GetPoolNonce(ctx context.Context, addr common.Address) (uint64, error) {
+	return b.sim.txpool.Nonce(addr), nil
+}
+
+func (b *apiBackend) Stats() (pending int, queued int) {
+	return b.sim.txpool.Stats()
+}
+
+func (b *apiBackend) TxPoolContent() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
+	return b.sim.txpool.Content()
+}
+
+func (b *apiBackend) TxPoolContentFrom(addr common.Address) (types.Transactions, types.Transactions) {
+	return b.sim.txpool.ContentFrom(addr)
+}
+
+func (b *apiBackend) StateAtBlock(ctx context.Context, block *types.Block, reexec uint64, base *state.StateDB, checkLive, preferDisk bool) (*state.StateDB, error) {
+	return b.sim.blockchain.StateAt(block.Root())
+}
+
+func (b *apiBackend) StateAtTransaction(ctx context.Context, block *types.Block, txIndex int, reexec uint64) (core.Message, vm.BlockContext, *state.StateDB, error) {
+	// Short circuit if it's genesis block.
+	if block.NumberU64() == 0 {
+		return nil, vm.BlockContext{}, nil, errors.New("no transaction in genesis")
+	}
+	parent := b.sim.blockchain.GetBlock(block.ParentHash(), block.NumberU64()-1)
+	if parent == nil {
+		return nil, vm.BlockContext{}, nil, fmt.Errorf("parent %#x not found", block.ParentHash())
+	}
+	statedb, err := b.sim.blockchain.StateAt(parent.Root())
+	if err != nil {
+		return nil, vm.BlockContext{}, nil, err
+	}
+	if txIndex == 0 && len(block.Transactions()) == 0 {
+		return nil, vm.BlockContext{}, statedb, nil
+	}
+	// Recompute transactions up to the target index.
+	signer := types.MakeSigner(b.sim.config, block.Number())
+	for idx, tx := range block.Transactions() {
+		msg, _ := tx.AsMessage(signer, block.BaseFee())
+		txContext := core.NewEVMTxContext(msg)
+		context := core.NewEVMBlockContext(block.Header(), b.sim.blockchain, nil)
+		if idx == txIndex {
+			return msg, context, statedb, nil
+		}
+		vmenv := vm.NewEVM(context, txContext, statedb, b.sim.config, vm.Config{})
+		statedb.Prepare(tx.Hash(), idx)
+		if _, err := core.ApplyMessage(vmenv, msg, new(core.GasPool).AddGas(tx.Gas())); err != nil {
+			return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction %#x failed: %v", tx.Hash(), err)
+		}
+		statedb.Finalise(vmenv.ChainConfig().IsEIP158(block.Number()))
+	}
+	return nil, vm.BlockContext{}, nil, fmt.Errorf("transaction index %d out of range for block %#x", txIndex, block.Hash())
+}
@@ -31,9 +31,12 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state/fork"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -1336,3 +1339,277 @@ func TestForkResendTx(t *testing.T) {
 		t.Errorf("TX included in wrong block: %d", h)
 	}
 }
+
+// TestSendTransactionNonceGap checks that transactions with a nonce gap are
+// queued in the pool until the gap is filled.
+func TestSendTransactionNonceGap(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+
+	head, _ := sim.HeaderByNumber(bgCtx, nil)
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+
+	signed := make([]*types.Transaction, 2)
+	for i := range signed {
+		tx := types.NewTransaction(uint64(i), testAddr, big.NewInt(1000), params.TxGas, gasPrice, nil)
+		signed[i], _ = types.SignTx(tx, types.HomesteadSigner{}, testKey)
+	}
+	// Send the second transaction first, it should be queued
+	if err := sim.SendTransaction(bgCtx, signed[1]); err != nil {
+		t.Fatalf("could not add gapped tx: %v", err)
+	}
+	if count := len(sim.pendingBlock.Transactions()); count != 0 {
+		t.Errorf("gapped transaction included in pending block: have %d txs", count)
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 0 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 0)
+	}
+	if _, pending, err := sim.TransactionByHash(bgCtx, signed[1].Hash()); err != nil || !pending {
+		t.Errorf("queued transaction not found in pool: pending %v, err %v", pending, err)
+	}
+	// Fill the gap, both transactions should become executable
+	if err := sim.SendTransaction(bgCtx, signed[0]); err != nil {
+		t.Fatalf("could not add tx: %v", err)
+	}
+	if count := len(sim.pendingBlock.Transactions()); count != 2 {
+		t.Errorf("pending block transaction count mismatch: have %d, want %d", count, 2)
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 2 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 2)
+	}
+	sim.Commit()
+
+	for _, tx := range signed {
+		receipt, err := sim.TransactionReceipt(bgCtx, tx.Hash())
+		if err != nil || receipt == nil {
+			t.Fatalf("missing receipt for tx %x: %v", tx.Hash(), err)
+		}
+		if receipt.BlockNumber.Uint64() != 1 {
+			t.Errorf("tx included in wrong block: %d", receipt.BlockNumber)
+		}
+	}
+}
+
+// TestSendTransactionReplacement checks that pending transactions can only be
+// replaced by ones paying a sufficiently higher price.
+func TestSendTransactionReplacement(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+
+	head, _ := sim.HeaderByNumber(bgCtx, nil)
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+
+	original, _ := types.SignTx(types.NewTransaction(0, testAddr, big.NewInt(1000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(bgCtx, original); err != nil {
+		t.Fatalf("could not add tx: %v", err)
+	}
+	underpriced, _ := types.SignTx(types.NewTransaction(0, testAddr, big.NewInt(2000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(bgCtx, underpriced); !errors.Is(err, core.ErrReplaceUnderpriced) {
+		t.Errorf("underpriced replacement error mismatch: have %v, want %v", err, core.ErrReplaceUnderpriced)
+	}
+	replacement, _ := types.SignTx(types.NewTransaction(0, testAddr, big.NewInt(3000), params.TxGas, new(big.Int).Mul(gasPrice, big.NewInt(2)), nil), types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(bgCtx, replacement); err != nil {
+		t.Fatalf("could not replace tx: %v", err)
+	}
+	sim.Commit()
+
+	if receipt, _ := sim.TransactionReceipt(bgCtx, original.Hash()); receipt != nil {
+		t.Errorf("replaced transaction was included")
+	}
+	if receipt, _ := sim.TransactionReceipt(bgCtx, replacement.Hash()); receipt == nil {
+		t.Errorf("replacement transaction was not included")
+	}
+}
+
+// TestSnapshotRevert checks that the chain can be rewound to a snapshot, and
+// that reverting invalidates the later snapshots.
+func TestSnapshotRevert(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+
+	balance, _ := sim.BalanceAt(bgCtx, testAddr, nil)
+	first := sim.Snapshot()
+
+	head, _ := sim.HeaderByNumber(bgCtx, nil)
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{1}, big.NewInt(1000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(bgCtx, tx); err != nil {
+		t.Fatalf("could not add tx: %v", err)
+	}
+	sim.Commit()
+	second := sim.Snapshot()
+	sim.Commit()
+
+	if number := sim.blockchain.CurrentBlock().NumberU64(); number != 2 {
+		t.Fatalf("head number mismatch: have %d, want %d", number, 2)
+	}
+	if err := sim.Revert(first); err != nil {
+		t.Fatalf("failed to revert: %v", err)
+	}
+	if number := sim.blockchain.CurrentBlock().NumberU64(); number != 0 {
+		t.Errorf("head number mismatch after revert: have %d, want %d", number, 0)
+	}
+	if have, _ := sim.BalanceAt(bgCtx, testAddr, nil); have.Cmp(balance) != 0 {
+		t.Errorf("balance mismatch after revert: have %v, want %v", have, balance)
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 0 {
+		t.Errorf("pending nonce mismatch after revert: have %d, want %d", nonce, 0)
+	}
+	if err := sim.Revert(second); err != errSnapshotDoesNotExist {
+		t.Errorf("invalidated snapshot error mismatch: have %v, want %v", err, errSnapshotDoesNotExist)
+	}
+	// The chain should be usable after a revert
+	if err := sim.SendTransaction(bgCtx, tx); err != nil {
+		t.Fatalf("could not resend tx: %v", err)
+	}
+	sim.Commit()
+	if receipt, _ := sim.TransactionReceipt(bgCtx, tx.Hash()); receipt == nil || receipt.BlockNumber.Uint64() != 1 {
+		t.Errorf("resent transaction not included in block 1")
+	}
+}
+
+// TestClient checks that the simulated backend can be used through ethclient,
+// including the txpool and debug namespaces.
+func TestClient(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	sim := simTestBackend(testAddr)
+	defer sim.Close()
+	bgCtx := context.Background()
+	client := ethclient.NewClient(sim.Client())
+
+	chainID, err := client.ChainID(bgCtx)
+	if err != nil {
+		t.Fatalf("failed to retrieve chain id: %v", err)
+	}
+	head, err := client.HeaderByNumber(bgCtx, nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve head: %v", err)
+	}
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+	tx, _ := types.SignTx(types.NewTransaction(0, common.Address{1}, big.NewInt(1000), params.TxGas, gasPrice, nil), types.NewEIP155Signer(chainID), testKey)
+	if err := client.SendTransaction(bgCtx, tx); err != nil {
+		t.Fatalf("failed to send transaction: %v", err)
+	}
+	var status map[string]hexutil.Uint
+	if err := sim.Client().CallContext(bgCtx, &status, "txpool_status"); err != nil {
+		t.Fatalf("failed to retrieve pool status: %v", err)
+	}
+	if status["pending"] != 1 || status["queued"] != 0 {
+		t.Errorf("pool status mismatch: have %v", status)
+	}
+	if nonce, _ := client.PendingNonceAt(bgCtx, testAddr); nonce != 1 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 1)
+	}
+	sim.Commit()
+
+	if number, _ := client.BlockNumber(bgCtx); number != 1 {
+		t.Errorf("block number mismatch: have %d, want %d", number, 1)
+	}
+	receipt, err := client.TransactionReceipt(bgCtx, tx.Hash())
+	if err != nil {
+		t.Fatalf("failed to retrieve receipt: %v", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		t.Errorf("transaction failed")
+	}
+	var trace struct {
+		Type string         `json:"type"`
+		From common.Address `json:"from"`
+		To   common.Address `json:"to"`
+	}
+	config := map[string]interface{}{"tracer": "callTracer"}
+	if err := sim.Client().CallContext(bgCtx, &trace, "debug_traceTransaction", tx.Hash(), config); err != nil {
+		t.Fatalf("failed to trace transaction: %v", err)
+	}
+	if trace.Type != "CALL" || trace.From != testAddr || trace.To != (common.Address{1}) {
+		t.Errorf("trace mismatch: have %+v", trace)
+	}
+}
+
+// TestForkedSimulatedBackend checks that a simulated backend can run on top of
+// the state of another chain, retrieved over RPC.
+func TestForkedSimulatedBackend(t *testing.T) {
+	testAddr := crypto.PubkeyToAddress(testKey.PublicKey)
+	remote := simTestBackend(testAddr)
+	defer remote.Close()
+	bgCtx := context.Background()
+
+	// Create some state on the remote chain to fork from
+	recipient := common.Address{1}
+	head, _ := remote.HeaderByNumber(bgCtx, nil)
+	gasPrice := new(big.Int).Add(head.BaseFee, big.NewInt(1))
+	tx, _ := types.SignTx(types.NewTransaction(0, recipient, big.NewInt(1000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testKey)
+	if err := remote.SendTransaction(bgCtx, tx); err != nil {
+		t.Fatalf("could not add tx: %v", err)
+	}
+	remote.Commit()
+	remoteBalance, _ := remote.BalanceAt(bgCtx, testAddr, nil)
+
+	// Fork the remote chain, overriding some accounts locally. The contract
+	// returns the current block number and the hash of the previous block.
+	var (
+		local    = common.Address{2}
+		contract = common.Address{3}
+		code     = common.FromHex("0x43600052600143034060205260406000f3")
+	)
+	sim, err := NewForkedSimulatedBackend(fork.NewRPCFetcher(ethclient.NewClient(remote.Client()), big.NewInt(1)), core.GenesisAlloc{
+		local:    {Balance: big.NewInt(1)},
+		contract: {Balance: big.NewInt(1), Code: code},
+	}, 10000000)
+	if err != nil {
+		t.Fatalf("failed to fork remote chain: %v", err)
+	}
+	defer sim.Close()
+
+	// The EVM must see the block numbers and hashes of the forked chain
+	checkBlock := func(number uint64) {
+		t.Helper()
+		out, err := sim.CallContract(bgCtx, ethereum.CallMsg{From: testAddr, To: &contract}, nil)
+		if err != nil {
+			t.Fatalf("call failed: %v", err)
+		}
+		parent, _ := remote.HeaderByNumber(bgCtx, new(big.Int).SetUint64(number-1))
+		if have := new(big.Int).SetBytes(out[:32]).Uint64(); have != number {
+			t.Errorf("block number mismatch: have %d, want %d", have, number)
+		}
+		if have := common.BytesToHash(out[32:]); have != parent.Hash() {
+			t.Errorf("block hash mismatch: have %x, want %x", have, parent.Hash())
+		}
+	}
+	checkBlock(1)
+
+	if balance, _ := sim.BalanceAt(bgCtx, recipient, nil); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("forked balance mismatch: have %v, want %v", balance, 1000)
+	}
+	if balance, _ := sim.BalanceAt(bgCtx, local, nil); balance.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("local balance mismatch: have %v, want %v", balance, 1)
+	}
+	if nonce, _ := sim.PendingNonceAt(bgCtx, testAddr); nonce != 1 {
+		t.Errorf("forked nonce mismatch: have %d, want %d", nonce, 1)
+	}
+	// Transact on top of the forked state, leaving the remote chain untouched
+	head, _ = sim.HeaderByNumber(bgCtx, nil)
+	gasPrice = new(big.Int).Add(head.BaseFee, big.NewInt(1))
+	tx, _ = types.SignTx(types.NewTransaction(1, recipient, big.NewInt(1000), params.TxGas, gasPrice, nil), types.HomesteadSigner{}, testKey)
+	if err := sim.SendTransaction(bgCtx, tx); err != nil {
+		t.Fatalf("could not add tx on fork: %v", err)
+	}
+	sim.Commit()
+
+	if balance, _ := sim.BalanceAt(bgCtx, recipient, nil); balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("forked balance mismatch after transfer: have %v, want %v", balance, 2000)
+	}
+	checkBlock(2)
+	if balance, _ := sim.BalanceAt(bgCtx, testAddr, nil); balance.Cmp(remoteBalance) >= 0 {
+		t.Errorf("sender balance not reduced on fork: have %v, remote %v", balance, remoteBalance)
+	}
+	if balance, _ := remote.BalanceAt(bgCtx, recipient, nil); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("remote balance modified: have %v, want %v", balance, 1000)
+	}
+}
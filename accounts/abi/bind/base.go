@@ -27,6 +27,7 @@ import (
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
@@ -402,10 +403,6 @@ func (c *BoundContract) transact(opts *TransactOpts, contract *common.Address, i
 // FilterLogs filters contract logs for past blocks, returning the necessary
 // channels to construct a strongly typed bound iterator on top of them.
 func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
-	// Don't crash on a lazy user
-	if opts == nil {
-		opts = new(FilterOpts)
-	}
 	// Append the event selector to the query parameters and construct the topic set
 	query = append([][]interface{}{{c.abi.Events[name].ID}}, query...)
 
@@ -413,6 +410,22 @@ func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]int
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.filterLogs(opts, topics)
+}
+
+// FilterAllLogs filters all the logs emitted by the contract for past blocks,
+// irrespective of their event type.
+func (c *BoundContract) FilterAllLogs(opts *FilterOpts) (chan types.Log, event.Subscription, error) {
+	return c.filterLogs(opts, nil)
+}
+
+// filterLogs retrieves the contract logs matching the given topic set for past
+// blocks and feeds them into a subscription.
+func (c *BoundContract) filterLogs(opts *FilterOpts, topics [][]common.Hash) (chan types.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(FilterOpts)
+	}
 	// Start the background filtering
 	logs := make(chan types.Log, 128)
 
@@ -451,10 +464,6 @@ func (c *BoundContract) FilterLogs(opts *FilterOpts, name string, query ...[]int
 // WatchLogs filters subscribes to contract logs for future blocks, returning a
 // subscription object that can be used to tear down the watcher.
 func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]interface{}) (chan types.Log, event.Subscription, error) {
-	// Don't crash on a lazy user
-	if opts == nil {
-		opts = new(WatchOpts)
-	}
 	// Append the event selector to the query parameters and construct the topic set
 	query = append([][]interface{}{{c.abi.Events[name].ID}}, query...)
 
@@ -462,6 +471,22 @@ func (c *BoundContract) WatchLogs(opts *WatchOpts, name string, query ...[]inter
 	if err != nil {
 		return nil, nil, err
 	}
+	return c.watchLogs(opts, topics)
+}
+
+// WatchAllLogs subscribes to all the logs emitted by the contract for future
+// blocks, irrespective of their event type.
+func (c *BoundContract) WatchAllLogs(opts *WatchOpts) (chan types.Log, event.Subscription, error) {
+	return c.watchLogs(opts, nil)
+}
+
+// watchLogs subscribes to the contract logs matching the given topic set for
+// future blocks.
+func (c *BoundContract) watchLogs(opts *WatchOpts, topics [][]common.Hash) (chan types.Log, event.Subscription, error) {
+	// Don't crash on a lazy user
+	if opts == nil {
+		opts = new(WatchOpts)
+	}
 	// Start the background filtering
 	logs := make(chan types.Log, 128)
 
@@ -517,6 +542,29 @@ func (c *BoundContract) UnpackLogIntoMap(out map[string]interface{}, event strin
 	return abi.ParseTopicsIntoMap(out, indexed, log.Topics[1:])
 }
 
+// RevertData extracts the raw revert data from an error returned by a contract
+// call or gas estimation, if the backend attached any. Both hex encoded string
+// and binary error data is supported.
+func RevertData(err error) ([]byte, bool) {
+	var dataErr interface {
+		ErrorData() interface{}
+	}
+	if !errors.As(err, &dataErr) {
+		return nil, false
+	}
+	switch data := dataErr.ErrorData().(type) {
+	case string:
+		blob, err := hexutil.Decode(data)
+		if err != nil {
+			return nil, false
+		}
+		return blob, true
+	case []byte:
+		return data, true
+	}
+	return nil, false
+}
+
 // ensureContext is a helper method to ensure a context is not nil, even if the
 // user specified it as such.
 func ensureContext(ctx context.Context) context.Context {
@@ -74,11 +74,12 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 
 		// Extract the call and transact methods; events, struct definitions; and sort them alphabetically
 		var (
-			calls     = make(map[string]*tmplMethod)
-			transacts = make(map[string]*tmplMethod)
-			events    = make(map[string]*tmplEvent)
-			fallback  *tmplMethod
-			receive   *tmplMethod
+			calls      = make(map[string]*tmplMethod)
+			transacts  = make(map[string]*tmplMethod)
+			events     = make(map[string]*tmplEvent)
+			customErrs = make(map[string]*tmplError)
+			fallback   *tmplMethod
+			receive    *tmplMethod
 
 			// identifiers are used to detect duplicated identifiers of functions
 			// and events. For all calls, transacts and events, abigen will generate
@@ -158,6 +159,45 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			// Append the event to the accumulator list
 			events[original.Name] = &tmplEvent{Original: original, Normalized: normalized}
 		}
+		for _, original := range evmABI.Errors {
+			// Normalize the error for capital cases and non-anonymous inputs. Errors
+			// are bound to types in the same namespace as events, so ensure there's
+			// no collision between the two.
+			normalized := original
+
+			normalizedName := methodNormalizer[lang](alias(aliases, original.Name))
+			if eventIdentifiers[normalizedName] {
+				return "", fmt.Errorf("duplicated identifier \"%s\"(normalized \"%s\"), use --alias for renaming", original.Name, normalizedName)
+			}
+			eventIdentifiers[normalizedName] = true
+			normalized.Name = normalizedName
+
+			normalized.Inputs = make([]abi.Argument, len(original.Inputs))
+			copy(normalized.Inputs, original.Inputs)
+			for j, input := range normalized.Inputs {
+				if input.Name == "" {
+					normalized.Inputs[j].Name = fmt.Sprintf("arg%d", j)
+				}
+				if hasStruct(input.Type) {
+					bindStructType[lang](input.Type, structs)
+				}
+			}
+			// Append the error to the accumulator list
+			customErrs[original.Name] = &tmplError{
+				Original:   original,
+				Normalized: normalized,
+				Selector:   fmt.Sprintf("%x", original.ID[:4]),
+			}
+		}
+		// The Go bindings of contracts with events contain a few helpers aggregating
+		// all of them, ensure they don't clash with the per-event identifiers.
+		if lang == LangGo && len(events) > 0 {
+			for _, name := range []string{"All", "Log", "Event"} {
+				if eventIdentifiers[name] {
+					return "", fmt.Errorf("identifier \"%s\" clashes with the generated event helpers, use --alias for renaming", name)
+				}
+			}
+		}
 		// Add two special fallback functions if they exist
 		if evmABI.HasFallback() {
 			fallback = &tmplMethod{Original: evmABI.Fallback}
@@ -180,6 +220,7 @@ func Bind(types []string, abis []string, bytecodes []string, fsigs []map[string]
 			Fallback:    fallback,
 			Receive:     receive,
 			Events:      events,
+			Errors:      customErrs,
 			Libraries:   make(map[string]string),
 		}
 		// Function 4-byte signatures are stored in the same sequence
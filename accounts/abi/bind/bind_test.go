@@ -915,6 +915,7 @@ var bindTests = []struct {
 			"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
 			"github.com/ethereum/go-ethereum/common"
 			"github.com/ethereum/go-ethereum/core"
+			"github.com/ethereum/go-ethereum/core/types"
 			"github.com/ethereum/go-ethereum/crypto"
 		`,
 		`
@@ -1078,6 +1079,79 @@ var bindTests = []struct {
 				t.Fatalf("unsubscribed simple event arrived: %v", event)
 			case <-time.After(250 * time.Millisecond):
 			}
+			// Test filtering for all events and dispatching them on their types
+			ait, err := eventer.FilterAll(nil)
+			if err != nil {
+				t.Fatalf("failed to filter for all events: %v", err)
+			}
+			defer ait.Close()
+
+			counts := make(map[string]int)
+			for ait.Next() {
+				switch event := ait.Event.(type) {
+				case *EventerSimpleEvent:
+					counts["simple"]++
+				case *EventerNodataEvent:
+					if event.Number.Uint64() != 314 {
+						t.Errorf("nodata log content mismatch: have %v, want 314", event.Number)
+					}
+					counts["nodata"]++
+				case *EventerDynamicEvent:
+					counts["dynamic"]++
+				case *EventerFixedBytesEvent:
+					if event.NonIndexedBytes != fblob {
+						t.Errorf("fixed bytes log content mismatch: have %v, want '%x'", event, fblob)
+					}
+					counts["fixed"]++
+				default:
+					t.Errorf("unexpected event type %T", event)
+				}
+			}
+			if err = ait.Error(); err != nil {
+				t.Fatalf("all event iteration failed: %v", err)
+			}
+			if counts["simple"] != 8 || counts["nodata"] != 1 || counts["dynamic"] != 1 || counts["fixed"] != 1 {
+				t.Errorf("event counts mismatch: have %v, want 8 simple, 1 nodata, 1 dynamic, 1 fixed", counts)
+			}
+			// Test that unknown logs are rejected by the generic parser
+			if _, err := eventer.ParseLog(types.Log{Topics: []common.Hash{{0xde, 0xad}}}); err != bind.ErrUnknownEvent {
+				t.Errorf("unknown log parse error mismatch: have %v, want %v", err, bind.ErrUnknownEvent)
+			}
+			// Test subscribing to all events and raising a few afterwards
+			all := make(chan EventerEvent, 16)
+			sub, err = eventer.WatchAll(nil, all)
+			if err != nil {
+				t.Fatalf("failed to subscribe to all events: %v", err)
+			}
+			defer sub.Unsubscribe()
+
+			if _, err := eventer.RaiseNodataEvent(auth, big.NewInt(42), 1, 2); err != nil {
+				t.Fatalf("failed to raise subscribed nodata event: %v", err)
+			}
+			if _, err := eventer.RaiseFixedBytesEvent(auth, fblob); err != nil {
+				t.Fatalf("failed to raise subscribed fixed bytes event: %v", err)
+			}
+			sim.Commit()
+
+			for i := 0; i < 2; i++ {
+				select {
+				case event := <-all:
+					switch event := event.(type) {
+					case *EventerNodataEvent:
+						if i != 0 || event.Number.Uint64() != 42 {
+							t.Errorf("event %d: nodata log mismatch: have %v", i, event)
+						}
+					case *EventerFixedBytesEvent:
+						if i != 1 || event.IndexedBytes != fblob {
+							t.Errorf("event %d: fixed bytes log mismatch: have %v", i, event)
+						}
+					default:
+						t.Errorf("event %d: unexpected event type %T", i, event)
+					}
+				case <-time.After(250 * time.Millisecond):
+					t.Fatalf("subscribed event %d didn't arrive", i)
+				}
+			}
 		`,
 		nil,
 		nil,
@@ -1875,6 +1949,7 @@ var bindTests = []struct {
 		[]string{"0x6080604052348015600f57600080fd5b5060998061001e6000396000f3fe6080604052348015600f57600080fd5b506004361060285760003560e01c8063726c638214602d575b600080fd5b60336035565b005b60405163024876cd60e61b815260016004820152600260248201526003604482015260640160405180910390fdfea264697066735822122093f786a1bc60216540cd999fbb4a6109e0fef20abcff6e9107fb2817ca968f3c64736f6c63430008070033"},
 		[]string{`[{"inputs":[{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError","type":"error"},{"inputs":[{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError1","type":"error"},{"inputs":[{"internalType":"uint256","name":"","type":"uint256"},{"internalType":"uint256","name":"","type":"uint256"}],"name":"MyError2","type":"error"},{"inputs":[{"internalType":"uint256","name":"a","type":"uint256"},{"internalType":"uint256","name":"b","type":"uint256"},{"internalType":"uint256","name":"c","type":"uint256"}],"name":"MyError3","type":"error"},{"inputs":[],"name":"Error","outputs":[],"stateMutability":"pure","type":"function"}]`},
 		`
+			"encoding/hex"
 			"math/big"
 	
 			"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -1900,11 +1975,101 @@ var bindTests = []struct {
 			if err != nil {
 				t.Error(err)
 			}
-			if err := contract.Error(new(bind.CallOpts)); err == nil {
+			err = contract.Error(new(bind.CallOpts))
+			if err == nil {
 				t.Fatalf("expected contract to throw error")
 			}
-			// TODO (MariusVanDerWijden unpack error using abigen
-			// once that is implemented
+			typed, ok := err.(*NewErrorsMyError3)
+			if !ok {
+				t.Fatalf("error type mismatch: have %T, want *NewErrorsMyError3", err)
+			}
+			if typed.A.Int64() != 1 || typed.B.Int64() != 2 || typed.C.Int64() != 3 {
+				t.Fatalf("error fields mismatch: have %+v", typed)
+			}
+			if have, want := typed.Error(), "MyError3(a: 1, b: 2, c: 3)"; have != want {
+				t.Fatalf("error message mismatch: have %q, want %q", have, want)
+			}
+			// Unpack errors from raw revert data, both known and unknown
+			data, _ := hex.DecodeString("919d29f90000000000000000000000000000000000000000000000000000000000000007")
+			if typed, ok := contract.UnpackError(data).(*NewErrorsMyError1); !ok || typed.Arg0.Int64() != 7 {
+				t.Fatalf("unpacked error mismatch: have %v", contract.UnpackError(data))
+			}
+			if err := contract.UnpackError([]byte{0xde, 0xad, 0xbe, 0xef}); err != nil {
+				t.Fatalf("unknown error unpacked: %v", err)
+			}
+	   `,
+		nil,
+		nil,
+		nil,
+		nil,
+	},
+	// Test that custom errors are returned typed from both calls and transactions
+	{
+		`Vault`,
+		`
+		pragma solidity >0.8.4;
+
+		contract Vault {
+			error InsufficientBalance(uint256 available, uint256 required);
+			error Unauthorized();
+
+			function withdraw(uint256 amount) public {
+				if (amount > address(this).balance) {
+					revert InsufficientBalance(address(this).balance, amount);
+				}
+			}
+			function owner() public view returns (address) {
+				revert Unauthorized();
+			}
+		}
+	   `,
+		[]string{"0x6057600c60003960576000f360003560e01c80632e1a7d4d146100205780638da5cb5b1461004657600080fd5b476004351161002b57005b63cf47918160e01b6000524760045260043560245260446000fd5b6382b4290060e01b60005260046000fd"},
+		[]string{`[{"inputs":[{"internalType":"uint256","name":"available","type":"uint256"},{"internalType":"uint256","name":"required","type":"uint256"}],"name":"InsufficientBalance","type":"error"},{"inputs":[],"name":"Unauthorized","type":"error"},{"inputs":[],"name":"owner","outputs":[{"internalType":"address","name":"","type":"address"}],"stateMutability":"view","type":"function"},{"inputs":[{"internalType":"uint256","name":"amount","type":"uint256"}],"name":"withdraw","outputs":[],"stateMutability":"nonpayable","type":"function"}]`},
+		`
+			"math/big"
+
+			"github.com/ethereum/go-ethereum/accounts/abi/bind"
+			"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+			"github.com/ethereum/go-ethereum/core"
+			"github.com/ethereum/go-ethereum/crypto"
+			"github.com/ethereum/go-ethereum/eth/ethconfig"
+	   `,
+		`
+			var (
+				key, _  = crypto.GenerateKey()
+				user, _ = bind.NewKeyedTransactorWithChainID(key, big.NewInt(1337))
+				sim     = backends.NewSimulatedBackend(core.GenesisAlloc{user.From: {Balance: big.NewInt(1000000000000000000)}}, ethconfig.Defaults.Miner.GasCeil)
+			)
+			defer sim.Close()
+
+			_, _, vault, err := DeployVault(user, sim)
+			if err != nil {
+				t.Fatalf("Failed to deploy contract: %v", err)
+			}
+			sim.Commit()
+
+			// Check that calls return the typed error
+			if _, err := vault.Owner(nil); err == nil {
+				t.Fatalf("expected call to revert")
+			} else if _, ok := err.(*VaultUnauthorized); !ok {
+				t.Fatalf("call error type mismatch: have %T (%v), want *VaultUnauthorized", err, err)
+			}
+			// Check that transactions failing gas estimation return the typed error
+			_, err = vault.Withdraw(user, big.NewInt(100))
+			if err == nil {
+				t.Fatalf("expected transaction to revert")
+			}
+			typed, ok := err.(*VaultInsufficientBalance)
+			if !ok {
+				t.Fatalf("transact error type mismatch: have %T (%v), want *VaultInsufficientBalance", err, err)
+			}
+			if typed.Available.Sign() != 0 || typed.Required.Int64() != 100 {
+				t.Fatalf("error fields mismatch: have %+v", typed)
+			}
+			// Successful transactions must not be affected
+			if _, err := vault.Withdraw(user, big.NewInt(0)); err != nil {
+				t.Fatalf("Failed to withdraw: %v", err)
+			}
 	   `,
 		nil,
 		nil,
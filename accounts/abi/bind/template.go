@@ -38,6 +38,7 @@ type tmplContract struct {
 	Fallback    *tmplMethod            // Additional special fallback function
 	Receive     *tmplMethod            // Additional special receive function
 	Events      map[string]*tmplEvent  // Contract events accessors
+	Errors      map[string]*tmplError  // Contract custom errors
 	Libraries   map[string]string      // Same as tmplData, but filtered to only keep what the contract needs
 	Library     bool                   // Indicator whether the contract is a library
 }
@@ -57,6 +58,14 @@ type tmplEvent struct {
 	Normalized abi.Event // Normalized version of the parsed fields
 }
 
+// tmplError is a wrapper around an abi.Error that contains a few preprocessed
+// and cached data fields.
+type tmplError struct {
+	Original   abi.Error // Original error as parsed by the abi package
+	Normalized abi.Error // Normalized version of the parsed fields
+	Selector   string    // Hex encoded 4-byte selector of the error
+}
+
 // tmplField is a wrapper around a struct field with binding language
 // struct type definition and relative filed name.
 type tmplField struct {
@@ -91,6 +100,7 @@ import (
 	"math/big"
 	"strings"
 	"errors"
+	"fmt"
 
 	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
@@ -103,6 +113,7 @@ import (
 // Reference imports to suppress errors if they are not otherwise used.
 var (
 	_ = errors.New
+	_ = fmt.Sprintf
 	_ = big.NewInt
 	_ = strings.NewReader
 	_ = ethereum.NotFound
@@ -165,7 +176,7 @@ var (
 		  {{end}}
 		  address, tx, contract, err := bind.DeployContract(auth, *parsed, common.FromHex({{.Type}}Bin), backend {{range .Constructor.Inputs}}, {{.Name}}{{end}})
 		  if err != nil {
-		    return common.Address{}, nil, nil, err
+		    return common.Address{}, nil, nil, {{if $contract.Errors}}wrap{{.Type}}Error(err){{else}}err{{end}}
 		  }
 		  return address, tx, &{{.Type}}{ {{.Type}}Caller: {{.Type}}Caller{contract: contract}, {{.Type}}Transactor: {{.Type}}Transactor{contract: contract}, {{.Type}}Filterer: {{.Type}}Filterer{contract: contract} }, nil
 		}
@@ -320,6 +331,7 @@ var (
 		func (_{{$contract.Type}} *{{$contract.Type}}Caller) {{.Normalized.Name}}(opts *bind.CallOpts {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) ({{if .Structured}}struct{ {{range .Normalized.Outputs}}{{.Name}} {{bindtype .Type $structs}};{{end}} },{{else}}{{range .Normalized.Outputs}}{{bindtype .Type $structs}},{{end}}{{end}} error) {
 			var out []interface{}
 			err := _{{$contract.Type}}.contract.Call(opts, &out, "{{.Original.Name}}" {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+			{{if $contract.Errors}}err = wrap{{$contract.Type}}Error(err){{end}}
 			{{if .Structured}}
 			outstruct := new(struct{ {{range .Normalized.Outputs}} {{.Name}} {{bindtype .Type $structs}}; {{end}} })
 			if err != nil {
@@ -360,7 +372,12 @@ var (
 		//
 		// Solidity: {{.Original.String}}
 		func (_{{$contract.Type}} *{{$contract.Type}}Transactor) {{.Normalized.Name}}(opts *bind.TransactOpts {{range .Normalized.Inputs}}, {{.Name}} {{bindtype .Type $structs}} {{end}}) (*types.Transaction, error) {
+			{{if $contract.Errors -}}
+			tx, err := _{{$contract.Type}}.contract.Transact(opts, "{{.Original.Name}}" {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+			return tx, wrap{{$contract.Type}}Error(err)
+			{{- else -}}
 			return _{{$contract.Type}}.contract.Transact(opts, "{{.Original.Name}}" {{range .Normalized.Inputs}}, {{.Name}}{{end}})
+			{{- end}}
 		}
 
 		// {{.Normalized.Name}} is a paid mutator transaction binding the contract method 0x{{printf "%x" .Original.ID}}.
@@ -567,6 +584,206 @@ var (
 		}
 
  	{{end}}
+
+	{{if .Events}}
+		// {{.Type}}Event is implemented by all the typed events raised by the {{.Type}} contract,
+		// allowing them to be handled uniformly or dispatched via a type switch.
+		type {{.Type}}Event interface {
+			is{{.Type}}Event()
+		}
+		{{range .Events}}
+		func (*{{$contract.Type}}{{.Normalized.Name}}) is{{$contract.Type}}Event() {}
+		{{end}}
+
+		// {{.Type}}EventIterator is returned from FilterAll and is used to iterate over the raw logs and unpacked data for all events raised by the {{.Type}} contract.
+		type {{.Type}}EventIterator struct {
+			Event {{.Type}}Event // Event containing the contract specifics and raw log
+
+			filterer *{{.Type}}Filterer // Typed contract filterer to use for unpacking event data
+
+			logs chan types.Log        // Log channel receiving the found contract events
+			sub  ethereum.Subscription // Subscription for errors, completion and termination
+			done bool                  // Whether the subscription completed delivering logs
+			fail error                 // Occurred error to stop iteration
+		}
+		// Next advances the iterator to the subsequent event, returning whether there
+		// are any more events found. Logs not matching any known event are skipped. In
+		// case of a retrieval or parsing error, false is returned and Error() can be
+		// queried for the exact failure.
+		func (it *{{.Type}}EventIterator) Next() bool {
+			for {
+				// If the iterator failed, stop iterating
+				if (it.fail != nil) {
+					return false
+				}
+				var log types.Log
+				if (it.done) {
+					// If the iterator completed, deliver directly whatever's available
+					select {
+					case log = <-it.logs:
+					default:
+						return false
+					}
+				} else {
+					// Iterator still in progress, wait for either a data or an error event
+					select {
+					case log = <-it.logs:
+					case err := <-it.sub.Err():
+						it.done = true
+						it.fail = err
+						continue
+					}
+				}
+				event, err := it.filterer.ParseLog(log)
+				if err == bind.ErrUnknownEvent {
+					continue
+				}
+				if err != nil {
+					it.fail = err
+					return false
+				}
+				it.Event = event
+				return true
+			}
+		}
+		// Error returns any retrieval or parsing error occurred during filtering.
+		func (it *{{.Type}}EventIterator) Error() error {
+			return it.fail
+		}
+		// Close terminates the iteration process, releasing any pending underlying
+		// resources.
+		func (it *{{.Type}}EventIterator) Close() error {
+			it.sub.Unsubscribe()
+			return nil
+		}
+
+		// FilterAll is a free log retrieval operation returning all the events raised by the {{.Type}} contract.
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) FilterAll(opts *bind.FilterOpts) (*{{$contract.Type}}EventIterator, error) {
+			logs, sub, err := _{{$contract.Type}}.contract.FilterAllLogs(opts)
+			if err != nil {
+				return nil, err
+			}
+			return &{{$contract.Type}}EventIterator{filterer: _{{$contract.Type}}, logs: logs, sub: sub}, nil
+		}
+
+		// WatchAll is a free log subscription operation delivering all the events raised by the {{.Type}} contract.
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) WatchAll(opts *bind.WatchOpts, sink chan<- {{$contract.Type}}Event) (event.Subscription, error) {
+			logs, sub, err := _{{$contract.Type}}.contract.WatchAllLogs(opts)
+			if err != nil {
+				return nil, err
+			}
+			return event.NewSubscription(func(quit <-chan struct{}) error {
+				defer sub.Unsubscribe()
+				for {
+					select {
+					case log := <-logs:
+						// New log arrived, parse the event and forward to the user
+						event, err := _{{$contract.Type}}.ParseLog(log)
+						if err == bind.ErrUnknownEvent {
+							continue
+						}
+						if err != nil {
+							return err
+						}
+						select {
+						case sink <- event:
+						case err := <-sub.Err():
+							return err
+						case <-quit:
+							return nil
+						}
+					case err := <-sub.Err():
+						return err
+					case <-quit:
+						return nil
+					}
+				}
+			}), nil
+		}
+
+		// ParseLog is a log parse operation decoding a log of any event raised by the {{.Type}}
+		// contract, dispatching on the event signature. If the log doesn't match any of the
+		// known events, bind.ErrUnknownEvent is returned.
+		func (_{{$contract.Type}} *{{$contract.Type}}Filterer) ParseLog(log types.Log) ({{$contract.Type}}Event, error) {
+			if len(log.Topics) == 0 {
+				return nil, bind.ErrUnknownEvent
+			}
+			switch log.Topics[0].Hex() {
+			{{range .Events}}
+			case "0x{{printf "%x" .Original.ID}}":
+				event, err := _{{$contract.Type}}.Parse{{.Normalized.Name}}(log)
+				if err != nil {
+					return nil, err
+				}
+				return event, nil
+			{{end}}
+			}
+			return nil, bind.ErrUnknownEvent
+		}
+	{{end}}
+
+	{{range .Errors}}
+		// {{$contract.Type}}{{.Normalized.Name}} represents a {{.Normalized.Name}} custom error raised by the {{$contract.Type}} contract.
+		type {{$contract.Type}}{{.Normalized.Name}} struct { {{range .Normalized.Inputs}}
+			{{capitalise .Name}} {{bindtype .Type $structs}}; {{end}}
+		}
+
+		// Error implements the error interface, binding the contract error 0x{{.Selector}}.
+		//
+		// Solidity: {{.Original.String}}
+		func (e *{{$contract.Type}}{{.Normalized.Name}}) Error() string {
+			return fmt.Sprintf("{{.Original.Name}}({{range $i, $_ := .Normalized.Inputs}}{{if $i}}, {{end}}{{.Name}}: %v{{end}})"{{range .Normalized.Inputs}}, e.{{capitalise .Name}}{{end}})
+		}
+	{{end}}
+
+	{{if .Errors}}
+		// UnpackError decodes the revert data of a failed call or transaction into the
+		// matching typed custom error of the {{.Type}} contract. Nil is returned if the
+		// data doesn't correspond to any of the errors declared in the contract ABI.
+		func (_{{$contract.Type}} *{{$contract.Type}}) UnpackError(data []byte) error {
+			return unpack{{$contract.Type}}Error(data)
+		}
+
+		// unpack{{.Type}}Error decodes revert data into one of the custom errors declared
+		// by the {{.Type}} contract, returning nil if none matches.
+		func unpack{{.Type}}Error(data []byte) error {
+			if len(data) < 4 {
+				return nil
+			}
+			parsed, err := {{.Type}}MetaData.GetAbi()
+			if err != nil {
+				return nil
+			}
+			switch common.Bytes2Hex(data[:4]) {
+			{{range .Errors}}
+			case "{{.Selector}}":
+				abiErr := parsed.Errors["{{.Original.Name}}"]
+				{{if .Normalized.Inputs}}values{{else}}_{{end}}, err := abiErr.Unpack(data)
+				if err != nil {
+					return nil
+				}
+				unpacked := new({{$contract.Type}}{{.Normalized.Name}})
+				{{if .Normalized.Inputs}}out := values.([]interface{}){{end}}
+				{{range $i, $_ := .Normalized.Inputs}}
+				unpacked.{{capitalise .Name}} = *abi.ConvertType(out[{{$i}}], new({{bindtype .Type $structs}})).(*{{bindtype .Type $structs}}){{end}}
+
+				return unpacked
+			{{end}}
+			}
+			return nil
+		}
+
+		// wrap{{.Type}}Error replaces an error carrying revert data with the typed custom
+		// error it encodes, leaving any other error untouched.
+		func wrap{{.Type}}Error(err error) error {
+			if data, ok := bind.RevertData(err); ok {
+				if typed := unpack{{.Type}}Error(data); typed != nil {
+					return typed
+				}
+			}
+			return err
+		}
+	{{end}}
 {{end}}
 `
 
@@ -17,8 +17,13 @@
 package external
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"math/big"
+	"net/http"
 	"sync"
 
 	"github.com/ethereum/go-ethereum"
@@ -50,6 +55,48 @@ func NewExternalBackend(endpoint string) (*ExternalBackend, error) {
 	}, nil
 }
 
+// NewExternalBackendWithTLS creates a backend for an external signer serving its
+// API over HTTPS, authenticating with the given TLS configuration.
+func NewExternalBackendWithTLS(endpoint string, config *tls.Config) (*ExternalBackend, error) {
+	signer, err := NewExternalSignerWithTLS(endpoint, config)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalBackend{
+		signers: []accounts.Wallet{signer},
+	}, nil
+}
+
+// NewTLSConfig creates the TLS configuration for connecting to an external
+// signer. The client certificate and key are optional, and the signer's
+// certificate is verified against the given CA file if set, or the system pool
+// otherwise.
+func NewTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("client certificate and key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load CA: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
 func (eb *ExternalBackend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
 	return event.NewSubscription(func(quit <-chan struct{}) error {
 		<-quit
@@ -73,6 +120,23 @@ func NewExternalSigner(endpoint string) (*ExternalSigner, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newExternalSigner(client, endpoint)
+}
+
+// NewExternalSignerWithTLS connects to an external signer serving its API over
+// HTTPS, authenticating with the given TLS configuration. The configuration may
+// contain a client certificate for signers requiring mutual authentication.
+func NewExternalSignerWithTLS(endpoint string, config *tls.Config) (*ExternalSigner, error) {
+	client, err := rpc.DialHTTPWithClient(endpoint, &http.Client{
+		Transport: &http.Transport{TLSClientConfig: config},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newExternalSigner(client, endpoint)
+}
+
+func newExternalSigner(client *rpc.Client, endpoint string) (*ExternalSigner, error) {
 	extsigner := &ExternalSigner{
 		client:   client,
 		endpoint: endpoint,
@@ -0,0 +1,186 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package external
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// testCert is a certificate issued for testing, along with its PEM encoding.
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// newTestCert creates a certificate for the given subject, self-signed if no
+// issuer is given.
+func newTestCert(t *testing.T, subject string, issuer *testCert, ca bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: subject},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	parent, signer := template, key
+	if issuer != nil {
+		parent, signer = issuer.cert, issuer.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// testSigner is a minimal external signer API, recording the subject of the
+// client certificates of its callers.
+type testSigner struct {
+	subjects chan string
+}
+
+func (s *testSigner) Version(ctx context.Context) (string, error) {
+	subject, _ := ctx.Value("TLS-Subject").(string)
+	s.subjects <- subject
+	return "6.0.0", nil
+}
+
+// Tests that external signers can be reached over mutually authenticated TLS,
+// and that the client certificate subjects are exposed to the signer.
+func TestExternalSignerTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "external-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		ca     = newTestCert(t, "test-ca", nil, true)
+		server = newTestCert(t, "clef", ca, false)
+		client = newTestCert(t, "geth", ca, false)
+		other  = newTestCert(t, "intruder", nil, false)
+	)
+	write := func(name string, blob []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, blob, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	caFile := write("ca.pem", ca.certPEM)
+	clientCert, clientKey := write("client.pem", client.certPEM), write("client.key", client.keyPEM)
+	otherCert, otherKey := write("other.pem", other.certPEM), write("other.key", other.keyPEM)
+
+	// Start a signer requiring client certificates issued by the CA
+	signer := &testSigner{subjects: make(chan string, 1)}
+	srv := rpc.NewServer()
+	if err := srv.RegisterName("account", signer); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Stop()
+
+	serverCert, err := tls.X509KeyPair(server.certPEM, server.keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+
+	httpSrv, addr, err := node.StartHTTPSEndpoint("127.0.0.1:0", rpc.DefaultHTTPTimeouts, srv, &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatalf("failed to start endpoint: %v", err)
+	}
+	defer httpSrv.Close()
+	endpoint := fmt.Sprintf("https://%v/", addr)
+
+	// Connect with a trusted client certificate
+	config, err := NewTLSConfig(clientCert, clientKey, caFile)
+	if err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if _, err := NewExternalSignerWithTLS(endpoint, config); err != nil {
+		t.Fatalf("failed to connect to signer: %v", err)
+	}
+	if subject := <-signer.subjects; subject != "CN=geth" {
+		t.Errorf("client subject mismatch: have %q, want %q", subject, "CN=geth")
+	}
+	// Connect without and with an untrusted client certificate
+	if config, err = NewTLSConfig("", "", caFile); err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if _, err := NewExternalSignerWithTLS(endpoint, config); err == nil {
+		t.Errorf("connected without client certificate")
+	}
+	if config, err = NewTLSConfig(otherCert, otherKey, caFile); err != nil {
+		t.Fatalf("failed to create TLS config: %v", err)
+	}
+	if _, err := NewExternalSignerWithTLS(endpoint, config); err == nil {
+		t.Errorf("connected with untrusted client certificate")
+	}
+	// Invalid configurations are rejected
+	if _, err := NewTLSConfig(clientCert, "", ""); err == nil {
+		t.Errorf("client certificate accepted without key")
+	}
+	if _, err := NewTLSConfig("", "", clientKey); err == nil {
+		t.Errorf("CA file without certificates accepted")
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package hdwallet implements hierarchical deterministic software wallets, whose
+// accounts are derived from a BIP-39 mnemonic as specified by BIP-32 and BIP-44.
+//
+// The seed of each wallet is stored encrypted in a file of the keystore directory,
+// alongside the keys of the keystore, which ignores it. Unlike the keys of the
+// keystore, a wallet needs to be opened with its passphrase before accounts can
+// be derived from it.
+package hdwallet
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/google/uuid"
+	"github.com/tyler-smith/go-bip39"
+)
+
+// Scheme is the protocol scheme prefixing account and wallet URLs.
+const Scheme = "hd"
+
+// walletRefreshCycle is the time between scans of the keystore directory for
+// added or removed seed files.
+const walletRefreshCycle = 3 * time.Second
+
+// mnemonicEntropy is the number of entropy bits of generated mnemonics, yielding
+// 24 words.
+const mnemonicEntropy = 256
+
+// ErrInvalidMnemonic is returned if a mnemonic to import has an unknown word or
+// a checksum mismatch.
+var ErrInvalidMnemonic = errors.New("invalid mnemonic")
+
+// BackendType is the reflect type of an HD wallet backend.
+var BackendType = reflect.TypeOf(&Backend{})
+
+// Backend is an accounts.Backend managing the HD wallets of a keystore directory.
+type Backend struct {
+	keydir  string // Directory containing the seed files
+	scryptN int    // Scrypt parameters of newly created seed files
+	scryptP int
+
+	wallets []*Wallet            // Wallets of the seed files, sorted by URL
+	skipped map[string]time.Time // Modification times of files not holding a seed
+
+	updateFeed  event.Feed              // Event feed to notify wallet additions/removals
+	updateScope event.SubscriptionScope // Subscription scope tracking current live listeners
+	updating    bool                    // Whether the event notification loop is running
+
+	lock sync.Mutex
+}
+
+// NewBackend creates a backend for the HD wallets stored in the given keystore
+// directory. The scrypt parameters are used to encrypt the seeds of new wallets.
+func NewBackend(keydir string, scryptN, scryptP int) *Backend {
+	keydir, _ = filepath.Abs(keydir)
+	b := &Backend{
+		keydir:  keydir,
+		scryptN: scryptN,
+		scryptP: scryptP,
+		skipped: make(map[string]time.Time),
+	}
+	b.refreshWallets()
+	return b
+}
+
+// Wallets implements accounts.Backend, returning the HD wallets stored in the
+// keystore directory.
+func (b *Backend) Wallets() []accounts.Wallet {
+	b.refreshWallets()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	cpy := make([]accounts.Wallet, len(b.wallets))
+	for i, wallet := range b.wallets {
+		cpy[i] = wallet
+	}
+	return cpy
+}
+
+// refreshWallets scans the keystore directory for seed files, and fires events
+// for wallets added or removed since the last scan.
+func (b *Backend) refreshWallets() {
+	files, err := ioutil.ReadDir(b.keydir)
+	if err != nil && !os.IsNotExist(err) {
+		log.Debug("Failed to scan keystore for seed files", "dir", b.keydir, "err", err)
+		return
+	}
+	b.lock.Lock()
+
+	known := make(map[string]*Wallet, len(b.wallets))
+	for _, wallet := range b.wallets {
+		known[wallet.path] = wallet
+	}
+	var (
+		wallets = make([]*Wallet, 0, len(b.wallets))
+		skipped = make(map[string]time.Time)
+		events  []accounts.WalletEvent
+	)
+	for _, fi := range files {
+		// Skip directories, editor backups and hidden files, like the keystore
+		name := fi.Name()
+		if fi.IsDir() || strings.HasSuffix(name, "~") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		path := filepath.Join(b.keydir, name)
+		if wallet, ok := known[path]; ok {
+			wallets = append(wallets, wallet)
+			delete(known, path)
+			continue
+		}
+		if modtime, ok := b.skipped[path]; ok && modtime.Equal(fi.ModTime()) {
+			skipped[path] = modtime
+			continue
+		}
+		file, err := readSeedFile(path)
+		if err != nil {
+			log.Warn("Failed to load seed file", "path", path, "err", err)
+		}
+		if file == nil {
+			skipped[path] = fi.ModTime()
+			continue
+		}
+		wallet := newWallet(b, path, file)
+		wallets = append(wallets, wallet)
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletArrived})
+	}
+	for _, wallet := range known {
+		events = append(events, accounts.WalletEvent{Wallet: wallet, Kind: accounts.WalletDropped})
+	}
+	b.wallets, b.skipped = wallets, skipped
+	b.lock.Unlock()
+
+	// Fire all wallet events and return
+	for _, event := range events {
+		b.updateFeed.Send(event)
+	}
+}
+
+// Subscribe implements accounts.Backend, creating an async subscription to
+// receive notifications on the addition or removal of HD wallets.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	// We need the mutex to reliably start/stop the update loop
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	// Subscribe the caller and track the subscriber count
+	sub := b.updateScope.Track(b.updateFeed.Subscribe(sink))
+
+	// Subscribers require an active notification loop, start it
+	if !b.updating {
+		b.updating = true
+		go b.updater()
+	}
+	return sub
+}
+
+// updater periodically rescans the keystore directory for seed files, as long
+// as there are subscribers to notify.
+func (b *Backend) updater() {
+	for {
+		time.Sleep(walletRefreshCycle)
+		b.refreshWallets()
+
+		// If all our subscribers left, stop the updater
+		b.lock.Lock()
+		if b.updateScope.Count() == 0 {
+			b.updating = false
+			b.lock.Unlock()
+			return
+		}
+		b.lock.Unlock()
+	}
+}
+
+// NewWallet creates an HD wallet from a newly generated 24 word mnemonic, whose
+// seed is encrypted with the passphrase. The mnemonic is returned to be backed up
+// by the user, it is not stored anywhere.
+func (b *Backend) NewWallet(passphrase string) (*Wallet, string, error) {
+	entropy, err := bip39.NewEntropy(mnemonicEntropy)
+	if err != nil {
+		return nil, "", err
+	}
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, "", err
+	}
+	wallet, err := b.store(bip39.NewSeed(mnemonic, ""), passphrase)
+	if err != nil {
+		return nil, "", err
+	}
+	return wallet, mnemonic, nil
+}
+
+// Import creates an HD wallet from an existing mnemonic, whose seed is encrypted
+// with the passphrase.
+func (b *Backend) Import(mnemonic, passphrase string) (*Wallet, error) {
+	return b.ImportWithSeedPassphrase(mnemonic, "", passphrase)
+}
+
+// ImportWithSeedPassphrase creates an HD wallet from an existing mnemonic, whose
+// seed is derived using the given BIP-39 passphrase and encrypted with the wallet
+// passphrase.
+func (b *Backend) ImportWithSeedPassphrase(mnemonic, seedPassphrase, passphrase string) (*Wallet, error) {
+	mnemonic = strings.Join(strings.Fields(mnemonic), " ")
+	if !bip39.IsMnemonicValid(mnemonic) {
+		return nil, ErrInvalidMnemonic
+	}
+	return b.store(bip39.NewSeed(mnemonic, seedPassphrase), passphrase)
+}
+
+// store writes a new seed file for the seed, tracking the account at the default
+// derivation path, and returns the wallet of it.
+func (b *Backend) store(seed []byte, passphrase string) (*Wallet, error) {
+	master, err := newMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+	defer master.wipe()
+
+	key, err := master.derive(accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		return nil, err
+	}
+	account := accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+
+	// Refuse to store the same seed twice, it would only lead to ambiguity
+	for _, wallet := range b.Wallets() {
+		if wallet.Contains(account) {
+			return nil, keystore.ErrAccountAlreadyExists
+		}
+	}
+	cryptoStruct, err := keystore.EncryptDataV3(seed, []byte(passphrase), b.scryptN, b.scryptP)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+	file := &seedFile{
+		Type:     seedFileType,
+		ID:       id.String(),
+		Version:  seedFileVersion,
+		Crypto:   cryptoStruct,
+		Accounts: []seedAccount{{Address: account.Address, Path: accounts.DefaultBaseDerivationPath.String()}},
+	}
+	path := filepath.Join(b.keydir, seedFileName(account.Address))
+	if err := writeSeedFile(path, file); err != nil {
+		return nil, err
+	}
+	b.refreshWallets()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, wallet := range b.wallets {
+		if wallet.path == path {
+			return wallet, nil
+		}
+	}
+	return nil, errors.New("stored seed file not found")
+}
@@ -0,0 +1,103 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	// seedFileType identifies seed files among the key files of the keystore.
+	seedFileType = "bip39-seed"
+
+	// seedFileVersion is the version of the seed file format.
+	seedFileVersion = 1
+)
+
+// seedFile is the on-disk format of an HD wallet. The BIP-39 seed is encrypted
+// like the keys of the keystore, whereas the tracked accounts are kept in plain
+// text so they can be listed without the passphrase. Seed files have no top
+// level address, which makes the keystore skip them.
+type seedFile struct {
+	Type     string              `json:"type"`
+	ID       string              `json:"id"`
+	Version  int                 `json:"version"`
+	Crypto   keystore.CryptoJSON `json:"crypto"`
+	Accounts []seedAccount       `json:"accounts"`
+}
+
+// seedAccount is an account derived from the seed and tracked by the wallet.
+type seedAccount struct {
+	Address common.Address `json:"address"`
+	Path    string         `json:"path"`
+}
+
+// readSeedFile loads the seed file at the given path. Files of other types, like
+// the keys of the keystore, are reported with a nil seed file and no error.
+func readSeedFile(path string) (*seedFile, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file seedFile
+	if err := json.Unmarshal(blob, &file); err != nil || file.Type != seedFileType {
+		return nil, nil
+	}
+	if file.Version != seedFileVersion {
+		return nil, fmt.Errorf("seed file version not supported: %d", file.Version)
+	}
+	return &file, nil
+}
+
+// seedFileName returns the name of a new seed file, tagged with the address of
+// the first account of the wallet.
+func seedFileName(addr common.Address) string {
+	ts := time.Now().UTC()
+	return fmt.Sprintf("UTC--%s--bip39--%s", ts.Format("2006-01-02T15-04-05.000000000Z"), hex.EncodeToString(addr[:]))
+}
+
+// writeSeedFile atomically writes the seed file to the given path. A temporary
+// hidden file is created first, which the keystore ignores, then moved into place.
+func writeSeedFile(path string, file *seedFile) error {
+	blob, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(blob); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	f.Close()
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,445 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errSeedMismatch is returned if the decrypted seed of a wallet doesn't derive
+// the accounts tracked in its seed file.
+var errSeedMismatch = errors.New("seed does not match tracked accounts")
+
+// Wallet is an accounts.Wallet deriving its accounts from an encrypted BIP-39
+// seed. Derived accounts are tracked in the seed file, so they are listed even
+// while the wallet is closed.
+type Wallet struct {
+	backend *Backend     // Backend to notify of the wallet being opened
+	path    string       // Path of the seed file
+	url     accounts.URL // Textual URL uniquely identifying this wallet
+
+	file     *seedFile                                  // Contents of the seed file
+	accounts []accounts.Account                         // List of derived accounts tracked
+	paths    map[common.Address]accounts.DerivationPath // Derivation paths of the tracked accounts
+	master   *extendedKey                               // Master key of the seed, nil while closed
+
+	deriveBases []accounts.DerivationPath // Base paths to discover used accounts from
+	deriveChain ethereum.ChainStateReader // Blockchain state reader to discover used accounts with
+	deriving    bool                      // Whether account discovery is running
+
+	lock sync.RWMutex
+}
+
+// newWallet creates a wallet for the given seed file.
+func newWallet(backend *Backend, path string, file *seedFile) *Wallet {
+	w := &Wallet{
+		backend: backend,
+		path:    path,
+		url:     accounts.URL{Scheme: Scheme, Path: path},
+		file:    file,
+		paths:   make(map[common.Address]accounts.DerivationPath),
+	}
+	for _, account := range file.Accounts {
+		path, err := accounts.ParseDerivationPath(account.Path)
+		if err != nil {
+			log.Warn("Invalid derivation path in seed file", "url", w.url, "path", account.Path, "err", err)
+			continue
+		}
+		w.track(account.Address, path)
+	}
+	return w
+}
+
+// URL implements accounts.Wallet, returning the URL of the seed file.
+func (w *Wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the seed of the wallet is
+// decrypted.
+func (w *Wallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.master == nil {
+		return "Locked", nil
+	}
+	return "Unlocked", nil
+}
+
+// Open implements accounts.Wallet, decrypting the seed of the wallet with the
+// given passphrase. Accounts can only be derived from an opened wallet.
+func (w *Wallet) Open(passphrase string) error {
+	if err := w.open(passphrase); err != nil {
+		return err
+	}
+	w.backend.updateFeed.Send(accounts.WalletEvent{Wallet: w, Kind: accounts.WalletOpened})
+
+	// Resume discovering accounts if the wallet was opened before
+	go w.selfDerive()
+	return nil
+}
+
+// open decrypts the seed of the wallet and checks it against the tracked accounts.
+func (w *Wallet) open(passphrase string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.master != nil {
+		return accounts.ErrWalletAlreadyOpen
+	}
+	seed, err := keystore.DecryptDataV3(w.file.Crypto, passphrase)
+	if err != nil {
+		return err
+	}
+	master, err := newMasterKey(seed)
+	for i := range seed {
+		seed[i] = 0
+	}
+	if err != nil {
+		return err
+	}
+	for _, account := range w.accounts {
+		key, err := master.derive(w.paths[account.Address])
+		if err != nil {
+			master.wipe()
+			return err
+		}
+		addr := crypto.PubkeyToAddress(key.PublicKey)
+		zeroKey(key)
+
+		if addr != account.Address {
+			master.wipe()
+			return errSeedMismatch
+		}
+	}
+	w.master = master
+	return nil
+}
+
+// Close implements accounts.Wallet, wiping the decrypted seed from memory.
+func (w *Wallet) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.master != nil {
+		w.master.wipe()
+		w.master = nil
+	}
+	return nil
+}
+
+// Accounts implements accounts.Wallet, returning the list of accounts derived
+// from the seed and tracked by the wallet.
+func (w *Wallet) Accounts() []accounts.Account {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not tracked by this wallet.
+func (w *Wallet) Contains(account accounts.Account) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, exists := w.paths[account.Address]
+	return exists
+}
+
+// Derive implements accounts.Wallet, deriving a new account at the specific
+// derivation path. If pin is set to true, the account will be added to the list
+// of tracked accounts, and stored in the seed file.
+func (w *Wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	account, err := w.derive(path)
+	if err != nil || !pin {
+		return account, err
+	}
+	if _, err := w.pin(account, path); err != nil {
+		return accounts.Account{}, err
+	}
+	return account, nil
+}
+
+// derive derives the account at the given path from the decrypted seed.
+func (w *Wallet) derive(path accounts.DerivationPath) (accounts.Account, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.master == nil {
+		return accounts.Account{}, accounts.ErrWalletClosed
+	}
+	key, err := w.master.derive(path)
+	if err != nil {
+		return accounts.Account{}, err
+	}
+	defer zeroKey(key)
+
+	return w.account(crypto.PubkeyToAddress(key.PublicKey), path), nil
+}
+
+// pin adds the account derived at the given path to the tracked accounts and
+// stores it in the seed file, returning whether it wasn't tracked yet.
+func (w *Wallet) pin(account accounts.Account, path accounts.DerivationPath) (bool, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if _, ok := w.paths[account.Address]; ok {
+		return false, nil
+	}
+	w.track(account.Address, path)
+	w.file.Accounts = append(w.file.Accounts, seedAccount{Address: account.Address, Path: path.String()})
+	if err := writeSeedFile(w.path, w.file); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// account returns the account of the given address derived at the given path.
+func (w *Wallet) account(addr common.Address, path accounts.DerivationPath) accounts.Account {
+	return accounts.Account{
+		Address: addr,
+		URL:     accounts.URL{Scheme: w.url.Scheme, Path: fmt.Sprintf("%s/%s", w.url.Path, path)},
+	}
+}
+
+// track adds the account derived at the given path to the tracked accounts. The
+// lock must be held by the caller.
+func (w *Wallet) track(addr common.Address, path accounts.DerivationPath) {
+	if _, ok := w.paths[addr]; ok {
+		return
+	}
+	w.accounts = append(w.accounts, w.account(addr, path))
+	w.paths[addr] = make(accounts.DerivationPath, len(path))
+	copy(w.paths[addr], path)
+}
+
+// SelfDerive sets base account derivation paths from which the wallet attempts
+// to discover used accounts and automatically add them to the list of tracked
+// accounts. Discovery runs whenever the wallet is opened. The first empty account
+// of the last base path is tracked too, to be used next.
+//
+// You can disable automatic account discovery by calling SelfDerive with a nil
+// chain state reader.
+func (w *Wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+	w.lock.Lock()
+	w.deriveBases = make([]accounts.DerivationPath, len(bases))
+	for i, base := range bases {
+		w.deriveBases[i] = make(accounts.DerivationPath, len(base))
+		copy(w.deriveBases[i], base)
+	}
+	w.deriveChain = chain
+	w.lock.Unlock()
+
+	go w.selfDerive()
+}
+
+// selfDerive discovers the used accounts of the base derivation paths, as long
+// as the wallet is open.
+func (w *Wallet) selfDerive() {
+	w.lock.Lock()
+	if w.deriving || w.master == nil || w.deriveChain == nil {
+		w.lock.Unlock()
+		return
+	}
+	w.deriving = true
+	bases, chain := w.deriveBases, w.deriveChain
+	w.lock.Unlock()
+
+	defer func() {
+		w.lock.Lock()
+		w.deriving = false
+		w.lock.Unlock()
+	}()
+	ctx := context.Background()
+	for i, base := range bases {
+		path := make(accounts.DerivationPath, len(base))
+		copy(path, base)
+
+		for {
+			account, err := w.derive(path)
+			if err != nil {
+				log.Debug("HD wallet account discovery aborted", "url", w.url, "err", err)
+				return
+			}
+			balance, err := chain.BalanceAt(ctx, account.Address, nil)
+			if err != nil {
+				log.Warn("HD wallet balance retrieval failed", "err", err)
+				return
+			}
+			nonce, err := chain.NonceAt(ctx, account.Address, nil)
+			if err != nil {
+				log.Warn("HD wallet nonce retrieval failed", "err", err)
+				return
+			}
+			empty := balance.Sign() == 0 && nonce == 0
+			if !empty || i == len(bases)-1 {
+				added, err := w.pin(account, path)
+				if err != nil {
+					log.Warn("HD wallet failed to track account", "path", path, "err", err)
+					return
+				}
+				if added {
+					log.Info("HD wallet discovered new account", "address", account.Address, "path", path, "balance", balance, "nonce", nonce)
+				}
+			}
+			if empty {
+				break
+			}
+			path[len(path)-1]++
+		}
+	}
+}
+
+// key derives the private key of a tracked account from the decrypted seed.
+func (w *Wallet) key(account accounts.Account) (*ecdsa.PrivateKey, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	path, ok := w.paths[account.Address]
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	if w.master == nil {
+		return nil, keystore.ErrLocked
+	}
+	return w.master.derive(path)
+}
+
+// keyWithPassphrase derives the private key of a tracked account from the seed
+// decrypted with the given passphrase, regardless of the wallet being open.
+func (w *Wallet) keyWithPassphrase(account accounts.Account, passphrase string) (*ecdsa.PrivateKey, error) {
+	w.lock.RLock()
+	path, ok := w.paths[account.Address]
+	cryptoStruct := w.file.Crypto
+	w.lock.RUnlock()
+
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	seed, err := keystore.DecryptDataV3(cryptoStruct, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	master, err := newMasterKey(seed)
+	for i := range seed {
+		seed[i] = 0
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer master.wipe()
+
+	return master.derive(path)
+}
+
+// signHash signs the given hash with the key of the account, derived from the
+// decrypted seed.
+func (w *Wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+// signHashWithPassphrase signs the given hash with the key of the account,
+// derived from the seed decrypted with the passphrase.
+func (w *Wallet) signHashWithPassphrase(account accounts.Account, passphrase string, hash []byte) ([]byte, error) {
+	key, err := w.keyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return crypto.Sign(hash, key)
+}
+
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed.
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase signs keccak256(data), deriving the key from the seed
+// decrypted with the passphrase.
+func (w *Wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.signHashWithPassphrase(account, passphrase, crypto.Keccak256(data))
+}
+
+// SignText implements accounts.Wallet, attempting to sign the hash of
+// the given text with the given account.
+func (w *Wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet, attempting to sign the
+// hash of the given text with the given account using passphrase as extra
+// authentication.
+func (w *Wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.signHashWithPassphrase(account, passphrase, accounts.TextHash(text))
+}
+
+// SignTx implements accounts.Wallet, signing the given transaction with the key
+// of the account, derived from the decrypted seed.
+func (w *Wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.key(account)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	// Depending on the presence of the chain ID, sign with 2718 or homestead
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet, signing the given transaction
+// with the key of the account, derived from the seed decrypted with the passphrase.
+func (w *Wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	key, err := w.keyWithPassphrase(account, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zeroKey(key)
+
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), key)
+}
+
+// zeroKey zeroes a private key in memory.
+func zeroKey(k *ecdsa.PrivateKey) {
+	b := k.D.Bits()
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package hdwallet
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/tyler-smith/go-bip39"
+)
+
+const testMnemonic = "test test test test test test test test test test test junk"
+
+var (
+	testAccount0 = common.HexToAddress("0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266")
+	testAccount1 = common.HexToAddress("0x70997970C51812dc3A010C7d01b50e0d17dc79C8")
+)
+
+func tmpBackend(t *testing.T) (string, *Backend) {
+	dir, err := ioutil.TempDir("", "hdwallet-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, NewBackend(dir, keystore.LightScryptN, keystore.LightScryptP)
+}
+
+// Tests the lifecycle of an imported wallet: opening, deriving, signing and
+// reloading the tracked accounts.
+func TestWalletLifecycle(t *testing.T) {
+	dir, backend := tmpBackend(t)
+	defer os.RemoveAll(dir)
+
+	if _, err := backend.Import("test test test", "pass"); err != ErrInvalidMnemonic {
+		t.Fatalf("invalid mnemonic: have %v, want %v", err, ErrInvalidMnemonic)
+	}
+	wallet, err := backend.Import(strings.ReplaceAll(testMnemonic, " ", "  ")+"\n", "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if _, err := backend.Import(testMnemonic, "other"); err != keystore.ErrAccountAlreadyExists {
+		t.Fatalf("duplicate import: have %v, want %v", err, keystore.ErrAccountAlreadyExists)
+	}
+	if accs := wallet.Accounts(); len(accs) != 1 || accs[0].Address != testAccount0 {
+		t.Fatalf("accounts mismatch: have %v, want %x", accs, testAccount0)
+	}
+	// The keystore in the same directory must not pick up the seed file
+	if accs := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP).Accounts(); len(accs) != 0 {
+		t.Errorf("keystore picked up seed file: %v", accs)
+	}
+	// Accounts can only be derived and used while the wallet is open
+	path := accounts.DefaultIterator(accounts.DefaultBaseDerivationPath)
+	path()
+	next := path()
+	if _, err := wallet.Derive(next, true); err != accounts.ErrWalletClosed {
+		t.Fatalf("derive from closed wallet: have %v, want %v", err, accounts.ErrWalletClosed)
+	}
+	tx := types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	if _, err := wallet.SignTx(accounts.Account{Address: testAccount0}, tx, big.NewInt(1)); err != keystore.ErrLocked {
+		t.Fatalf("sign with closed wallet: have %v, want %v", err, keystore.ErrLocked)
+	}
+	if err := wallet.Open("wrong"); err != keystore.ErrDecrypt {
+		t.Fatalf("open with wrong passphrase: have %v, want %v", err, keystore.ErrDecrypt)
+	}
+	if err := wallet.Open("pass"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	if status, _ := wallet.Status(); status != "Unlocked" {
+		t.Errorf("status mismatch: have %s, want Unlocked", status)
+	}
+	account, err := wallet.Derive(next, true)
+	if err != nil {
+		t.Fatalf("failed to derive account: %v", err)
+	}
+	if account.Address != testAccount1 {
+		t.Errorf("derived address mismatch: have %x, want %x", account.Address, testAccount1)
+	}
+	signed, err := wallet.SignTx(account, tx, big.NewInt(1))
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	if sender, _ := types.Sender(types.LatestSignerForChainID(big.NewInt(1)), signed); sender != testAccount1 {
+		t.Errorf("sender mismatch: have %x, want %x", sender, testAccount1)
+	}
+	wallet.Close()
+
+	// The pinned account is tracked after reloading, and usable with passphrase
+	reloaded := NewBackend(dir, keystore.LightScryptN, keystore.LightScryptP).Wallets()
+	if len(reloaded) != 1 {
+		t.Fatalf("wallet count mismatch: have %d, want 1", len(reloaded))
+	}
+	if accs := reloaded[0].Accounts(); len(accs) != 2 || accs[1] != account {
+		t.Fatalf("reloaded accounts mismatch: have %v", accs)
+	}
+	if _, err := reloaded[0].SignTxWithPassphrase(account, "wrong", tx, big.NewInt(1)); err != keystore.ErrDecrypt {
+		t.Errorf("sign with wrong passphrase: have %v, want %v", err, keystore.ErrDecrypt)
+	}
+	if _, err := reloaded[0].SignTxWithPassphrase(account, "pass", tx, big.NewInt(1)); err != nil {
+		t.Errorf("failed to sign with passphrase: %v", err)
+	}
+	if _, err := reloaded[0].SignTextWithPassphrase(accounts.Account{Address: common.Address{1}}, "pass", []byte("hi")); err != accounts.ErrUnknownAccount {
+		t.Errorf("sign with unknown account: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that newly created wallets are announced to subscribers.
+func TestNewWallet(t *testing.T) {
+	dir, backend := tmpBackend(t)
+	defer os.RemoveAll(dir)
+
+	events := make(chan accounts.WalletEvent, 4)
+	sub := backend.Subscribe(events)
+	defer sub.Unsubscribe()
+
+	wallet, mnemonic, err := backend.NewWallet("pass")
+	if err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if words := strings.Fields(mnemonic); len(words) != 24 {
+		t.Errorf("mnemonic length mismatch: have %d words, want 24", len(words))
+	}
+	select {
+	case ev := <-events:
+		if ev.Kind != accounts.WalletArrived || ev.Wallet != wallet {
+			t.Errorf("unexpected event: %v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wallet arrival not announced")
+	}
+	os.Remove(wallet.path)
+	if wallets := backend.Wallets(); len(wallets) != 0 {
+		t.Errorf("removed wallet still listed")
+	}
+	select {
+	case ev := <-events:
+		if ev.Kind != accounts.WalletDropped {
+			t.Errorf("unexpected event: %v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wallet removal not announced")
+	}
+}
+
+// testChain is a chain state reader with a set of used accounts.
+type testChain struct {
+	balances map[common.Address]*big.Int
+}
+
+func (c *testChain) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	if balance, ok := c.balances[account]; ok {
+		return balance, nil
+	}
+	return new(big.Int), nil
+}
+
+func (c *testChain) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *testChain) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return nil, nil
+}
+
+func (c *testChain) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return 0, nil
+}
+
+// Tests that mnemonics protected by a BIP-39 passphrase are imported with the
+// seed derived using the passphrase.
+func TestImportSeedPassphrase(t *testing.T) {
+	dir, backend := tmpBackend(t)
+	defer os.RemoveAll(dir)
+
+	master, err := newMasterKey(bip39.NewSeed(testMnemonic, "TREZOR"))
+	if err != nil {
+		t.Fatalf("failed to create master key: %v", err)
+	}
+	key, err := master.derive(accounts.DefaultBaseDerivationPath)
+	if err != nil {
+		t.Fatalf("failed to derive key: %v", err)
+	}
+	want := crypto.PubkeyToAddress(key.PublicKey)
+	if want == testAccount0 {
+		t.Fatalf("passphrase not applied to seed")
+	}
+	wallet, err := backend.ImportWithSeedPassphrase(testMnemonic, "TREZOR", "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	if accs := wallet.Accounts(); len(accs) != 1 || accs[0].Address != want {
+		t.Fatalf("accounts mismatch: have %v, want %x", accs, want)
+	}
+}
+
+// Tests that used accounts are discovered once the wallet is opened.
+func TestSelfDerive(t *testing.T) {
+	dir, backend := tmpBackend(t)
+	defer os.RemoveAll(dir)
+
+	wallet, err := backend.Import(testMnemonic, "pass")
+	if err != nil {
+		t.Fatalf("failed to import mnemonic: %v", err)
+	}
+	chain := &testChain{balances: map[common.Address]*big.Int{
+		testAccount0: big.NewInt(1),
+		testAccount1: big.NewInt(1),
+	}}
+	wallet.SelfDerive([]accounts.DerivationPath{accounts.DefaultBaseDerivationPath}, chain)
+	if err := wallet.Open("pass"); err != nil {
+		t.Fatalf("failed to open wallet: %v", err)
+	}
+	// Both used accounts and the first empty one must be tracked
+	for i := 0; i < 100 && len(wallet.Accounts()) < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	accs := wallet.Accounts()
+	if len(accs) != 3 {
+		t.Fatalf("account count mismatch: have %d, want 3", len(accs))
+	}
+	if accs[0].Address != testAccount0 || accs[1].Address != testAccount1 {
+		t.Errorf("discovered accounts mismatch: have %v", accs)
+	}
+	if have, want := accs[2].URL.Path, wallet.path+"/m/44'/60'/0'/0/2"; have != want {
+		t.Errorf("account url mismatch: have %s, want %s", have, want)
+	}
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 var (
@@ -288,6 +289,17 @@ func (ks *KeyStore) SignTx(a accounts.Account, tx *types.Transaction, chainID *b
 	return types.SignTx(tx, signer, unlockedKey.PrivateKey)
 }
 
+// SignTypedData signs EIP-712 typed data with the requested account, returning
+// the signature over keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+// in the [R || S || V] format where V is 0 or 1.
+func (ks *KeyStore) SignTypedData(a accounts.Account, typedData apitypes.TypedData) ([]byte, error) {
+	sighash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return ks.SignHash(a, sighash)
+}
+
 // SignHashWithPassphrase signs hash if the private key matching the given address
 // can be decrypted with the given passphrase. The produced signature is in the
 // [R || S || V] format where V is 0 or 1.
@@ -313,6 +325,16 @@ func (ks *KeyStore) SignTxWithPassphrase(a accounts.Account, passphrase string,
 	return types.SignTx(tx, signer, key.PrivateKey)
 }
 
+// SignTypedDataWithPassphrase signs EIP-712 typed data if the private key
+// matching the given address can be decrypted with the given passphrase.
+func (ks *KeyStore) SignTypedDataWithPassphrase(a accounts.Account, passphrase string, typedData apitypes.TypedData) ([]byte, error) {
+	sighash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	return ks.SignHashWithPassphrase(a, passphrase, sighash)
+}
+
 // Unlock unlocks the given account indefinitely.
 func (ks *KeyStore) Unlock(a accounts.Account, passphrase string) error {
 	return ks.TimedUnlock(a, passphrase, 0)
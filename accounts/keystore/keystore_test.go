@@ -17,6 +17,7 @@
 package keystore
 
 import (
+	"bytes"
 	"io/ioutil"
 	"math/rand"
 	"os"
@@ -30,8 +31,10 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
 var testSigData = make([]byte, 32)
@@ -116,6 +119,71 @@ func TestSignWithPassphrase(t *testing.T) {
 	}
 }
 
+func TestSignTypedData(t *testing.T) {
+	dir, ks := tmpKeyStore(t, true)
+	defer os.RemoveAll(dir)
+
+	pass := "passwd"
+	acc, err := ks.NewAccount(pass)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": []apitypes.Type{
+				{Name: "name", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Mail": []apitypes.Type{
+				{Name: "to", Type: "address[]"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: apitypes.TypedDataDomain{
+			Name:    "Ether Mail",
+			ChainId: math.NewHexOrDecimal256(1),
+		},
+		Message: apitypes.TypedDataMessage{
+			"to":       []interface{}{acc.Address.Hex()},
+			"contents": "Hello, Bob!",
+		},
+	}
+	sighash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ks.SignTypedData(acc, typedData); err != ErrLocked {
+		t.Fatalf("signing with locked account: have %v, want %v", err, ErrLocked)
+	}
+	sig, err := ks.SignTypedDataWithPassphrase(acc, pass, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, err := crypto.SigToPub(sighash, sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer := crypto.PubkeyToAddress(*pubkey); signer != acc.Address {
+		t.Fatalf("signer mismatch: have %x, want %x", signer, acc.Address)
+	}
+	if err := ks.Unlock(acc, pass); err != nil {
+		t.Fatal(err)
+	}
+	unlockedSig, err := ks.SignTypedData(acc, typedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sig, unlockedSig) {
+		t.Fatalf("signature mismatch: have %x, want %x", unlockedSig, sig)
+	}
+	// Invalid typed data must be rejected before signing
+	typedData.Message["to"] = "not an array"
+	if _, err := ks.SignTypedData(acc, typedData); err == nil {
+		t.Fatal("expected invalid typed data to be rejected")
+	}
+}
+
 func TestTimedUnlock(t *testing.T) {
 	dir, ks := tmpKeyStore(t, true)
 	defer os.RemoveAll(dir)
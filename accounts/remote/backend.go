@@ -0,0 +1,303 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// Scheme is the URL scheme of the wallets and accounts of remote signers.
+const Scheme = "remote"
+
+// requestTimeout is the maximum time to wait for a remote signer to respond.
+const requestTimeout = 30 * time.Second
+
+// refreshInterval is the time after which the cached keys of a remote signer are
+// retrieved again in the background.
+const refreshInterval = time.Minute
+
+var (
+	secp256k1N     = crypto.S256().Params().N
+	secp256k1halfN = new(big.Int).Rsh(secp256k1N, 1)
+
+	// errSignerMismatch is returned if a remote signer returns a signature
+	// which was not made by the requested key.
+	errSignerMismatch = errors.New("remote signature does not match account")
+)
+
+// Backend is an accounts.Backend exposing the keys of a remote signer as the
+// accounts of a single wallet.
+type Backend struct {
+	wallet *wallet
+}
+
+// NewBackend creates an account backend for the given remote signer, identified
+// by name in the wallet and account URLs. The keys available are retrieved from
+// the signer, failing if it cannot be reached.
+func NewBackend(name string, signer Signer) (*Backend, error) {
+	w := &wallet{
+		url:    accounts.URL{Scheme: Scheme, Path: name},
+		signer: signer,
+	}
+	if err := w.refresh(); err != nil {
+		return nil, err
+	}
+	return &Backend{wallet: w}, nil
+}
+
+// Wallets implements accounts.Backend, returning the single wallet of the
+// remote signer.
+func (b *Backend) Wallets() []accounts.Wallet {
+	return []accounts.Wallet{b.wallet}
+}
+
+// Subscribe implements accounts.Backend. The wallet of the remote signer is
+// fixed, so no events are ever delivered.
+func (b *Backend) Subscribe(sink chan<- accounts.WalletEvent) event.Subscription {
+	return event.NewSubscription(func(quit <-chan struct{}) error {
+		<-quit
+		return nil
+	})
+}
+
+// wallet implements accounts.Wallet for the keys held by a remote signer.
+type wallet struct {
+	url    accounts.URL
+	signer Signer
+
+	accounts   []accounts.Account        // Accounts of the keys last retrieved
+	ids        map[common.Address]string // Key ids of the accounts
+	err        error                     // Failure of the last key retrieval
+	refreshed  time.Time                 // Time of the last key retrieval
+	refreshing bool                      // Whether a key retrieval is in progress
+	lock       sync.RWMutex
+}
+
+// refresh retrieves the keys available from the remote signer.
+func (w *wallet) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	keys, err := w.signer.Keys(ctx)
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.refreshed, w.refreshing = time.Now(), false
+	if err != nil {
+		w.err = err
+		return err
+	}
+	w.accounts = make([]accounts.Account, 0, len(keys))
+	w.ids = make(map[common.Address]string, len(keys))
+	for _, key := range keys {
+		w.accounts = append(w.accounts, accounts.Account{
+			Address: key.Address,
+			URL:     accounts.URL{Scheme: Scheme, Path: w.url.Path + "/" + key.ID},
+		})
+		w.ids[key.Address] = key.ID
+	}
+	w.err = nil
+	return nil
+}
+
+// URL implements accounts.Wallet, returning the URL of the remote signer.
+func (w *wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet, returning whether the remote signer could
+// be reached the last time its keys were retrieved.
+func (w *wallet) Status() (string, error) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	if w.err != nil {
+		return "Offline", w.err
+	}
+	return "Online", nil
+}
+
+// Open implements accounts.Wallet, but is a noop for remote wallets since the
+// keys are unlocked by the remote signer.
+func (w *wallet) Open(passphrase string) error { return nil }
+
+// Close implements accounts.Wallet, but is a noop for remote wallets since
+// there is no meaningful open operation.
+func (w *wallet) Close() error { return nil }
+
+// Accounts implements accounts.Wallet, returning the accounts of the keys held by
+// the remote signer as last retrieved. If they are older than the refresh interval,
+// they are retrieved again in the background without blocking the caller.
+func (w *wallet) Accounts() []accounts.Account {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if !w.refreshing && time.Since(w.refreshed) > refreshInterval {
+		w.refreshing = true
+		go func() {
+			if err := w.refresh(); err != nil {
+				log.Warn("Failed to retrieve remote signer keys", "url", w.url, "err", err)
+			}
+		}()
+	}
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet, returning whether a particular account is
+// or is not held by the remote signer.
+func (w *wallet) Contains(account accounts.Account) bool {
+	_, ok := w.keyID(account)
+	return ok
+}
+
+// keyID returns the id of the remote key of the account, if it is held by the
+// remote signer.
+func (w *wallet) keyID(account accounts.Account) (string, bool) {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	id, ok := w.ids[account.Address]
+	if !ok {
+		return "", false
+	}
+	if account.URL != (accounts.URL{}) && account.URL.Path != w.url.Path+"/"+id {
+		return "", false
+	}
+	return id, true
+}
+
+// Derive implements accounts.Wallet, but is not supported by remote signers.
+func (w *wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet, but is a noop for remote wallets since
+// there is no notion of hierarchical account derivation for remote keys.
+func (w *wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// signHash requests the remote signer to sign the hash with the key of the given
+// account, returning the signature in the [R || S || V] format where V is 0 or 1.
+func (w *wallet) signHash(account accounts.Account, hash []byte) ([]byte, error) {
+	id, ok := w.keyID(account)
+	if !ok {
+		return nil, accounts.ErrUnknownAccount
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	r, s, v, err := w.signer.Sign(ctx, id, hash)
+	if err != nil {
+		return nil, fmt.Errorf("remote signer failed: %v", err)
+	}
+	sig, err := encodeSignature(r, s, v)
+	if err != nil {
+		return nil, err
+	}
+	// Don't trust the remote signer, make sure the signature is from the account
+	pubkey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return nil, err
+	}
+	if crypto.PubkeyToAddress(*pubkey) != account.Address {
+		return nil, errSignerMismatch
+	}
+	return sig, nil
+}
+
+// encodeSignature converts the signature values returned by a remote signer into
+// the [R || S || V] format, normalizing s into the lower half of the curve order
+// as required since Homestead.
+func encodeSignature(r, s *big.Int, v byte) ([]byte, error) {
+	if r == nil || s == nil {
+		return nil, errors.New("incomplete signature")
+	}
+	if v >= 27 {
+		v -= 27
+	}
+	if s.Cmp(secp256k1halfN) > 0 {
+		s = new(big.Int).Sub(secp256k1N, s)
+		v ^= 1
+	}
+	if !crypto.ValidateSignatureValues(v, r, s, true) {
+		return nil, errors.New("invalid signature values")
+	}
+	sig := make([]byte, crypto.SignatureLength)
+	math.ReadBits(r, sig[:32])
+	math.ReadBits(s, sig[32:64])
+	sig[64] = v
+	return sig, nil
+}
+
+// SignData signs keccak256(data). The mimetype parameter describes the type of data being signed.
+func (w *wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.signHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase signs keccak256(data). The passphrase is ignored, since
+// the keys are unlocked by the remote signer.
+func (w *wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, attempting to sign the hash of
+// the given text with the given account.
+func (w *wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	return w.signHash(account, accounts.TextHash(text))
+}
+
+// SignTextWithPassphrase implements accounts.Wallet. The passphrase is ignored,
+// since the keys are unlocked by the remote signer.
+func (w *wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet, requesting the remote signer to sign the
+// given transaction with the given account.
+func (w *wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	// Depending on the presence of the chain ID, sign with 2718 or homestead
+	signer := types.LatestSignerForChainID(chainID)
+	sig, err := w.signHash(account, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return tx.WithSignature(signer, sig)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. The passphrase is ignored,
+// since the keys are unlocked by the remote signer.
+func (w *wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
@@ -0,0 +1,237 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"context"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// newTestSigner creates an in-memory signer with the given number of keys.
+func newTestSigner(t *testing.T, n int) *MemorySigner {
+	signer := NewMemorySigner()
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		signer.Add(string(rune('a'+i)), key)
+	}
+	return signer
+}
+
+// Tests that the keys of a remote signer are exposed as accounts through the
+// account manager, and that they can sign.
+func TestBackendAccounts(t *testing.T) {
+	signer := newTestSigner(t, 2)
+	srv := httptest.NewServer(NewServer(signer))
+	defer srv.Close()
+
+	backend, err := NewHTTPBackend(srv.URL, nil)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	am := accounts.NewManager(&accounts.Config{}, backend)
+	defer am.Close()
+
+	keys, _ := signer.Keys(context.Background())
+	accs := am.Accounts()
+	if len(accs) != len(keys) {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), len(keys))
+	}
+	for i, key := range keys {
+		if accs[i] != key.Address {
+			t.Errorf("account %d: address mismatch: have %x, want %x", i, accs[i], key.Address)
+		}
+	}
+	// Sign a transaction through the account manager and check the sender
+	account := accounts.Account{Address: keys[1].Address}
+	wallet, err := am.Find(account)
+	if err != nil {
+		t.Fatalf("failed to find account: %v", err)
+	}
+	if have, want := wallet.URL().String(), "remote://"+srv.Listener.Addr().String(); have != want {
+		t.Errorf("wallet url mismatch: have %s, want %s", have, want)
+	}
+	chainID := big.NewInt(1337)
+	tx := types.NewTransaction(0, common.Address{0xaa}, big.NewInt(1), 21000, big.NewInt(1), nil)
+	signed, err := wallet.SignTxWithPassphrase(account, "", tx, chainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	if err != nil {
+		t.Fatalf("failed to recover sender: %v", err)
+	}
+	if sender != account.Address {
+		t.Errorf("sender mismatch: have %x, want %x", sender, account.Address)
+	}
+	// Sign some text and check the signer
+	sig, err := wallet.SignText(account, []byte("hello"))
+	if err != nil {
+		t.Fatalf("failed to sign text: %v", err)
+	}
+	pubkey, err := crypto.SigToPub(accounts.TextHash([]byte("hello")), sig)
+	if err != nil {
+		t.Fatalf("failed to recover signer: %v", err)
+	}
+	if addr := crypto.PubkeyToAddress(*pubkey); addr != account.Address {
+		t.Errorf("signer mismatch: have %x, want %x", addr, account.Address)
+	}
+	// Accounts not held by the signer must be rejected
+	if _, err := wallet.SignText(accounts.Account{Address: common.Address{0xff}}, []byte("hello")); err != accounts.ErrUnknownAccount {
+		t.Errorf("signing with unknown account: have %v, want %v", err, accounts.ErrUnknownAccount)
+	}
+}
+
+// Tests that keys added to or removed from the remote signer are picked up, and
+// that the last known keys are retained while the signer is unreachable.
+func TestBackendRefresh(t *testing.T) {
+	signer := newTestSigner(t, 1)
+	srv := httptest.NewServer(NewServer(signer))
+
+	backend, err := NewBackend("test", NewHTTPSigner(srv.URL, nil))
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.wallet
+
+	key, _ := crypto.GenerateKey()
+	signer.Add("z", key)
+	wallet.refresh()
+	accs := wallet.Accounts()
+	if len(accs) != 2 {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), 2)
+	}
+	if have, want := accs[1].URL.String(), "remote://test/z"; have != want {
+		t.Errorf("account url mismatch: have %s, want %s", have, want)
+	}
+	signer.Remove("a")
+	wallet.refresh()
+	if accs := wallet.Accounts(); len(accs) != 1 {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), 1)
+	}
+	srv.Close()
+	wallet.refresh()
+	if accs := wallet.Accounts(); len(accs) != 1 {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), 1)
+	}
+	if status, err := wallet.Status(); err == nil {
+		t.Errorf("unreachable signer reported as %q", status)
+	}
+	// A backend can't be created for an unreachable signer
+	if _, err := NewBackend("test", NewHTTPSigner(srv.URL, nil)); err == nil {
+		t.Errorf("created backend for unreachable signer")
+	}
+}
+
+// blockingSigner is a remote signer whose key listing blocks until released.
+type blockingSigner struct {
+	*MemorySigner
+	release chan struct{}
+}
+
+func (s *blockingSigner) Keys(ctx context.Context) ([]Key, error) {
+	<-s.release
+	return s.MemorySigner.Keys(ctx)
+}
+
+// Tests that stale keys are retrieved in the background, without blocking the
+// account listing.
+func TestBackendBackgroundRefresh(t *testing.T) {
+	signer := &blockingSigner{MemorySigner: newTestSigner(t, 1), release: make(chan struct{})}
+	close(signer.release)
+
+	backend, err := NewBackend("test", signer)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.wallet
+
+	// Add a key and mark the cached keys stale, the listing must not block
+	signer.release = make(chan struct{})
+	key, _ := crypto.GenerateKey()
+	signer.Add("z", key)
+
+	wallet.lock.Lock()
+	wallet.refreshed = time.Time{}
+	wallet.lock.Unlock()
+
+	if accs := wallet.Accounts(); len(accs) != 1 {
+		t.Fatalf("account count mismatch: have %d, want %d", len(accs), 1)
+	}
+	close(signer.release)
+
+	for i := 0; i < 100; i++ {
+		if len(wallet.Accounts()) == 2 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("keys not refreshed in the background")
+}
+
+// rogueSigner is a remote signer returning signatures made with an unexpected
+// key, or with the s value in the upper half of the curve order.
+type rogueSigner struct {
+	*MemorySigner
+	swap  map[string]string // Keys actually used for signing
+	highS bool              // Whether to return the s value in the upper half
+}
+
+func (s *rogueSigner) Sign(ctx context.Context, id string, hash []byte) (*big.Int, *big.Int, byte, error) {
+	if other, ok := s.swap[id]; ok {
+		id = other
+	}
+	r, sv, v, err := s.MemorySigner.Sign(ctx, id, hash)
+	if err != nil || !s.highS {
+		return r, sv, v, err
+	}
+	return r, new(big.Int).Sub(secp256k1N, sv), (v ^ 1) + 27, nil
+}
+
+// Tests that signatures returned by remote signers are normalized and verified.
+func TestBackendSignatureChecks(t *testing.T) {
+	signer := &rogueSigner{MemorySigner: newTestSigner(t, 2), highS: true}
+
+	backend, err := NewBackend("test", signer)
+	if err != nil {
+		t.Fatalf("failed to create backend: %v", err)
+	}
+	wallet := backend.Wallets()[0]
+	accs := wallet.Accounts()
+
+	sig, err := wallet.SignData(accs[0], accounts.MimetypeTextPlain, []byte("hello"))
+	if err != nil {
+		t.Fatalf("failed to sign with high s value: %v", err)
+	}
+	if s := new(big.Int).SetBytes(sig[32:64]); s.Cmp(secp256k1halfN) > 0 {
+		t.Errorf("s value not normalized: %x", s)
+	}
+	signer.swap = map[string]string{"a": "b"}
+	if _, err := wallet.SignData(accs[0], accounts.MimetypeTextPlain, []byte("hello")); err != errSignerMismatch {
+		t.Errorf("signature by wrong key: have %v, want %v", err, errSignerMismatch)
+	}
+}
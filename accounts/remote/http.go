@@ -0,0 +1,218 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// The HTTP protocol spoken between HTTPSigner and Server consists of two calls:
+//
+//	GET  <endpoint>/keys  -> [{"id": "...", "address": "0x..."}, ...]
+//	POST <endpoint>/sign  {"id": "...", "hash": "0x..."} -> {"r": "0x...", "s": "0x...", "v": "0x0"}
+//
+// Failures are reported with a non-200 status code and the error message as the
+// plain text body.
+
+// maxMessageSize is the maximum size of a request or response body.
+const maxMessageSize = 1024 * 1024
+
+// signRequest is the body of a signing request.
+type signRequest struct {
+	ID   string        `json:"id"`
+	Hash hexutil.Bytes `json:"hash"`
+}
+
+// signResponse is the body of a signing response.
+type signResponse struct {
+	R *hexutil.Big   `json:"r"`
+	S *hexutil.Big   `json:"s"`
+	V hexutil.Uint64 `json:"v"`
+}
+
+// HTTPSigner is a Signer delegating to a remote key management service over
+// HTTP, such as one served by Server.
+type HTTPSigner struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPSigner creates a signer for the service at the given endpoint. If no
+// client is given, http.DefaultClient is used. Authentication with the service,
+// e.g. by TLS client certificates, is configured through the client.
+func NewHTTPSigner(endpoint string, client *http.Client) *HTTPSigner {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &HTTPSigner{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   client,
+	}
+}
+
+// NewHTTPBackend creates an account backend for the service at the given HTTP
+// endpoint, named by the host of the endpoint.
+func NewHTTPBackend(endpoint string, client *http.Client) (*Backend, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported remote signer scheme %q", u.Scheme)
+	}
+	return NewBackend(u.Host, NewHTTPSigner(endpoint, client))
+}
+
+// Keys implements Signer, retrieving the keys held by the remote service.
+func (s *HTTPSigner) Keys(ctx context.Context) ([]Key, error) {
+	var keys []Key
+	if err := s.call(ctx, http.MethodGet, "/keys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Sign implements Signer, requesting the remote service to sign the hash.
+func (s *HTTPSigner) Sign(ctx context.Context, id string, hash []byte) (*big.Int, *big.Int, byte, error) {
+	var res signResponse
+	if err := s.call(ctx, http.MethodPost, "/sign", &signRequest{ID: id, Hash: hash}, &res); err != nil {
+		return nil, nil, 0, err
+	}
+	if res.R == nil || res.S == nil {
+		return nil, nil, 0, errors.New("incomplete signature")
+	}
+	if res.V > 255 {
+		return nil, nil, 0, fmt.Errorf("invalid recovery id %d", res.V)
+	}
+	return res.R.ToInt(), res.S.ToInt(), byte(res.V), nil
+}
+
+// call executes a request against the remote service, decoding the response
+// into result.
+func (s *HTTPSigner) call(ctx context.Context, method, path string, args interface{}, result interface{}) error {
+	var body io.Reader
+	if args != nil {
+		blob, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(blob)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.endpoint+path, body)
+	if err != nil {
+		return err
+	}
+	if args != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	blob, err := ioutil.ReadAll(io.LimitReader(res.Body, maxMessageSize))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode != http.StatusOK {
+		if len(blob) == 0 {
+			return errors.New(res.Status)
+		}
+		return fmt.Errorf("%s: %s", res.Status, strings.TrimSpace(string(blob)))
+	}
+	return json.Unmarshal(blob, result)
+}
+
+// Server is a reference HTTP server exposing the keys of a Signer, implementing
+// the protocol spoken by HTTPSigner. It does not authenticate its callers, which
+// is left to the surrounding deployment.
+type Server struct {
+	signer Signer
+}
+
+// NewServer creates an HTTP server exposing the given signer.
+func NewServer(signer Signer) *Server {
+	return &Server{signer: signer}
+}
+
+// ServeHTTP implements http.Handler.
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/keys":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		keys, err := srv.signer.Keys(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		srv.reply(w, keys)
+
+	case "/sign":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var req signRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxMessageSize)).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if len(req.Hash) != 32 {
+			http.Error(w, fmt.Sprintf("invalid hash length %d", len(req.Hash)), http.StatusBadRequest)
+			return
+		}
+		sigR, sigS, sigV, err := srv.signer.Sign(r.Context(), req.ID, req.Hash)
+		switch {
+		case err == ErrUnknownKey:
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		log.Debug("Signed hash with remote key", "id", req.ID, "hash", req.Hash)
+		srv.reply(w, &signResponse{R: (*hexutil.Big)(sigR), S: (*hexutil.Big)(sigS), V: hexutil.Uint64(sigV)})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// reply writes a JSON encoded response.
+func (srv *Server) reply(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Debug("Failed to write signer response", "err", err)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package remote implements an account backend for keys held by a remote key
+// management service, which signs hashes on behalf of the node without the
+// private keys ever touching the local disk.
+package remote
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrUnknownKey is returned by signers for signing requests with a key id that
+// they do not hold.
+var ErrUnknownKey = errors.New("unknown key")
+
+// Key is a secp256k1 key held by a remote signer.
+type Key struct {
+	ID      string         `json:"id"`      // Identifier of the key within the signer
+	Address common.Address `json:"address"` // Ethereum address derived from the key
+}
+
+// Signer is the interface to a remote key management service. The service holds
+// the private keys, and only ever hands out signatures over 32 byte hashes.
+type Signer interface {
+	// Keys returns the keys available for signing.
+	Keys(ctx context.Context) ([]Key, error)
+
+	// Sign signs the 32 byte hash with the key of the given id, returning the
+	// signature values r and s, along with the recovery id v. The recovery id
+	// may be in the range [0, 1] or [27, 28].
+	Sign(ctx context.Context, id string, hash []byte) (r, s *big.Int, v byte, err error)
+}
+
+// MemorySigner is a Signer holding its keys in memory. It is meant for testing
+// and for serving keys through the reference HTTP server.
+type MemorySigner struct {
+	keys map[string]*ecdsa.PrivateKey
+	lock sync.RWMutex
+}
+
+// NewMemorySigner creates an empty in-memory signer.
+func NewMemorySigner() *MemorySigner {
+	return &MemorySigner{
+		keys: make(map[string]*ecdsa.PrivateKey),
+	}
+}
+
+// Add inserts a key into the signer under the given id, replacing any previous
+// key with the same id.
+func (s *MemorySigner) Add(id string, key *ecdsa.PrivateKey) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys[id] = key
+}
+
+// Remove deletes the key with the given id from the signer.
+func (s *MemorySigner) Remove(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.keys, id)
+}
+
+// Keys implements Signer, returning the keys held sorted by id.
+func (s *MemorySigner) Keys(ctx context.Context) ([]Key, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	for id, key := range s.keys {
+		keys = append(keys, Key{ID: id, Address: crypto.PubkeyToAddress(key.PublicKey)})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+// Sign implements Signer, signing the hash with the key of the given id.
+func (s *MemorySigner) Sign(ctx context.Context, id string, hash []byte) (*big.Int, *big.Int, byte, error) {
+	s.lock.RLock()
+	key, ok := s.keys[id]
+	s.lock.RUnlock()
+
+	if !ok {
+		return nil, nil, 0, ErrUnknownKey
+	}
+	sig, err := crypto.Sign(hash, key)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64]), sig[64], nil
+}
@@ -21,12 +21,16 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math/big"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -37,6 +41,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/remote"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -107,6 +112,18 @@ var (
 		Usage: "HTTP-RPC server listening port",
 		Value: node.DefaultHTTPPort + 5,
 	}
+	tlsCertFlag = cli.StringFlag{
+		Name:  "http.tlscert",
+		Usage: "Certificate file for serving the HTTP-RPC API over TLS",
+	}
+	tlsKeyFlag = cli.StringFlag{
+		Name:  "http.tlskey",
+		Usage: "Key file for serving the HTTP-RPC API over TLS",
+	}
+	tlsClientCAFlag = cli.StringFlag{
+		Name:  "http.tlsclientca",
+		Usage: "CA file for verifying client certificates, requiring all HTTP-RPC clients to authenticate over TLS",
+	}
 	signerSecretFlag = cli.StringFlag{
 		Name:  "signersecret",
 		Usage: "A file containing the (encrypted) master seed to encrypt Clef data, e.g. keystore credentials and ruleset hash",
@@ -125,6 +142,10 @@ var (
 		Name:  "rules",
 		Usage: "Path to the rule file to auto-authorize requests with",
 	}
+	policyFlag = cli.StringFlag{
+		Name:  "policy",
+		Usage: "Path to the declarative policy file (YAML or JSON) to auto-authorize requests with, instead of a rule file",
+	}
 	stdiouiFlag = cli.BoolFlag{
 		Name: "stdio-ui",
 		Usage: "Use STDIN/STDOUT as a channel for an external UI. " +
@@ -229,16 +250,21 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.LightKDFFlag,
 			utils.NoUSBFlag,
 			utils.SmartCardDaemonPathFlag,
+			utils.RemoteSignerFlag,
 			utils.HTTPListenAddrFlag,
 			utils.HTTPVirtualHostsFlag,
 			utils.IPCDisabledFlag,
 			utils.IPCPathFlag,
 			utils.HTTPEnabledFlag,
 			rpcPortFlag,
+			tlsCertFlag,
+			tlsKeyFlag,
+			tlsClientCAFlag,
 			signerSecretFlag,
 			customDBFlag,
 			auditLogFlag,
 			ruleFlag,
+			policyFlag,
 			stdiouiFlag,
 			testFlag,
 			advancedMode,
@@ -258,16 +284,21 @@ func init() {
 		utils.LightKDFFlag,
 		utils.NoUSBFlag,
 		utils.SmartCardDaemonPathFlag,
+		utils.RemoteSignerFlag,
 		utils.HTTPListenAddrFlag,
 		utils.HTTPVirtualHostsFlag,
 		utils.IPCDisabledFlag,
 		utils.IPCPathFlag,
 		utils.HTTPEnabledFlag,
 		rpcPortFlag,
+		tlsCertFlag,
+		tlsKeyFlag,
+		tlsClientCAFlag,
 		signerSecretFlag,
 		customDBFlag,
 		auditLogFlag,
 		ruleFlag,
+		policyFlag,
 		stdiouiFlag,
 		testFlag,
 		advancedMode,
@@ -523,6 +554,46 @@ func initialize(c *cli.Context) error {
 	return nil
 }
 
+// serverTLSConfig creates the TLS configuration of the HTTP-RPC API, or nil if
+// TLS is not enabled. If a client CA is configured, all clients are required to
+// present a certificate issued by it.
+func serverTLSConfig(c *cli.Context) (*tls.Config, error) {
+	var (
+		certFile = c.GlobalString(tlsCertFlag.Name)
+		keyFile  = c.GlobalString(tlsKeyFlag.Name)
+		caFile   = c.GlobalString(tlsClientCAFlag.Name)
+	)
+	if certFile == "" && keyFile == "" && caFile == "" {
+		return nil, nil
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, fmt.Errorf("--%s and --%s are required for TLS", tlsCertFlag.Name, tlsKeyFlag.Name)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate: %v", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if caFile == "" {
+		log.Warn("Serving TLS without client authentication, anyone reaching the endpoint can issue requests")
+		return config, nil
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load client CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
 // ipcEndpoint resolves an IPC endpoint based on a configured value, taking into
 // account the set data folders as well as the designated platform we're currently
 // running on.
@@ -592,6 +663,9 @@ func signer(c *cli.Context) error {
 		configStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "config.json"), confkey)
 
 		// Do we have a rule-file?
+		if c.GlobalIsSet(ruleFlag.Name) && c.GlobalIsSet(policyFlag.Name) {
+			utils.Fatalf("Flags --%s and --%s are mutually exclusive", ruleFlag.Name, policyFlag.Name)
+		}
 		if ruleFile := c.GlobalString(ruleFlag.Name); ruleFile != "" {
 			ruleJS, err := ioutil.ReadFile(ruleFile)
 			if err != nil {
@@ -614,18 +688,50 @@ func signer(c *cli.Context) error {
 				}
 			}
 		}
+		// Do we have a policy file?
+		if policyFile := c.GlobalString(policyFlag.Name); policyFile != "" {
+			blob, err := ioutil.ReadFile(policyFile)
+			if err != nil {
+				log.Warn("Could not load policy, disabling", "file", policyFile, "err", err)
+			} else {
+				shasum := sha256.Sum256(blob)
+				foundShaSum := hex.EncodeToString(shasum[:])
+				storedShasum, _ := configStorage.Get("ruleset_sha256")
+				if storedShasum != foundShaSum {
+					log.Warn("Policy hash not attested, disabling", "hash", foundShaSum, "attested", storedShasum)
+				} else {
+					policy, err := rules.ParsePolicy(blob)
+					if err != nil {
+						utils.Fatalf("Invalid policy file %s: %v", policyFile, err)
+					}
+					policyStorage := storage.NewAESEncryptedStorage(filepath.Join(vaultLocation, "policystorage.json"),
+						crypto.Keccak256([]byte("policystorage"), stretchedKey))
+					ui = rules.NewPolicyEvaluator(ui, policy, policyStorage, db)
+					log.Info("Policy engine configured", "file", policyFile)
+				}
+			}
+		}
 	}
 	var (
-		chainId  = c.GlobalInt64(chainIdFlag.Name)
-		ksLoc    = c.GlobalString(keystoreFlag.Name)
-		lightKdf = c.GlobalBool(utils.LightKDFFlag.Name)
-		advanced = c.GlobalBool(advancedMode.Name)
-		nousb    = c.GlobalBool(utils.NoUSBFlag.Name)
-		scpath   = c.GlobalString(utils.SmartCardDaemonPathFlag.Name)
+		chainId   = c.GlobalInt64(chainIdFlag.Name)
+		ksLoc     = c.GlobalString(keystoreFlag.Name)
+		lightKdf  = c.GlobalBool(utils.LightKDFFlag.Name)
+		advanced  = c.GlobalBool(advancedMode.Name)
+		nousb     = c.GlobalBool(utils.NoUSBFlag.Name)
+		scpath    = c.GlobalString(utils.SmartCardDaemonPathFlag.Name)
+		remoteURL = c.GlobalString(utils.RemoteSignerFlag.Name)
 	)
 	log.Info("Starting signer", "chainid", chainId, "keystore", ksLoc,
 		"light-kdf", lightKdf, "advanced", advanced)
 	am := core.StartClefAccountManager(ksLoc, nousb, lightKdf, scpath)
+	if remoteURL != "" {
+		backend, err := remote.NewHTTPBackend(remoteURL, nil)
+		if err != nil {
+			utils.Fatalf("Failed to connect to remote signer: %v", err)
+		}
+		am.AddBackend(backend)
+		log.Info("Using remote signer", "url", remoteURL)
+	}
 	apiImpl := core.NewSignerAPI(am, chainId, nousb, ui, db, advanced, pwStorage)
 
 	// Establish the bidirectional communication, by creating a new UI backend and registering
@@ -668,11 +774,25 @@ func signer(c *cli.Context) error {
 
 		// start http server
 		httpEndpoint := fmt.Sprintf("%s:%d", c.GlobalString(utils.HTTPListenAddrFlag.Name), port)
-		httpServer, addr, err := node.StartHTTPEndpoint(httpEndpoint, rpc.DefaultHTTPTimeouts, handler)
-		if err != nil {
-			utils.Fatalf("Could not start RPC api: %v", err)
+		var (
+			httpServer *http.Server
+			addr       net.Addr
+		)
+		if tlsConfig, err := serverTLSConfig(c); err != nil {
+			utils.Fatalf("Invalid TLS configuration: %v", err)
+		} else if tlsConfig != nil {
+			httpServer, addr, err = node.StartHTTPSEndpoint(httpEndpoint, rpc.DefaultHTTPTimeouts, handler, tlsConfig)
+			if err != nil {
+				utils.Fatalf("Could not start RPC api: %v", err)
+			}
+			extapiURL = fmt.Sprintf("https://%v/", addr)
+		} else {
+			httpServer, addr, err = node.StartHTTPEndpoint(httpEndpoint, rpc.DefaultHTTPTimeouts, handler)
+			if err != nil {
+				utils.Fatalf("Could not start RPC api: %v", err)
+			}
+			extapiURL = fmt.Sprintf("http://%v/", addr)
 		}
-		extapiURL = fmt.Sprintf("http://%v/", addr)
 		log.Info("HTTP endpoint opened", "url", extapiURL)
 
 		defer func() {
@@ -898,7 +1018,7 @@ func testExternalUI(api *core.SignerAPI) {
 		addr, _ := common.NewMixedcaseAddressFromString("0x0011223344556677889900112233445566778899")
 		data := `{"types":{"EIP712Domain":[{"name":"name","type":"string"},{"name":"version","type":"string"},{"name":"chainId","type":"uint256"},{"name":"verifyingContract","type":"address"}],"Person":[{"name":"name","type":"string"},{"name":"test","type":"uint8"},{"name":"wallet","type":"address"}],"Mail":[{"name":"from","type":"Person"},{"name":"to","type":"Person"},{"name":"contents","type":"string"}]},"primaryType":"Mail","domain":{"name":"Ether Mail","version":"1","chainId":"1","verifyingContract":"0xCCCcccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},"message":{"from":{"name":"Cow","test":"3","wallet":"0xcD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},"to":{"name":"Bob","wallet":"0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB","test":"2"},"contents":"Hello, Bob!"}}`
 		//_, err := api.SignData(ctx, accounts.MimetypeTypedData, *addr, hexutil.Encode([]byte(data)))
-		var typedData core.TypedData
+		var typedData apitypes.TypedData
 		json.Unmarshal([]byte(data), &typedData)
 		_, err := api.SignTypedData(ctx, *addr, typedData)
 		expectApprove("sign 712 typed data", err)
@@ -1025,7 +1145,7 @@ func GenDoc(ctx *cli.Context) {
 			"of the work in canonicalizing and making sense of the data, and it's up to the UI to present" +
 			"the user with the contents of the `message`"
 		sighash, msg := accounts.TextAndHash([]byte("hello world"))
-		messages := []*core.NameValueType{{Name: "message", Value: msg, Typ: accounts.MimetypeTextPlain}}
+		messages := []*apitypes.NameValueType{{Name: "message", Value: msg, Typ: accounts.MimetypeTextPlain}}
 
 		add("SignDataRequest", desc, &core.SignDataRequest{
 			Address:     common.NewMixedcaseAddress(a),
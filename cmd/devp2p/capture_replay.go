@@ -0,0 +1,247 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var ethMsgNames = map[uint64]string{
+	eth.StatusMsg:                     "Status",
+	eth.NewBlockHashesMsg:             "NewBlockHashes",
+	eth.TransactionsMsg:               "Transactions",
+	eth.GetBlockHeadersMsg:            "GetBlockHeaders",
+	eth.BlockHeadersMsg:               "BlockHeaders",
+	eth.GetBlockBodiesMsg:             "GetBlockBodies",
+	eth.BlockBodiesMsg:                "BlockBodies",
+	eth.NewBlockMsg:                   "NewBlock",
+	eth.NewPooledTransactionHashesMsg: "NewPooledTransactionHashes",
+	eth.GetPooledTransactionsMsg:      "GetPooledTransactions",
+	eth.PooledTransactionsMsg:         "PooledTransactions",
+	eth.GetNodeDataMsg:                "GetNodeData",
+	eth.NodeDataMsg:                   "NodeData",
+	eth.GetReceiptsMsg:                "GetReceipts",
+	eth.ReceiptsMsg:                   "Receipts",
+}
+
+// replayer feeds captured messages into the eth protocol handler. Requests are
+// served from the replayer's chain, all other packets passed to the backend are
+// only printed, they don't reach the fetchers, downloader or transaction pool.
+//
+// Replay is deterministic: the next message is delivered only after the handler
+// has finished processing the previous one, and all output of the handler is
+// consumed on the replay goroutine.
+type replayer struct {
+	out   io.Writer
+	chain *core.BlockChain
+}
+
+func newReplayer(out io.Writer, genesis *core.Genesis) (*replayer, error) {
+	db := rawdb.NewMemoryDatabase()
+	if _, err := genesis.Commit(db); err != nil {
+		return nil, err
+	}
+	// The state snapshot isn't needed for serving eth requests.
+	cacheConfig := &core.CacheConfig{TrieCleanLimit: 256, TrieDirtyLimit: 256, TrieTimeLimit: 5 * time.Minute}
+	chain, err := core.NewBlockChain(db, cacheConfig, genesis.Config, ethash.NewFaker(), vm.Config{}, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &replayer{out: out, chain: chain}, nil
+}
+
+func (r *replayer) close() {
+	r.chain.Stop()
+}
+
+// importChain inserts the blocks of an RLP file into the chain.
+func (r *replayer) importChain(file string) error {
+	fd, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	var (
+		stream = rlp.NewStream(bufio.NewReader(fd), 0)
+		blocks []*types.Block
+	)
+	for {
+		var b types.Block
+		if err := stream.Decode(&b); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("%s: block %d: %v", file, len(blocks), err)
+		}
+		if b.NumberU64() == 0 {
+			continue // skip genesis
+		}
+		blocks = append(blocks, &b)
+	}
+	if n, err := r.chain.InsertChain(blocks); err != nil {
+		return fmt.Errorf("can't import block %d: %v", blocks[n].NumberU64(), err)
+	}
+	fmt.Fprintf(r.out, "Imported %d blocks, head %d\n", len(blocks), r.chain.CurrentBlock().NumberU64())
+	return nil
+}
+
+// replay runs the handler with the inbound messages of msgs.
+func (r *replayer) replay(msgs []*p2p.CapturedMsg) error {
+	var (
+		version     = msgs[0].Version
+		caps        = []p2p.Cap{{Name: eth.ProtocolName, Version: version}}
+		peer        = p2p.NewPeer(msgs[0].Peer, "replay", caps)
+		local, pipe = p2p.MsgPipe()
+		s           = &replaySession{
+			replayer:   r,
+			ready:      make(chan struct{}),
+			responses:  make(chan p2p.Msg),
+			handlerErr: make(chan error, 1),
+			quit:       make(chan struct{}),
+		}
+	)
+	defer local.Close()
+	defer close(s.quit)
+
+	ethPeer := eth.NewPeer(version, peer, &replayRW{pipe, s}, replayTxPool{})
+	defer ethPeer.Close()
+	go s.readResponses(local)
+	go func() {
+		s.handlerErr <- eth.Handle(s, ethPeer)
+	}()
+
+	delivered := -1
+	for i, msg := range msgs {
+		if !msg.Inbound || msg.Code == eth.StatusMsg {
+			continue
+		}
+		if err := s.waitReady(); err != nil {
+			return fmt.Errorf("handler failed on message %d: %v", delivered, err)
+		}
+		fmt.Fprintf(r.out, "%d: >> %s (%d bytes)\n", i, captureMsgName(msg.Protocol, msg.Code), len(msg.Payload))
+		err := local.WriteMsg(p2p.Msg{Code: msg.Code, Size: uint32(len(msg.Payload)), Payload: bytes.NewReader(msg.Payload)})
+		if err != nil {
+			return fmt.Errorf("can't deliver message %d: %v", i, err)
+		}
+		delivered = i
+	}
+	if err := s.waitReady(); err != nil {
+		return fmt.Errorf("handler failed on message %d: %v", delivered, err)
+	}
+	fmt.Fprintln(r.out, "Replay complete")
+	return nil
+}
+
+// replaySession is the state of a single replay. It implements eth.Backend.
+type replaySession struct {
+	*replayer
+	ready      chan struct{} // handler is ready for the next message
+	responses  chan p2p.Msg  // messages sent by the handler
+	handlerErr chan error
+	quit       chan struct{}
+}
+
+// waitReady prints handler responses until the handler is ready to read the next
+// message.
+func (s *replaySession) waitReady() error {
+	for {
+		select {
+		case msg := <-s.responses:
+			payload, err := ioutil.ReadAll(msg.Payload)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(s.out, "   << %s (%d bytes)\n", captureMsgName(eth.ProtocolName, msg.Code), len(payload))
+		case <-s.ready:
+			return nil
+		case err := <-s.handlerErr:
+			return err
+		}
+	}
+}
+
+// readResponses forwards messages written by the handler. The payload is not
+// read here, so the handler's write doesn't complete before waitReady has
+// seen the message.
+func (s *replaySession) readResponses(rw p2p.MsgReader) {
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return
+		}
+		select {
+		case s.responses <- msg:
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+func (s *replaySession) Chain() *core.BlockChain          { return s.chain }
+func (s *replaySession) StateBloom() *trie.SyncBloom      { return nil }
+func (s *replaySession) TxPool() eth.TxPool               { return replayTxPool{} }
+func (s *replaySession) AcceptTxs() bool                  { return true }
+func (s *replaySession) PeerInfo(id enode.ID) interface{} { return nil }
+
+func (s *replaySession) RunPeer(peer *eth.Peer, handler eth.Handler) error {
+	return handler(peer)
+}
+
+// Handle is called for packets which the protocol handler passes to the backend.
+// They are not processed any further.
+func (s *replaySession) Handle(peer *eth.Peer, packet eth.Packet) error {
+	fmt.Fprintf(s.out, "   handled %s\n", packet.Name())
+	return nil
+}
+
+// replayRW signals the session when the handler reads the next message.
+type replayRW struct {
+	p2p.MsgReadWriter
+	s *replaySession
+}
+
+func (rw *replayRW) ReadMsg() (p2p.Msg, error) {
+	select {
+	case rw.s.ready <- struct{}{}:
+	case <-rw.s.quit:
+		return p2p.Msg{}, p2p.ErrPipeClosed
+	}
+	return rw.MsgReadWriter.ReadMsg()
+}
+
+// replayTxPool is an empty transaction pool.
+type replayTxPool struct{}
+
+func (replayTxPool) Get(hash common.Hash) *types.Transaction { return nil }
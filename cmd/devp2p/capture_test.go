@@ -0,0 +1,143 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func writeTestCapture(t *testing.T, file string, msgs []*p2p.CapturedMsg) {
+	c, err := p2p.NewMsgCapture(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, msg := range msgs {
+		if err := c.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func testCapturedMsg(t *testing.T, peer enode.ID, inbound bool, code uint64, val interface{}) *p2p.CapturedMsg {
+	payload, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &p2p.CapturedMsg{Peer: peer, Protocol: "eth", Version: eth.ETH66, Inbound: inbound, Code: code, Payload: payload}
+}
+
+func testReplayGenesis() *core.Genesis {
+	return &core.Genesis{Config: params.TestChainConfig}
+}
+
+func TestCaptureReplay(t *testing.T) {
+	var (
+		file = filepath.Join(t.TempDir(), "capture")
+		peer = enode.ID{1}
+		req  = &eth.GetBlockHeadersPacket66{RequestId: 1, GetBlockHeadersPacket: &eth.GetBlockHeadersPacket{Amount: 1}}
+	)
+	writeTestCapture(t, file, []*p2p.CapturedMsg{
+		testCapturedMsg(t, peer, true, eth.StatusMsg, []uint{1}), // skipped
+		testCapturedMsg(t, peer, true, eth.GetBlockHeadersMsg, req),
+		testCapturedMsg(t, peer, false, eth.BlockHeadersMsg, []uint{}), // outbound, skipped
+		testCapturedMsg(t, peer, true, eth.NewPooledTransactionHashesMsg, []common.Hash{{1}}),
+	})
+	msgs, err := loadCapture(file, "", "eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replay twice to check that the output is deterministic.
+	var outputs []string
+	for i := 0; i < 2; i++ {
+		out := new(bytes.Buffer)
+		r, err := newReplayer(out, testReplayGenesis())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.replay(msgs)
+		r.close()
+		if err != nil {
+			t.Fatal("replay error:", err)
+		}
+		outputs = append(outputs, out.String())
+	}
+	want := `1: >> GetBlockHeaders (7 bytes)
+   << BlockHeaders (514 bytes)
+3: >> NewPooledTransactionHashes (34 bytes)
+   handled NewPooledTransactionHashes
+Replay complete
+`
+	for _, out := range outputs {
+		if out != want {
+			t.Errorf("wrong output:\n%s\nwant:\n%s", out, want)
+		}
+	}
+}
+
+func TestCaptureReplayHandlerError(t *testing.T) {
+	var (
+		file = filepath.Join(t.TempDir(), "capture")
+		peer = enode.ID{1}
+	)
+	writeTestCapture(t, file, []*p2p.CapturedMsg{
+		testCapturedMsg(t, peer, true, eth.NewPooledTransactionHashesMsg, []common.Hash{{1}}),
+		testCapturedMsg(t, peer, true, eth.GetBlockHeadersMsg, "invalid"),
+		testCapturedMsg(t, peer, true, eth.NewPooledTransactionHashesMsg, []common.Hash{{2}}),
+	})
+	msgs, err := loadCapture(file, peer.String(), "eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newReplayer(new(bytes.Buffer), testReplayGenesis())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.close()
+	err = r.replay(msgs)
+	if err == nil || !strings.HasPrefix(err.Error(), "handler failed on message 1:") {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+func TestLoadCapturePeerFilter(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture")
+	writeTestCapture(t, file, []*p2p.CapturedMsg{
+		testCapturedMsg(t, enode.ID{1}, true, eth.TransactionsMsg, []uint{}),
+		testCapturedMsg(t, enode.ID{2}, true, eth.TransactionsMsg, []uint{}),
+		{Peer: enode.ID{2}, Protocol: "snap", Version: 1, Inbound: true, Code: 0},
+	})
+	msgs, err := loadCapture(file, enode.ID{2}.String(), "eth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 1 || msgs[0].Peer != (enode.ID{2}) || msgs[0].Protocol != "eth" {
+		t.Fatalf("wrong messages after filtering: %+v", msgs)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	captureCommand = cli.Command{
+		Name:  "capture",
+		Usage: "Tools for message captures (geth --netcapture)",
+		Subcommands: []cli.Command{
+			captureDumpCommand,
+			captureReplayCommand,
+		},
+	}
+	captureDumpCommand = cli.Command{
+		Name:      "dump",
+		Usage:     "Prints the messages of a capture",
+		Action:    captureDump,
+		ArgsUsage: "<capture-file>",
+		Flags:     []cli.Flag{capturePeerFlag},
+	}
+	captureReplayCommand = cli.Command{
+		Name:      "replay",
+		Usage:     "Feeds the requests of a peer back into the eth protocol request handlers",
+		Action:    captureReplay,
+		ArgsUsage: "<capture-file>",
+		Flags: []cli.Flag{
+			capturePeerFlag,
+			captureGenesisFlag,
+			captureChainFlag,
+		},
+	}
+)
+
+var (
+	capturePeerFlag = cli.StringFlag{
+		Name:  "peer",
+		Usage: "Only use messages of the peer with the given node ID",
+	}
+	captureGenesisFlag = cli.StringFlag{
+		Name:  "genesis",
+		Usage: "Genesis JSON file of the chain (default = mainnet genesis)",
+	}
+	captureChainFlag = cli.StringFlag{
+		Name:  "chain",
+		Usage: "RLP file of blocks to import before replaying",
+	}
+)
+
+func captureDump(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need capture file as argument")
+	}
+	msgs, err := loadCapture(ctx.Args().First(), ctx.String(capturePeerFlag.Name), "")
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		dir := "<<"
+		if msg.Inbound {
+			dir = ">>"
+		}
+		fmt.Printf("%s %s %s %s/%d %s (%d bytes)\n",
+			time.Unix(0, int64(msg.Time)).UTC().Format("2006-01-02 15:04:05.000000"),
+			msg.Peer.TerminalString(), dir, msg.Protocol, msg.Version,
+			captureMsgName(msg.Protocol, msg.Code), len(msg.Payload))
+	}
+	return nil
+}
+
+func captureReplay(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need capture file as argument")
+	}
+	msgs, err := loadCapture(ctx.Args().First(), ctx.String(capturePeerFlag.Name), "eth")
+	if err != nil {
+		return err
+	}
+	if len(msgs) == 0 {
+		return fmt.Errorf("no eth messages in capture")
+	}
+	for _, msg := range msgs[1:] {
+		if msg.Peer != msgs[0].Peer {
+			return fmt.Errorf("capture contains messages of multiple peers, select one using --%s", capturePeerFlag.Name)
+		}
+	}
+
+	genesis := core.DefaultGenesisBlock()
+	if file := ctx.String(captureGenesisFlag.Name); file != "" {
+		if genesis, err = loadGenesisFile(file); err != nil {
+			return err
+		}
+	}
+	r, err := newReplayer(os.Stdout, genesis)
+	if err != nil {
+		return err
+	}
+	defer r.close()
+	if file := ctx.String(captureChainFlag.Name); file != "" {
+		if err := r.importChain(file); err != nil {
+			return err
+		}
+	}
+	return r.replay(msgs)
+}
+
+// loadCapture reads all messages of a capture, including the rotated files.
+// Messages can be filtered by peer ID and protocol name.
+func loadCapture(file string, peer string, protocol string) ([]*p2p.CapturedMsg, error) {
+	var peerID enode.ID
+	if peer != "" {
+		id, err := enode.ParseID(peer)
+		if err != nil {
+			return nil, fmt.Errorf("invalid peer ID: %v", err)
+		}
+		peerID = id
+	}
+	files := p2p.MsgCaptureFiles(file)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("capture file %s does not exist", file)
+	}
+	var msgs []*p2p.CapturedMsg
+	for _, file := range files {
+		fd, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		r := p2p.NewMsgCaptureReader(fd)
+		for {
+			msg, err := r.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				fd.Close()
+				return nil, fmt.Errorf("%s: %v", file, err)
+			}
+			if peer != "" && msg.Peer != peerID {
+				continue
+			}
+			if protocol != "" && msg.Protocol != protocol {
+				continue
+			}
+			msgs = append(msgs, msg)
+		}
+		fd.Close()
+	}
+	return msgs, nil
+}
+
+func loadGenesisFile(file string) (*core.Genesis, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	genesis := new(core.Genesis)
+	if err := json.Unmarshal(data, genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %v", err)
+	}
+	if genesis.Config == nil {
+		return nil, errors.New("genesis has no chain configuration")
+	}
+	return genesis, nil
+}
+
+// captureMsgName returns a readable name of a message code.
+func captureMsgName(protocol string, code uint64) string {
+	if protocol == "eth" {
+		if name, ok := ethMsgNames[code]; ok {
+			return name
+		}
+	}
+	return fmt.Sprintf("0x%02x", code)
+}
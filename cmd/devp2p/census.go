@@ -0,0 +1,420 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/ethtest"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	censusHistoryLimit = 64 // number of checks kept per node
+	censusQueueLimit   = 1024
+	censusDialTimeout  = 5 * time.Second
+	censusReadTimeout  = 10 * time.Second
+)
+
+// censusSet is the census.json file format. It holds the results of RLPx
+// connection attempts to nodes found by the crawler.
+type censusSet map[enode.ID]censusJSON
+
+type censusJSON struct {
+	N *enode.Node `json:"record"`
+
+	// Information sent by the node during the last successful handshake.
+	Hello  *censusHello  `json:"hello,omitempty"`
+	Status *censusStatus `json:"status,omitempty"`
+
+	// These track the time of successful RLPx handshakes.
+	FirstResponse time.Time `json:"firstResponse,omitempty"`
+	LastResponse  time.Time `json:"lastResponse,omitempty"`
+	// Checks counts all connection attempts, Responses counts the successful ones.
+	Checks    int `json:"checks"`
+	Responses int `json:"responses"`
+	// History contains the most recent connection attempts, oldest first.
+	History []censusCheck `json:"history,omitempty"`
+}
+
+// censusHello contains the information of the devp2p Hello message.
+type censusHello struct {
+	Name          string   `json:"name"`
+	Client        string   `json:"client"`
+	ClientVersion string   `json:"clientVersion,omitempty"`
+	Version       uint64   `json:"version"`
+	Caps          []string `json:"caps"`
+}
+
+// censusStatus contains the information of the eth Status message.
+type censusStatus struct {
+	ProtocolVersion uint32         `json:"protocolVersion"`
+	NetworkID       uint64         `json:"networkID"`
+	TD              *hexutil.Big   `json:"td"`
+	Head            common.Hash    `json:"head"`
+	Genesis         common.Hash    `json:"genesis"`
+	ForkHash        hexutil.Bytes  `json:"forkHash"`
+	ForkNext        hexutil.Uint64 `json:"forkNext"`
+}
+
+// censusCheck is a connection attempt. The node is reachable if the RLPx
+// handshake succeeded, even if the node disconnected afterwards.
+type censusCheck struct {
+	Time      time.Time `json:"time"`
+	Reachable bool      `json:"reachable"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func loadCensusJSON(file string) censusSet {
+	var set censusSet
+	if err := common.LoadJSON(file, &set); err != nil {
+		exit(err)
+	}
+	return set
+}
+
+func writeCensusJSON(file string, set censusSet) {
+	setJSON, err := json.MarshalIndent(set, "", jsonIndent)
+	if err != nil {
+		exit(err)
+	}
+	if file == "-" {
+		os.Stdout.Write(setJSON)
+		return
+	}
+	if err := ioutil.WriteFile(file, setJSON, 0644); err != nil {
+		exit(err)
+	}
+}
+
+// reachedSince reports whether the node responded after the given time.
+func (c *censusJSON) reachedSince(t time.Time) bool {
+	return !c.LastResponse.IsZero() && !c.LastResponse.Before(t)
+}
+
+// census connects to nodes over RLPx and records the result in a censusSet.
+type census struct {
+	key     *ecdsa.PrivateKey
+	queue   chan *enode.Node
+	wg      sync.WaitGroup
+	probe   func(*ecdsa.PrivateKey, *enode.Node) probeResult
+	mu      sync.Mutex
+	set     censusSet
+	pending map[enode.ID]struct{}
+
+	// settings
+	revalidateInterval time.Duration
+}
+
+func newCensus(set censusSet, workers int) *census {
+	if set == nil {
+		set = make(censusSet)
+	}
+	key, _ := crypto.GenerateKey()
+	c := &census{
+		key:     key,
+		queue:   make(chan *enode.Node, censusQueueLimit),
+		probe:   probeNode,
+		set:     set,
+		pending: make(map[enode.ID]struct{}),
+	}
+	c.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go c.worker()
+	}
+	return c
+}
+
+// add schedules a check of the given node. Nodes which were checked recently,
+// and nodes without a TCP endpoint are skipped.
+func (c *census) add(n *enode.Node) {
+	if n.IP() == nil || n.TCP() == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.pending[n.ID()]; ok {
+		return
+	}
+	if e, ok := c.set[n.ID()]; ok && len(e.History) > 0 {
+		if time.Since(e.History[len(e.History)-1].Time) < c.revalidateInterval {
+			return
+		}
+	}
+	select {
+	case c.queue <- n:
+		c.pending[n.ID()] = struct{}{}
+	default:
+		log.Debug("Census queue full, skipping node", "id", n.ID())
+	}
+}
+
+// wait waits for all scheduled checks and returns the result. The census can't be
+// used anymore after calling wait.
+func (c *census) wait() censusSet {
+	close(c.queue)
+	c.wg.Wait()
+	return c.set
+}
+
+func (c *census) worker() {
+	defer c.wg.Done()
+	for n := range c.queue {
+		res := c.probe(c.key, n)
+		c.update(n, res)
+	}
+}
+
+func (c *census) update(n *enode.Node, res probeResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, n.ID())
+	e := c.set[n.ID()]
+	if e.N == nil || n.Seq() >= e.N.Seq() {
+		e.N = n
+	}
+	check := censusCheck{Time: truncNow(), Reachable: res.reachable}
+	e.Checks++
+	if res.err != nil {
+		check.Error = res.err.Error()
+	}
+	if res.reachable {
+		e.Responses++
+		if e.FirstResponse.IsZero() {
+			e.FirstResponse = check.Time
+		}
+		e.LastResponse = check.Time
+	}
+	if res.hello != nil {
+		e.Hello = res.hello
+	}
+	if res.status != nil {
+		e.Status = res.status
+	}
+	e.History = append(e.History, check)
+	if len(e.History) > censusHistoryLimit {
+		e.History = append(e.History[:0], e.History[len(e.History)-censusHistoryLimit:]...)
+	}
+	c.set[n.ID()] = e
+
+	if res.err != nil {
+		log.Debug("Census check failed", "id", n.ID(), "reachable", res.reachable, "err", res.err)
+	} else {
+		log.Info("Census check done", "id", n.ID(), "name", res.hello.Name, "network", res.status.NetworkID)
+	}
+}
+
+// probeResult is the outcome of a connection attempt.
+type probeResult struct {
+	reachable bool
+	hello     *censusHello
+	status    *censusStatus
+	err       error
+}
+
+// baseProtocolLength is the number of message codes reserved for the devp2p base
+// protocol. The message codes of subprotocols follow it.
+const baseProtocolLength = 16
+
+// Local capabilities announced by the census, all supported eth versions.
+var censusCaps = func() []p2p.Cap {
+	caps := make([]p2p.Cap, 0, len(eth.ProtocolVersions))
+	for _, version := range eth.ProtocolVersions {
+		caps = append(caps, p2p.Cap{Name: eth.ProtocolName, Version: version})
+	}
+	return caps
+}()
+
+// censusProtoLengths are the number of message codes of the announced protocols.
+var censusProtoLengths = map[string]uint64{eth.ProtocolName: 17}
+
+// matchEthCap negotiates the eth protocol with a node announcing the given
+// capabilities, returning the offset of its message codes. Like the p2p server,
+// the highest version of each shared protocol is used, and the shared protocols
+// are assigned message codes in the order of their names.
+func matchEthCap(caps []p2p.Cap) (offset uint64, ok bool) {
+	shared := make(map[string]uint)
+	for _, ours := range censusCaps {
+		for _, theirs := range caps {
+			if ours == theirs && ours.Version >= shared[ours.Name] {
+				shared[ours.Name] = ours.Version
+			}
+		}
+	}
+	names := make([]string, 0, len(shared))
+	for name := range shared {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	offset = baseProtocolLength
+	for _, name := range names {
+		if name == eth.ProtocolName {
+			return offset, true
+		}
+		offset += censusProtoLengths[name]
+	}
+	return 0, false
+}
+
+// probeNode connects to a node, performs the RLPx and devp2p handshakes and waits
+// for the eth Status message.
+func probeNode(key *ecdsa.PrivateKey, n *enode.Node) (res probeResult) {
+	addr := &net.TCPAddr{IP: n.IP(), Port: n.TCP()}
+	fd, err := net.DialTimeout("tcp", addr.String(), censusDialTimeout)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	conn := rlpx.NewConn(fd, n.Pubkey())
+	defer conn.Close()
+
+	conn.SetDeadline(time.Now().Add(censusReadTimeout))
+	if _, err := conn.Handshake(key); err != nil {
+		res.err = fmt.Errorf("RLPx handshake failed: %v", err)
+		return res
+	}
+	res.reachable = true
+
+	ourHello := &ethtest.Hello{
+		Version: 5,
+		Name:    "devp2p-census",
+		Caps:    censusCaps,
+		ID:      crypto.FromECDSAPub(&key.PublicKey)[1:],
+	}
+	if err := writeCensusMsg(conn, 0x00, ourHello); err != nil {
+		res.err = err
+		return res
+	}
+	var (
+		ethOffset uint64
+		ok        bool
+	)
+	for {
+		code, data, _, err := conn.Read()
+		if err != nil {
+			res.err = err
+			return res
+		}
+		switch {
+		case code == 0x00 && res.hello == nil:
+			var h ethtest.Hello
+			if err := rlp.DecodeBytes(data, &h); err != nil {
+				res.err = fmt.Errorf("invalid hello: %v", err)
+				return res
+			}
+			res.hello = newCensusHello(&h)
+			if h.Version >= 5 {
+				conn.SetSnappy(true)
+			}
+			if ethOffset, ok = matchEthCap(h.Caps); !ok {
+				res.err = errors.New("no matching eth protocol version")
+				return res
+			}
+		case code == 0x01:
+			var reason []p2p.DiscReason
+			if rlp.DecodeBytes(data, &reason); len(reason) == 0 {
+				res.err = errors.New("invalid disconnect message")
+			} else {
+				res.err = fmt.Errorf("disconnected: %v", reason[0])
+			}
+			return res
+		case code == 0x02:
+			writeCensusMsg(conn, 0x03, []interface{}{})
+		case res.hello != nil && code == ethOffset+eth.StatusMsg:
+			var s eth.StatusPacket
+			if err := rlp.DecodeBytes(data, &s); err != nil {
+				res.err = fmt.Errorf("invalid status: %v", err)
+				return res
+			}
+			res.status = newCensusStatus(&s)
+			writeCensusMsg(conn, 0x01, []p2p.DiscReason{p2p.DiscQuitting})
+			return res
+		}
+	}
+}
+
+func writeCensusMsg(conn *rlpx.Conn, code uint64, msg interface{}) error {
+	data, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Write(code, data)
+	return err
+}
+
+func newCensusHello(h *ethtest.Hello) *censusHello {
+	ch := &censusHello{Name: h.Name, Version: h.Version}
+	ch.Client, ch.ClientVersion = parseClientName(h.Name)
+	for _, c := range h.Caps {
+		ch.Caps = append(ch.Caps, c.String())
+	}
+	return ch
+}
+
+func newCensusStatus(s *eth.StatusPacket) *censusStatus {
+	td := s.TD
+	if td == nil {
+		td = new(big.Int)
+	}
+	return &censusStatus{
+		ProtocolVersion: s.ProtocolVersion,
+		NetworkID:       s.NetworkID,
+		TD:              (*hexutil.Big)(td),
+		Head:            s.Head,
+		Genesis:         s.Genesis,
+		ForkHash:        s.ForkID.Hash[:],
+		ForkNext:        hexutil.Uint64(s.ForkID.Next),
+	}
+}
+
+// parseClientName splits the client identifier of the Hello message, which is
+// usually of the form "Geth/v1.10.13-stable-7a0c19f8/linux-amd64/go1.17.2",
+// into the lowercase client name and version. Nodes may add a custom identity
+// after the client name, so the version is the first element after the name
+// which looks like a version number.
+func parseClientName(name string) (client, version string) {
+	parts := strings.Split(name, "/")
+	client = strings.ToLower(parts[0])
+	for _, p := range parts[1:] {
+		if len(p) > 1 && (p[0] == 'v' || p[0] == 'V') && p[1] >= '0' && p[1] <= '9' {
+			return client, p
+		}
+	}
+	return client, ""
+}
@@ -0,0 +1,191 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+func TestCensusProbe(t *testing.T) {
+	status := eth.StatusPacket{
+		ProtocolVersion: 66,
+		NetworkID:       1,
+		TD:              big.NewInt(1000),
+		Head:            params.MainnetGenesisHash,
+		Genesis:         params.MainnetGenesisHash,
+		ForkID:          forkid.NewID(params.MainnetChainConfig, params.MainnetGenesisHash, 0),
+	}
+	srv := startCensusTestServer(t, status)
+	defer srv.Stop()
+
+	c := newCensus(nil, 1)
+	c.add(srv.Self())
+	set := c.wait()
+
+	e := set[srv.Self().ID()]
+	if e.Checks != 1 || e.Responses != 1 || len(e.History) != 1 {
+		t.Fatalf("wrong check counts: %+v", e)
+	}
+	if e.History[0].Error != "" {
+		t.Fatalf("check failed: %s", e.History[0].Error)
+	}
+	wantHello := &censusHello{
+		Name:          "Geth/test/v1.10.13-stable/linux-amd64/go1.17",
+		Client:        "geth",
+		ClientVersion: "v1.10.13-stable",
+		Version:       5,
+		Caps:          []string{"eth/66"},
+	}
+	if !reflect.DeepEqual(e.Hello, wantHello) {
+		t.Errorf("wrong hello: %+v", e.Hello)
+	}
+	wantStatus := &censusStatus{
+		ProtocolVersion: 66,
+		NetworkID:       1,
+		TD:              (*hexutil.Big)(big.NewInt(1000)),
+		Head:            params.MainnetGenesisHash,
+		Genesis:         params.MainnetGenesisHash,
+		ForkHash:        status.ForkID.Hash[:],
+		ForkNext:        hexutil.Uint64(status.ForkID.Next),
+	}
+	if !reflect.DeepEqual(e.Status, wantStatus) {
+		t.Errorf("wrong status: %+v", e.Status)
+	}
+}
+
+// startCensusTestServer starts a server which sends the given status to all peers.
+func startCensusTestServer(t *testing.T, status eth.StatusPacket) *p2p.Server {
+	key, _ := crypto.GenerateKey()
+	srv := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    10,
+		NoDiscovery: true,
+		ListenAddr:  "127.0.0.1:0",
+		Name:        "Geth/test/v1.10.13-stable/linux-amd64/go1.17",
+		Protocols: []p2p.Protocol{{
+			Name:    "eth",
+			Version: uint(status.ProtocolVersion),
+			Length:  17,
+			Run: func(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+				if err := p2p.Send(rw, eth.StatusMsg, &status); err != nil {
+					return err
+				}
+				_, err := rw.ReadMsg()
+				return err
+			},
+		}},
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+// Tests that the eth protocol is negotiated like the p2p server does, using the
+// highest shared version and skipping the message codes of preceding protocols.
+func TestMatchEthCap(t *testing.T) {
+	tests := []struct {
+		caps   []p2p.Cap
+		offset uint64
+		ok     bool
+	}{
+		{[]p2p.Cap{{Name: "eth", Version: 66}}, 16, true},
+		{[]p2p.Cap{{Name: "eth", Version: 66}, {Name: "eth", Version: 68}}, 16, true},
+		{[]p2p.Cap{{Name: "abc", Version: 1}, {Name: "eth", Version: 68}}, 16, true},
+		{[]p2p.Cap{{Name: "eth", Version: 65}}, 0, false},
+		{[]p2p.Cap{{Name: "snap", Version: 1}}, 0, false},
+	}
+	for i, test := range tests {
+		offset, ok := matchEthCap(test.caps)
+		if offset != test.offset || ok != test.ok {
+			t.Errorf("test %d: have (%d, %v), want (%d, %v)", i, offset, ok, test.offset, test.ok)
+		}
+	}
+}
+
+// Tests that nodes running eth/68 can be probed.
+func TestCensusProbeETH68(t *testing.T) {
+	status := eth.StatusPacket{
+		ProtocolVersion: 68,
+		NetworkID:       1,
+		TD:              big.NewInt(1000),
+		Head:            params.MainnetGenesisHash,
+		Genesis:         params.MainnetGenesisHash,
+		ForkID:          forkid.NewID(params.MainnetChainConfig, params.MainnetGenesisHash, 0),
+	}
+	srv := startCensusTestServer(t, status)
+	defer srv.Stop()
+
+	key, _ := crypto.GenerateKey()
+	res := probeNode(key, srv.Self())
+	if res.err != nil {
+		t.Fatalf("probe failed: %v", res.err)
+	}
+	if res.status == nil || res.status.ProtocolVersion != 68 {
+		t.Fatalf("wrong status: %+v", res.status)
+	}
+}
+
+func TestCensusProbeUnreachable(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	n := enode.NewV4(&key.PublicKey, []byte{127, 0, 0, 1}, 1, 0)
+
+	res := probeNode(key, n)
+	if res.reachable || res.err == nil {
+		t.Fatalf("unreachable node reported as reachable (err %v)", res.err)
+	}
+}
+
+func TestParseClientName(t *testing.T) {
+	tests := []struct {
+		name, client, version string
+	}{
+		{"Geth/v1.10.13-stable-7a0c19f8/linux-amd64/go1.17.2", "geth", "v1.10.13-stable-7a0c19f8"},
+		{"Geth/mynode/v1.10.12-stable/linux-amd64/go1.17", "geth", "v1.10.12-stable"},
+		{"Nethermind/v1.11.7-0-1e2a2bf8b-20211117/X64-Linux/5.0.8", "nethermind", "v1.11.7-0-1e2a2bf8b-20211117"},
+		{"erigon/v2021.11.2-beta-1d2b9e29/linux-amd64/go1.16.3", "erigon", "v2021.11.2-beta-1d2b9e29"},
+		{"custom", "custom", ""},
+	}
+	for _, test := range tests {
+		client, version := parseClientName(test.name)
+		if client != test.client || version != test.version {
+			t.Errorf("%q: got %q %q, want %q %q", test.name, client, version, test.client, test.version)
+		}
+	}
+}
+
+func TestForkName(t *testing.T) {
+	config, genesis := params.MainnetChainConfig, params.MainnetGenesisHash
+	london := config.LondonBlock.Uint64()
+	id := forkid.NewID(config, genesis, london)
+	s := &censusStatus{Genesis: genesis, ForkHash: id.Hash[:], ForkNext: hexutil.Uint64(id.Next)}
+
+	want := "0xb715077d next=13773000 mainnet: London, next ArrowGlacier"
+	if name := forkName(s); name != want {
+		t.Errorf("wrong fork name %q, want %q", name, want)
+	}
+}
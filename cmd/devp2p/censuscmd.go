@@ -0,0 +1,348 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	censusCommand = cli.Command{
+		Name:  "census",
+		Usage: "Network census tools",
+		Subcommands: []cli.Command{
+			censusProbeCommand,
+			censusReportCommand,
+		},
+	}
+	censusProbeCommand = cli.Command{
+		Name:      "probe",
+		Usage:     "Connects to all nodes of a node set and updates a census file",
+		Action:    censusProbe,
+		ArgsUsage: "<nodes.json> <census.json>",
+		Flags:     []cli.Flag{censusWorkersFlag},
+	}
+	censusReportCommand = cli.Command{
+		Name:      "report",
+		Usage:     "Shows client and fork statistics of a census",
+		Action:    censusReport,
+		ArgsUsage: "<census.json>",
+		Flags:     []cli.Flag{censusMaxAgeFlag, censusTopFlag},
+	}
+)
+
+var (
+	censusFileFlag = cli.StringFlag{
+		Name:  "census",
+		Usage: "Census file to update by connecting to crawled nodes over RLPx",
+	}
+	censusWorkersFlag = cli.IntFlag{
+		Name:  "census-workers",
+		Usage: "Number of concurrent RLPx connection attempts",
+		Value: 16,
+	}
+	censusMaxAgeFlag = cli.DurationFlag{
+		Name:  "max-age",
+		Usage: "Only include nodes which responded within this time",
+		Value: 24 * time.Hour,
+	}
+	censusTopFlag = cli.IntFlag{
+		Name:  "top",
+		Usage: "Maximum number of entries in each table",
+		Value: 20,
+	}
+)
+
+// startCensus creates the census of a crawl if requested on the command line.
+func startCensus(ctx *cli.Context) *census {
+	file := ctx.String(censusFileFlag.Name)
+	if file == "" {
+		return nil
+	}
+	var set censusSet
+	if common.FileExist(file) {
+		set = loadCensusJSON(file)
+	}
+	c := newCensus(set, ctx.Int(censusWorkersFlag.Name))
+	c.revalidateInterval = 10 * time.Minute
+	return c
+}
+
+// finishCensus waits for the census of a crawl and writes the census file.
+func finishCensus(ctx *cli.Context, c *census) {
+	if c == nil {
+		return
+	}
+	writeCensusJSON(ctx.String(censusFileFlag.Name), c.wait())
+}
+
+func censusProbe(ctx *cli.Context) error {
+	if ctx.NArg() < 2 {
+		return fmt.Errorf("need nodes file and census file as arguments")
+	}
+	ns := loadNodesJSON(ctx.Args().Get(0))
+	censusFile := ctx.Args().Get(1)
+	var set censusSet
+	if common.FileExist(censusFile) {
+		set = loadCensusJSON(censusFile)
+	}
+
+	c := newCensus(set, ctx.Int(censusWorkersFlag.Name))
+	for _, n := range ns.nodes() {
+		if n.IP() != nil && n.TCP() != 0 {
+			c.queue <- n
+		}
+	}
+	writeCensusJSON(censusFile, c.wait())
+	return nil
+}
+
+func censusReport(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need census file as argument")
+	}
+	var (
+		set    = loadCensusJSON(ctx.Args().First())
+		since  = time.Now().Add(-ctx.Duration(censusMaxAgeFlag.Name))
+		top    = ctx.Int(censusTopFlag.Name)
+		active []censusJSON
+	)
+	for _, e := range set {
+		if e.reachedSince(since) {
+			active = append(active, e)
+		}
+	}
+	fmt.Printf("Census contains %d nodes, %d responded within %v.\n", len(set), len(active), ctx.Duration(censusMaxAgeFlag.Name))
+
+	var (
+		clients   = make(counter)
+		versions  = make(counter)
+		caps      = make(counter)
+		networks  = make(counter)
+		forks     = make(counter)
+		nohello   int
+		nostatus  int
+		reachable = make(counter)
+	)
+	for _, e := range set {
+		reachable.add(reachabilityClass(e))
+	}
+	for _, e := range active {
+		if e.Hello == nil {
+			nohello++
+		} else {
+			clients.add(e.Hello.Client)
+			versions.add(strings.TrimSpace(e.Hello.Client + " " + e.Hello.ClientVersion))
+			for _, c := range e.Hello.Caps {
+				caps.add(c)
+			}
+		}
+		if e.Status == nil {
+			nostatus++
+		} else {
+			networks.add(networkName(e.Status))
+			forks.add(forkName(e.Status))
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Clients (%d nodes without hello):\n", nohello)
+	clients.print(top)
+	fmt.Println()
+	fmt.Println("Client versions:")
+	versions.print(top)
+	fmt.Println()
+	fmt.Println("Capabilities:")
+	caps.print(top)
+	fmt.Println()
+	fmt.Printf("Networks (%d nodes without status):\n", nostatus)
+	networks.print(top)
+	fmt.Println()
+	fmt.Println("Fork IDs:")
+	forks.print(top)
+	fmt.Println()
+	fmt.Println("Reachability of all nodes:")
+	reachable.print(0)
+	return nil
+}
+
+// reachabilityClass classifies nodes by the share of successful connection attempts.
+func reachabilityClass(e censusJSON) string {
+	var ok int
+	for _, c := range e.History {
+		if c.Reachable {
+			ok++
+		}
+	}
+	switch {
+	case len(e.History) == 0 || e.Responses == 0:
+		return "never"
+	case ok == len(e.History):
+		return "always"
+	case 2*ok >= len(e.History):
+		return "mostly"
+	default:
+		return "rarely"
+	}
+}
+
+// counter counts occurrences of strings.
+type counter map[string]int
+
+func (c counter) add(key string) {
+	c[key]++
+}
+
+// print shows the n most common keys. If n is zero, all keys are shown.
+func (c counter) print(n int) {
+	var (
+		keys  = make([]string, 0, len(c))
+		total int
+	)
+	for k, v := range c {
+		keys = append(keys, k)
+		total += v
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if c[keys[i]] != c[keys[j]] {
+			return c[keys[i]] > c[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	var other int
+	if n > 0 && len(keys) > n {
+		for _, k := range keys[n:] {
+			other += c[k]
+		}
+		keys = keys[:n]
+	}
+	for _, k := range keys {
+		fmt.Printf("  %6d %5.1f%%  %s\n", c[k], 100*float64(c[k])/float64(total), k)
+	}
+	if other > 0 {
+		fmt.Printf("  %6d %5.1f%%  (other)\n", other, 100*float64(other)/float64(total))
+	}
+}
+
+// knownNetwork is a network for which fork names can be shown.
+type knownNetwork struct {
+	name    string
+	genesis common.Hash
+	config  *params.ChainConfig
+}
+
+var knownNetworks = []knownNetwork{
+	{"mainnet", params.MainnetGenesisHash, params.MainnetChainConfig},
+	{"ropsten", params.RopstenGenesisHash, params.RopstenChainConfig},
+	{"rinkeby", params.RinkebyGenesisHash, params.RinkebyChainConfig},
+	{"goerli", params.GoerliGenesisHash, params.GoerliChainConfig},
+	{"sepolia", params.SepoliaGenesisHash, params.SepoliaChainConfig},
+}
+
+func findNetwork(genesis common.Hash) *knownNetwork {
+	for i := range knownNetworks {
+		if knownNetworks[i].genesis == genesis {
+			return &knownNetworks[i]
+		}
+	}
+	return nil
+}
+
+func networkName(s *censusStatus) string {
+	if kn := findNetwork(s.Genesis); kn != nil {
+		return fmt.Sprintf("%d (%s)", s.NetworkID, kn.name)
+	}
+	return fmt.Sprintf("%d (genesis %x)", s.NetworkID, s.Genesis[:8])
+}
+
+// forkName describes the fork ID of a node. For known networks, it shows the names
+// of the last fork activated by the node and of the next fork the node is
+// prepared for.
+func forkName(s *censusStatus) string {
+	desc := fmt.Sprintf("%v next=%d", s.ForkHash, s.ForkNext)
+	kn := findNetwork(s.Genesis)
+	if kn == nil {
+		return desc
+	}
+	var hash [4]byte
+	copy(hash[:], s.ForkHash)
+	names := chainForks(kn.config)
+	current, next := "unknown fork", "none"
+	if hash == forkid.NewID(kn.config, kn.genesis, 0).Hash {
+		current = "genesis"
+	}
+	for _, f := range names {
+		if hash == forkid.NewID(kn.config, kn.genesis, f.block).Hash {
+			current = f.name
+		}
+		if uint64(s.ForkNext) == f.block {
+			next = f.name
+		}
+	}
+	if s.ForkNext != 0 && next == "none" {
+		next = "unknown fork"
+	}
+	return fmt.Sprintf("%s %s: %s, next %s", desc, kn.name, current, next)
+}
+
+// namedFork is a fork of a chain configuration.
+type namedFork struct {
+	name  string
+	block uint64
+}
+
+// chainForks returns the forks of a chain configuration, ordered by block number.
+// Forks at block zero are omitted, and forks at the same block are combined.
+// Like forkid, this finds the forks using reflection on the configuration.
+func chainForks(config *params.ChainConfig) []namedFork {
+	var (
+		kind  = reflect.TypeOf(params.ChainConfig{})
+		conf  = reflect.ValueOf(config).Elem()
+		forks []namedFork
+	)
+	for i := 0; i < kind.NumField(); i++ {
+		field := kind.Field(i)
+		if !strings.HasSuffix(field.Name, "Block") || field.Type != reflect.TypeOf(new(big.Int)) {
+			continue
+		}
+		rule := conf.Field(i).Interface().(*big.Int)
+		if rule == nil || rule.Sign() == 0 {
+			continue
+		}
+		forks = append(forks, namedFork{strings.TrimSuffix(field.Name, "Block"), rule.Uint64()})
+	}
+	sort.SliceStable(forks, func(i, j int) bool { return forks[i].block < forks[j].block })
+	var result []namedFork
+	for _, f := range forks {
+		if len(result) > 0 && result[len(result)-1].block == f.block {
+			result[len(result)-1].name += "+" + f.name
+			continue
+		}
+		result = append(result, f)
+	}
+	return result
+}
@@ -31,6 +31,7 @@ type crawler struct {
 	inputIter enode.Iterator
 	ch        chan *enode.Node
 	closed    chan struct{}
+	census    *census // optional
 
 	// settings
 	revalidateInterval time.Duration
@@ -139,6 +140,9 @@ func (c *crawler) updateNode(n *enode.Node) {
 			node.FirstResponse = node.LastCheck
 		}
 		node.LastResponse = node.LastCheck
+		if c.census != nil {
+			c.census.add(nn)
+		}
 	}
 
 	// Store/update node in output set.
@@ -23,6 +23,8 @@ import (
 	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/v5test"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/discover/topicindex"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"gopkg.in/urfave/cli.v1"
 )
 
@@ -36,6 +38,7 @@ var (
 			discv5CrawlCommand,
 			discv5TestCommand,
 			discv5ListenCommand,
+			discv5TopicQueryCommand,
 		},
 	}
 	discv5PingCommand = cli.Command{
@@ -53,7 +56,7 @@ var (
 		Name:   "crawl",
 		Usage:  "Updates a nodes.json file with random nodes found in the DHT",
 		Action: discv5Crawl,
-		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag},
+		Flags:  []cli.Flag{bootnodesFlag, crawlTimeoutFlag, censusFileFlag, censusWorkersFlag},
 	}
 	discv5TestCommand = cli.Command{
 		Name:   "test",
@@ -75,8 +78,28 @@ var (
 			nodekeyFlag,
 			nodedbFlag,
 			listenAddrFlag,
+			topicFlag,
 		},
 	}
+	discv5TopicQueryCommand = cli.Command{
+		Name:      "topicquery",
+		Usage:     "Finds nodes advertised for a topic",
+		Action:    discv5TopicQuery,
+		ArgsUsage: "<topic>",
+		Flags:     []cli.Flag{bootnodesFlag, topicQueryTimeoutFlag},
+	}
+)
+
+var (
+	topicFlag = cli.StringFlag{
+		Name:  "topic",
+		Usage: "Topic to advertise the node for",
+	}
+	topicQueryTimeoutFlag = cli.DurationFlag{
+		Name:  "timeout",
+		Usage: "Time limit for the topic search",
+		Value: 1 * time.Minute,
+	}
 )
 
 func discv5Ping(ctx *cli.Context) error {
@@ -111,8 +134,10 @@ func discv5Crawl(ctx *cli.Context) error {
 	defer disc.Close()
 	c := newCrawler(inputSet, disc, disc.RandomNodes())
 	c.revalidateInterval = 10 * time.Minute
+	c.census = startCensus(ctx)
 	output := c.run(ctx.Duration(crawlTimeoutFlag.Name))
 	writeNodesJSON(nodesFile, output)
+	finishCensus(ctx, c.census)
 	return nil
 }
 
@@ -130,10 +155,39 @@ func discv5Listen(ctx *cli.Context) error {
 	disc := startV5(ctx)
 	defer disc.Close()
 
+	if ctx.IsSet(topicFlag.Name) {
+		disc.RegisterTopic(topicindex.NewTopicID(ctx.String(topicFlag.Name)))
+	}
 	fmt.Println(disc.Self())
 	select {}
 }
 
+// discv5TopicQuery prints the nodes advertised for a topic.
+func discv5TopicQuery(ctx *cli.Context) error {
+	if ctx.NArg() < 1 {
+		return fmt.Errorf("need topic as argument")
+	}
+	topic := topicindex.NewTopicID(ctx.Args().First())
+	disc := startV5(ctx)
+	defer disc.Close()
+
+	it := disc.TopicSearch(topic)
+	defer it.Close()
+	timeout := time.AfterFunc(ctx.Duration(topicQueryTimeoutFlag.Name), it.Close)
+	defer timeout.Stop()
+
+	seen := make(map[enode.ID]struct{})
+	for it.Next() {
+		n := it.Node()
+		if _, ok := seen[n.ID()]; ok {
+			continue
+		}
+		seen[n.ID()] = struct{}{}
+		fmt.Println(n.String())
+	}
+	return nil
+}
+
 // startV5 starts an ephemeral discovery v5 node.
 func startV5(ctx *cli.Context) *discover.UDPv5 {
 	ln, config := makeDiscoveryConfig(ctx)
@@ -0,0 +1,637 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"golang.org/x/net/dns/dnsmessage"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const (
+	// Limits for a single RFC 2136 update message. Updates are sent over TCP,
+	// where messages can be up to 64kB.
+	rfc2136UpdateSizeLimit  = 32000
+	rfc2136UpdateCountLimit = 500
+
+	dnsOpCodeUpdate = 5
+	dnsTypeTSIG     = 250
+	tsigFudge       = 300 // seconds
+)
+
+var (
+	rfc2136ServerFlag = cli.StringFlag{
+		Name:  "server",
+		Usage: "Address of the primary DNS server (host:port)",
+	}
+	rfc2136ZoneFlag = cli.StringFlag{
+		Name:  "zone",
+		Usage: "DNS zone containing the tree (default: parent domain of the tree)",
+	}
+	rfc2136KeyNameFlag = cli.StringFlag{
+		Name:   "tsig-key",
+		Usage:  "Name of the TSIG key",
+		EnvVar: "DNS_TSIG_KEY",
+	}
+	rfc2136KeySecretFlag = cli.StringFlag{
+		Name:   "tsig-secret",
+		Usage:  "TSIG key secret (base64)",
+		EnvVar: "DNS_TSIG_SECRET",
+	}
+	rfc2136KeyAlgorithmFlag = cli.StringFlag{
+		Name:  "tsig-algorithm",
+		Usage: "TSIG algorithm (hmac-sha1, hmac-sha256, hmac-sha512)",
+		Value: "hmac-sha256",
+	}
+)
+
+// rfc2136Client publishes trees to a DNS server using dynamic updates.
+type rfc2136Client struct {
+	server  string
+	zone    string
+	key     *tsigKey
+	timeout time.Duration
+}
+
+// newRFC2136Client sets up a dynamic update client from command line flags.
+func newRFC2136Client(ctx *cli.Context) *rfc2136Client {
+	server := ctx.String(rfc2136ServerFlag.Name)
+	if server == "" {
+		exit(fmt.Errorf("need DNS server address to proceed"))
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	c := &rfc2136Client{
+		server:  server,
+		zone:    ctx.String(rfc2136ZoneFlag.Name),
+		timeout: 10 * time.Second,
+	}
+	if name := ctx.String(rfc2136KeyNameFlag.Name); name != "" {
+		key, err := newTSIGKey(name, ctx.String(rfc2136KeyAlgorithmFlag.Name), ctx.String(rfc2136KeySecretFlag.Name))
+		if err != nil {
+			exit(err)
+		}
+		c.key = key
+	}
+	return c
+}
+
+// deploy uploads the given tree to the DNS server.
+func (c *rfc2136Client) deploy(name string, t *dnsdisc.Tree) error {
+	zone := c.zone
+	if zone == "" {
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return fmt.Errorf("can't determine zone of %q, use --%s", name, rfc2136ZoneFlag.Name)
+		}
+		zone = name[i+1:]
+	}
+	if !isSubdomain(name, zone) {
+		return fmt.Errorf("name %q is not in zone %q", name, zone)
+	}
+
+	log.Info(fmt.Sprintf("Retrieving existing TXT records on %s", name))
+	existing, err := c.collectRecords(name)
+	if err != nil {
+		return err
+	}
+	log.Info(fmt.Sprintf("Found %d TXT records", len(existing)))
+
+	records := t.ToTXT(name)
+	for i, batch := range computeRFC2136Updates(name, records, existing) {
+		log.Info(fmt.Sprintf("Submitting update %d (%d changes)", i+1, len(batch)))
+		if err := c.update(zone, batch); err != nil {
+			return err
+		}
+	}
+	log.Info("Deployment complete")
+	return nil
+}
+
+// rfc2136Update is a change of the TXT record at a name. When value is empty,
+// the record is deleted.
+type rfc2136Update struct {
+	name  string
+	value string
+	ttl   uint32
+}
+
+// computeRFC2136Updates creates the update messages needed to change the existing
+// records to the new records. New tree nodes are added first, then the root is
+// replaced, and finally the records of the old tree are deleted. This ensures that
+// clients can always resolve the tree while it is being updated.
+func computeRFC2136Updates(name string, records, existing map[string]string) [][]rfc2136Update {
+	var adds, deletes []rfc2136Update
+	for path, value := range records {
+		if path == name || existing[path] == value {
+			continue
+		}
+		adds = append(adds, rfc2136Update{name: path, value: value, ttl: treeNodeTTL})
+	}
+	for path := range existing {
+		if _, ok := records[path]; !ok {
+			deletes = append(deletes, rfc2136Update{name: path})
+		}
+	}
+	sort.Slice(adds, func(i, j int) bool { return adds[i].name < adds[j].name })
+	sort.Slice(deletes, func(i, j int) bool { return deletes[i].name < deletes[j].name })
+
+	batches := splitRFC2136Updates(adds)
+	if existing[name] != records[name] {
+		root := rfc2136Update{name: name, value: records[name], ttl: rootTTL}
+		batches = append(batches, []rfc2136Update{root})
+	}
+	return append(batches, splitRFC2136Updates(deletes)...)
+}
+
+// splitRFC2136Updates splits updates into batches which fit into a single message.
+func splitRFC2136Updates(updates []rfc2136Update) [][]rfc2136Update {
+	var (
+		batches [][]rfc2136Update
+		batch   []rfc2136Update
+		size    int
+	)
+	for _, u := range updates {
+		usize := 2*len(u.name) + len(u.value) + 32
+		if len(batch) > 0 && (size+usize > rfc2136UpdateSizeLimit || len(batch) >= rfc2136UpdateCountLimit) {
+			batches = append(batches, batch)
+			batch, size = nil, 0
+		}
+		batch = append(batch, u)
+		size += usize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// update sends a dynamic update message.
+func (c *rfc2136Client) update(zone string, updates []rfc2136Update) error {
+	zoneName, err := dnsmessage.NewName(fqdn(zone))
+	if err != nil {
+		return err
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: uint16(rand.Uint32()), OpCode: dnsOpCodeUpdate})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: zoneName, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET})
+	// Updates go into the authority section.
+	b.StartAuthorities()
+	for _, u := range updates {
+		name, err := dnsmessage.NewName(fqdn(u.name))
+		if err != nil {
+			return err
+		}
+		// Delete the existing record set.
+		hdr := dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassANY}
+		if err := b.TXTResource(hdr, dnsmessage.TXTResource{}); err != nil {
+			return err
+		}
+		if u.value == "" {
+			continue
+		}
+		hdr = dnsmessage.ResourceHeader{Name: name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: u.ttl}
+		if err := b.TXTResource(hdr, dnsmessage.TXTResource{TXT: txtStrings(u.value)}); err != nil {
+			return err
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return err
+	}
+	resp, err := c.exchange(msg)
+	if err != nil {
+		return err
+	}
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return err
+	}
+	if hdr.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("DNS update failed: %v", hdr.RCode)
+	}
+	return nil
+}
+
+// lookupTXT queries the TXT record at name. It returns the empty string if the
+// name doesn't exist.
+func (c *rfc2136Client) lookupTXT(name string) (string, error) {
+	qname, err := dnsmessage.NewName(fqdn(name))
+	if err != nil {
+		return "", err
+	}
+	msg, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32())},
+		Questions: []dnsmessage.Question{{Name: qname, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return "", err
+	}
+	resp, err := c.exchange(msg)
+	if err != nil {
+		return "", err
+	}
+	var p dnsmessage.Parser
+	hdr, err := p.Start(resp)
+	if err != nil {
+		return "", err
+	}
+	switch hdr.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return "", nil
+	default:
+		return "", fmt.Errorf("DNS query for %s failed: %v", name, hdr.RCode)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return "", err
+	}
+	for {
+		h, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			return "", nil
+		} else if err != nil {
+			return "", err
+		}
+		if h.Type != dnsmessage.TypeTXT {
+			p.SkipAnswer()
+			continue
+		}
+		txt, err := p.TXTResource()
+		if err != nil {
+			return "", err
+		}
+		return strings.Join(txt.TXT, ""), nil
+	}
+}
+
+// collectRecords retrieves the records of the tree which is currently deployed
+// at name by walking it.
+func (c *rfc2136Client) collectRecords(name string) (map[string]string, error) {
+	existing := make(map[string]string)
+	root, err := c.lookupTXT(name)
+	if err != nil || root == "" {
+		return existing, err
+	}
+	existing[name] = root
+
+	var queue []string
+	for _, field := range strings.Fields(root) {
+		if strings.HasPrefix(field, "e=") || strings.HasPrefix(field, "l=") {
+			queue = append(queue, field[2:])
+		}
+	}
+	for len(queue) > 0 {
+		path := queue[0] + "." + name
+		queue = queue[1:]
+		if _, ok := existing[path]; ok {
+			continue
+		}
+		txt, err := c.lookupTXT(path)
+		if err != nil {
+			return nil, err
+		}
+		if txt == "" {
+			continue
+		}
+		existing[path] = txt
+		if strings.HasPrefix(txt, "enrtree-branch:") {
+			for _, h := range strings.Split(strings.TrimPrefix(txt, "enrtree-branch:"), ",") {
+				if h != "" {
+					queue = append(queue, h)
+				}
+			}
+		}
+	}
+	return existing, nil
+}
+
+// exchange sends a message to the server over TCP and returns the response.
+// The message is signed if a TSIG key is configured.
+func (c *rfc2136Client) exchange(msg []byte) ([]byte, error) {
+	var mac []byte
+	if c.key != nil {
+		var err error
+		if msg, mac, err = c.key.sign(msg, time.Now(), nil); err != nil {
+			return nil, err
+		}
+	}
+	conn, err := net.DialTimeout("tcp", c.server, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	if err := writeTCPMessage(conn, msg); err != nil {
+		return nil, err
+	}
+	resp, err := readTCPMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 || resp[0] != msg[0] || resp[1] != msg[1] {
+		return nil, errors.New("DNS response ID mismatch")
+	}
+	if c.key != nil {
+		if resp, err = c.key.verify(resp, time.Now(), mac); err != nil {
+			return nil, fmt.Errorf("invalid DNS response: %v", err)
+		}
+	}
+	return resp, nil
+}
+
+// tsigKey is a key for transaction signatures (RFC 8945).
+type tsigKey struct {
+	name      string // fully qualified
+	algorithm string // fully qualified algorithm name
+	secret    []byte
+	hash      func() hash.Hash
+}
+
+var tsigAlgorithms = map[string]func() hash.Hash{
+	"hmac-sha1.":   sha1.New,
+	"hmac-sha256.": sha256.New,
+	"hmac-sha512.": sha512.New,
+}
+
+func newTSIGKey(name, algorithm, secret string) (*tsigKey, error) {
+	algorithm = fqdn(strings.ToLower(algorithm))
+	h, ok := tsigAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", algorithm)
+	}
+	key, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid TSIG secret: %v", err)
+	}
+	if len(key) == 0 {
+		return nil, errors.New("missing TSIG secret")
+	}
+	return &tsigKey{name: fqdn(strings.ToLower(name)), algorithm: algorithm, secret: key, hash: h}, nil
+}
+
+// tsigRecord contains the fields of a TSIG record.
+type tsigRecord struct {
+	algorithm  string
+	timeSigned uint64
+	fudge      uint16
+	mac        []byte
+	origID     uint16
+	error      uint16
+	other      []byte
+}
+
+// sign appends a TSIG record to a DNS message. For responses, requestMAC is the
+// MAC of the request. It returns the signed message and its MAC.
+func (k *tsigKey) sign(msg []byte, now time.Time, requestMAC []byte) ([]byte, []byte, error) {
+	if len(msg) < 12 {
+		return nil, nil, errors.New("DNS message too short")
+	}
+	rec := tsigRecord{
+		algorithm:  k.algorithm,
+		timeSigned: uint64(now.Unix()),
+		fudge:      tsigFudge,
+		origID:     binary.BigEndian.Uint16(msg),
+	}
+	rec.mac = k.mac(msg, &rec, requestMAC)
+
+	signed := append([]byte{}, msg...)
+	signed = append(signed, encodeDNSName(k.name)...)
+	signed = appendUint16(signed, dnsTypeTSIG)
+	signed = appendUint16(signed, uint16(dnsmessage.ClassANY))
+	signed = append(signed, 0, 0, 0, 0) // TTL
+	rdata := encodeDNSName(rec.algorithm)
+	rdata = appendUint48(rdata, rec.timeSigned)
+	rdata = appendUint16(rdata, rec.fudge)
+	rdata = appendUint16(rdata, uint16(len(rec.mac)))
+	rdata = append(rdata, rec.mac...)
+	rdata = appendUint16(rdata, rec.origID)
+	rdata = appendUint16(rdata, rec.error)
+	rdata = appendUint16(rdata, uint16(len(rec.other)))
+	rdata = append(rdata, rec.other...)
+	signed = appendUint16(signed, uint16(len(rdata)))
+	signed = append(signed, rdata...)
+
+	// Increment ARCOUNT.
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+	return signed, rec.mac, nil
+}
+
+// verify checks the TSIG record at the end of a message and returns the message
+// without it.
+func (k *tsigKey) verify(msg []byte, now time.Time, requestMAC []byte) ([]byte, error) {
+	offset, rec, err := findTSIG(msg)
+	if err != nil {
+		return nil, err
+	}
+	if rec.algorithm != k.algorithm {
+		return nil, fmt.Errorf("TSIG algorithm mismatch: %s", rec.algorithm)
+	}
+	if rec.error != 0 {
+		return nil, fmt.Errorf("TSIG error %d", rec.error)
+	}
+	// Restore the message as it was before signing.
+	unsigned := append([]byte{}, msg[:offset]...)
+	binary.BigEndian.PutUint16(unsigned, rec.origID)
+	binary.BigEndian.PutUint16(unsigned[10:], binary.BigEndian.Uint16(unsigned[10:])-1)
+	if !hmac.Equal(rec.mac, k.mac(unsigned, &rec, requestMAC)) {
+		return nil, errors.New("bad TSIG signature")
+	}
+	signedAt := time.Unix(int64(rec.timeSigned), 0)
+	if d := now.Sub(signedAt); d > time.Duration(rec.fudge)*time.Second || -d > time.Duration(rec.fudge)*time.Second {
+		return nil, errors.New("TSIG time outside of allowed range")
+	}
+	return unsigned, nil
+}
+
+// mac computes the MAC of a message.
+func (k *tsigKey) mac(msg []byte, rec *tsigRecord, requestMAC []byte) []byte {
+	h := hmac.New(k.hash, k.secret)
+	if requestMAC != nil {
+		h.Write(appendUint16(nil, uint16(len(requestMAC))))
+		h.Write(requestMAC)
+	}
+	h.Write(msg)
+	// TSIG variables.
+	vars := encodeDNSName(k.name)
+	vars = appendUint16(vars, uint16(dnsmessage.ClassANY))
+	vars = append(vars, 0, 0, 0, 0) // TTL
+	vars = append(vars, encodeDNSName(rec.algorithm)...)
+	vars = appendUint48(vars, rec.timeSigned)
+	vars = appendUint16(vars, rec.fudge)
+	vars = appendUint16(vars, rec.error)
+	vars = appendUint16(vars, uint16(len(rec.other)))
+	vars = append(vars, rec.other...)
+	h.Write(vars)
+	return h.Sum(nil)
+}
+
+// findTSIG locates the TSIG record, which must be the last record of a message.
+func findTSIG(msg []byte) (int, tsigRecord, error) {
+	var rec tsigRecord
+	if len(msg) < 12 {
+		return 0, rec, errors.New("DNS message too short")
+	}
+	var (
+		qdcount = int(binary.BigEndian.Uint16(msg[4:]))
+		rrcount = int(binary.BigEndian.Uint16(msg[6:])) + int(binary.BigEndian.Uint16(msg[8:])) + int(binary.BigEndian.Uint16(msg[10:]))
+		off     = 12
+		err     error
+	)
+	if rrcount == 0 {
+		return 0, rec, errors.New("message not signed")
+	}
+	for i := 0; i < qdcount; i++ {
+		if off, err = skipDNSName(msg, off); err != nil {
+			return 0, rec, err
+		}
+		off += 4
+	}
+	for i := 0; i < rrcount-1; i++ {
+		if off, err = skipDNSRecord(msg, off); err != nil {
+			return 0, rec, err
+		}
+	}
+	start := off
+	if off, err = skipDNSName(msg, off); err != nil {
+		return 0, rec, err
+	}
+	if off+10 > len(msg) || binary.BigEndian.Uint16(msg[off:]) != dnsTypeTSIG {
+		return 0, rec, errors.New("message not signed")
+	}
+	rdlen := int(binary.BigEndian.Uint16(msg[off+8:]))
+	rdata := msg[off+10:]
+	if len(rdata) != rdlen {
+		return 0, rec, errors.New("invalid TSIG record")
+	}
+	// Parse the record data.
+	aend, err := skipDNSName(rdata, 0)
+	if err != nil {
+		return 0, rec, err
+	}
+	rec.algorithm = decodeDNSName(rdata[:aend])
+	rdata = rdata[aend:]
+	if len(rdata) < 10 {
+		return 0, rec, errors.New("invalid TSIG record")
+	}
+	rec.timeSigned = uint64(binary.BigEndian.Uint16(rdata))<<32 | uint64(binary.BigEndian.Uint32(rdata[2:]))
+	rec.fudge = binary.BigEndian.Uint16(rdata[6:])
+	macLen := int(binary.BigEndian.Uint16(rdata[8:]))
+	rdata = rdata[10:]
+	if len(rdata) < macLen+6 {
+		return 0, rec, errors.New("invalid TSIG record")
+	}
+	rec.mac, rdata = rdata[:macLen], rdata[macLen:]
+	rec.origID = binary.BigEndian.Uint16(rdata)
+	rec.error = binary.BigEndian.Uint16(rdata[2:])
+	otherLen := int(binary.BigEndian.Uint16(rdata[4:]))
+	if len(rdata[6:]) != otherLen {
+		return 0, rec, errors.New("invalid TSIG record")
+	}
+	rec.other = rdata[6:]
+	return start, rec, nil
+}
+
+func skipDNSRecord(msg []byte, off int) (int, error) {
+	off, err := skipDNSName(msg, off)
+	if err != nil {
+		return 0, err
+	}
+	if off+10 > len(msg) {
+		return 0, errors.New("truncated DNS record")
+	}
+	off += 10 + int(binary.BigEndian.Uint16(msg[off+8:]))
+	if off > len(msg) {
+		return 0, errors.New("truncated DNS record")
+	}
+	return off, nil
+}
+
+func skipDNSName(msg []byte, off int) (int, error) {
+	for {
+		if off >= len(msg) {
+			return 0, errors.New("truncated DNS name")
+		}
+		l := int(msg[off])
+		switch {
+		case l == 0:
+			return off + 1, nil
+		case l&0xC0 == 0xC0:
+			return off + 2, nil
+		default:
+			off += 1 + l
+		}
+	}
+}
+
+// encodeDNSName encodes a name in canonical wire format, i.e. uncompressed
+// and lowercase.
+func encodeDNSName(name string) []byte {
+	var enc []byte
+	for _, label := range strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".") {
+		if label == "" {
+			continue
+		}
+		enc = append(enc, byte(len(label)))
+		enc = append(enc, label...)
+	}
+	return append(enc, 0)
+}
+
+// decodeDNSName decodes an uncompressed name.
+func decodeDNSName(enc []byte) string {
+	var labels []string
+	for len(enc) > 0 && enc[0] != 0 && int(enc[0]) < len(enc) {
+		labels = append(labels, string(enc[1:1+enc[0]]))
+		enc = enc[1+enc[0]:]
+	}
+	return strings.ToLower(strings.Join(labels, ".")) + "."
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+// fqdn adds the trailing dot to a name.
+func fqdn(name string) string {
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "."
+}
@@ -0,0 +1,260 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/base64"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// This test checks that computeRFC2136Updates creates updates in
+// leaf-added -> root-changed -> leaf-deleted order.
+func TestRFC2136UpdateOrder(t *testing.T) {
+	existing := map[string]string{
+		"n":   "enrtree-root:v1 e=A seq=0",
+		"a.n": "enr:a",
+		"b.n": "enr:b",
+	}
+	records := map[string]string{
+		"n":   "enrtree-root:v1 e=B seq=1",
+		"b.n": "enr:b",
+		"c.n": "enr:c",
+		"d.n": "enr:d",
+	}
+	want := [][]rfc2136Update{
+		{{name: "c.n", value: "enr:c", ttl: treeNodeTTL}, {name: "d.n", value: "enr:d", ttl: treeNodeTTL}},
+		{{name: "n", value: "enrtree-root:v1 e=B seq=1", ttl: rootTTL}},
+		{{name: "a.n"}},
+	}
+	if have := computeRFC2136Updates("n", records, existing); !reflect.DeepEqual(have, want) {
+		t.Errorf("wrong updates:\nhave %v\nwant %v", have, want)
+	}
+}
+
+func TestTSIGSignVerify(t *testing.T) {
+	key, err := newTSIGKey("test-key", "hmac-sha256", base64.StdEncoding.EncodeToString([]byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 7},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("n."), Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET}},
+	}).Pack()
+
+	now := time.Now()
+	signed, mac, err := key.sign(msg, now, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsigned, err := key.verify(signed, now, nil)
+	if err != nil {
+		t.Fatal("verify error:", err)
+	}
+	if !reflect.DeepEqual(unsigned, msg) {
+		t.Error("verify returned wrong message")
+	}
+	if _, err := key.verify(signed, now.Add(time.Hour), nil); err == nil {
+		t.Error("expired signature accepted")
+	}
+	if _, err := key.verify(signed, now, mac); err == nil {
+		t.Error("signature accepted with wrong request MAC")
+	}
+	otherKey, _ := newTSIGKey("test-key", "hmac-sha256", base64.StdEncoding.EncodeToString([]byte("other")))
+	if _, err := otherKey.verify(signed, now, nil); err == nil {
+		t.Error("signature accepted with wrong key")
+	}
+}
+
+// This test deploys two versions of a tree to a server and checks that stale
+// records are removed.
+func TestRFC2136Deploy(t *testing.T) {
+	const domain = "nodes.example.org"
+	key, _ := newTSIGKey("test-key", "hmac-sha256", base64.StdEncoding.EncodeToString([]byte("secret")))
+	srv := newTestUpdateServer(t, domain, key)
+	defer srv.close()
+
+	client := &rfc2136Client{server: srv.addr(), key: key, timeout: 5 * time.Second}
+	for seq := uint(1); seq <= 2; seq++ {
+		tree, url := makeTestTree(t, domain, seq, 10)
+		if err := client.deploy(domain, tree); err != nil {
+			t.Fatalf("deploy of tree %d failed: %v", seq, err)
+		}
+		if have, want := srv.records(), lowerKeys(tree.ToTXT(domain)); !reflect.DeepEqual(have, want) {
+			t.Fatalf("wrong records after deploying tree %d:\nhave %v\nwant %v", seq, have, want)
+		}
+		dc := dnsdisc.NewClient(dnsdisc.Config{Resolver: testResolver(srv.dns.addr()), RateLimit: 1000})
+		synced, err := dc.SyncTree(url)
+		if err != nil {
+			t.Fatalf("sync of tree %d failed: %v", seq, err)
+		}
+		if !reflect.DeepEqual(sortedIDs(synced.Nodes()), sortedIDs(tree.Nodes())) {
+			t.Fatalf("wrong nodes in synced tree %d", seq)
+		}
+	}
+
+	// Updates signed with the wrong key must be rejected.
+	badKey, _ := newTSIGKey("test-key", "hmac-sha256", base64.StdEncoding.EncodeToString([]byte("wrong")))
+	client.key = badKey
+	tree, _ := makeTestTree(t, domain, 3, 1)
+	if err := client.deploy(domain, tree); err == nil {
+		t.Fatal("deploy with wrong key succeeded")
+	}
+}
+
+// testUpdateServer is a DNS server which accepts TSIG-signed dynamic updates of
+// TXT records. Queries are answered by dnsServer.
+type testUpdateServer struct {
+	t   *testing.T
+	key *tsigKey
+	dns *dnsServer
+	l   net.Listener
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	txt map[string]string
+}
+
+func newTestUpdateServer(t *testing.T, domain string, key *tsigKey) *testUpdateServer {
+	s := &testUpdateServer{t: t, key: key, dns: newDNSServer(domain, nil), txt: make(map[string]string)}
+	if err := s.dns.listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.l = l
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *testUpdateServer) addr() string {
+	return s.l.Addr().String()
+}
+
+func (s *testUpdateServer) close() {
+	s.l.Close()
+	s.wg.Wait()
+	s.dns.close()
+}
+
+func (s *testUpdateServer) records() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return lowerKeys(s.txt)
+}
+
+func (s *testUpdateServer) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		req, err := readTCPMessage(conn)
+		if err == nil {
+			writeTCPMessage(conn, s.handle(req))
+		}
+		conn.Close()
+	}
+}
+
+func (s *testUpdateServer) handle(signed []byte) []byte {
+	_, tsig, err := findTSIG(signed)
+	if err != nil {
+		s.t.Error("unsigned request:", err)
+		return nil
+	}
+	req, err := s.key.verify(signed, time.Now(), nil)
+	if err != nil {
+		// Reply with NOTAUTH without a signature.
+		resp := append([]byte{}, signed[:12]...)
+		resp[2] = 0x80 | dnsOpCodeUpdate<<3
+		resp[3] = 9
+		for i := 4; i < 12; i++ {
+			resp[i] = 0
+		}
+		return resp
+	}
+	var p dnsmessage.Parser
+	hdr, err := p.Start(req)
+	if err != nil {
+		s.t.Error("invalid request:", err)
+		return nil
+	}
+	var resp []byte
+	if hdr.OpCode == dnsOpCodeUpdate {
+		resp = s.update(hdr, &p)
+	} else {
+		resp = s.dns.handle(req, false)
+	}
+	resp, _, err = s.key.sign(resp, time.Now(), tsig.mac)
+	if err != nil {
+		s.t.Error("can't sign response:", err)
+	}
+	return resp
+}
+
+func (s *testUpdateServer) update(hdr dnsmessage.Header, p *dnsmessage.Parser) []byte {
+	p.SkipAllQuestions()
+	p.SkipAllAnswers()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		h, err := p.AuthorityHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		} else if err != nil {
+			s.t.Error("invalid update:", err)
+			return nil
+		}
+		txt, err := p.TXTResource()
+		if err != nil {
+			s.t.Error("invalid update:", err)
+			return nil
+		}
+		name := strings.TrimSuffix(h.Name.String(), ".")
+		switch h.Class {
+		case dnsmessage.ClassANY:
+			delete(s.txt, name)
+		case dnsmessage.ClassINET:
+			s.txt[name] = strings.Join(txt.TXT, "")
+		}
+	}
+	s.dns.setRecords(s.txt)
+
+	resp, _ := (&dnsmessage.Message{Header: dnsmessage.Header{ID: hdr.ID, Response: true, OpCode: hdr.OpCode}}).Pack()
+	return resp
+}
+
+func lowerKeys(m map[string]string) map[string]string {
+	lm := make(map[string]string, len(m))
+	for k, v := range m {
+		lm[strings.ToLower(k)] = v
+	}
+	return lm
+}
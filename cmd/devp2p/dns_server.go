@@ -0,0 +1,310 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	// Maximum size of UDP responses for queries without EDNS(0).
+	dnsMaxUDPSize = 512
+
+	// Maximum length of a character-string in a TXT record.
+	dnsMaxTXTStringLength = 255
+
+	dnsTCPTimeout = 10 * time.Second
+)
+
+// dnsServer is a minimal authoritative DNS server for the TXT records of a
+// discovery tree. It answers queries over UDP and TCP.
+type dnsServer struct {
+	domain string // lowercase, without trailing dot
+
+	mu      sync.RWMutex
+	records map[string]string // lowercase name -> TXT value
+
+	udp  net.PacketConn
+	tcp  net.Listener
+	wg   sync.WaitGroup
+	quit chan struct{}
+}
+
+// newDNSServer creates a server for the records of a tree deployed at domain.
+func newDNSServer(domain string, records map[string]string) *dnsServer {
+	s := &dnsServer{
+		domain: strings.ToLower(strings.TrimSuffix(domain, ".")),
+		quit:   make(chan struct{}),
+	}
+	s.setRecords(records)
+	return s
+}
+
+// setRecords replaces the records served.
+func (s *dnsServer) setRecords(records map[string]string) {
+	m := make(map[string]string, len(records))
+	for name, value := range records {
+		m[strings.ToLower(strings.TrimSuffix(name, "."))] = value
+	}
+	s.mu.Lock()
+	s.records = m
+	s.mu.Unlock()
+}
+
+// listen starts serving on the given UDP and TCP address.
+func (s *dnsServer) listen(addr string) error {
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	// Use the same port for TCP, in case it was chosen by the OS.
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		udp.Close()
+		return err
+	}
+	s.udp, s.tcp = udp, tcp
+	s.wg.Add(2)
+	go s.serveUDP()
+	go s.serveTCP()
+	return nil
+}
+
+// addr returns the listening address.
+func (s *dnsServer) addr() string {
+	return s.udp.LocalAddr().String()
+}
+
+// close stops the server.
+func (s *dnsServer) close() {
+	close(s.quit)
+	s.udp.Close()
+	s.tcp.Close()
+	s.wg.Wait()
+}
+
+func (s *dnsServer) closed() bool {
+	select {
+	case <-s.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *dnsServer) serveUDP() {
+	defer s.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, from, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if !s.closed() {
+				log.Debug("DNS UDP read error", "err", err)
+			}
+			return
+		}
+		if resp := s.handle(buf[:n], true); resp != nil {
+			s.udp.WriteTo(resp, from)
+		}
+	}
+}
+
+func (s *dnsServer) serveTCP() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if !s.closed() {
+				log.Debug("DNS TCP accept error", "err", err)
+			}
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serveTCPConn(conn)
+		}()
+	}
+}
+
+func (s *dnsServer) serveTCPConn(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		conn.SetDeadline(time.Now().Add(dnsTCPTimeout))
+		req, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+		resp := s.handle(req, false)
+		if resp == nil {
+			return
+		}
+		if err := writeTCPMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle processes a query. It returns nil if the message should be dropped.
+func (s *dnsServer) handle(req []byte, udp bool) []byte {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(req)
+	if err != nil || hdr.Response {
+		return nil
+	}
+	resp := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 hdr.ID,
+			Response:           true,
+			OpCode:             hdr.OpCode,
+			RecursionDesired:   hdr.RecursionDesired,
+			Authoritative:      true,
+			RCode:              dnsmessage.RCodeSuccess,
+			RecursionAvailable: false,
+		},
+	}
+	questions, err := p.AllQuestions()
+	switch {
+	case err != nil:
+		resp.Header.RCode = dnsmessage.RCodeFormatError
+	case hdr.OpCode != 0:
+		resp.Header.RCode = dnsmessage.RCodeNotImplemented
+	case len(questions) != 1:
+		resp.Header.RCode = dnsmessage.RCodeFormatError
+	default:
+		q := questions[0]
+		resp.Questions = questions
+		resp.Header.RCode, resp.Answers = s.answer(q)
+	}
+
+	maxSize := 65535
+	if udp {
+		maxSize = dnsMaxUDPSize
+		if err := p.SkipAllAnswers(); err == nil {
+			if err := p.SkipAllAuthorities(); err == nil {
+				if size, ok := ednsUDPSize(&p); ok && size > maxSize {
+					maxSize = size
+				}
+			}
+		}
+	}
+	packed, err := resp.Pack()
+	if err != nil {
+		log.Debug("Can't pack DNS response", "err", err)
+		return nil
+	}
+	if len(packed) > maxSize {
+		// Tell the client to retry over TCP.
+		resp.Header.Truncated = true
+		resp.Answers = nil
+		packed, _ = resp.Pack()
+	}
+	return packed
+}
+
+// answer looks up the records for a question.
+func (s *dnsServer) answer(q dnsmessage.Question) (dnsmessage.RCode, []dnsmessage.Resource) {
+	name := strings.ToLower(strings.TrimSuffix(q.Name.String(), "."))
+	if name != s.domain && !strings.HasSuffix(name, "."+s.domain) {
+		return dnsmessage.RCodeRefused, nil
+	}
+	s.mu.RLock()
+	value, ok := s.records[name]
+	s.mu.RUnlock()
+	if !ok {
+		return dnsmessage.RCodeNameError, nil
+	}
+	if q.Class != dnsmessage.ClassINET && q.Class != dnsmessage.ClassANY {
+		return dnsmessage.RCodeSuccess, nil
+	}
+	if q.Type != dnsmessage.TypeTXT && q.Type != dnsmessage.TypeALL {
+		return dnsmessage.RCodeSuccess, nil
+	}
+	ttl := uint32(treeNodeTTL)
+	if name == s.domain {
+		ttl = rootTTL
+	}
+	rr := dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeTXT, Class: dnsmessage.ClassINET, TTL: ttl},
+		Body:   &dnsmessage.TXTResource{TXT: txtStrings(value)},
+	}
+	return dnsmessage.RCodeSuccess, []dnsmessage.Resource{rr}
+}
+
+// ednsUDPSize returns the UDP payload size announced in the OPT record of a query.
+// The parser must be positioned at the additional section.
+func ednsUDPSize(p *dnsmessage.Parser) (int, bool) {
+	for {
+		h, err := p.AdditionalHeader()
+		if err != nil {
+			return 0, false
+		}
+		if h.Type == dnsmessage.TypeOPT {
+			return int(h.Class), true
+		}
+		if err := p.SkipAdditional(); err != nil {
+			return 0, false
+		}
+	}
+}
+
+// txtStrings splits a record value into character-strings.
+func txtStrings(value string) []string {
+	var parts []string
+	for len(value) > dnsMaxTXTStringLength {
+		parts = append(parts, value[:dnsMaxTXTStringLength])
+		value = value[dnsMaxTXTStringLength:]
+	}
+	return append(parts, value)
+}
+
+// readTCPMessage reads a length-prefixed DNS message.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// writeTCPMessage writes a length-prefixed DNS message.
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	if len(msg) > 65535 {
+		return fmt.Errorf("DNS message too large (%d bytes)", len(msg))
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/rand"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// This test syncs a tree from the built-in DNS server using the dnsdisc client.
+func TestDNSServerSync(t *testing.T) {
+	const domain = "nodes.example.org"
+	tree, url := makeTestTree(t, domain, 1, 20)
+
+	srv := newDNSServer(domain, tree.ToTXT(domain))
+	if err := srv.listen("127.0.0.1:0"); err != nil {
+		t.Fatal(err)
+	}
+	defer srv.close()
+
+	client := dnsdisc.NewClient(dnsdisc.Config{Resolver: testResolver(srv.addr()), RateLimit: 1000})
+	synced, err := client.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortedIDs(synced.Nodes()), sortedIDs(tree.Nodes())) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if synced.Seq() != tree.Seq() {
+		t.Errorf("wrong seq %d in synced tree, want %d", synced.Seq(), tree.Seq())
+	}
+}
+
+func TestDNSServerErrors(t *testing.T) {
+	srv := newDNSServer("nodes.example.org", map[string]string{"nodes.example.org": "enrtree-root:v1"})
+
+	tests := []struct {
+		name  string
+		typ   dnsmessage.Type
+		rcode dnsmessage.RCode
+		nans  int
+	}{
+		{"nodes.example.org.", dnsmessage.TypeTXT, dnsmessage.RCodeSuccess, 1},
+		{"NODES.example.org.", dnsmessage.TypeTXT, dnsmessage.RCodeSuccess, 1},
+		{"nodes.example.org.", dnsmessage.TypeA, dnsmessage.RCodeSuccess, 0},
+		{"x.nodes.example.org.", dnsmessage.TypeTXT, dnsmessage.RCodeNameError, 0},
+		{"example.org.", dnsmessage.TypeTXT, dnsmessage.RCodeRefused, 0},
+	}
+	for _, test := range tests {
+		req, _ := (&dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: 1},
+			Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName(test.name), Type: test.typ, Class: dnsmessage.ClassINET}},
+		}).Pack()
+		var resp dnsmessage.Message
+		if err := resp.Unpack(srv.handle(req, true)); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Header.RCode != test.rcode || len(resp.Answers) != test.nans {
+			t.Errorf("%s %v: got rcode %v with %d answers, want %v with %d", test.name, test.typ, resp.Header.RCode, len(resp.Answers), test.rcode, test.nans)
+		}
+		if !resp.Header.Authoritative {
+			t.Errorf("%s %v: response not authoritative", test.name, test.typ)
+		}
+	}
+}
+
+// testResolver creates a resolver which sends all queries to the given server.
+func testResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// makeTestTree creates a signed tree with n random nodes.
+func makeTestTree(t *testing.T, domain string, seq uint, n int) (*dnsdisc.Tree, string) {
+	rand := rand.New(rand.NewSource(int64(seq)))
+	nodes := make([]*enode.Node, n)
+	for i := range nodes {
+		nodes[i] = testNode(t, rand)
+	}
+	tree, err := dnsdisc.MakeTree(seq, nodes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := tree.Sign(testNodeKey(t, rand), domain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree, url
+}
+
+func testNodeKey(t *testing.T, rand *rand.Rand) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(crypto.S256(), rand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func testNode(t *testing.T, rand *rand.Rand) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IPv4{127, 0, 0, 1})
+	if err := enode.SignV4(&r, testNodeKey(t, rand)); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enode.New(enode.ValidSchemes, &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func sortedIDs(nodes []*enode.Node) []enode.ID {
+	ids := make([]enode.ID, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID()
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].String() < ids[j].String() })
+	return ids
+}
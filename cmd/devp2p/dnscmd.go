@@ -22,12 +22,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/console/prompt"
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"gopkg.in/urfave/cli.v1"
@@ -44,6 +47,8 @@ var (
 			dnsCloudflareCommand,
 			dnsRoute53Command,
 			dnsRoute53NukeCommand,
+			dnsRFC2136Command,
+			dnsServeCommand,
 		},
 	}
 	dnsSyncCommand = cli.Command{
@@ -85,6 +90,26 @@ var (
 			route53RegionFlag,
 		},
 	}
+	dnsRFC2136Command = cli.Command{
+		Name:      "to-rfc2136",
+		Usage:     "Deploy DNS TXT records using RFC 2136 dynamic updates",
+		ArgsUsage: "<tree-directory>",
+		Action:    dnsToRFC2136,
+		Flags: []cli.Flag{
+			rfc2136ServerFlag,
+			rfc2136ZoneFlag,
+			rfc2136KeyNameFlag,
+			rfc2136KeySecretFlag,
+			rfc2136KeyAlgorithmFlag,
+		},
+	}
+	dnsServeCommand = cli.Command{
+		Name:      "serve",
+		Usage:     "Serve a DNS discovery tree from a built-in DNS server",
+		ArgsUsage: "<tree-directory>",
+		Action:    dnsServe,
+		Flags:     []cli.Flag{dnsListenAddrFlag},
+	}
 	dnsRoute53NukeCommand = cli.Command{
 		Name:      "nuke-route53",
 		Usage:     "Deletes DNS TXT records of a subdomain on Amazon Route53",
@@ -112,6 +137,11 @@ var (
 		Name:  "seq",
 		Usage: "New sequence number of the tree",
 	}
+	dnsListenAddrFlag = cli.StringFlag{
+		Name:  "addr",
+		Usage: "Listening address of the DNS server (UDP and TCP)",
+		Value: "127.0.0.1:5300",
+	}
 )
 
 const (
@@ -242,6 +272,41 @@ func dnsToRoute53(ctx *cli.Context) error {
 	return client.deploy(domain, t)
 }
 
+// dnsToRFC2136 performs dnsRFC2136Command.
+func dnsToRFC2136(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need tree definition directory as argument")
+	}
+	domain, t, err := loadTreeDefinitionForExport(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	client := newRFC2136Client(ctx)
+	return client.deploy(domain, t)
+}
+
+// dnsServe performs dnsServeCommand.
+func dnsServe(ctx *cli.Context) error {
+	if ctx.NArg() != 1 {
+		return fmt.Errorf("need tree definition directory as argument")
+	}
+	domain, t, err := loadTreeDefinitionForExport(ctx.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	srv := newDNSServer(domain, t.ToTXT(domain))
+	if err := srv.listen(ctx.String(dnsListenAddrFlag.Name)); err != nil {
+		return err
+	}
+	defer srv.close()
+	log.Info("Serving DNS discovery tree", "domain", domain, "seq", t.Seq(), "addr", srv.addr())
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+	return nil
+}
+
 // dnsNukeRoute53 performs dnsRoute53NukeCommand.
 func dnsNukeRoute53(ctx *cli.Context) error {
 	if ctx.NArg() != 1 {
@@ -62,6 +62,8 @@ func init() {
 		discv4Command,
 		discv5Command,
 		dnsCommand,
+		censusCommand,
+		captureCommand,
 		nodesetCommand,
 		rlpxCommand,
 	}
@@ -0,0 +1,487 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/core/vm/runtime"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	FuzzTraceOutputFlag = cli.StringFlag{
+		Name: "trace.output",
+		Usage: "Determines where to write the local JSON trace.\n" +
+			"\t`stdout` - into the stdout output\n" +
+			"\t`stderr` - into the stderr output\n" +
+			"\t<file> - into the given file",
+		Value: "stderr",
+	}
+	FuzzContextFlag = cli.IntFlag{
+		Name:  "context",
+		Usage: "Number of matching steps to show before the first divergence",
+		Value: 3,
+	}
+)
+
+var fuzzCommand = cli.Command{
+	Action:    fuzzCmd,
+	Name:      "fuzz",
+	Usage:     "executes code and diffs the trace against an external evm",
+	ArgsUsage: " ",
+	Description: `
+The fuzz command executes the code given via --code or --codefile on top of the
+--prestate, emitting the standard JSON trace of each executed opcode. The trace
+of an external implementation running the same code is read from stdin and the
+two are compared step by step. At the first divergence a minimised report of the
+mismatching fields is printed and the command exits with a non-zero code.
+
+Example:
+    external-evm --json --code 6001600201 | evm --code 6001600201 fuzz`,
+	Flags: []cli.Flag{
+		FuzzTraceOutputFlag,
+		FuzzContextFlag,
+	},
+}
+
+func fuzzCmd(ctx *cli.Context) error {
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(ctx.GlobalInt(VerbosityFlag.Name)))
+	log.Root().SetHandler(glogger)
+
+	code, err := readFuzzCode(ctx)
+	if err != nil {
+		return err
+	}
+	logconfig := &vm.LogConfig{
+		EnableMemory:     !ctx.GlobalBool(DisableMemoryFlag.Name),
+		DisableStack:     ctx.GlobalBool(DisableStackFlag.Name),
+		DisableStorage:   true,
+		EnableReturnData: !ctx.GlobalBool(DisableReturnDataFlag.Name),
+	}
+	// Execute the code locally, collecting the trace for later comparison
+	var trace bytes.Buffer
+	if err := runFuzzCode(ctx, code, vm.NewJSONLogger(logconfig, &trace)); err != nil {
+		return err
+	}
+	if err := writeFuzzTrace(ctx.String(FuzzTraceOutputFlag.Name), trace.Bytes()); err != nil {
+		return err
+	}
+	// Compare against the external trace and report the first divergence
+	cmp := &traceComparer{
+		context:    ctx.Int(FuzzContextFlag.Name),
+		memory:     logconfig.EnableMemory,
+		returnData: logconfig.EnableReturnData,
+	}
+	div, err := cmp.compare(&trace, os.Stdin)
+	if err != nil {
+		return err
+	}
+	if div == nil {
+		fmt.Printf("traces match (%d steps)\n", cmp.steps)
+		return nil
+	}
+	fmt.Print(div.String())
+	return fmt.Errorf("traces diverged at step %d", div.Step)
+}
+
+// readFuzzCode loads the hex code to execute from the --code or --codefile flags.
+// Since stdin is reserved for the external trace, it cannot be used as code source.
+func readFuzzCode(ctx *cli.Context) ([]byte, error) {
+	var hexcode []byte
+	switch fn := ctx.GlobalString(CodeFileFlag.Name); {
+	case fn == "-":
+		return nil, errors.New("stdin is reserved for the external trace, use --code or a file")
+	case fn != "":
+		var err error
+		if hexcode, err = ioutil.ReadFile(fn); err != nil {
+			return nil, fmt.Errorf("could not load code from file: %v", err)
+		}
+	default:
+		hexcode = []byte(ctx.GlobalString(CodeFlag.Name))
+	}
+	hexcode = bytes.TrimSpace(hexcode)
+	if len(hexcode) == 0 {
+		return nil, errors.New("no code specified, use --code or --codefile")
+	}
+	if len(hexcode)%2 != 0 {
+		return nil, fmt.Errorf("invalid input length for hex data (%d)", len(hexcode))
+	}
+	return common.FromHex(string(hexcode)), nil
+}
+
+// runFuzzCode executes the code as the receiver account on top of the prestate,
+// feeding every step into the given tracer.
+func runFuzzCode(ctx *cli.Context, code []byte, tracer vm.EVMLogger) error {
+	var (
+		statedb       *state.StateDB
+		chainConfig   = params.AllEthashProtocolChanges
+		genesisConfig = new(core.Genesis)
+		sender        = common.BytesToAddress([]byte("sender"))
+		receiver      = common.BytesToAddress([]byte("receiver"))
+	)
+	if path := ctx.GlobalString(GenesisFlag.Name); path != "" {
+		genesisConfig = readGenesis(path)
+		db := rawdb.NewMemoryDatabase()
+		genesis := genesisConfig.ToBlock(db)
+		statedb, _ = state.New(genesis.Root(), state.NewDatabase(db), nil)
+		if genesisConfig.Config != nil {
+			chainConfig = genesisConfig.Config
+		}
+	} else {
+		statedb, _ = state.New(common.Hash{}, state.NewDatabase(rawdb.NewMemoryDatabase()), nil)
+	}
+	if addr := ctx.GlobalString(SenderFlag.Name); addr != "" {
+		sender = common.HexToAddress(addr)
+	}
+	if addr := ctx.GlobalString(ReceiverFlag.Name); addr != "" {
+		receiver = common.HexToAddress(addr)
+	}
+	statedb.CreateAccount(sender)
+	statedb.SetCode(receiver, code)
+
+	var hexInput []byte
+	if fn := ctx.GlobalString(InputFileFlag.Name); fn != "" {
+		var err error
+		if hexInput, err = ioutil.ReadFile(fn); err != nil {
+			return fmt.Errorf("could not load input from file: %v", err)
+		}
+	} else {
+		hexInput = []byte(ctx.GlobalString(InputFlag.Name))
+	}
+	gasLimit := ctx.GlobalUint64(GasFlag.Name)
+	if genesisConfig.GasLimit != 0 {
+		gasLimit = genesisConfig.GasLimit
+	}
+	runtimeConfig := &runtime.Config{
+		ChainConfig: chainConfig,
+		Origin:      sender,
+		State:       statedb,
+		GasLimit:    gasLimit,
+		GasPrice:    utils.GlobalBig(ctx, PriceFlag.Name),
+		Value:       utils.GlobalBig(ctx, ValueFlag.Name),
+		Difficulty:  genesisConfig.Difficulty,
+		Time:        new(big.Int).SetUint64(genesisConfig.Timestamp),
+		Coinbase:    genesisConfig.Coinbase,
+		BlockNumber: new(big.Int).SetUint64(genesisConfig.Number),
+		EVMConfig: vm.Config{
+			Tracer: tracer,
+			Debug:  true,
+		},
+	}
+	// Execution errors are part of the trace, only the setup can fail here
+	runtime.Call(receiver, common.FromHex(string(bytes.TrimSpace(hexInput))), runtimeConfig)
+	return nil
+}
+
+// writeFuzzTrace dumps the local trace into the requested destination.
+func writeFuzzTrace(dest string, trace []byte) error {
+	switch dest {
+	case "":
+		return nil
+	case "stdout":
+		_, err := os.Stdout.Write(trace)
+		return err
+	case "stderr":
+		_, err := os.Stderr.Write(trace)
+		return err
+	default:
+		return ioutil.WriteFile(dest, trace, 0644)
+	}
+}
+
+// traceStepFields are the per-opcode fields compared between two traces, in the
+// order they are reported. Fields missing from either of the traces are skipped,
+// since not all implementations emit all of them.
+var traceStepFields = []string{"depth", "pc", "op", "gas", "gasCost", "memSize", "refund", "stack", "memory", "returnData"}
+
+// traceEndFields are the fields of the summary line emitted after execution.
+var traceEndFields = []string{"output", "gasUsed"}
+
+// traceLine is a single normalised line of a JSON trace, mapping field names to
+// their canonical string representation.
+type traceLine map[string]string
+
+// isStep reports whether the line describes an executed opcode.
+func (l traceLine) isStep() bool {
+	_, ok := l["pc"]
+	return ok
+}
+
+// isEnd reports whether the line is the summary emitted at the end of execution.
+func (l traceLine) isEnd() bool {
+	_, ok := l["gasUsed"]
+	return ok
+}
+
+// summary returns a short, single line description of a step.
+func (l traceLine) summary() string {
+	op := l["op"]
+	if code, ok := new(big.Int).SetString(op, 10); ok && code.IsUint64() && code.Uint64() < 256 {
+		op = vm.OpCode(code.Uint64()).String()
+	}
+	return fmt.Sprintf("depth=%s pc=%s op=%s gas=%s cost=%s", l["depth"], l["pc"], op, l["gas"], l["gasCost"])
+}
+
+// fieldDiff is a single mismatching field at the divergence point.
+type fieldDiff struct {
+	Field  string
+	Ours   string
+	Theirs string
+}
+
+// traceDivergence is the minimised report of the first mismatch between two traces.
+type traceDivergence struct {
+	Step    int         // Index of the diverging step, zero based
+	Context []traceLine // Matching steps directly preceding the divergence
+	Ours    traceLine   // Local step at the divergence, nil if the trace ended
+	Theirs  traceLine   // External step at the divergence, nil if the trace ended
+	Diffs   []fieldDiff // Mismatching fields (empty if one trace ended early)
+}
+
+// String formats the divergence into a human readable report.
+func (d *traceDivergence) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "traces diverged at step %d\n", d.Step)
+	for i, line := range d.Context {
+		fmt.Fprintf(&b, "  #%d  %s\n", d.Step-len(d.Context)+i, line.summary())
+	}
+	switch {
+	case d.Ours == nil:
+		fmt.Fprintf(&b, "  local trace ended, external continues with: %s\n", d.Theirs.summary())
+	case d.Theirs == nil:
+		fmt.Fprintf(&b, "  external trace ended, local continues with: %s\n", d.Ours.summary())
+	default:
+		fmt.Fprintf(&b, "> #%d  %s\n", d.Step, d.Ours.summary())
+		for _, diff := range d.Diffs {
+			fmt.Fprintf(&b, "    %-10s ours: %s\n", diff.Field, diff.Ours)
+			fmt.Fprintf(&b, "    %-10s theirs: %s\n", "", diff.Theirs)
+		}
+	}
+	return b.String()
+}
+
+// traceComparer diffs two JSON traces step by step.
+type traceComparer struct {
+	context    int  // Number of matching steps to retain before a divergence
+	memory     bool // Whether to compare memory contents
+	returnData bool // Whether to compare return data
+
+	steps int // Number of steps compared so far
+}
+
+// compare reads the two traces in lock-step and returns the first divergence, or
+// nil if the traces are equivalent.
+func (c *traceComparer) compare(ours, theirs io.Reader) (*traceDivergence, error) {
+	var (
+		ourTrace   = newTraceReader(ours)
+		theirTrace = newTraceReader(theirs)
+		history    []traceLine
+	)
+	c.steps = 0
+	for {
+		our, err := ourTrace.next()
+		if err != nil {
+			return nil, fmt.Errorf("local trace: %v", err)
+		}
+		their, err := theirTrace.next()
+		if err != nil {
+			return nil, fmt.Errorf("external trace: %v", err)
+		}
+		if our == nil && their == nil {
+			return nil, nil
+		}
+		if our == nil || their == nil || our.isStep() != their.isStep() {
+			div := &traceDivergence{Step: c.steps, Context: history}
+			if our != nil && our.isStep() {
+				div.Ours = our
+			}
+			if their != nil && their.isStep() {
+				div.Theirs = their
+			}
+			return div, nil
+		}
+		fields := traceStepFields
+		if !our.isStep() {
+			fields = traceEndFields
+		}
+		if diffs := c.diff(fields, our, their); len(diffs) > 0 {
+			return &traceDivergence{Step: c.steps, Context: history, Ours: our, Theirs: their, Diffs: diffs}, nil
+		}
+		if !our.isStep() {
+			continue
+		}
+		c.steps++
+		if c.context > 0 {
+			if len(history) == c.context {
+				history = history[1:]
+			}
+			history = append(history, our)
+		}
+	}
+}
+
+// diff returns the mismatching fields of two trace lines.
+func (c *traceComparer) diff(fields []string, our, their traceLine) []fieldDiff {
+	var diffs []fieldDiff
+	for _, field := range fields {
+		if (field == "memory" && !c.memory) || (field == "returnData" && !c.returnData) {
+			continue
+		}
+		a, ok1 := our[field]
+		b, ok2 := their[field]
+		if !ok1 || !ok2 || a == b {
+			continue
+		}
+		if field == "stack" {
+			a, b = minimiseStacks(a, b)
+		}
+		diffs = append(diffs, fieldDiff{Field: field, Ours: a, Theirs: b})
+	}
+	return diffs
+}
+
+// minimiseStacks strips the common bottom of two stacks, leaving only the items
+// that actually differ.
+func minimiseStacks(a, b string) (string, string) {
+	as, bs := strings.Split(a, ","), strings.Split(b, ",")
+	i := 0
+	for i < len(as) && i < len(bs) && as[i] == bs[i] {
+		i++
+	}
+	if i == 0 {
+		return a, b
+	}
+	prefix := fmt.Sprintf("[%d common]", i)
+	return strings.Join(append([]string{prefix}, as[i:]...), ","), strings.Join(append([]string{prefix}, bs[i:]...), ",")
+}
+
+// traceReader parses a stream of JSON trace lines.
+type traceReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newTraceReader(r io.Reader) *traceReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	return &traceReader{scanner: scanner}
+}
+
+// next returns the next step or summary line of the trace, or nil at the end of
+// the stream. Empty lines and unknown objects (e.g. state roots) are skipped.
+func (r *traceReader) next() (traceLine, error) {
+	for r.scanner.Scan() {
+		r.line++
+		raw := bytes.TrimSpace(r.scanner.Bytes())
+		if len(raw) == 0 || raw[0] != '{' {
+			continue
+		}
+		line, err := parseTraceLine(raw)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", r.line, err)
+		}
+		if line.isStep() || line.isEnd() {
+			return line, nil
+		}
+	}
+	return nil, r.scanner.Err()
+}
+
+// parseTraceLine decodes a JSON trace line into its normalised form. Numbers are
+// accepted both as JSON numbers and as hex or decimal strings, and byte blobs
+// are compared case insensitively, so that formatting differences between the
+// implementations don't show up as divergences.
+func parseTraceLine(raw []byte) (traceLine, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	line := make(traceLine)
+	for name, value := range fields {
+		if string(value) == "null" {
+			continue
+		}
+		switch name {
+		case "depth", "pc", "op", "gas", "gasCost", "memSize", "refund", "gasUsed":
+			n, err := parseTraceNumber(value)
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %v", name, err)
+			}
+			line[name] = n.String()
+
+		case "stack":
+			var items []json.RawMessage
+			if err := json.Unmarshal(value, &items); err != nil {
+				return nil, fmt.Errorf("field %q: %v", name, err)
+			}
+			stack := make([]string, len(items))
+			for i, item := range items {
+				n, err := parseTraceNumber(item)
+				if err != nil {
+					return nil, fmt.Errorf("field %q item %d: %v", name, i, err)
+				}
+				stack[i] = "0x" + n.Text(16)
+			}
+			line[name] = strings.Join(stack, ",")
+
+		case "memory", "returnData", "output":
+			var blob string
+			if err := json.Unmarshal(value, &blob); err != nil {
+				return nil, fmt.Errorf("field %q: %v", name, err)
+			}
+			line[name] = "0x" + strings.ToLower(strings.TrimPrefix(blob, "0x"))
+		}
+	}
+	return line, nil
+}
+
+// parseTraceNumber decodes a JSON number or a hex/decimal string.
+func parseTraceNumber(value json.RawMessage) (*big.Int, error) {
+	var s string
+	if len(value) > 0 && value[0] == '"' {
+		if err := json.Unmarshal(value, &s); err != nil {
+			return nil, err
+		}
+	} else {
+		s = string(value)
+	}
+	n, ok := math.ParseBig256(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", s)
+	}
+	return n, nil
+}
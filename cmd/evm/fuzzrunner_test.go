@@ -0,0 +1,96 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// Trace of PUSH1 1, PUSH1 2, ADD, STOP as emitted by the local JSON logger.
+const fuzzLocalTrace = `{"pc":0,"op":96,"gas":"0x2540be400","gasCost":"0x3","memory":"0x","memSize":0,"stack":[],"returnData":"0x","depth":1,"refund":0,"opName":"PUSH1","error":""}
+{"pc":2,"op":96,"gas":"0x2540be3fd","gasCost":"0x3","memory":"0x","memSize":0,"stack":["0x1"],"returnData":"0x","depth":1,"refund":0,"opName":"PUSH1","error":""}
+{"pc":4,"op":1,"gas":"0x2540be3fa","gasCost":"0x3","memory":"0x","memSize":0,"stack":["0x1","0x2"],"returnData":"0x","depth":1,"refund":0,"opName":"ADD","error":""}
+{"pc":5,"op":0,"gas":"0x2540be3f7","gasCost":"0x0","memory":"0x","memSize":0,"stack":["0x3"],"returnData":"0x","depth":1,"refund":0,"opName":"STOP","error":""}
+{"output":"","gasUsed":"0x9","time":1000}
+`
+
+func TestFuzzTraceMatch(t *testing.T) {
+	// Same trace, formatted the way a different implementation might
+	external := `{"depth":1,"pc":0,"op":96,"gas":"10000000000","gasCost":3,"stack":[]}
+{"depth":1,"pc":2,"op":96,"gas":"0x2540BE3FD","gasCost":3,"stack":["0x0000000000000000000000000000000000000000000000000000000000000001"]}
+{"stateRoot":"0x00"}
+{"depth":1,"pc":4,"op":1,"gas":"0x2540be3fa","gasCost":3,"stack":["0x1","0x2"]}
+{"depth":1,"pc":5,"op":0,"gas":"0x2540be3f7","gasCost":0,"stack":["0x3"]}
+{"output":"0x","gasUsed":9}
+`
+	cmp := &traceComparer{context: 2}
+	div, err := cmp.compare(strings.NewReader(fuzzLocalTrace), strings.NewReader(external))
+	if err != nil {
+		t.Fatalf("comparison failed: %v", err)
+	}
+	if div != nil {
+		t.Fatalf("unexpected divergence:\n%v", div)
+	}
+}
+
+func TestFuzzTraceDivergence(t *testing.T) {
+	external := `{"depth":1,"pc":0,"op":96,"gas":"0x2540be400","gasCost":"0x3","stack":[]}
+{"depth":1,"pc":2,"op":96,"gas":"0x2540be3fd","gasCost":"0x3","stack":["0x1"]}
+{"depth":1,"pc":4,"op":1,"gas":"0x2540be3fa","gasCost":"0x5","stack":["0x1","0x3"]}
+`
+	cmp := &traceComparer{context: 1}
+	div, err := cmp.compare(strings.NewReader(fuzzLocalTrace), strings.NewReader(external))
+	if err != nil {
+		t.Fatalf("comparison failed: %v", err)
+	}
+	if div == nil {
+		t.Fatal("divergence not detected")
+	}
+	if div.Step != 2 {
+		t.Errorf("divergence step mismatch: have %d, want 2", div.Step)
+	}
+	if len(div.Context) != 1 || div.Context[0]["pc"] != "2" {
+		t.Errorf("context mismatch: have %v", div.Context)
+	}
+	if len(div.Diffs) != 2 {
+		t.Fatalf("diff count mismatch: have %d, want 2: %v", len(div.Diffs), div.Diffs)
+	}
+	if d := div.Diffs[0]; d.Field != "gasCost" || d.Ours != "3" || d.Theirs != "5" {
+		t.Errorf("gas cost diff mismatch: %+v", d)
+	}
+	if d := div.Diffs[1]; d.Field != "stack" || d.Ours != "[1 common],0x2" || d.Theirs != "[1 common],0x3" {
+		t.Errorf("stack diff mismatch: %+v", d)
+	}
+}
+
+func TestFuzzTraceTruncated(t *testing.T) {
+	external := `{"depth":1,"pc":0,"op":96,"gas":"0x2540be400","gasCost":"0x3","stack":[]}
+{"output":"","gasUsed":"0x3","error":"out of gas"}
+`
+	cmp := new(traceComparer)
+	div, err := cmp.compare(strings.NewReader(fuzzLocalTrace), strings.NewReader(external))
+	if err != nil {
+		t.Fatalf("comparison failed: %v", err)
+	}
+	if div == nil {
+		t.Fatal("divergence not detected")
+	}
+	if div.Step != 1 || div.Ours == nil || div.Theirs != nil {
+		t.Errorf("unexpected divergence: %+v", div)
+	}
+}
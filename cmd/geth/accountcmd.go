@@ -21,14 +21,25 @@ import (
 	"io/ioutil"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/console/prompt"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"gopkg.in/urfave/cli.v1"
 )
 
 var (
+	mnemonicFlag = cli.BoolFlag{
+		Name:  "mnemonic",
+		Usage: "Use a hierarchical deterministic wallet backed by a BIP-39 mnemonic",
+	}
+	mnemonicPassphraseFlag = cli.BoolFlag{
+		Name:  "mnemonic.passphrase",
+		Usage: "Prompt for the BIP-39 passphrase of the imported mnemonic",
+	}
+
 	walletCommand = cli.Command{
 		Name:      "wallet",
 		Usage:     "Manage Ethereum presale wallets",
@@ -109,6 +120,7 @@ Print a short summary of all accounts`,
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					mnemonicFlag,
 				},
 				Description: `
     geth account new
@@ -123,6 +135,17 @@ For non-interactive use the password can be specified with the --password flag:
 
 Note, this is meant to be used for testing only, it is a bad idea to save your
 password to file or expose in any other way.
+
+    geth account new --mnemonic
+
+Creates a hierarchical deterministic wallet from a new 24 word BIP-39 mnemonic,
+and prints the mnemonic along with the address of its first account, derived at
+m/44'/60'/0'/0/0. The seed is saved in encrypted format in the keystore, further
+accounts are derived with personal.deriveAccount once the wallet is opened with
+personal.openWallet. The wallet is not opened on startup, and opening it over
+HTTP requires --allow-insecure-unlock.
+
+The mnemonic is not saved, write it down: it restores all accounts of the wallet.
 `,
 			},
 			{
@@ -163,6 +186,8 @@ changing your password is only possible interactively.
 					utils.KeyStoreDirFlag,
 					utils.PasswordFileFlag,
 					utils.LightKDFFlag,
+					mnemonicFlag,
+					mnemonicPassphraseFlag,
 				},
 				ArgsUsage: "<keyFile>",
 				Description: `
@@ -181,6 +206,13 @@ For non-interactive use the password can be specified with the -password flag:
 
     geth account import [options] <keyfile>
 
+With the --mnemonic flag, a hierarchical deterministic wallet is restored from
+the BIP-39 mnemonic in <keyfile> instead. If no file is given, you are prompted
+for the mnemonic. If the mnemonic is protected by a BIP-39 passphrase, use the
+--mnemonic.passphrase flag to be prompted for it.
+
+    geth account import --mnemonic [--mnemonic.passphrase] [<keyfile>]
+
 Note:
 As you can directly copy your encrypted accounts to another ethereum instance,
 this import mechanism is not needed when you transfer an account between
@@ -281,6 +313,20 @@ func accountCreate(ctx *cli.Context) error {
 
 	password := utils.GetPassPhraseWithList("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
 
+	if ctx.Bool(mnemonicFlag.Name) {
+		wallet, mnemonic, err := hdwallet.NewBackend(keydir, scryptN, scryptP).NewWallet(password)
+		if err != nil {
+			utils.Fatalf("Failed to create wallet: %v", err)
+		}
+		fmt.Printf("\nYour new wallet was generated\n\n")
+		fmt.Printf("Public address of the first account: %s\n", wallet.Accounts()[0].Address.Hex())
+		fmt.Printf("Path of the secret seed file:        %s\n\n", wallet.URL().Path)
+		fmt.Printf("Mnemonic of the wallet:\n\n%s\n\n", mnemonic)
+		fmt.Printf("- You must NEVER share the mnemonic with anyone! It controls access to all accounts of the wallet!\n")
+		fmt.Printf("- You must BACKUP your mnemonic! It is not stored, but restores all accounts with `geth account import --mnemonic`!\n")
+		fmt.Printf("- You must REMEMBER your password! Without the password, it's impossible to decrypt the seed file!\n\n")
+		return nil
+	}
 	account, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
 
 	if err != nil {
@@ -338,6 +384,9 @@ func importWallet(ctx *cli.Context) error {
 }
 
 func accountImport(ctx *cli.Context) error {
+	if ctx.Bool(mnemonicFlag.Name) {
+		return mnemonicImport(ctx)
+	}
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
 		utils.Fatalf("keyfile must be given as argument")
@@ -357,3 +406,46 @@ func accountImport(ctx *cli.Context) error {
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	return nil
 }
+
+// mnemonicImport restores a hierarchical deterministic wallet from a mnemonic,
+// read from the file given as argument or prompted for.
+func mnemonicImport(ctx *cli.Context) error {
+	var mnemonic string
+	if file := ctx.Args().First(); file != "" {
+		blob, err := ioutil.ReadFile(file)
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = string(blob)
+	} else {
+		input, err := prompt.Stdin.PromptPassword("Mnemonic: ")
+		if err != nil {
+			utils.Fatalf("Failed to read the mnemonic: %v", err)
+		}
+		mnemonic = input
+	}
+	var seedPassphrase string
+	if ctx.Bool(mnemonicPassphraseFlag.Name) {
+		input, err := prompt.Stdin.PromptPassword("BIP-39 passphrase: ")
+		if err != nil {
+			utils.Fatalf("Failed to read the BIP-39 passphrase: %v", err)
+		}
+		seedPassphrase = input
+	}
+	stack, _ := makeConfigNode(ctx)
+	passphrase := utils.GetPassPhraseWithList("Your new wallet is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+
+	// Create the backend directly, it isn't registered if an external signer is used
+	scryptN, scryptP := keystore.StandardScryptN, keystore.StandardScryptP
+	if stack.Config().UseLightweightKDF {
+		scryptN, scryptP = keystore.LightScryptN, keystore.LightScryptP
+	}
+	backend := hdwallet.NewBackend(stack.KeyStoreDir(), scryptN, scryptP)
+	wallet, err := backend.ImportWithSeedPassphrase(mnemonic, seedPassphrase, passphrase)
+	if err != nil {
+		utils.Fatalf("Could not import the mnemonic: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", wallet.Accounts()[0].Address)
+	fmt.Printf("Wallet:  %s\n", wallet.URL())
+	return nil
+}
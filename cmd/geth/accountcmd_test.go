@@ -125,6 +125,25 @@ func importAccountWithExpect(t *testing.T, key string, expected string) {
 	geth.Expect(expected)
 }
 
+func TestAccountImportMnemonicPassphrase(t *testing.T) {
+	dir := tmpdir(t)
+	mnemonicFile := filepath.Join(dir, "mnemonic.txt")
+	if err := ioutil.WriteFile(mnemonicFile, []byte("test test test test test test test test test test test junk"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(dir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	geth := runGeth(t, "account", "import", "--lightkdf", "--mnemonic", "--mnemonic.passphrase", "--password", passwordFile, mnemonicFile)
+	defer geth.ExpectExit()
+	geth.Expect(`
+!! Unsupported terminal, password will be echoed.
+BIP-39 passphrase: {{.InputLine "TREZOR"}}
+`)
+	geth.ExpectRegexp(`Address: \{9313778b3753108128b9c476ebdd42fbd566f4ed\}\nWallet:  .+\n`)
+}
+
 func TestAccountNewBadRepeat(t *testing.T) {
 	geth := runGeth(t, "account", "new", "--lightkdf")
 	defer geth.ExpectExit()
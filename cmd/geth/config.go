@@ -28,7 +28,9 @@ import (
 	"gopkg.in/urfave/cli.v1"
 
 	"github.com/ethereum/go-ethereum/accounts/external"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/remote"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/cmd/utils"
@@ -156,6 +158,10 @@ func makeConfigNode(ctx *cli.Context) (*node.Node, gethConfig) {
 // makeFullNode loads geth configuration and creates the Ethereum backend.
 func makeFullNode(ctx *cli.Context) (*node.Node, ethapi.Backend) {
 	stack, cfg := makeConfigNode(ctx)
+
+	// Start span export if enabled, before any database is opened
+	utils.SetupTracing(ctx, stack)
+
 	if ctx.GlobalIsSet(utils.OverrideArrowGlacierFlag.Name) {
 		cfg.Eth.OverrideArrowGlacier = new(big.Int).SetUint64(ctx.GlobalUint64(utils.OverrideArrowGlacierFlag.Name))
 	}
@@ -281,12 +287,24 @@ func setAccountManagerBackends(stack *node.Node) error {
 	// Assemble the supported backends
 	if len(conf.ExternalSigner) > 0 {
 		log.Info("Using external signer", "url", conf.ExternalSigner)
-		if extapi, err := external.NewExternalBackend(conf.ExternalSigner); err == nil {
-			am.AddBackend(extapi)
-			return nil
+		var (
+			extapi *external.ExternalBackend
+			err    error
+		)
+		if conf.ExternalSignerCert != "" || conf.ExternalSignerKey != "" || conf.ExternalSignerCA != "" {
+			tlsConfig, tlsErr := external.NewTLSConfig(conf.ExternalSignerCert, conf.ExternalSignerKey, conf.ExternalSignerCA)
+			if tlsErr != nil {
+				return fmt.Errorf("invalid external signer TLS configuration: %v", tlsErr)
+			}
+			extapi, err = external.NewExternalBackendWithTLS(conf.ExternalSigner, tlsConfig)
 		} else {
+			extapi, err = external.NewExternalBackend(conf.ExternalSigner)
+		}
+		if err != nil {
 			return fmt.Errorf("error connecting to external signer: %v", err)
 		}
+		am.AddBackend(extapi)
+		return nil
 	}
 
 	// For now, we're using EITHER external signer OR local signers.
@@ -294,6 +312,20 @@ func setAccountManagerBackends(stack *node.Node) error {
 	// we can have both, but it's very confusing for the user to see the same
 	// accounts in both externally and locally, plus very racey.
 	am.AddBackend(keystore.NewKeyStore(keydir, scryptN, scryptP))
+	am.AddBackend(hdwallet.NewBackend(keydir, scryptN, scryptP))
+	if len(conf.RemoteSigner) > 0 {
+		// Remote keys are usable without unlocking, so they are subject to the
+		// same restrictions as unlocked accounts if the node's APIs are exposed.
+		if !conf.InsecureUnlockAllowed && conf.ExtRPCEnabled() {
+			return fmt.Errorf("remote signer with HTTP access is forbidden, use --%s to allow", utils.InsecureUnlockAllowedFlag.Name)
+		}
+		log.Info("Using remote signer", "url", conf.RemoteSigner)
+		backend, err := remote.NewHTTPBackend(conf.RemoteSigner, nil)
+		if err != nil {
+			return fmt.Errorf("error connecting to remote signer: %v", err)
+		}
+		am.AddBackend(backend)
+	}
 	if conf.USB {
 		// Start a USB hub for Ledger hardware wallets
 		if ledgerhub, err := usbwallet.NewLedgerHub(); err != nil {
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/cmd/utils"
 	"github.com/ethereum/go-ethereum/common"
@@ -68,6 +69,10 @@ var (
 		utils.MinFreeDiskSpaceFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
+		utils.ExternalSignerCertFlag,
+		utils.ExternalSignerKeyFlag,
+		utils.ExternalSignerCAFlag,
+		utils.RemoteSignerFlag,
 		utils.NoUSBFlag,
 		utils.USBFlag,
 		utils.SmartCardDaemonPathFlag,
@@ -134,6 +139,7 @@ var (
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
 		utils.NetrestrictFlag,
+		utils.NetCaptureFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DNSDiscoveryFlag,
@@ -201,6 +207,12 @@ var (
 		utils.MetricsInfluxDBBucketFlag,
 		utils.MetricsInfluxDBOrganizationFlag,
 	}
+
+	tracingFlags = []cli.Flag{
+		utils.TracingEnabledFlag,
+		utils.TracingEndpointFlag,
+		utils.TracingDatabaseFlag,
+	}
 )
 
 func init() {
@@ -247,6 +259,7 @@ func init() {
 	app.Flags = append(app.Flags, consoleFlags...)
 	app.Flags = append(app.Flags, debug.Flags...)
 	app.Flags = append(app.Flags, metricsFlags...)
+	app.Flags = append(app.Flags, tracingFlags...)
 
 	app.Before = func(ctx *cli.Context) error {
 		return debug.Setup(ctx)
@@ -348,8 +361,12 @@ func startNode(ctx *cli.Context, stack *node.Node, backend ethapi.Backend) {
 	ethClient := ethclient.NewClient(rpcClient)
 
 	go func() {
-		// Open any wallets already attached
+		// Open any wallets already attached. Seed wallets are encrypted with
+		// a user passphrase, they are only opened on explicit request.
 		for _, wallet := range stack.AccountManager().Wallets() {
+			if wallet.URL().Scheme == hdwallet.Scheme {
+				continue
+			}
 			if err := wallet.Open(""); err != nil {
 				log.Warn("Failed to open wallet", "url", wallet.URL(), "err", err)
 			}
@@ -358,6 +375,9 @@ func startNode(ctx *cli.Context, stack *node.Node, backend ethapi.Backend) {
 		for event := range events {
 			switch event.Kind {
 			case accounts.WalletArrived:
+				if event.Wallet.URL().Scheme == hdwallet.Scheme {
+					continue
+				}
 				if err := event.Wallet.Open(""); err != nil {
 					log.Warn("New wallet appeared, failed to open", "url", event.Wallet.URL(), "err", err)
 				}
@@ -127,6 +127,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.UnlockedAccountFlag,
 			utils.PasswordFileFlag,
 			utils.ExternalSignerFlag,
+			utils.ExternalSignerCertFlag,
+			utils.ExternalSignerKeyFlag,
+			utils.ExternalSignerCAFlag,
+			utils.RemoteSignerFlag,
 			utils.InsecureUnlockAllowedFlag,
 		},
 	},
@@ -172,6 +176,7 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
 			utils.NetrestrictFlag,
+			utils.NetCaptureFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
 		},
@@ -217,6 +222,10 @@ var AppHelpFlagGroups = []flags.FlagGroup{
 		Name:  "METRICS AND STATS",
 		Flags: metricsFlags,
 	},
+	{
+		Name:  "TRACING",
+		Flags: tracingFlags,
+	},
 	{
 		Name: "ALIASED (deprecated)",
 		Flags: []cli.Flag{
@@ -34,6 +34,19 @@
 //     $ p2psim node connect node01 node02
 //     Connected node01 to node02
 //
+// When the simulation runs in virtual time (e.g. the ping-pong example server
+// started with --virtual-time), network conditions can be changed and the clock
+// can be advanced:
+//
+//     $ p2psim node link --latency 50ms --loss 0.01 node01 node02
+//     Updated link between node01 and node02
+//
+//     $ p2psim partition node01 node02
+//     Partitioned network into 2 groups
+//
+//     $ p2psim advance 10s
+//     Advanced clock by 10s
+//
 package main
 
 import (
@@ -44,12 +57,14 @@ import (
 	"os"
 	"strings"
 	"text/tabwriter"
+	"time"
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/simulations"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
 	"github.com/ethereum/go-ethereum/rpc"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -103,6 +118,23 @@ func main() {
 			Usage:  "load a network snapshot from stdin",
 			Action: loadSnapshot,
 		},
+		{
+			Name:      "partition",
+			ArgsUsage: "<nodes> <nodes> [<nodes>...]",
+			Usage:     "split the network into groups of nodes (comma separated)",
+			Action:    partitionNetwork,
+		},
+		{
+			Name:   "heal",
+			Usage:  "restore the default network conditions on all links",
+			Action: healNetwork,
+		},
+		{
+			Name:      "advance",
+			ArgsUsage: "<duration>",
+			Usage:     "advance the virtual clock of the network",
+			Action:    advanceTime,
+		},
 		{
 			Name:   "node",
 			Usage:  "manage simulation nodes",
@@ -165,6 +197,30 @@ func main() {
 					Usage:     "disconnect a node from a peer node",
 					Action:    disconnectNode,
 				},
+				{
+					Name:      "link",
+					ArgsUsage: "<node> <peer>",
+					Usage:     "set the network conditions between a node and a peer node",
+					Action:    setLink,
+					Flags: []cli.Flag{
+						cli.DurationFlag{
+							Name:  "latency",
+							Usage: "one-way delay",
+						},
+						cli.Int64Flag{
+							Name:  "bandwidth",
+							Usage: "bandwidth in bytes per second (0 = unlimited)",
+						},
+						cli.Float64Flag{
+							Name:  "loss",
+							Usage: "probability that a write needs to be retransmitted",
+						},
+						cli.BoolFlag{
+							Name:  "down",
+							Usage: "cut the link",
+						},
+					},
+				},
 				{
 					Name:      "rpc",
 					ArgsUsage: "<node> <method> [<args>]",
@@ -249,6 +305,49 @@ func loadSnapshot(ctx *cli.Context) error {
 	return client.LoadSnapshot(snap)
 }
 
+func partitionNetwork(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) < 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	groups := make([][]string, len(args))
+	for i, arg := range args {
+		groups[i] = strings.Split(arg, ",")
+	}
+	if err := client.Partition(groups...); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Partitioned network into", len(groups), "groups")
+	return nil
+}
+
+func healNetwork(ctx *cli.Context) error {
+	if len(ctx.Args()) != 0 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	if err := client.Heal(); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Healed network")
+	return nil
+}
+
+func advanceTime(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 1 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	d, err := time.ParseDuration(args[0])
+	if err != nil {
+		return err
+	}
+	if err := client.AdvanceTime(d); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Advanced clock by", d)
+	return nil
+}
+
 func listNodes(ctx *cli.Context) error {
 	if len(ctx.Args()) != 0 {
 		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
@@ -378,6 +477,26 @@ func disconnectNode(ctx *cli.Context) error {
 	return nil
 }
 
+func setLink(ctx *cli.Context) error {
+	args := ctx.Args()
+	if len(args) != 2 {
+		return cli.ShowCommandHelp(ctx, ctx.Command.Name)
+	}
+	nodeName := args[0]
+	peerName := args[1]
+	config := pipes.LinkConfig{
+		Latency:   ctx.Duration("latency"),
+		Bandwidth: ctx.Int64("bandwidth"),
+		Loss:      ctx.Float64("loss"),
+		Down:      ctx.Bool("down"),
+	}
+	if err := client.SetLink(nodeName, peerName, config); err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.App.Writer, "Updated link between", nodeName, "and", peerName)
+	return nil
+}
+
 func rpcNode(ctx *cli.Context) error {
 	args := ctx.Args()
 	if len(args) < 2 {
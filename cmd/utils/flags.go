@@ -54,6 +54,7 @@ import (
 	"github.com/ethereum/go-ethereum/graphql"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/internal/flags"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/les"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
@@ -489,6 +490,22 @@ var (
 		Usage: "External signer (url or path to ipc file)",
 		Value: "",
 	}
+	ExternalSignerCertFlag = cli.StringFlag{
+		Name:  "signer.tlscert",
+		Usage: "Client certificate file for authenticating to an external signer over TLS",
+	}
+	ExternalSignerKeyFlag = cli.StringFlag{
+		Name:  "signer.tlskey",
+		Usage: "Client key file for authenticating to an external signer over TLS",
+	}
+	ExternalSignerCAFlag = cli.StringFlag{
+		Name:  "signer.tlsca",
+		Usage: "CA file for verifying the certificate of an external signer over TLS",
+	}
+	RemoteSignerFlag = cli.StringFlag{
+		Name:  "remotesigner",
+		Usage: "HTTP endpoint of a remote key management service holding signing keys (keys are used without unlocking)",
+	}
 	VMEnableDebugFlag = cli.BoolFlag{
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
@@ -655,7 +672,7 @@ var (
 	}
 	NATFlag = cli.StringFlag{
 		Name:  "nat",
-		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|extip:<IP>)",
+		Usage: "NAT port mapping mechanism (any|none|upnp|pmp|pcp|extip:<IP>|stun:<server>)",
 		Value: "any",
 	}
 	NoDiscoverFlag = cli.BoolFlag{
@@ -670,6 +687,10 @@ var (
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
 	}
+	NetCaptureFlag = cli.StringFlag{
+		Name:  "netcapture",
+		Usage: "Writes all messages exchanged with peers to the given file (for debugging)",
+	}
 	DNSDiscoveryFlag = cli.StringFlag{
 		Name:  "discovery.dns",
 		Usage: "Sets DNS discovery entry points (use \"\" to disable DNS)",
@@ -785,6 +806,21 @@ var (
 		Value: metrics.DefaultConfig.InfluxDBOrganization,
 	}
 
+	// Tracing flags
+	TracingEnabledFlag = cli.BoolFlag{
+		Name:  "tracing",
+		Usage: "Enable span tracing of RPC calls, block imports and state access",
+	}
+	TracingEndpointFlag = cli.StringFlag{
+		Name:  "tracing.endpoint",
+		Usage: "OpenTelemetry collector endpoint to export spans to (OTLP/HTTP with JSON encoding)",
+		Value: "http://localhost:4318/v1/traces",
+	}
+	TracingDatabaseFlag = cli.DurationFlag{
+		Name:  "tracing.database",
+		Usage: "Trace database reads slower than the given threshold (0 = disabled)",
+	}
+
 	CatalystFlag = cli.BoolFlag{
 		Name:  "catalyst",
 		Usage: "Catalyst mode (eth2 integration testing)",
@@ -1195,6 +1231,9 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		}
 		cfg.NetRestrict = list
 	}
+	if ctx.GlobalIsSet(NetCaptureFlag.Name) {
+		cfg.MsgCaptureFile = ctx.GlobalString(NetCaptureFlag.Name)
+	}
 
 	if ctx.GlobalBool(DeveloperFlag.Name) || ctx.GlobalBool(CatalystFlag.Name) {
 		// --dev mode can't use p2p networking.
@@ -1220,6 +1259,18 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(ExternalSignerFlag.Name) {
 		cfg.ExternalSigner = ctx.GlobalString(ExternalSignerFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExternalSignerCertFlag.Name) {
+		cfg.ExternalSignerCert = ctx.GlobalString(ExternalSignerCertFlag.Name)
+	}
+	if ctx.GlobalIsSet(ExternalSignerKeyFlag.Name) {
+		cfg.ExternalSignerKey = ctx.GlobalString(ExternalSignerKeyFlag.Name)
+	}
+	if ctx.GlobalIsSet(ExternalSignerCAFlag.Name) {
+		cfg.ExternalSignerCA = ctx.GlobalString(ExternalSignerCAFlag.Name)
+	}
+	if ctx.GlobalIsSet(RemoteSignerFlag.Name) {
+		cfg.RemoteSigner = ctx.GlobalString(RemoteSignerFlag.Name)
+	}
 
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
@@ -1802,6 +1853,22 @@ func SetupMetrics(ctx *cli.Context) {
 	}
 }
 
+// SetupTracing starts the span exporter if tracing was enabled. The exporter is
+// registered with the node, so pending spans are flushed on shutdown.
+func SetupTracing(ctx *cli.Context, stack *node.Node) {
+	if !ctx.GlobalBool(TracingEnabledFlag.Name) {
+		return
+	}
+	endpoint := ctx.GlobalString(TracingEndpointFlag.Name)
+	log.Info("Enabling span tracing", "endpoint", endpoint)
+	stack.RegisterLifecycle(tracing.Setup(endpoint, "geth"))
+
+	if threshold := ctx.GlobalDuration(TracingDatabaseFlag.Name); threshold > 0 {
+		log.Info("Enabling database read tracing", "threshold", threshold)
+		tracing.DatabaseThreshold = threshold
+	}
+}
+
 func SplitTagsFlag(tagsFlag string) map[string]string {
 	tags := strings.Split(tagsFlag, ",")
 	tagsMap := map[string]string{}
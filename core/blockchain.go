@@ -18,6 +18,7 @@
 package core
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -40,6 +41,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/internal/syncx"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/params"
@@ -132,6 +134,15 @@ type CacheConfig struct {
 	Preimages           bool          // Whether to store preimage of trie key to the disk
 
 	SnapshotWait bool // Wait for snapshot construction on startup. TODO(karalabe): This is a dirty hack for testing, nuke it
+
+	// StateDatabase optionally overrides the state database constructor, e.g. to
+	// lazily mirror the state of a remote chain. Snapshots are generated from the
+	// local tries only, so they might need to be disabled for custom databases.
+	StateDatabase func(db ethdb.Database, config *trie.Config) state.Database
+
+	// Fork optionally identifies the block of another chain whose state the
+	// chain runs on, so the EVM reports block numbers and hashes continuing it.
+	Fork ForkContext
 }
 
 // defaultCacheConfig are the default caching values if none are specified by the
@@ -227,12 +238,16 @@ func NewBlockChain(db ethdb.Database, cacheConfig *CacheConfig, chainConfig *par
 	txLookupCache, _ := lru.New(txLookupCacheLimit)
 	futureBlocks, _ := lru.New(maxFutureBlocks)
 
+	newStateDatabase := state.NewDatabaseWithConfig
+	if cacheConfig.StateDatabase != nil {
+		newStateDatabase = cacheConfig.StateDatabase
+	}
 	bc := &BlockChain{
 		chainConfig: chainConfig,
 		cacheConfig: cacheConfig,
 		db:          db,
 		triegc:      prque.New(nil),
-		stateCache: state.NewDatabaseWithConfig(db, &trie.Config{
+		stateCache: newStateDatabase(db, &trie.Config{
 			Cache:     cacheConfig.TrieCleanLimit,
 			Journal:   cacheConfig.TrieCleanJournal,
 			Preimages: cacheConfig.Preimages,
@@ -1413,6 +1428,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 	// Start a parallel signature recovery (signer will fluke on fork transition, minimal perf loss)
 	senderCacher.recoverFromBlocks(types.MakeSigner(bc.chainConfig, chain[0].Number()), chain)
 
+	chainCtx, chainSpan := tracing.Start(context.Background(), "core/insertChain", "blocks", len(chain), "first", chain[0].NumberU64())
+	defer chainSpan.End()
+
 	var (
 		stats     = insertStats{startTime: mclock.Now()}
 		lastCanon *types.Block
@@ -1578,8 +1596,13 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		if parent == nil {
 			parent = bc.GetHeader(block.ParentHash(), block.NumberU64()-1)
 		}
+		blockCtx, blockSpan := tracing.Start(chainCtx, "core/insertBlock", "number", block.NumberU64(), "hash", block.Hash(),
+			"txs", len(block.Transactions()), "gas", block.GasUsed())
+
 		statedb, err := state.New(parent.Root, bc.stateCache, bc.snaps)
 		if err != nil {
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
 
@@ -1595,7 +1618,10 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 				throwaway, _ := state.New(parent.Root, bc.stateCache, bc.snaps)
 
 				go func(start time.Time, followup *types.Block, throwaway *state.StateDB, interrupt *uint32) {
+					_, span := tracing.Start(blockCtx, "core/prefetch", "number", followup.NumberU64())
 					bc.prefetcher.Prefetch(followup, throwaway, bc.vmConfig, &followupInterrupt)
+					span.SetAttributes("interrupted", atomic.LoadUint32(interrupt) == 1)
+					span.End()
 
 					blockPrefetchExecuteTimer.Update(time.Since(start))
 					if atomic.LoadUint32(interrupt) == 1 {
@@ -1607,10 +1633,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		// Process block using the parent state as reference point
 		substart := time.Now()
+		_, span := tracing.Start(blockCtx, "core/execution")
 		receipts, logs, usedGas, err := bc.processor.Process(block, statedb, bc.vmConfig)
+		span.SetError(err)
+		span.End()
 		if err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
 
@@ -1629,11 +1660,17 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		// Validate the state using the default validator
 		substart = time.Now()
+		_, span = tracing.Start(blockCtx, "core/validation")
 		if err := bc.validator.ValidateState(block, statedb, receipts, usedGas); err != nil {
 			bc.reportBlock(block, receipts, err)
 			atomic.StoreUint32(&followupInterrupt, 1)
+			span.SetError(err)
+			span.End()
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
+		span.End()
 		proctime := time.Since(start)
 
 		// Update the metrics touched during block validation
@@ -1644,9 +1681,15 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 
 		// Write the block to the chain and get the status.
 		substart = time.Now()
+		commitCtx, span := tracing.Start(blockCtx, "core/commit")
+		statedb.SetTraceContext(commitCtx)
 		status, err := bc.writeBlockWithState(block, receipts, logs, statedb, false)
 		atomic.StoreUint32(&followupInterrupt, 1)
+		span.SetError(err)
+		span.End()
 		if err != nil {
+			blockSpan.SetError(err)
+			blockSpan.End()
 			return it.index, err
 		}
 		// Update the metrics touched during block commit
@@ -1657,6 +1700,9 @@ func (bc *BlockChain) insertChain(chain types.Blocks, verifySeals bool) (int, er
 		blockWriteTimer.Update(time.Since(substart) - statedb.AccountCommits - statedb.StorageCommits - statedb.SnapshotCommits)
 		blockInsertTimer.UpdateSince(start)
 
+		blockSpan.SetAttributes("canonical", status == CanonStatTy)
+		blockSpan.End()
+
 		switch status {
 		case CanonStatTy:
 			log.Debug("Inserted new block", "number", block.Number(), "hash", block.Hash(),
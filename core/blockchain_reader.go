@@ -308,6 +308,25 @@ func (bc *BlockChain) Config() *params.ChainConfig { return bc.chainConfig }
 // Engine retrieves the blockchain's consensus engine.
 func (bc *BlockChain) Engine() consensus.Engine { return bc.engine }
 
+// ForkHeader returns the header of the block of another chain whose state the
+// chain runs on, or nil if it isn't forked. A nil chain, as used by the chain
+// generator, is never forked.
+func (bc *BlockChain) ForkHeader() *types.Header {
+	if bc == nil || bc.cacheConfig.Fork == nil {
+		return nil
+	}
+	return bc.cacheConfig.Fork.ForkHeader()
+}
+
+// ForkHash returns the hash of a block of the chain whose state the chain runs
+// on, up to and including the fork block.
+func (bc *BlockChain) ForkHash(number uint64) common.Hash {
+	if bc == nil || bc.cacheConfig.Fork == nil {
+		return common.Hash{}
+	}
+	return bc.cacheConfig.Fork.ForkHash(number)
+}
+
 // Snapshots returns the blockchain snapshot tree.
 func (bc *BlockChain) Snapshots() *snapshot.Tree {
 	return bc.snaps
@@ -35,6 +35,19 @@ type ChainContext interface {
 	GetHeader(common.Hash, uint64) *types.Header
 }
 
+// ForkContext is optionally implemented by chain contexts running on top of the
+// state of another chain. The EVM numbers their blocks as the continuation of the
+// block they were forked off, with the local genesis block standing in for it.
+type ForkContext interface {
+	// ForkHeader returns the header of the block the chain was forked off, or
+	// nil if the chain is not forked.
+	ForkHeader() *types.Header
+
+	// ForkHash returns the hash of a block of the forked chain, up to and
+	// including the fork block.
+	ForkHash(number uint64) common.Hash
+}
+
 // NewEVMBlockContext creates a new context for use in the EVM.
 func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common.Address) vm.BlockContext {
 	var (
@@ -51,12 +64,19 @@ func NewEVMBlockContext(header *types.Header, chain ChainContext, author *common
 	if header.BaseFee != nil {
 		baseFee = new(big.Int).Set(header.BaseFee)
 	}
+	number, getHash := new(big.Int).Set(header.Number), GetHashFn(header, chain)
+	if fork, ok := chain.(ForkContext); ok {
+		if forkHeader := fork.ForkHeader(); forkHeader != nil {
+			number.Add(number, forkHeader.Number)
+			getHash = forkGetHashFn(forkHeader.Number.Uint64(), getHash, fork)
+		}
+	}
 	return vm.BlockContext{
 		CanTransfer: CanTransfer,
 		Transfer:    Transfer,
-		GetHash:     GetHashFn(header, chain),
+		GetHash:     getHash,
 		Coinbase:    beneficiary,
-		BlockNumber: new(big.Int).Set(header.Number),
+		BlockNumber: number,
 		Time:        new(big.Int).SetUint64(header.Time),
 		Difficulty:  new(big.Int).Set(header.Difficulty),
 		BaseFee:     baseFee,
@@ -106,6 +126,18 @@ func GetHashFn(ref *types.Header, chain ChainContext) func(n uint64) common.Hash
 	}
 }
 
+// forkGetHashFn returns a GetHashFunc for a forked chain, which retrieves the
+// hashes up to and including the fork block from the forked chain, and the ones
+// of later blocks from the local chain.
+func forkGetHashFn(forkNumber uint64, local func(n uint64) common.Hash, fork ForkContext) func(n uint64) common.Hash {
+	return func(n uint64) common.Hash {
+		if n <= forkNumber {
+			return fork.ForkHash(n)
+		}
+		return local(n - forkNumber)
+	}
+}
+
 // CanTransfer checks whether there are enough funds in the address' account to make a transfer.
 // This does not take the necessary gas in to account to make the transfer valid.
 func CanTransfer(db vm.StateDB, addr common.Address, amount *big.Int) bool {
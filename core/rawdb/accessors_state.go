@@ -94,3 +94,39 @@ func DeleteTrieNode(db ethdb.KeyValueWriter, hash common.Hash) {
 		log.Crit("Failed to delete trie node", "err", err)
 	}
 }
+
+// ReadForkAccount retrieves the RLP encoded account retrieved from a forked
+// chain. The returned flag indicates whether the account was cached at all, as
+// an empty blob denotes a non-existent account.
+func ReadForkAccount(db ethdb.KeyValueReader, addr common.Address) ([]byte, bool) {
+	data, err := db.Get(forkAccountKey(addr))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// WriteForkAccount caches the RLP encoded account retrieved from a forked chain.
+func WriteForkAccount(db ethdb.KeyValueWriter, addr common.Address, account []byte) {
+	if err := db.Put(forkAccountKey(addr), account); err != nil {
+		log.Crit("Failed to store forked account", "err", err)
+	}
+}
+
+// ReadForkStorage retrieves the storage value of an account retrieved from a
+// forked chain, along with whether it was cached at all.
+func ReadForkStorage(db ethdb.KeyValueReader, addr common.Address, slot common.Hash) (common.Hash, bool) {
+	data, err := db.Get(forkStorageKey(addr, slot))
+	if err != nil {
+		return common.Hash{}, false
+	}
+	return common.BytesToHash(data), true
+}
+
+// WriteForkStorage caches the storage value of an account retrieved from a
+// forked chain.
+func WriteForkStorage(db ethdb.KeyValueWriter, addr common.Address, slot common.Hash, value common.Hash) {
+	if err := db.Put(forkStorageKey(addr, slot), value.Bytes()); err != nil {
+		log.Crit("Failed to store forked storage slot", "err", err)
+	}
+}
@@ -90,6 +90,9 @@ var (
 	SnapshotStoragePrefix = []byte("o") // SnapshotStoragePrefix + account hash + storage hash -> storage trie value
 	CodePrefix            = []byte("c") // CodePrefix + code hash -> account code
 
+	forkAccountPrefix = []byte("fa") // forkAccountPrefix + address -> account retrieved from a forked chain
+	forkStoragePrefix = []byte("fs") // forkStoragePrefix + address + slot -> storage value retrieved from a forked chain
+
 	PreimagePrefix = []byte("secure-key-")      // PreimagePrefix + hash -> preimage
 	configPrefix   = []byte("ethereum-config-") // config prefix for the db
 
@@ -217,6 +220,16 @@ func codeKey(hash common.Hash) []byte {
 	return append(CodePrefix, hash.Bytes()...)
 }
 
+// forkAccountKey = forkAccountPrefix + address
+func forkAccountKey(addr common.Address) []byte {
+	return append(forkAccountPrefix, addr.Bytes()...)
+}
+
+// forkStorageKey = forkStoragePrefix + address + slot
+func forkStorageKey(addr common.Address, slot common.Hash) []byte {
+	return append(append(forkStoragePrefix, addr.Bytes()...), slot.Bytes()...)
+}
+
 // IsCodeKey reports whether the given byte slice is the key of contract code,
 // if so return the raw code hash as well.
 func IsCodeKey(key []byte) (bool, []byte) {
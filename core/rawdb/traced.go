@@ -0,0 +1,136 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/internal/tracing"
+)
+
+// tracedKeyPrefix is the number of key bytes reported on database read spans,
+// enough to identify the schema prefix and some of the key.
+const tracedKeyPrefix = 8
+
+// tracedDatabase is a wrapper around a database that emits tracing spans for
+// all reads taking longer than a configured threshold.
+//
+// The database interface carries no context, so the spans are standalone traces
+// which can be correlated with the rest by time.
+type tracedDatabase struct {
+	ethdb.Database
+	threshold time.Duration
+}
+
+// NewTracedDatabase returns a database object that reports all reads slower
+// than the given threshold as tracing spans.
+func NewTracedDatabase(db ethdb.Database, threshold time.Duration) ethdb.Database {
+	return &tracedDatabase{
+		Database:  db,
+		threshold: threshold,
+	}
+}
+
+// trace emits a span for a database operation started at the given time, if
+// it took longer than the threshold. The key (if any) is reported truncated.
+func (db *tracedDatabase) trace(name string, start time.Time, key []byte, err error, attrs ...interface{}) {
+	if !tracing.Enabled() || time.Since(start) < db.threshold {
+		return
+	}
+	if key != nil {
+		if len(key) > tracedKeyPrefix {
+			key = key[:tracedKeyPrefix]
+		}
+		attrs = append(attrs, "key", hexutil.Encode(key))
+	}
+	_, span := tracing.StartAt(context.Background(), name, start, attrs...)
+	span.SetError(err)
+	span.End()
+}
+
+// Has retrieves if a key is present in the database.
+func (db *tracedDatabase) Has(key []byte) (bool, error) {
+	start := time.Now()
+	has, err := db.Database.Has(key)
+	db.trace("ethdb/has", start, key, err)
+	return has, err
+}
+
+// Get retrieves the given key if it's present in the database.
+func (db *tracedDatabase) Get(key []byte) ([]byte, error) {
+	start := time.Now()
+	val, err := db.Database.Get(key)
+	db.trace("ethdb/get", start, key, err, "size", len(val))
+	return val, err
+}
+
+// Ancient retrieves an ancient binary blob from the append-only immutable files.
+func (db *tracedDatabase) Ancient(kind string, number uint64) ([]byte, error) {
+	start := time.Now()
+	val, err := db.Database.Ancient(kind, number)
+	db.trace("ethdb/ancient", start, nil, err, "kind", kind, "number", number, "size", len(val))
+	return val, err
+}
+
+// AncientRange retrieves multiple items in sequence from the append-only
+// immutable files.
+func (db *tracedDatabase) AncientRange(kind string, start, count, maxBytes uint64) ([][]byte, error) {
+	started := time.Now()
+	vals, err := db.Database.AncientRange(kind, start, count, maxBytes)
+	db.trace("ethdb/ancientRange", started, nil, err, "kind", kind, "start", start, "count", len(vals))
+	return vals, err
+}
+
+// NewIterator creates a binary-alphabetical iterator over a subset of database
+// content, tracing its whole lifetime up until it is released.
+func (db *tracedDatabase) NewIterator(prefix []byte, start []byte) ethdb.Iterator {
+	return &tracedIterator{
+		Iterator: db.Database.NewIterator(prefix, start),
+		db:       db,
+		prefix:   prefix,
+		start:    time.Now(),
+	}
+}
+
+// tracedIterator is a wrapper around a database iterator that reports its
+// lifetime as a tracing span if it exceeds the threshold.
+type tracedIterator struct {
+	ethdb.Iterator
+	db     *tracedDatabase
+	prefix []byte
+	start  time.Time
+	items  int
+}
+
+// Next moves the iterator to the next key/value pair.
+func (it *tracedIterator) Next() bool {
+	if it.Iterator.Next() {
+		it.items++
+		return true
+	}
+	return false
+}
+
+// Release releases associated resources and emits the iteration span.
+func (it *tracedIterator) Release() {
+	err := it.Iterator.Error()
+	it.Iterator.Release()
+	it.db.trace("ethdb/iterate", it.start, it.prefix, err, "items", it.items)
+}
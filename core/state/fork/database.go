@@ -0,0 +1,279 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package fork implements a state database lazily mirroring the state of a
+// remote chain at a given block.
+//
+// Local state tries only contain the accounts and storage slots written locally.
+// Whenever a key is missing from them, it is resolved through a Fetcher from the
+// forked chain and cached in the local database. As the cache is not keyed by
+// the fork point, a database must only ever be used with a single fork.
+//
+// Only the storage of accounts resolved from the forked chain falls back to it.
+// Their storage tries carry a marker entry, which is missing from the storage of
+// accounts created locally, including the ones destroyed and re-created.
+//
+// Snapshots are generated from the local tries only and must be disabled for
+// chains operating on a forked state.
+package fork
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// emptyRoot is the known root hash of an empty trie.
+	emptyRoot = common.HexToHash("56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421")
+
+	// emptyCode is the known hash of the empty EVM bytecode.
+	emptyCode = crypto.Keccak256(nil)
+
+	// deletedAccount is stored in the local account trie in place of deleted
+	// accounts, shadowing the remote ones. An empty account can't otherwise
+	// exist after EIP-158, so it can't be confused with a live one.
+	deletedAccount, _ = rlp.EncodeToBytes(&types.StateAccount{
+		Balance:  new(big.Int),
+		Root:     emptyRoot,
+		CodeHash: emptyCode,
+	})
+
+	// deletedSlot is stored in the local storage tries in place of cleared slots,
+	// shadowing the remote ones. It is the RLP encoding of a zero value.
+	deletedSlot = []byte{0x80}
+
+	// remoteMarker is the key of the marker entry in the storage tries of the
+	// accounts resolved from the forked chain. Storage slot keys are always 32
+	// bytes long, so it can't clash with them.
+	remoteMarker = []byte("fork")
+
+	// remoteRoot is the root hash of a storage trie containing only the marker,
+	// which is the storage root of all accounts resolved from the forked chain.
+	remoteRoot = func() common.Hash {
+		tr, _ := trie.NewSecure(common.Hash{}, trie.NewDatabase(rawdb.NewMemoryDatabase()))
+		tr.Update(remoteMarker, []byte{0x01})
+		return tr.Hash()
+	}()
+)
+
+// Database is a state.Database operating on a local database, which falls back
+// to a remote chain for all the accounts and storage slots not written locally.
+type Database struct {
+	state.Database
+
+	disk    ethdb.Database
+	fetcher Fetcher
+
+	addrs map[common.Hash]common.Address // Preimages of the accounts resolved so far
+	lock  sync.RWMutex
+}
+
+// New creates a state database over the given local database, resolving state
+// missing locally through the given fetcher.
+func New(db ethdb.Database, config *trie.Config, fetcher Fetcher) *Database {
+	return &Database{
+		Database: state.NewDatabaseWithConfig(db, config),
+		disk:     db,
+		fetcher:  fetcher,
+		addrs:    make(map[common.Hash]common.Address),
+	}
+}
+
+// OpenTrie opens the main account trie at a specific root hash. The root must
+// be available locally.
+func (db *Database) OpenTrie(root common.Hash) (state.Trie, error) {
+	tr, err := trie.NewSecure(root, db.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	return &forkTrie{SecureTrie: tr, db: db}, nil
+}
+
+// OpenStorageTrie opens the storage trie of an account. Slots missing from it
+// are only resolved from the forked chain if the trie carries the marker of the
+// accounts resolved from there.
+func (db *Database) OpenStorageTrie(addrHash, root common.Hash) (state.Trie, error) {
+	if root == remoteRoot {
+		// The storage of the account was never written locally, so the marker
+		// trie might not be available on disk yet. Recreate it.
+		tr, err := trie.NewSecure(common.Hash{}, db.TrieDB())
+		if err != nil {
+			return nil, err
+		}
+		if err := tr.TryUpdate(remoteMarker, []byte{0x01}); err != nil {
+			return nil, err
+		}
+		return &forkTrie{SecureTrie: tr, db: db, owner: addrHash, storage: true, remote: true}, nil
+	}
+	tr, err := trie.NewSecure(root, db.TrieDB())
+	if err != nil {
+		return nil, err
+	}
+	var remote bool
+	if root != emptyRoot {
+		marker, err := tr.TryGet(remoteMarker)
+		if err != nil {
+			return nil, err
+		}
+		remote = marker != nil
+	}
+	return &forkTrie{SecureTrie: tr, db: db, owner: addrHash, storage: true, remote: remote}, nil
+}
+
+// CopyTrie returns an independent copy of the given trie.
+func (db *Database) CopyTrie(t state.Trie) state.Trie {
+	switch t := t.(type) {
+	case *forkTrie:
+		cpy := *t
+		cpy.SecureTrie = t.SecureTrie.Copy()
+		return &cpy
+	default:
+		return db.Database.CopyTrie(t)
+	}
+}
+
+// account resolves an account of the forked chain, returning its RLP encoding,
+// or nil if it does not exist.
+func (db *Database) account(addr common.Address) ([]byte, error) {
+	if enc, ok := rawdb.ReadForkAccount(db.disk, addr); ok {
+		if len(enc) == 0 {
+			return nil, nil
+		}
+		return enc, nil
+	}
+	acc, err := db.fetcher.Account(context.Background(), addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch account %x: %v", addr, err)
+	}
+	var enc []byte
+	if acc != nil {
+		codeHash := crypto.Keccak256Hash(acc.Code)
+		if len(acc.Code) > 0 {
+			rawdb.WriteCode(db.disk, codeHash, acc.Code)
+		}
+		balance := acc.Balance
+		if balance == nil {
+			balance = new(big.Int)
+		}
+		enc, err = rlp.EncodeToBytes(&types.StateAccount{
+			Nonce:    acc.Nonce,
+			Balance:  balance,
+			Root:     remoteRoot,
+			CodeHash: codeHash.Bytes(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	rawdb.WriteForkAccount(db.disk, addr, enc)
+	return enc, nil
+}
+
+// storage resolves a storage slot of an account of the forked chain, returning
+// its RLP encoding as stored in the storage trie, or nil if it is empty.
+func (db *Database) storage(addrHash common.Hash, slot common.Hash) ([]byte, error) {
+	db.lock.RLock()
+	addr, ok := db.addrs[addrHash]
+	db.lock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown account %x", addrHash)
+	}
+	value, ok := rawdb.ReadForkStorage(db.disk, addr, slot)
+	if !ok {
+		var err error
+		if value, err = db.fetcher.Storage(context.Background(), addr, slot); err != nil {
+			return nil, fmt.Errorf("failed to fetch storage slot %x of %x: %v", slot, addr, err)
+		}
+		rawdb.WriteForkStorage(db.disk, addr, slot, value)
+	}
+	if value == (common.Hash{}) {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes(common.TrimLeftZeroes(value[:]))
+}
+
+// forkTrie is a local state trie, resolving the keys never written locally from
+// the forked chain.
+type forkTrie struct {
+	*trie.SecureTrie
+
+	db      *Database
+	owner   common.Hash // Hash of the account owning the storage trie
+	storage bool        // Whether the trie is a storage trie
+	remote  bool        // Whether missing storage slots are resolved remotely
+}
+
+// TryGet returns the value for key stored in the local trie, or in the forked
+// chain if the key was never written locally.
+func (t *forkTrie) TryGet(key []byte) ([]byte, error) {
+	enc, err := t.SecureTrie.TryGet(key)
+	if err != nil {
+		return nil, err
+	}
+	if !t.storage {
+		// Track the account preimage for resolving its storage later on
+		addr := common.BytesToAddress(key)
+
+		t.db.lock.Lock()
+		t.db.addrs[crypto.Keccak256Hash(key)] = addr
+		t.db.lock.Unlock()
+
+		switch {
+		case bytes.Equal(enc, deletedAccount):
+			return nil, nil
+		case enc != nil:
+			return enc, nil
+		}
+		return t.db.account(addr)
+	}
+	if enc != nil || !t.remote {
+		return enc, nil
+	}
+	return t.db.storage(t.owner, common.BytesToHash(key))
+}
+
+// TryUpdate associates key with value in the local trie. Empty values shadow
+// the value of the key in the forked chain instead of deleting the key.
+func (t *forkTrie) TryUpdate(key, value []byte) error {
+	if len(value) == 0 {
+		return t.TryDelete(key)
+	}
+	return t.SecureTrie.TryUpdate(key, value)
+}
+
+// TryDelete shadows the value of key in the forked chain in the local trie.
+func (t *forkTrie) TryDelete(key []byte) error {
+	if t.storage {
+		if !t.remote {
+			return t.SecureTrie.TryDelete(key)
+		}
+		return t.SecureTrie.TryUpdate(key, deletedSlot)
+	}
+	return t.SecureTrie.TryUpdate(key, deletedAccount)
+}
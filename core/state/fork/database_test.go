@@ -0,0 +1,279 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+var (
+	contractAddr = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	userAddr     = common.HexToAddress("0x2000000000000000000000000000000000000002")
+	otherAddr    = common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	testFixture = core.GenesisAlloc{
+		contractAddr: {
+			Balance: big.NewInt(1),
+			Nonce:   1,
+			Code:    []byte{0x60, 0x00, 0x54, 0x00}, // PUSH1 0 SLOAD STOP
+			Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x01"): common.HexToHash("0x2a"),
+				common.HexToHash("0x03"): common.HexToHash("0x03"),
+			},
+		},
+		userAddr:  {Balance: big.NewInt(1000), Nonce: 5},
+		otherAddr: {Balance: big.NewInt(7)},
+	}
+)
+
+// countingFetcher is a Fetcher tracking the number of remote requests.
+type countingFetcher struct {
+	Fetcher
+	requests int32
+}
+
+func (f *countingFetcher) Account(ctx context.Context, addr common.Address) (*Account, error) {
+	atomic.AddInt32(&f.requests, 1)
+	return f.Fetcher.Account(ctx, addr)
+}
+
+func (f *countingFetcher) Storage(ctx context.Context, addr common.Address, slot common.Hash) (common.Hash, error) {
+	atomic.AddInt32(&f.requests, 1)
+	return f.Fetcher.Storage(ctx, addr, slot)
+}
+
+// Tests that the remote state is visible through the local state and that local
+// modifications, including deletions, shadow it.
+func TestForkedState(t *testing.T) {
+	db := New(rawdb.NewMemoryDatabase(), nil, NewFixtureFetcher(testFixture))
+
+	statedb, err := state.New(common.Hash{}, db, nil)
+	if err != nil {
+		t.Fatalf("failed to open state: %v", err)
+	}
+	if balance := statedb.GetBalance(userAddr); balance.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 1000)
+	}
+	if nonce := statedb.GetNonce(userAddr); nonce != 5 {
+		t.Errorf("nonce mismatch: have %d, want %d", nonce, 5)
+	}
+	if code := statedb.GetCode(contractAddr); !bytes.Equal(code, testFixture[contractAddr].Code) {
+		t.Errorf("code mismatch: have %x, want %x", code, testFixture[contractAddr].Code)
+	}
+	if value := statedb.GetState(contractAddr, common.HexToHash("0x01")); value != common.HexToHash("0x2a") {
+		t.Errorf("storage mismatch: have %x, want %x", value, common.HexToHash("0x2a"))
+	}
+	if statedb.Exist(common.Address{0xff}) {
+		t.Errorf("non-existent account reported as existing")
+	}
+	// Modify the state locally and persist it
+	statedb.AddBalance(userAddr, big.NewInt(1))
+	statedb.SetState(contractAddr, common.HexToHash("0x01"), common.Hash{})
+	statedb.SetState(contractAddr, common.HexToHash("0x02"), common.HexToHash("0x07"))
+	statedb.Suicide(otherAddr)
+
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	if err := db.TrieDB().Commit(root, false, nil); err != nil {
+		t.Fatalf("failed to commit tries: %v", err)
+	}
+	// Reopen the state and check that local changes shadow the remote state
+	statedb, err = state.New(root, db, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen state: %v", err)
+	}
+	if balance := statedb.GetBalance(userAddr); balance.Cmp(big.NewInt(1001)) != 0 {
+		t.Errorf("balance mismatch: have %v, want %v", balance, 1001)
+	}
+	if nonce := statedb.GetNonce(userAddr); nonce != 5 {
+		t.Errorf("nonce mismatch: have %d, want %d", nonce, 5)
+	}
+	if code := statedb.GetCode(contractAddr); !bytes.Equal(code, testFixture[contractAddr].Code) {
+		t.Errorf("code mismatch: have %x, want %x", code, testFixture[contractAddr].Code)
+	}
+	storage := map[common.Hash]common.Hash{
+		common.HexToHash("0x01"): {},
+		common.HexToHash("0x02"): common.HexToHash("0x07"),
+		common.HexToHash("0x03"): common.HexToHash("0x03"),
+	}
+	for slot, want := range storage {
+		if have := statedb.GetState(contractAddr, slot); have != want {
+			t.Errorf("storage slot %x mismatch: have %x, want %x", slot, have, want)
+		}
+	}
+	if statedb.Exist(otherAddr) {
+		t.Errorf("deleted account still exists")
+	}
+}
+
+// Tests that accounts destroyed and re-created locally don't fall back to the
+// storage of the forked chain any more.
+func TestForkedStateRecreatedAccount(t *testing.T) {
+	db := New(rawdb.NewMemoryDatabase(), nil, NewFixtureFetcher(testFixture))
+
+	statedb, _ := state.New(common.Hash{}, db, nil)
+	if value := statedb.GetState(contractAddr, common.HexToHash("0x01")); value != common.HexToHash("0x2a") {
+		t.Fatalf("storage mismatch: have %x, want %x", value, common.HexToHash("0x2a"))
+	}
+	statedb.Suicide(contractAddr)
+	statedb.Finalise(true)
+
+	statedb.CreateAccount(contractAddr)
+	statedb.SetNonce(contractAddr, 1)
+	statedb.SetState(contractAddr, common.HexToHash("0x02"), common.HexToHash("0x07"))
+	if value := statedb.GetState(contractAddr, common.HexToHash("0x03")); value != (common.Hash{}) {
+		t.Errorf("re-created account reads remote storage: %x", value)
+	}
+	root, err := statedb.Commit(true)
+	if err != nil {
+		t.Fatalf("failed to commit state: %v", err)
+	}
+	statedb, err = state.New(root, db, nil)
+	if err != nil {
+		t.Fatalf("failed to reopen state: %v", err)
+	}
+	storage := map[common.Hash]common.Hash{
+		common.HexToHash("0x01"): {},
+		common.HexToHash("0x02"): common.HexToHash("0x07"),
+		common.HexToHash("0x03"): {},
+	}
+	for slot, want := range storage {
+		if have := statedb.GetState(contractAddr, slot); have != want {
+			t.Errorf("storage slot %x mismatch: have %x, want %x", slot, have, want)
+		}
+	}
+}
+
+// Tests that storage tries missing from the local database are reported as an
+// error instead of being read as empty.
+func TestForkedStateMissingStorage(t *testing.T) {
+	db := New(rawdb.NewMemoryDatabase(), nil, NewFixtureFetcher(testFixture))
+	if _, err := db.OpenStorageTrie(common.Hash{0x01}, common.Hash{0x02}); err == nil {
+		t.Fatal("missing storage trie opened without error")
+	}
+}
+
+// Tests that the retrieved remote state is cached in the local database.
+func TestForkedStateCache(t *testing.T) {
+	var (
+		disk    = rawdb.NewMemoryDatabase()
+		fetcher = &countingFetcher{Fetcher: NewFixtureFetcher(testFixture)}
+	)
+	read := func(db ethdb.Database) {
+		statedb, _ := state.New(common.Hash{}, New(db, nil, fetcher), nil)
+		statedb.GetBalance(userAddr)
+		statedb.GetBalance(common.Address{0xff})
+		statedb.GetCode(contractAddr)
+		statedb.GetState(contractAddr, common.HexToHash("0x01"))
+	}
+	read(disk)
+	if requests := atomic.LoadInt32(&fetcher.requests); requests != 4 {
+		t.Fatalf("remote request count mismatch: have %d, want %d", requests, 4)
+	}
+	read(disk)
+	if requests := atomic.LoadInt32(&fetcher.requests); requests != 4 {
+		t.Errorf("cached state requested again: have %d requests, want %d", requests, 4)
+	}
+}
+
+// Tests that the history resolves the fork block and the hashes of its ancestors.
+func TestHistory(t *testing.T) {
+	if _, err := NewHistory(context.Background(), NewFixtureFetcher(testFixture)); err == nil {
+		t.Fatal("history created without a known fork block")
+	}
+	var headers []*types.Header
+	for i := 0; i < 3; i++ {
+		headers = append(headers, &types.Header{Number: big.NewInt(int64(10 + i)), Extra: []byte{byte(i)}})
+	}
+	history, err := NewHistory(context.Background(), NewFixtureFetcher(testFixture, headers...))
+	if err != nil {
+		t.Fatalf("failed to create history: %v", err)
+	}
+	if history.ForkHeader() != headers[2] {
+		t.Errorf("fork header mismatch: have %v, want %v", history.ForkHeader().Number, headers[2].Number)
+	}
+	for _, header := range headers {
+		if have := history.ForkHash(header.Number.Uint64()); have != header.Hash() {
+			t.Errorf("block %d hash mismatch: have %x, want %x", header.Number, have, header.Hash())
+		}
+	}
+	if have := history.ForkHash(9); have != (common.Hash{}) {
+		t.Errorf("unknown block has hash %x", have)
+	}
+	if have := history.ForkHash(13); have != (common.Hash{}) {
+		t.Errorf("block after the fork has hash %x", have)
+	}
+}
+
+// Tests that recorded state can be loaded back as a fixture.
+func TestRecorderFixture(t *testing.T) {
+	recorder := NewRecorder(NewFixtureFetcher(testFixture))
+
+	statedb, _ := state.New(common.Hash{}, New(rawdb.NewMemoryDatabase(), nil, recorder), nil)
+	statedb.GetBalance(userAddr)
+	statedb.GetState(contractAddr, common.HexToHash("0x01"))
+
+	blob, err := recorder.Fixture()
+	if err != nil {
+		t.Fatalf("failed to export fixture: %v", err)
+	}
+	dir, err := ioutil.TempDir("", "fork-fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "fixture.json")
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	fixture, err := LoadFixture(path)
+	if err != nil {
+		t.Fatalf("failed to load fixture: %v", err)
+	}
+	want := core.GenesisAlloc{
+		userAddr: testFixture[userAddr],
+		contractAddr: {
+			Balance: big.NewInt(1),
+			Nonce:   1,
+			Code:    testFixture[contractAddr].Code,
+			Storage: map[common.Hash]common.Hash{
+				common.HexToHash("0x01"): common.HexToHash("0x2a"),
+			},
+		},
+	}
+	if !reflect.DeepEqual(fixture.alloc, want) {
+		t.Errorf("fixture mismatch:\nhave %+v\nwant %+v", fixture.alloc, want)
+	}
+}
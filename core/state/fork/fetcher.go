@@ -0,0 +1,261 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// Account is the state of an account in the forked chain.
+type Account struct {
+	Nonce   uint64
+	Balance *big.Int
+	Code    []byte
+}
+
+// Fetcher retrieves the state of the forked chain at the fork point.
+type Fetcher interface {
+	// Account retrieves an account of the forked chain, or nil if it does not
+	// exist.
+	Account(ctx context.Context, addr common.Address) (*Account, error)
+
+	// Storage retrieves a storage slot of an account of the forked chain.
+	Storage(ctx context.Context, addr common.Address, slot common.Hash) (common.Hash, error)
+
+	// Header retrieves the header of a block of the forked chain, up to and
+	// including the fork block. A nil number retrieves the fork block. If the
+	// fetcher does not know the block, nil is returned.
+	Header(ctx context.Context, number *big.Int) (*types.Header, error)
+}
+
+// RPCFetcher is a Fetcher retrieving the state of a remote node over RPC.
+type RPCFetcher struct {
+	client *ethclient.Client
+	number *big.Int
+	lock   sync.Mutex
+}
+
+// NewRPCFetcher creates a fetcher retrieving the state of the remote node at
+// the given block number. If number is nil, the fetcher is pinned to the head
+// block of the remote node at the time of the first request.
+func NewRPCFetcher(client *ethclient.Client, number *big.Int) *RPCFetcher {
+	return &RPCFetcher{client: client, number: number}
+}
+
+// forkNumber returns the number of the fork block, pinning it to the current
+// head of the remote node if it wasn't specified.
+func (f *RPCFetcher) forkNumber(ctx context.Context) (*big.Int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.number == nil {
+		header, err := f.client.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		f.number = header.Number
+	}
+	return f.number, nil
+}
+
+// Account retrieves an account of the remote node. Accounts without balance,
+// nonce and code are reported as non-existent.
+func (f *RPCFetcher) Account(ctx context.Context, addr common.Address) (*Account, error) {
+	number, err := f.forkNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	balance, err := f.client.BalanceAt(ctx, addr, number)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := f.client.NonceAt(ctx, addr, number)
+	if err != nil {
+		return nil, err
+	}
+	code, err := f.client.CodeAt(ctx, addr, number)
+	if err != nil {
+		return nil, err
+	}
+	if balance.Sign() == 0 && nonce == 0 && len(code) == 0 {
+		return nil, nil
+	}
+	return &Account{Nonce: nonce, Balance: balance, Code: code}, nil
+}
+
+// Storage retrieves a storage slot of an account of the remote node.
+func (f *RPCFetcher) Storage(ctx context.Context, addr common.Address, slot common.Hash) (common.Hash, error) {
+	number, err := f.forkNumber(ctx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	value, err := f.client.StorageAt(ctx, addr, slot, number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(value), nil
+}
+
+// Header retrieves a block header of the remote node.
+func (f *RPCFetcher) Header(ctx context.Context, number *big.Int) (*types.Header, error) {
+	forkNumber, err := f.forkNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if number == nil {
+		number = forkNumber
+	}
+	if number.Cmp(forkNumber) > 0 {
+		return nil, nil
+	}
+	return f.client.HeaderByNumber(ctx, number)
+}
+
+// FixtureFetcher is a Fetcher serving the state of the forked chain from a
+// fixed set of accounts, in the same format as the genesis allocation. Accounts
+// and storage slots missing from the fixture are reported as empty.
+type FixtureFetcher struct {
+	alloc   core.GenesisAlloc
+	headers []*types.Header
+}
+
+// NewFixtureFetcher creates a fetcher serving the given accounts. The optional
+// headers are the blocks of the forked chain known to the fetcher, the last one
+// being the fork block.
+func NewFixtureFetcher(alloc core.GenesisAlloc, headers ...*types.Header) *FixtureFetcher {
+	return &FixtureFetcher{alloc: alloc, headers: headers}
+}
+
+// LoadFixture creates a fetcher serving the accounts of a JSON fixture file.
+func LoadFixture(path string) (*FixtureFetcher, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	alloc := make(core.GenesisAlloc)
+	if err := json.Unmarshal(blob, &alloc); err != nil {
+		return nil, err
+	}
+	return NewFixtureFetcher(alloc), nil
+}
+
+// Account retrieves an account from the fixture.
+func (f *FixtureFetcher) Account(ctx context.Context, addr common.Address) (*Account, error) {
+	acc, ok := f.alloc[addr]
+	if !ok {
+		return nil, nil
+	}
+	return &Account{Nonce: acc.Nonce, Balance: acc.Balance, Code: acc.Code}, nil
+}
+
+// Storage retrieves a storage slot of an account from the fixture.
+func (f *FixtureFetcher) Storage(ctx context.Context, addr common.Address, slot common.Hash) (common.Hash, error) {
+	return f.alloc[addr].Storage[slot], nil
+}
+
+// Header retrieves a block header from the fixture.
+func (f *FixtureFetcher) Header(ctx context.Context, number *big.Int) (*types.Header, error) {
+	if len(f.headers) == 0 {
+		return nil, nil
+	}
+	if number == nil {
+		return f.headers[len(f.headers)-1], nil
+	}
+	for _, header := range f.headers {
+		if header.Number.Cmp(number) == 0 {
+			return header, nil
+		}
+	}
+	return nil, nil
+}
+
+// Recorder is a Fetcher recording all the state retrieved through another one,
+// allowing fixtures for offline tests to be captured from a live node.
+type Recorder struct {
+	fetcher Fetcher
+	alloc   core.GenesisAlloc
+	lock    sync.Mutex
+}
+
+// NewRecorder creates a fetcher recording the state retrieved through the given
+// fetcher.
+func NewRecorder(fetcher Fetcher) *Recorder {
+	return &Recorder{fetcher: fetcher, alloc: make(core.GenesisAlloc)}
+}
+
+// Account retrieves an account through the wrapped fetcher and records it.
+func (r *Recorder) Account(ctx context.Context, addr common.Address) (*Account, error) {
+	acc, err := r.fetcher.Account(ctx, addr)
+	if err != nil || acc == nil {
+		return acc, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rec := r.alloc[addr]
+	rec.Nonce, rec.Balance, rec.Code = acc.Nonce, acc.Balance, acc.Code
+	if rec.Balance == nil {
+		rec.Balance = new(big.Int)
+	}
+	r.alloc[addr] = rec
+	return acc, nil
+}
+
+// Storage retrieves a storage slot through the wrapped fetcher and records it.
+func (r *Recorder) Storage(ctx context.Context, addr common.Address, slot common.Hash) (common.Hash, error) {
+	value, err := r.fetcher.Storage(ctx, addr, slot)
+	if err != nil || value == (common.Hash{}) {
+		return value, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	rec := r.alloc[addr]
+	if rec.Storage == nil {
+		rec.Storage = make(map[common.Hash]common.Hash)
+	}
+	rec.Storage[slot] = value
+	if rec.Balance == nil {
+		rec.Balance = new(big.Int)
+	}
+	r.alloc[addr] = rec
+	return value, nil
+}
+
+// Header retrieves a block header through the wrapped fetcher. Headers are not
+// part of the recorded fixture.
+func (r *Recorder) Header(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return r.fetcher.Header(ctx, number)
+}
+
+// Fixture returns a JSON fixture of all the state recorded so far, which can be
+// loaded by LoadFixture.
+func (r *Recorder) Fixture() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return json.MarshalIndent(r.alloc, "", "  ")
+}
@@ -0,0 +1,92 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// errUnknownForkBlock is returned if the fetcher does not know the fork block.
+var errUnknownForkBlock = errors.New("fork block unknown")
+
+// History is the chain history up to the fork block, as retrieved through a
+// Fetcher. It implements core.ForkContext, letting the EVM of a local chain
+// running on the forked state report the block numbers and hashes of the
+// forked chain.
+type History struct {
+	fetcher Fetcher
+	header  *types.Header
+
+	hashes map[uint64]common.Hash // Hashes of the blocks retrieved so far
+	lock   sync.Mutex
+}
+
+var _ core.ForkContext = (*History)(nil)
+
+// NewHistory retrieves the fork block through the given fetcher.
+func NewHistory(ctx context.Context, fetcher Fetcher) (*History, error) {
+	header, err := fetcher.Header(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, errUnknownForkBlock
+	}
+	return &History{
+		fetcher: fetcher,
+		header:  header,
+		hashes:  map[uint64]common.Hash{header.Number.Uint64(): header.Hash()},
+	}, nil
+}
+
+// ForkHeader returns the header of the fork block.
+func (h *History) ForkHeader() *types.Header {
+	return h.header
+}
+
+// ForkHash returns the hash of a block of the forked chain, up to and including
+// the fork block. The zero hash is returned if it can't be retrieved.
+func (h *History) ForkHash(number uint64) common.Hash {
+	if number > h.header.Number.Uint64() {
+		return common.Hash{}
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if hash, ok := h.hashes[number]; ok {
+		return hash
+	}
+	header, err := h.fetcher.Header(context.Background(), new(big.Int).SetUint64(number))
+	if err != nil {
+		log.Warn("Failed to retrieve forked block", "number", number, "err", err)
+		return common.Hash{}
+	}
+	var hash common.Hash
+	if header != nil {
+		hash = header.Hash()
+	}
+	h.hashes[number] = hash
+	return hash
+}
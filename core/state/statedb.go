@@ -18,6 +18,7 @@
 package state
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math/big"
@@ -29,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/state/snapshot"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/tracing"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -122,6 +124,8 @@ type StateDB struct {
 	StorageUpdated int
 	AccountDeleted int
 	StorageDeleted int
+
+	traceCtx context.Context // Tracing context to attach the commit spans to
 }
 
 // New creates a new state from a given trie.
@@ -176,6 +180,21 @@ func (s *StateDB) StopPrefetcher() {
 	}
 }
 
+// SetTraceContext sets the tracing context to which the spans emitted while
+// committing the state are attached.
+func (s *StateDB) SetTraceContext(ctx context.Context) {
+	s.traceCtx = ctx
+}
+
+// traceContext returns the tracing context of the state, defaulting to an empty
+// one if none was set.
+func (s *StateDB) traceContext() context.Context {
+	if s.traceCtx == nil {
+		return context.Background()
+	}
+	return s.traceCtx
+}
+
 // setError remembers the first non-nil error it is called with.
 func (s *StateDB) setError(err error) {
 	if s.dbErr == nil {
@@ -967,6 +986,9 @@ func (s *StateDB) Commit(deleteEmptyObjects bool) (common.Hash, error) {
 		if metrics.EnabledExpensive {
 			defer func(start time.Time) { s.SnapshotCommits += time.Since(start) }(time.Now())
 		}
+		_, span := tracing.Start(s.traceContext(), "state/snapshotUpdate", "root", root)
+		defer span.End()
+
 		// Only update if there's a state transition (skip empty Clique blocks)
 		if parent := s.snap.Root(); parent != root {
 			if err := s.snaps.Update(root, parent, s.snapDestructs, s.snapAccounts, s.snapStorage); err != nil {
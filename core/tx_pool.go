@@ -425,6 +425,31 @@ func (pool *TxPool) Stop() {
 	log.Info("Transaction pool stopped")
 }
 
+// Sync explicitly resets the pool to the current head of the chain and waits
+// for the reset to complete. It is meant for simulators and tests in which the
+// chain head changes in quick succession, without leaving time for the event
+// driven background resets to run.
+func (pool *TxPool) Sync() {
+	<-pool.requestReset(nil, pool.chain.CurrentBlock().Header())
+}
+
+// Clear drops all the tracked transactions from the pool, both executable and
+// queued ones, local or remote.
+func (pool *TxPool) Clear() {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	var hashes []common.Hash
+	pool.all.Range(func(hash common.Hash, tx *types.Transaction, local bool) bool {
+		hashes = append(hashes, hash)
+		return true
+	}, true, true)
+
+	for _, hash := range hashes {
+		pool.removeTx(hash, true)
+	}
+}
+
 // SubscribeNewTxsEvent registers a subscription of NewTxsEvent and
 // starts sending event to the given channel.
 func (pool *TxPool) SubscribeNewTxsEvent(ch chan<- NewTxsEvent) event.Subscription {
@@ -492,6 +517,32 @@ func (pool *TxPool) stats() (int, int) {
 	return pending, queued
 }
 
+// OriginStats retrieves the current pool stats together with the number of
+// pending and queued transactions originating from accounts marked as local,
+// all taken from the same snapshot of the pool.
+func (pool *TxPool) OriginStats() (pending int, queued int, localPending int, localQueued int) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	pending, queued = pool.stats()
+	localPending, localQueued = pool.localStats()
+	return pending, queued, localPending, localQueued
+}
+
+// localStats retrieves the number of pending and queued local transactions.
+func (pool *TxPool) localStats() (int, int) {
+	pending, queued := 0, 0
+	for addr := range pool.locals.accounts {
+		if list := pool.pending[addr]; list != nil {
+			pending += list.Len()
+		}
+		if list := pool.queue[addr]; list != nil {
+			queued += list.Len()
+		}
+	}
+	return pending, queued
+}
+
 // Content retrieves the data content of the transaction pool, returning all the
 // pending as well as queued transactions, grouped by account and sorted by nonce.
 func (pool *TxPool) Content() (map[common.Address]types.Transactions, map[common.Address]types.Transactions) {
@@ -139,6 +139,11 @@ func validateTxPoolInternals(pool *TxPool) error {
 	if total := pool.all.Count(); total != pending+queued {
 		return fmt.Errorf("total transaction count %d != %d pending + %d queued", total, pending, queued)
 	}
+	// Ensure the local transaction set is consistent with the tracked accounts
+	localPending, localQueued := pool.localStats()
+	if local := pool.all.LocalCount(); local != localPending+localQueued {
+		return fmt.Errorf("local transaction count %d != %d pending + %d queued", local, localPending, localQueued)
+	}
 	pool.priced.Reheap()
 	priced, remote := pool.priced.urgent.Len()+pool.priced.floating.Len(), pool.all.RemoteCount()
 	if priced != remote {
@@ -813,6 +818,38 @@ func TestTransactionGapFilling(t *testing.T) {
 	}
 }
 
+// Tests that clearing the pool drops both pending and queued transactions and
+// resets the pending nonces.
+func TestTransactionPoolClear(t *testing.T) {
+	t.Parallel()
+
+	pool, key := setupTxPool()
+	defer pool.Stop()
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	testAddBalance(pool, addr, big.NewInt(1000000))
+
+	for _, nonce := range []uint64{0, 1, 3} {
+		if err := pool.addRemoteSync(transaction(nonce, 100000, key)); err != nil {
+			t.Fatalf("failed to add transaction %d: %v", nonce, err)
+		}
+	}
+	if pending, queued := pool.Stats(); pending != 2 || queued != 1 {
+		t.Fatalf("pool stats mismatch: have %d pending, %d queued, want 2 pending, 1 queued", pending, queued)
+	}
+	pool.Clear()
+
+	if pending, queued := pool.Stats(); pending != 0 || queued != 0 {
+		t.Errorf("pool not empty after clear: have %d pending, %d queued", pending, queued)
+	}
+	if nonce := pool.Nonce(addr); nonce != 0 {
+		t.Errorf("pending nonce mismatch: have %d, want %d", nonce, 0)
+	}
+	if err := validateTxPoolInternals(pool); err != nil {
+		t.Fatalf("pool internal state corrupted: %v", err)
+	}
+}
+
 // Tests that if the transaction count belonging to a single account goes above
 // some threshold, the higher transactions are dropped to prevent DOS attacks.
 func TestTransactionQueueAccountLimiting(t *testing.T) {
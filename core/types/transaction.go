@@ -374,14 +374,18 @@ func (tx *Transaction) Hash() common.Hash {
 	return h
 }
 
-// Size returns the true RLP encoded storage size of the transaction, either by
-// encoding and returning it, or returning a previously cached value.
+// Size returns the true encoded storage size of the transaction, either by
+// encoding and returning it, or returning a previously cached value. For typed
+// transactions this is the size of the envelope, i.e. it includes the type byte.
 func (tx *Transaction) Size() common.StorageSize {
 	if size := tx.size.Load(); size != nil {
 		return size.(common.StorageSize)
 	}
 	c := writeCounter(0)
 	rlp.Encode(&c, &tx.inner)
+	if tx.Type() != LegacyTxType {
+		c += 1 // type byte
+	}
 	tx.size.Store(common.StorageSize(c))
 	return common.StorageSize(c)
 }
@@ -478,6 +478,9 @@ func TestTransactionCoding(t *testing.T) {
 			t.Fatal(err)
 		}
 		assertEqual(parsedTx, tx)
+		if tx.Size() != parsedTx.Size() {
+			t.Fatalf("size mismatch for tx type %d: have %v, decoded %v", tx.Type(), tx.Size(), parsedTx.Size())
+		}
 
 		// JSON
 		parsedTx, err = encodeDecodeJSON(tx)
@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -53,6 +54,7 @@ type downloadTester struct {
 	stateDb ethdb.Database // Database used by the tester for syncing from peers
 	peerDb  ethdb.Database // Database of the peers containing all data
 	peers   map[string]*downloadTesterPeer
+	dropped map[string]p2p.ScoreEvent // Behaviors the dropped peers were scored for
 
 	ownHashes   []common.Hash                  // Hash chain belonging to the tester
 	ownHeaders  map[common.Hash]*types.Header  // Headers belonging to the tester
@@ -74,6 +76,7 @@ func newTester() *downloadTester {
 		genesis:     testGenesis,
 		peerDb:      testDB,
 		peers:       make(map[string]*downloadTesterPeer),
+		dropped:     make(map[string]p2p.ScoreEvent),
 		ownHashes:   []common.Hash{testGenesis.Hash()},
 		ownHeaders:  map[common.Hash]*types.Header{testGenesis.Hash(): testGenesis.Header()},
 		ownBlocks:   map[common.Hash]*types.Block{testGenesis.Hash(): testGenesis},
@@ -402,11 +405,12 @@ func (dl *downloadTester) newPeer(id string, version uint, chain *testChain) err
 }
 
 // dropPeer simulates a hard peer removal from the connection pool.
-func (dl *downloadTester) dropPeer(id string) {
+func (dl *downloadTester) dropPeer(id string, event p2p.ScoreEvent) {
 	dl.lock.Lock()
 	defer dl.lock.Unlock()
 
 	delete(dl.peers, id)
+	dl.dropped[id] = event
 	dl.downloader.UnregisterPeer(id)
 }
 
@@ -1094,22 +1098,23 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol uint) {
 	tests := []struct {
 		result error
 		drop   bool
+		event  p2p.ScoreEvent // Behavior the dropped peer is scored for
 	}{
-		{nil, false},                        // Sync succeeded, all is well
-		{errBusy, false},                    // Sync is already in progress, no problem
-		{errUnknownPeer, false},             // Peer is unknown, was already dropped, don't double drop
-		{errBadPeer, true},                  // Peer was deemed bad for some reason, drop it
-		{errStallingPeer, true},             // Peer was detected to be stalling, drop it
-		{errUnsyncedPeer, true},             // Peer was detected to be unsynced, drop it
-		{errNoPeers, false},                 // No peers to download from, soft race, no issue
-		{errTimeout, true},                  // No hashes received in due time, drop the peer
-		{errEmptyHeaderSet, true},           // No headers were returned as a response, drop as it's a dead end
-		{errPeersUnavailable, true},         // Nobody had the advertised blocks, drop the advertiser
-		{errInvalidAncestor, true},          // Agreed upon ancestor is not acceptable, drop the chain rewriter
-		{errInvalidChain, true},             // Hash chain was detected as invalid, definitely drop
-		{errInvalidBody, false},             // A bad peer was detected, but not the sync origin
-		{errInvalidReceipt, false},          // A bad peer was detected, but not the sync origin
-		{errCancelContentProcessing, false}, // Synchronisation was canceled, origin may be innocent, don't drop
+		{nil, false, 0},                                     // Sync succeeded, all is well
+		{errBusy, false, 0},                                 // Sync is already in progress, no problem
+		{errUnknownPeer, false, 0},                          // Peer is unknown, was already dropped, don't double drop
+		{errBadPeer, true, p2p.ScoreInvalidMessage},         // Peer was deemed bad for some reason, drop it
+		{errStallingPeer, true, p2p.ScoreTimeout},           // Peer was detected to be stalling, drop it
+		{errUnsyncedPeer, true, p2p.ScoreTimeout},           // Peer was detected to be unsynced, drop it
+		{errNoPeers, false, 0},                              // No peers to download from, soft race, no issue
+		{errTimeout, true, p2p.ScoreTimeout},                // No hashes received in due time, drop the peer
+		{errEmptyHeaderSet, true, p2p.ScoreInvalidMessage},  // No headers were returned as a response, drop as it's a dead end
+		{errPeersUnavailable, true, p2p.ScoreTimeout},       // Nobody had the advertised blocks, drop the advertiser
+		{errInvalidAncestor, true, p2p.ScoreInvalidMessage}, // Agreed upon ancestor is not acceptable, drop the chain rewriter
+		{errInvalidChain, true, p2p.ScoreInvalidMessage},    // Hash chain was detected as invalid, definitely drop
+		{errInvalidBody, false, 0},                          // A bad peer was detected, but not the sync origin
+		{errInvalidReceipt, false, 0},                       // A bad peer was detected, but not the sync origin
+		{errCancelContentProcessing, false, 0},              // Synchronisation was canceled, origin may be innocent, don't drop
 	}
 	// Run the tests and check disconnection status
 	tester := newTester()
@@ -1132,6 +1137,9 @@ func testBlockHeaderAttackerDropping(t *testing.T, protocol uint) {
 		if _, ok := tester.peers[id]; !ok != tt.drop {
 			t.Errorf("test %d: peer drop mismatch for %v: have %v, want %v", i, tt.result, !ok, tt.drop)
 		}
+		if event, ok := tester.dropped[id]; ok && event != tt.event {
+			t.Errorf("test %d: drop score mismatch for %v: have %v, want %v", i, tt.result, event, tt.event)
+		}
 	}
 }
 
@@ -59,6 +59,7 @@ type peerConnection struct {
 	stateStarted   time.Time // Time instance when the last node data fetch was started
 
 	rates   *msgrate.Tracker         // Tracker to hone in on the number of items retrievable per second
+	beat    *msgrate.Trackers        // Rate trackers of all peers, providing the target round trip time
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 
 	peer Peer
@@ -200,7 +201,7 @@ func (p *peerConnection) FetchNodeData(hashes []common.Hash) error {
 // requests. Its estimated header retrieval throughput is updated with that measured
 // just now.
 func (p *peerConnection) SetHeadersIdle(delivered int, deliveryTime time.Time) {
-	p.rates.Update(eth.BlockHeadersMsg, deliveryTime.Sub(p.headerStarted), delivered)
+	p.updateRates(eth.BlockHeadersMsg, deliveryTime.Sub(p.headerStarted), delivered)
 	atomic.StoreInt32(&p.headerIdle, 0)
 }
 
@@ -208,7 +209,7 @@ func (p *peerConnection) SetHeadersIdle(delivered int, deliveryTime time.Time) {
 // requests. Its estimated body retrieval throughput is updated with that measured
 // just now.
 func (p *peerConnection) SetBodiesIdle(delivered int, deliveryTime time.Time) {
-	p.rates.Update(eth.BlockBodiesMsg, deliveryTime.Sub(p.blockStarted), delivered)
+	p.updateRates(eth.BlockBodiesMsg, deliveryTime.Sub(p.blockStarted), delivered)
 	atomic.StoreInt32(&p.blockIdle, 0)
 }
 
@@ -216,7 +217,7 @@ func (p *peerConnection) SetBodiesIdle(delivered int, deliveryTime time.Time) {
 // retrieval requests. Its estimated receipt retrieval throughput is updated
 // with that measured just now.
 func (p *peerConnection) SetReceiptsIdle(delivered int, deliveryTime time.Time) {
-	p.rates.Update(eth.ReceiptsMsg, deliveryTime.Sub(p.receiptStarted), delivered)
+	p.updateRates(eth.ReceiptsMsg, deliveryTime.Sub(p.receiptStarted), delivered)
 	atomic.StoreInt32(&p.receiptIdle, 0)
 }
 
@@ -224,10 +225,25 @@ func (p *peerConnection) SetReceiptsIdle(delivered int, deliveryTime time.Time)
 // data retrieval requests. Its estimated state retrieval throughput is updated
 // with that measured just now.
 func (p *peerConnection) SetNodeDataIdle(delivered int, deliveryTime time.Time) {
-	p.rates.Update(eth.NodeDataMsg, deliveryTime.Sub(p.stateStarted), delivered)
+	p.updateRates(eth.NodeDataMsg, deliveryTime.Sub(p.stateStarted), delivered)
 	atomic.StoreInt32(&p.stateIdle, 0)
 }
 
+// latencyScorer is implemented by peers which track their reputation.
+type latencyScorer interface {
+	ScoreLatency(rtt, target time.Duration)
+}
+
+// updateRates updates the peer's estimated retrieval throughput with a request
+// that has just finished. Successful deliveries are also reported to the peer's
+// reputation, if it is tracked.
+func (p *peerConnection) updateRates(kind uint64, elapsed time.Duration, delivered int) {
+	p.rates.Update(kind, elapsed, delivered)
+	if scorer, ok := p.peer.(latencyScorer); ok && delivered > 0 && p.beat != nil {
+		scorer.ScoreLatency(elapsed, p.beat.TargetRoundTrip())
+	}
+}
+
 // HeaderCapacity retrieves the peers header download allowance based on its
 // previously discovered throughput.
 func (p *peerConnection) HeaderCapacity(targetRTT time.Duration) int {
@@ -349,6 +365,7 @@ func (ps *peerSet) Register(p *peerConnection) error {
 		return errAlreadyRegistered
 	}
 	p.rates = msgrate.NewTracker(ps.rates.MeanCapacities(), ps.rates.MedianRoundTrip())
+	p.beat = ps.rates
 	if err := ps.rates.Track(p.id, p.rates); err != nil {
 		return err
 	}
@@ -413,7 +430,7 @@ func (ps *peerSet) HeaderIdlePeers() ([]*peerConnection, int) {
 	throughput := func(p *peerConnection) int {
 		return p.rates.Capacity(eth.BlockHeadersMsg, time.Second)
 	}
-	return ps.idlePeers(eth.ETH66, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH66, eth.ETH68, idle, throughput)
 }
 
 // BodyIdlePeers retrieves a flat list of all the currently body-idle peers within
@@ -425,7 +442,7 @@ func (ps *peerSet) BodyIdlePeers() ([]*peerConnection, int) {
 	throughput := func(p *peerConnection) int {
 		return p.rates.Capacity(eth.BlockBodiesMsg, time.Second)
 	}
-	return ps.idlePeers(eth.ETH66, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH66, eth.ETH68, idle, throughput)
 }
 
 // ReceiptIdlePeers retrieves a flat list of all the currently receipt-idle peers
@@ -437,7 +454,7 @@ func (ps *peerSet) ReceiptIdlePeers() ([]*peerConnection, int) {
 	throughput := func(p *peerConnection) int {
 		return p.rates.Capacity(eth.ReceiptsMsg, time.Second)
 	}
-	return ps.idlePeers(eth.ETH66, eth.ETH66, idle, throughput)
+	return ps.idlePeers(eth.ETH66, eth.ETH68, idle, throughput)
 }
 
 // NodeDataIdlePeers retrieves a flat list of all the currently node-data-idle
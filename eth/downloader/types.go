@@ -20,10 +20,12 @@ import (
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/p2p"
 )
 
-// peerDropFn is a callback type for dropping a peer detected as malicious.
-type peerDropFn func(id string)
+// peerDropFn is a callback type for dropping a peer detected as malicious or
+// stalling, with the behavior it is scored for.
+type peerDropFn func(id string, event p2p.ScoreEvent)
 
 // dataPack is a data message returned by a peer for some query.
 type dataPack interface {
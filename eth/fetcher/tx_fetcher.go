@@ -48,6 +48,16 @@ const (
 	//     of the retrieval and response size overflow won't happen in most cases.
 	maxTxRetrievals = 256
 
+	// maxTxRetrievalSize is the maximum total size of transactions requested
+	// from a peer in one go, according to their announced sizes. Transactions
+	// announced without size hints (eth/66) are only limited by maxTxRetrievals.
+	maxTxRetrievalSize = 128 * 1024
+
+	// maxTxSize is the maximum size of transactions which are fetched. Larger
+	// announced transactions would be rejected by the pool (see txMaxSize in
+	// core/tx_pool.go), so they are not requested at all.
+	maxTxSize = 4 * 32 * 1024
+
 	// maxTxUnderpricedSetSize is the size of the underpriced transaction set that
 	// is used to track recent transactions that have been dropped so we don't
 	// re-request them.
@@ -68,11 +78,17 @@ var (
 	txFetchTimeout = 5 * time.Second
 )
 
+// errInvalidAnnounce is returned if the metadata hints of an announcement do not
+// line up with its hashes.
+var errInvalidAnnounce = errors.New("invalid announcement")
+
 var (
 	txAnnounceInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/in", nil)
 	txAnnounceKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/known", nil)
 	txAnnounceUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/underpriced", nil)
 	txAnnounceDOSMeter         = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/dos", nil)
+	txAnnounceOversizeMeter    = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/oversize", nil)
+	txAnnounceBadTypeMeter     = metrics.NewRegisteredMeter("eth/fetcher/transaction/announces/badtype", nil)
 
 	txBroadcastInMeter          = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/in", nil)
 	txBroadcastKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/broadcasts/known", nil)
@@ -88,6 +104,7 @@ var (
 	txReplyKnownMeter       = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/known", nil)
 	txReplyUnderpricedMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/underpriced", nil)
 	txReplyOtherRejectMeter = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/otherreject", nil)
+	txReplyMismatchMeter    = metrics.NewRegisteredMeter("eth/fetcher/transaction/replies/mismatch", nil)
 
 	txFetcherWaitingPeers   = metrics.NewRegisteredGauge("eth/fetcher/transaction/waiting/peers", nil)
 	txFetcherWaitingHashes  = metrics.NewRegisteredGauge("eth/fetcher/transaction/waiting/hashes", nil)
@@ -102,6 +119,13 @@ var (
 type txAnnounce struct {
 	origin string        // Identifier of the peer originating the notification
 	hashes []common.Hash // Batch of transaction hashes being announced
+	metas  []*txMetadata // Batch of metadata associated with the hashes (nil entries for eth/66)
+}
+
+// txMetadata is the type and size hint of an announced transaction.
+type txMetadata struct {
+	kind byte   // Transaction consensus type
+	size uint32 // Transaction size in bytes
 }
 
 // txRequest represents an in-flight transaction retrieval request destined to
@@ -117,6 +141,7 @@ type txRequest struct {
 type txDelivery struct {
 	origin string        // Identifier of the peer originating the notification
 	hashes []common.Hash // Batch of transaction hashes having been delivered
+	metas  []txMetadata  // Batch of metadata associated with the delivered hashes
 	direct bool          // Whether this is a direct reply or a broadcast
 }
 
@@ -125,6 +150,22 @@ type txDrop struct {
 	peer string
 }
 
+// txPeerStats is the delivery record of a peer. Retrievals are scheduled to
+// peers which reliably deliver what they announce first.
+type txPeerStats struct {
+	requested uint64 // Number of transactions requested from the peer
+	delivered uint64 // Number of requested transactions delivered with matching metadata
+}
+
+// score returns the estimated fraction of requested transactions delivered by
+// the peer. Peers without a record start out in the middle.
+func (s *txPeerStats) score() float64 {
+	if s == nil {
+		return 0.5
+	}
+	return float64(s.delivered+1) / float64(s.requested+2)
+}
+
 // TxFetcher is responsible for retrieving new transaction based on announcements.
 //
 // The fetcher operates in 3 stages:
@@ -135,6 +176,11 @@ type txDrop struct {
 //     transaction queued up (and announced by the peer) are allocated to the
 //     peer and moved into a fetching status until it's fulfilled or fails.
 //
+// Peers on eth/68 announce the type and size of transactions. These hints are
+// used to limit the size of requests and to skip transactions that wouldn't be
+// accepted by the pool anyway. Idle peers are served in the order of their
+// delivery record, so peers which reliably deliver get the contended hashes.
+//
 // The invariants of the fetcher are:
 //   - Each tracked transaction (hash) must only be present in one of the
 //     three stages. This ensures that the fetcher operates akin to a finite
@@ -152,14 +198,14 @@ type TxFetcher struct {
 
 	// Stage 1: Waiting lists for newly discovered transactions that might be
 	// broadcast without needing explicit request/reply round trips.
-	waitlist  map[common.Hash]map[string]struct{} // Transactions waiting for an potential broadcast
-	waittime  map[common.Hash]mclock.AbsTime      // Timestamps when transactions were added to the waitlist
-	waitslots map[string]map[common.Hash]struct{} // Waiting announcement sgroupped by peer (DoS protection)
+	waitlist  map[common.Hash]map[string]struct{}    // Transactions waiting for an potential broadcast
+	waittime  map[common.Hash]mclock.AbsTime         // Timestamps when transactions were added to the waitlist
+	waitslots map[string]map[common.Hash]*txMetadata // Waiting announcements grouped by peer (DoS protection)
 
 	// Stage 2: Queue of transactions that waiting to be allocated to some peer
 	// to be retrieved directly.
-	announces map[string]map[common.Hash]struct{} // Set of announced transactions, grouped by origin peer
-	announced map[common.Hash]map[string]struct{} // Set of download locations, grouped by transaction hash
+	announces map[string]map[common.Hash]*txMetadata // Set of announced transactions, grouped by origin peer
+	announced map[common.Hash]map[string]struct{}    // Set of download locations, grouped by transaction hash
 
 	// Stage 3: Set of transactions currently being retrieved, some which may be
 	// fulfilled and some rescheduled. Note, this step shares 'announces' from the
@@ -167,11 +213,18 @@ type TxFetcher struct {
 	fetching   map[common.Hash]string              // Transaction set currently being retrieved
 	requests   map[string]*txRequest               // In-flight transaction retrievals
 	alternates map[common.Hash]map[string]struct{} // In-flight transaction alternate origins if retrieval fails
+	stats      map[string]*txPeerStats             // Delivery records of peers that were sent requests
+
+	// Ranking of peers by delivery record, sorted only when records change
+	ranking      []string            // Peers scheduled so far, best delivery record first
+	ranked       map[string]struct{} // Set of peers contained in the ranking
+	rankingStale bool                // Whether the ranking needs to be re-sorted
 
 	// Callbacks
 	hasTx    func(common.Hash) bool             // Retrieves a tx from the local txpool
 	addTxs   func([]*types.Transaction) []error // Insert a batch of transactions into local txpool
 	fetchTxs func(string, []common.Hash) error  // Retrieves a set of txs from a remote peer
+	dropPeer func(string)                       // Drops a peer in case of announcement violation
 
 	step  chan struct{} // Notification channel when the fetcher loop iterates
 	clock mclock.Clock  // Time wrapper to simulate in tests
@@ -180,15 +233,15 @@ type TxFetcher struct {
 
 // NewTxFetcher creates a transaction fetcher to retrieve transaction
 // based on hash announcements.
-func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error) *TxFetcher {
-	return NewTxFetcherForTests(hasTx, addTxs, fetchTxs, mclock.System{}, nil)
+func NewTxFetcher(hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error, dropPeer func(string)) *TxFetcher {
+	return NewTxFetcherForTests(hasTx, addTxs, fetchTxs, dropPeer, mclock.System{}, nil)
 }
 
 // NewTxFetcherForTests is a testing method to mock out the realtime clock with
 // a simulated version and the internal randomness with a deterministic one.
 func NewTxFetcherForTests(
 	hasTx func(common.Hash) bool, addTxs func([]*types.Transaction) []error, fetchTxs func(string, []common.Hash) error,
-	clock mclock.Clock, rand *mrand.Rand) *TxFetcher {
+	dropPeer func(string), clock mclock.Clock, rand *mrand.Rand) *TxFetcher {
 	return &TxFetcher{
 		notify:      make(chan *txAnnounce),
 		cleanup:     make(chan *txDelivery),
@@ -196,24 +249,32 @@ func NewTxFetcherForTests(
 		quit:        make(chan struct{}),
 		waitlist:    make(map[common.Hash]map[string]struct{}),
 		waittime:    make(map[common.Hash]mclock.AbsTime),
-		waitslots:   make(map[string]map[common.Hash]struct{}),
-		announces:   make(map[string]map[common.Hash]struct{}),
+		waitslots:   make(map[string]map[common.Hash]*txMetadata),
+		announces:   make(map[string]map[common.Hash]*txMetadata),
 		announced:   make(map[common.Hash]map[string]struct{}),
 		fetching:    make(map[common.Hash]string),
 		requests:    make(map[string]*txRequest),
 		alternates:  make(map[common.Hash]map[string]struct{}),
+		stats:       make(map[string]*txPeerStats),
+		ranked:      make(map[string]struct{}),
 		underpriced: mapset.NewSet(),
 		hasTx:       hasTx,
 		addTxs:      addTxs,
 		fetchTxs:    fetchTxs,
+		dropPeer:    dropPeer,
 		clock:       clock,
 		rand:        rand,
 	}
 }
 
 // Notify announces the fetcher of the potential availability of a new batch of
-// transactions in the network.
-func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
+// transactions in the network. The kinds and sizes are the metadata hints of
+// eth/68 announcements, they are nil for eth/66 peers.
+func (f *TxFetcher) Notify(peer string, kinds []byte, sizes []uint32, hashes []common.Hash) error {
+	// The metadata hints, if any, must be given for every hash
+	if (kinds != nil || sizes != nil) && (len(kinds) != len(hashes) || len(sizes) != len(hashes)) {
+		return fmt.Errorf("%w: %d hashes, %d types, %d sizes", errInvalidAnnounce, len(hashes), len(kinds), len(sizes))
+	}
 	// Keep track of all the announced transactions
 	txAnnounceInMeter.Mark(int64(len(hashes)))
 
@@ -222,11 +283,16 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 	// because multiple concurrent notifies will still manage to pass it, but it's
 	// still valuable to check here because it runs concurrent  to the internal
 	// loop, so anything caught here is time saved internally.
+	//
+	// Transactions with metadata hints are also skipped if they are too large or
+	// of a type the pool doesn't know about.
 	var (
-		unknowns               = make([]common.Hash, 0, len(hashes))
-		duplicate, underpriced int64
+		unknownHashes = make([]common.Hash, 0, len(hashes))
+		unknownMetas  = make([]*txMetadata, 0, len(hashes))
+
+		duplicate, underpriced, oversize, badtype int64
 	)
-	for _, hash := range hashes {
+	for i, hash := range hashes {
 		switch {
 		case f.hasTx(hash):
 			duplicate++
@@ -234,20 +300,34 @@ func (f *TxFetcher) Notify(peer string, hashes []common.Hash) error {
 		case f.underpriced.Contains(hash):
 			underpriced++
 
+		case kinds != nil && sizes[i] > maxTxSize:
+			oversize++
+
+		case kinds != nil && kinds[i] > types.DynamicFeeTxType:
+			badtype++
+
 		default:
-			unknowns = append(unknowns, hash)
+			unknownHashes = append(unknownHashes, hash)
+			if kinds == nil {
+				unknownMetas = append(unknownMetas, nil)
+			} else {
+				unknownMetas = append(unknownMetas, &txMetadata{kind: kinds[i], size: sizes[i]})
+			}
 		}
 	}
 	txAnnounceKnownMeter.Mark(duplicate)
 	txAnnounceUnderpricedMeter.Mark(underpriced)
+	txAnnounceOversizeMeter.Mark(oversize)
+	txAnnounceBadTypeMeter.Mark(badtype)
 
 	// If anything's left to announce, push it into the internal loop
-	if len(unknowns) == 0 {
+	if len(unknownHashes) == 0 {
 		return nil
 	}
 	announce := &txAnnounce{
 		origin: peer,
-		hashes: unknowns,
+		hashes: unknownHashes,
+		metas:  unknownMetas,
 	}
 	select {
 	case f.notify <- announce:
@@ -272,6 +352,7 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 	// re-requesting them and dropping the peer in case of malicious transfers.
 	var (
 		added       = make([]common.Hash, 0, len(txs))
+		metas       = make([]txMetadata, 0, len(txs))
 		duplicate   int64
 		underpriced int64
 		otherreject int64
@@ -301,6 +382,7 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 			otherreject++
 		}
 		added = append(added, txs[i].Hash())
+		metas = append(metas, txMetadata{kind: txs[i].Type(), size: uint32(txs[i].Size())})
 	}
 	if direct {
 		txReplyKnownMeter.Mark(duplicate)
@@ -312,7 +394,7 @@ func (f *TxFetcher) Enqueue(peer string, txs []*types.Transaction, direct bool)
 		txBroadcastOtherRejectMeter.Mark(otherreject)
 	}
 	select {
-	case f.cleanup <- &txDelivery{origin: peer, hashes: added, direct: direct}:
+	case f.cleanup <- &txDelivery{origin: peer, hashes: added, metas: metas, direct: direct}:
 		return nil
 	case <-f.quit:
 		return errTerminated
@@ -370,12 +452,15 @@ func (f *TxFetcher) loop() {
 			if want > maxTxAnnounces {
 				txAnnounceDOSMeter.Mark(int64(want - maxTxAnnounces))
 				ann.hashes = ann.hashes[:want-maxTxAnnounces]
+				ann.metas = ann.metas[:want-maxTxAnnounces]
 			}
 			// All is well, schedule the remainder of the transactions
 			idleWait := len(f.waittime) == 0
 			_, oldPeer := f.announces[ann.origin]
 
-			for _, hash := range ann.hashes {
+			for i, hash := range ann.hashes {
+				meta := ann.metas[i]
+
 				// If the transaction is already downloading, add it to the list
 				// of possible alternates (in case the current retrieval fails) and
 				// also account it for the peer.
@@ -384,9 +469,9 @@ func (f *TxFetcher) loop() {
 
 					// Stage 2 and 3 share the set of origins per tx
 					if announces := f.announces[ann.origin]; announces != nil {
-						announces[hash] = struct{}{}
+						announces[hash] = meta
 					} else {
-						f.announces[ann.origin] = map[common.Hash]struct{}{hash: {}}
+						f.announces[ann.origin] = map[common.Hash]*txMetadata{hash: meta}
 					}
 					continue
 				}
@@ -397,9 +482,9 @@ func (f *TxFetcher) loop() {
 
 					// Stage 2 and 3 share the set of origins per tx
 					if announces := f.announces[ann.origin]; announces != nil {
-						announces[hash] = struct{}{}
+						announces[hash] = meta
 					} else {
-						f.announces[ann.origin] = map[common.Hash]struct{}{hash: {}}
+						f.announces[ann.origin] = map[common.Hash]*txMetadata{hash: meta}
 					}
 					continue
 				}
@@ -410,9 +495,9 @@ func (f *TxFetcher) loop() {
 					f.waitlist[hash][ann.origin] = struct{}{}
 
 					if waitslots := f.waitslots[ann.origin]; waitslots != nil {
-						waitslots[hash] = struct{}{}
+						waitslots[hash] = meta
 					} else {
-						f.waitslots[ann.origin] = map[common.Hash]struct{}{hash: {}}
+						f.waitslots[ann.origin] = map[common.Hash]*txMetadata{hash: meta}
 					}
 					continue
 				}
@@ -421,9 +506,9 @@ func (f *TxFetcher) loop() {
 				f.waittime[hash] = f.clock.Now()
 
 				if waitslots := f.waitslots[ann.origin]; waitslots != nil {
-					waitslots[hash] = struct{}{}
+					waitslots[hash] = meta
 				} else {
-					f.waitslots[ann.origin] = map[common.Hash]struct{}{hash: {}}
+					f.waitslots[ann.origin] = map[common.Hash]*txMetadata{hash: meta}
 				}
 			}
 			// If a new item was added to the waitlist, schedule it into the fetcher
@@ -448,10 +533,11 @@ func (f *TxFetcher) loop() {
 					}
 					f.announced[hash] = f.waitlist[hash]
 					for peer := range f.waitlist[hash] {
+						meta := f.waitslots[peer][hash]
 						if announces := f.announces[peer]; announces != nil {
-							announces[hash] = struct{}{}
+							announces[hash] = meta
 						} else {
-							f.announces[peer] = map[common.Hash]struct{}{hash: {}}
+							f.announces[peer] = map[common.Hash]*txMetadata{hash: meta}
 						}
 						delete(f.waitslots[peer], hash)
 						if len(f.waitslots[peer]) == 0 {
@@ -519,6 +605,29 @@ func (f *TxFetcher) loop() {
 			f.rescheduleTimeout(timeoutTimer, timeoutTrigger)
 
 		case delivery := <-f.cleanup:
+			// In case of a direct delivery, check the transactions against the
+			// metadata announced by the peer before the announcements are gone.
+			// Mismatching transactions count as undelivered in the peer's record,
+			// and the peer is dropped as eth/68 requires exact announcements.
+			var mismatches uint64
+			if delivery.direct {
+				for i, hash := range delivery.hashes {
+					meta := f.announces[delivery.origin][hash]
+					if meta == nil {
+						continue
+					}
+					if meta.kind != delivery.metas[i].kind || meta.size != delivery.metas[i].size {
+						log.Debug("Announced transaction metadata mismatch", "peer", delivery.origin, "tx", hash,
+							"type", delivery.metas[i].kind, "announced type", meta.kind,
+							"size", delivery.metas[i].size, "announced size", meta.size)
+						mismatches++
+					}
+				}
+				txReplyMismatchMeter.Mark(int64(mismatches))
+				if mismatches > 0 {
+					f.dropPeer(delivery.origin)
+				}
+			}
 			// Independent if the delivery was direct or broadcast, remove all
 			// traces of the hash from internal trackers
 			for _, hash := range delivery.hashes {
@@ -575,6 +684,16 @@ func (f *TxFetcher) loop() {
 				for _, hash := range delivery.hashes {
 					delivered[hash] = struct{}{}
 				}
+				// Update the delivery record of the peer
+				var fulfilled uint64
+				for _, hash := range req.hashes {
+					if _, ok := delivered[hash]; ok {
+						fulfilled++
+					}
+				}
+				if fulfilled > mismatches {
+					f.peerStats(delivery.origin).delivered += fulfilled - mismatches
+				}
 				cutoff := len(req.hashes) // If nothing is delivered, assume everything is missing, don't retry!!!
 				for i, hash := range req.hashes {
 					if _, ok := delivered[hash]; ok {
@@ -658,6 +777,9 @@ func (f *TxFetcher) loop() {
 				}
 				delete(f.announces, drop.peer)
 			}
+			delete(f.stats, drop.peer)
+			f.unrank(drop.peer)
+
 			// If a request was cancelled, check if anything needs to be rescheduled
 			if request != nil {
 				f.scheduleFetches(timeoutTimer, timeoutTrigger, nil)
@@ -769,7 +891,10 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 		if len(f.announces[peer]) == 0 {
 			return // continue in the for-each
 		}
-		hashes := make([]common.Hash, 0, maxTxRetrievals)
+		var (
+			hashes = make([]common.Hash, 0, maxTxRetrievals)
+			size   uint64
+		)
 		f.forEachHash(f.announces[peer], func(hash common.Hash) bool {
 			if _, ok := f.fetching[hash]; !ok {
 				// Mark the hash as fetching and stash away possible alternates
@@ -781,17 +906,25 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 				f.alternates[hash] = f.announced[hash]
 				delete(f.announced, hash)
 
-				// Accumulate the hash and stop if the limit was reached
+				// Accumulate the hash and stop if the count or size limit
+				// was reached
 				hashes = append(hashes, hash)
 				if len(hashes) >= maxTxRetrievals {
 					return false // break in the for-each
 				}
+				if meta := f.announces[peer][hash]; meta != nil {
+					size += uint64(meta.size)
+					if size >= maxTxRetrievalSize {
+						return false // break in the for-each
+					}
+				}
 			}
 			return true // continue in the for-each
 		})
 		// If any hashes were allocated, request them from the peer
 		if len(hashes) > 0 {
 			f.requests[peer] = &txRequest{hashes: hashes, time: f.clock.Now()}
+			f.peerStats(peer).requested += uint64(len(hashes))
 			txRequestOutMeter.Mark(int64(len(hashes)))
 
 			go func(peer string, hashes []common.Hash) {
@@ -810,31 +943,66 @@ func (f *TxFetcher) scheduleFetches(timer *mclock.Timer, timeout chan struct{},
 	}
 }
 
-// forEachPeer does a range loop over a map of peers in production, but during
-// testing it does a deterministic sorted random to allow reproducing issues.
-func (f *TxFetcher) forEachPeer(peers map[string]struct{}, do func(peer string)) {
-	// If we're running production, use whatever Go's map gives us
-	if f.rand == nil {
-		for peer := range peers {
-			do(peer)
-		}
+// peerStats returns the delivery record of a peer for updating, creating it if
+// needed. The ranking of peers is re-sorted before its next use.
+func (f *TxFetcher) peerStats(peer string) *txPeerStats {
+	stats := f.stats[peer]
+	if stats == nil {
+		stats = new(txPeerStats)
+		f.stats[peer] = stats
+	}
+	f.rankingStale = true
+	return stats
+}
+
+// unrank removes a dropped peer from the ranking.
+func (f *TxFetcher) unrank(peer string) {
+	if _, ok := f.ranked[peer]; !ok {
 		return
 	}
-	// We're running the test suite, make iteration deterministic
-	list := make([]string, 0, len(peers))
+	delete(f.ranked, peer)
+	for i, ranked := range f.ranking {
+		if ranked == peer {
+			f.ranking = append(f.ranking[:i], f.ranking[i+1:]...)
+			break
+		}
+	}
+}
+
+// forEachPeer iterates over a map of peers, visiting peers with a better delivery
+// record first. The ranking of peers is only re-sorted when delivery records
+// changed or new peers were seen. Peers with equal records are visited in the
+// order they were first seen in production, but during testing it does a
+// deterministic sorted random to allow reproducing issues.
+func (f *TxFetcher) forEachPeer(peers map[string]struct{}, do func(peer string)) {
 	for peer := range peers {
-		list = append(list, peer)
+		if _, ok := f.ranked[peer]; !ok {
+			f.ranked[peer] = struct{}{}
+			f.ranking = append(f.ranking, peer)
+			f.rankingStale = true
+		}
+	}
+	if f.rankingStale && len(f.ranking) > 0 {
+		// We're running the test suite, make iteration deterministic
+		if f.rand != nil {
+			sort.Strings(f.ranking)
+			rotateStrings(f.ranking, f.rand.Intn(len(f.ranking)))
+		}
+		sort.SliceStable(f.ranking, func(i, j int) bool {
+			return f.stats[f.ranking[i]].score() > f.stats[f.ranking[j]].score()
+		})
+		f.rankingStale = false
 	}
-	sort.Strings(list)
-	rotateStrings(list, f.rand.Intn(len(list)))
-	for _, peer := range list {
-		do(peer)
+	for _, peer := range f.ranking {
+		if _, ok := peers[peer]; ok {
+			do(peer)
+		}
 	}
 }
 
 // forEachHash does a range loop over a map of hashes in production, but during
 // testing it does a deterministic sorted random to allow reproducing issues.
-func (f *TxFetcher) forEachHash(hashes map[common.Hash]struct{}, do func(hash common.Hash) bool) {
+func (f *TxFetcher) forEachHash(hashes map[common.Hash]*txMetadata, do func(hash common.Hash) bool) {
 	// If we're running production, use whatever Go's map gives us
 	if f.rand == nil {
 		for hash := range hashes {
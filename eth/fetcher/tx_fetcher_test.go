@@ -27,6 +27,8 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 var (
@@ -44,6 +46,8 @@ var (
 type doTxNotify struct {
 	peer   string
 	hashes []common.Hash
+	types  []byte
+	sizes  []uint32
 }
 type doTxEnqueue struct {
 	peer   string
@@ -81,6 +85,7 @@ func TestTransactionFetcherWaiting(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -171,6 +176,7 @@ func TestTransactionFetcherSkipWaiting(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -234,6 +240,7 @@ func TestTransactionFetcherSingletonRequesting(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -314,6 +321,7 @@ func TestTransactionFetcherFailedRescheduling(t *testing.T) {
 					<-proceed
 					return errors.New("peer disconnected")
 				},
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -383,6 +391,7 @@ func TestTransactionFetcherCleanup(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -422,6 +431,7 @@ func TestTransactionFetcherCleanupEmpty(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -460,6 +470,7 @@ func TestTransactionFetcherMissingRescheduling(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -506,6 +517,7 @@ func TestTransactionFetcherMissingCleanup(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -544,6 +556,7 @@ func TestTransactionFetcherBroadcasts(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -592,6 +605,7 @@ func TestTransactionFetcherWaitTimerResets(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -649,6 +663,7 @@ func TestTransactionFetcherTimeoutRescheduling(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -714,6 +729,7 @@ func TestTransactionFetcherTimeoutTimerResets(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -773,6 +789,7 @@ func TestTransactionFetcherRateLimiting(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -811,6 +828,7 @@ func TestTransactionFetcherDoSProtection(t *testing.T) {
 				func(common.Hash) bool { return false },
 				nil,
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -878,6 +896,7 @@ func TestTransactionFetcherUnderpricedDedup(t *testing.T) {
 					return errs
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -947,6 +966,7 @@ func TestTransactionFetcherUnderpricedDoSProtection(t *testing.T) {
 					return errs
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: append(steps, []interface{}{
@@ -969,6 +989,7 @@ func TestTransactionFetcherOutOfBoundDeliveries(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1022,6 +1043,7 @@ func TestTransactionFetcherDrop(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1088,6 +1110,7 @@ func TestTransactionFetcherDropRescheduling(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1121,6 +1144,291 @@ func TestTransactionFetcherDropRescheduling(t *testing.T) {
 	})
 }
 
+// Tests that announcements of oversized transactions and unknown transaction
+// types are not scheduled.
+func TestTransactionFetcherSkipUnwanted(t *testing.T) {
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				nil,
+				func(string, []common.Hash) error { return nil },
+				nil,
+			)
+		},
+		steps: []interface{}{
+			doTxNotify{
+				peer:   "A",
+				hashes: []common.Hash{{0x01}, {0x02}, {0x03}, {0x04}},
+				types:  []byte{types.LegacyTxType, types.DynamicFeeTxType, types.LegacyTxType, 0x7f},
+				sizes:  []uint32{100, maxTxSize, maxTxSize + 1, 100},
+			},
+			isWaiting(map[string][]common.Hash{
+				"A": {{0x01}, {0x02}},
+			}),
+		},
+	})
+}
+
+// Tests that the announced sizes of transactions limit the number of transactions
+// requested at once.
+func TestTransactionFetcherSizeLimit(t *testing.T) {
+	var (
+		hashes    []common.Hash
+		kinds     []byte
+		sizes     []uint32
+		requested = make(chan []common.Hash, 1)
+	)
+	for i := 0; i < 10; i++ {
+		hashes = append(hashes, common.Hash{byte(i + 1)})
+		kinds = append(kinds, types.LegacyTxType)
+		sizes = append(sizes, 40*1024)
+	}
+	checkRequested := func(n int) doFunc {
+		return func() {
+			select {
+			case req := <-requested:
+				if len(req) != n {
+					t.Errorf("wrong number of requested transactions %d, want %d", len(req), n)
+				}
+			case <-time.After(time.Second):
+				t.Error("no request sent")
+			}
+		}
+	}
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				nil,
+				func(peer string, hashes []common.Hash) error {
+					requested <- hashes
+					return nil
+				},
+				nil,
+			)
+		},
+		steps: []interface{}{
+			// Announce with sizes, the request must stop once the size limit
+			// is reached.
+			doTxNotify{peer: "A", hashes: hashes, types: kinds, sizes: sizes},
+			doWait{time: txArriveTimeout, step: true},
+			checkRequested(maxTxRetrievalSize/(40*1024) + 1),
+
+			// Without sizes, everything is requested at once.
+			doDrop("A"),
+			doTxNotify{peer: "B", hashes: hashes},
+			doWait{time: txArriveTimeout, step: true},
+			checkRequested(len(hashes)),
+		},
+	})
+}
+
+// Tests that contended transactions are requested from the peer with the better
+// delivery record first.
+func TestTransactionFetcherPeerPriority(t *testing.T) {
+	t.Parallel()
+	testTransactionFetcherPeerPriority(t, "A", "B")
+	testTransactionFetcherPeerPriority(t, "B", "A")
+}
+
+func testTransactionFetcherPeerPriority(t *testing.T, good, bad string) {
+	testTransactionFetcher(t, txFetcherTest{
+		init: func() *TxFetcher {
+			return NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+				nil,
+			)
+		},
+		steps: []interface{}{
+			// Build a record: the good peer delivers, the bad one doesn't
+			doTxNotify{peer: good, hashes: []common.Hash{testTxsHashes[0]}},
+			doTxNotify{peer: bad, hashes: []common.Hash{testTxsHashes[1]}},
+			doWait{time: txArriveTimeout, step: true},
+			doTxEnqueue{peer: good, txs: []*types.Transaction{testTxs[0]}, direct: true},
+			doTxEnqueue{peer: bad, txs: nil, direct: true},
+			isScheduled{tracking: nil, fetching: nil},
+
+			// Announce the same transactions from both peers, the bad peer
+			// first. The good one should be asked for all of them.
+			doTxNotify{peer: bad, hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doTxNotify{peer: good, hashes: []common.Hash{testTxsHashes[2], testTxsHashes[3]}},
+			doWait{time: txArriveTimeout, step: true},
+			isScheduled{
+				tracking: map[string][]common.Hash{
+					good: {testTxsHashes[2], testTxsHashes[3]},
+					bad:  {testTxsHashes[2], testTxsHashes[3]},
+				},
+				fetching: map[string][]common.Hash{
+					good: {testTxsHashes[2], testTxsHashes[3]},
+				},
+			},
+		},
+	})
+}
+
+// Tests that deliveries which don't match the announced metadata are not counted
+// in the delivery record of a peer, and that the peer is dropped.
+func TestTransactionFetcherMetadataMismatch(t *testing.T) {
+	var (
+		fetcher *TxFetcher
+		dropped []string
+	)
+	checkStats := func(peer string, requested, delivered uint64) doFunc {
+		return func() {
+			stats := fetcher.stats[peer]
+			if stats == nil {
+				t.Fatalf("no delivery record for peer %s", peer)
+			}
+			if stats.requested != requested || stats.delivered != delivered {
+				t.Errorf("peer %s: wrong record %+v, want requested %d, delivered %d", peer, *stats, requested, delivered)
+			}
+		}
+	}
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			fetcher = NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+				func(peer string) { dropped = append(dropped, peer) },
+			)
+			return fetcher
+		},
+		steps: []interface{}{
+			doTxNotify{
+				peer:   "A",
+				hashes: []common.Hash{testTxsHashes[0], testTxsHashes[1]},
+				types:  []byte{testTxs[0].Type(), testTxs[1].Type()},
+				sizes:  []uint32{uint32(testTxs[0].Size()), uint32(testTxs[1].Size()) + 1},
+			},
+			doWait{time: txArriveTimeout, step: true},
+			doTxEnqueue{peer: "A", txs: []*types.Transaction{testTxs[0], testTxs[1]}, direct: true},
+			checkStats("A", 2, 1),
+			isScheduled{tracking: nil, fetching: nil},
+			doFunc(func() {
+				if len(dropped) != 1 || dropped[0] != "A" {
+					t.Errorf("dropped peers mismatch: have %v, want [A]", dropped)
+				}
+			}),
+
+			// The record is forgotten when the peer disconnects.
+			doDrop("A"),
+			doFunc(func() {
+				if fetcher.stats["A"] != nil {
+					t.Error("delivery record not removed on drop")
+				}
+			}),
+		},
+	})
+}
+
+// Tests that a freshly signed typed transaction announced with the metadata of
+// the sender's pool matches the same transaction decoded from the network.
+func TestTransactionFetcherTypedMetadataRoundtrip(t *testing.T) {
+	signer := types.LatestSignerForChainID(common.Big1)
+	tx, err := types.SignNewTx(testKey, signer, &types.DynamicFeeTx{
+		ChainID:   common.Big1,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		To:        &common.Address{0x01},
+		Value:     big.NewInt(1),
+	})
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	// Announce the transaction as the broadcaster does and ship the announcement
+	// and the reply through RLP.
+	var ann eth.NewPooledTransactionHashesPacket68
+	blob, err := rlp.EncodeToBytes(&eth.NewPooledTransactionHashesPacket68{
+		Types:  []byte{tx.Type()},
+		Sizes:  []uint32{uint32(tx.Size())},
+		Hashes: []common.Hash{tx.Hash()},
+	})
+	if err != nil {
+		t.Fatalf("failed to encode announcement: %v", err)
+	}
+	if err := rlp.DecodeBytes(blob, &ann); err != nil {
+		t.Fatalf("failed to decode announcement: %v", err)
+	}
+	var reply eth.PooledTransactionsPacket
+	if blob, err = rlp.EncodeToBytes(eth.PooledTransactionsPacket{tx}); err != nil {
+		t.Fatalf("failed to encode transactions: %v", err)
+	}
+	if err := rlp.DecodeBytes(blob, &reply); err != nil {
+		t.Fatalf("failed to decode transactions: %v", err)
+	}
+	if enc, _ := tx.MarshalBinary(); ann.Sizes[0] != uint32(len(enc)) {
+		t.Errorf("announced size mismatch: have %d, want %d", ann.Sizes[0], len(enc))
+	}
+	var (
+		fetcher *TxFetcher
+		dropped []string
+	)
+	testTransactionFetcherParallel(t, txFetcherTest{
+		init: func() *TxFetcher {
+			fetcher = NewTxFetcher(
+				func(common.Hash) bool { return false },
+				func(txs []*types.Transaction) []error {
+					return make([]error, len(txs))
+				},
+				func(string, []common.Hash) error { return nil },
+				func(peer string) { dropped = append(dropped, peer) },
+			)
+			return fetcher
+		},
+		steps: []interface{}{
+			doTxNotify{peer: "A", hashes: ann.Hashes, types: ann.Types, sizes: ann.Sizes},
+			doWait{time: txArriveTimeout, step: true},
+			doTxEnqueue{peer: "A", txs: reply, direct: true},
+			isScheduled{tracking: nil, fetching: nil},
+			doFunc(func() {
+				if len(dropped) != 0 {
+					t.Errorf("dropped peers mismatch: have %v, want none", dropped)
+				}
+				if stats := fetcher.stats["A"]; stats == nil || stats.delivered != 1 {
+					t.Errorf("delivery not recorded: %+v", stats)
+				}
+			}),
+		},
+	})
+}
+
+// Tests that announcements with metadata hints not covering every hash are
+// rejected.
+func TestTransactionFetcherInvalidAnnounce(t *testing.T) {
+	fetcher := NewTxFetcher(
+		func(common.Hash) bool { return false },
+		nil,
+		func(string, []common.Hash) error { return nil },
+		nil,
+	)
+	hashes := []common.Hash{{0x01}, {0x02}}
+	tests := []struct {
+		types []byte
+		sizes []uint32
+	}{
+		{types: []byte{0, 0}, sizes: nil},
+		{types: nil, sizes: []uint32{1, 1}},
+		{types: []byte{0}, sizes: []uint32{1, 1}},
+		{types: []byte{0, 0}, sizes: []uint32{1}},
+		{types: []byte{0, 0, 0}, sizes: []uint32{1, 1, 1}},
+	}
+	for i, tt := range tests {
+		if err := fetcher.Notify("A", tt.types, tt.sizes, hashes); !errors.Is(err, errInvalidAnnounce) {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, errInvalidAnnounce)
+		}
+	}
+}
+
 // This test reproduces a crash caught by the fuzzer. The root cause was a
 // dangling transaction timing out and clashing on readd with a concurrently
 // announced one.
@@ -1133,6 +1441,7 @@ func TestTransactionFetcherFuzzCrash01(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1160,6 +1469,7 @@ func TestTransactionFetcherFuzzCrash02(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1189,6 +1499,7 @@ func TestTransactionFetcherFuzzCrash03(t *testing.T) {
 					return make([]error, len(txs))
 				},
 				func(string, []common.Hash) error { return nil },
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1225,6 +1536,7 @@ func TestTransactionFetcherFuzzCrash04(t *testing.T) {
 					<-proceed
 					return errors.New("peer disconnected")
 				},
+				nil,
 			)
 		},
 		steps: []interface{}{
@@ -1267,7 +1579,7 @@ func testTransactionFetcher(t *testing.T, tt txFetcherTest) {
 	for i, step := range tt.steps {
 		switch step := step.(type) {
 		case doTxNotify:
-			if err := fetcher.Notify(step.peer, step.hashes); err != nil {
+			if err := fetcher.Notify(step.peer, step.types, step.sizes, step.hashes); err != nil {
 				t.Errorf("step %d: %v", i, err)
 			}
 			<-wait // Fetcher needs to process this, wait until it's done
@@ -217,7 +217,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return n, err
 	}
-	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.removePeer)
+	h.blockFetcher = fetcher.NewBlockFetcher(false, nil, h.chain.GetBlockByHash, validator, h.BroadcastBlock, heighter, nil, inserter, h.dropInvalidPeer, h.creditPeer)
 
 	fetchTx := func(peer string, hashes []common.Hash) error {
 		p := h.peers.peer(peer)
@@ -226,7 +226,7 @@ func newHandler(config *handlerConfig) (*handler, error) {
 		}
 		return p.RequestTxs(hashes)
 	}
-	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx)
+	h.txFetcher = fetcher.NewTxFetcher(h.txpool.Has, h.txpool.AddRemotes, fetchTx, h.dropInvalidPeer)
 	h.chainSync = newChainSyncer(h)
 	return h, nil
 }
@@ -317,7 +317,8 @@ func (h *handler) runEthPeer(peer *eth.Peer, handler eth.Handler) error {
 		// Start a timer to disconnect if the peer doesn't reply in time
 		p.syncDrop = time.AfterFunc(syncChallengeTimeout, func() {
 			peer.Log().Warn("Checkpoint challenge timed out, dropping", "addr", peer.RemoteAddr(), "type", peer.Name())
-			h.removePeer(peer.ID())
+			peer.Score(p2p.ScoreTimeout)
+			peer.Disconnect(p2p.DiscUselessPeer)
 		})
 		// Make sure it's cleaned up if the peer dies off
 		defer func() {
@@ -352,14 +353,28 @@ func (h *handler) runSnapExtension(peer *snap.Peer, handler snap.Handler) error
 	return handler(peer)
 }
 
-// removePeer requests disconnection of a peer.
-func (h *handler) removePeer(id string) {
+// removePeer requests disconnection of a misbehaving or stalling peer, scoring
+// it for the given behavior.
+func (h *handler) removePeer(id string, event p2p.ScoreEvent) {
 	peer := h.peers.peer(id)
 	if peer != nil {
+		peer.Peer.Score(event)
 		peer.Peer.Disconnect(p2p.DiscUselessPeer)
 	}
 }
 
+// dropInvalidPeer requests disconnection of a peer which sent invalid data.
+func (h *handler) dropInvalidPeer(id string) {
+	h.removePeer(id, p2p.ScoreInvalidMessage)
+}
+
+// creditPeer records the delivery of a valid block by a peer.
+func (h *handler) creditPeer(id string) {
+	if peer := h.peers.peer(id); peer != nil {
+		peer.Peer.Score(p2p.ScoreValidBlock)
+	}
+}
+
 // unregisterPeer removes a peer from the downloader, fetchers and main peer set.
 func (h *handler) unregisterPeer(id string) {
 	// Create a custom logger to avoid printing the entire id
@@ -28,6 +28,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/protocols/eth"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/trie"
 )
@@ -91,19 +92,42 @@ func (h *ethHandler) Handle(peer *eth.Peer, packet eth.Packet) error {
 		return h.handleBlockBroadcast(peer, packet.Block, packet.TD)
 
 	case *eth.NewPooledTransactionHashesPacket:
-		return h.txFetcher.Notify(peer.ID(), *packet)
+		return h.txFetcher.Notify(peer.ID(), nil, nil, *packet)
+
+	case *eth.NewPooledTransactionHashesPacket68:
+		return h.txFetcher.Notify(peer.ID(), packet.Types, packet.Sizes, packet.Hashes)
 
 	case *eth.TransactionsPacket:
-		return h.txFetcher.Enqueue(peer.ID(), *packet, false)
+		return h.handleTransactions(peer, *packet, false)
 
 	case *eth.PooledTransactionsPacket:
-		return h.txFetcher.Enqueue(peer.ID(), *packet, true)
+		return h.handleTransactions(peer, *packet, true)
 
 	default:
 		return fmt.Errorf("unexpected eth packet type: %T", packet)
 	}
 }
 
+// handleTransactions is invoked from a peer's message handler when it transmits a
+// batch of transactions for the local node to process. The peer is credited if any
+// of the transactions made it into the pool.
+func (h *ethHandler) handleTransactions(peer *eth.Peer, txs []*types.Transaction, direct bool) error {
+	known := make([]bool, len(txs))
+	for i, tx := range txs {
+		known[i] = h.txpool.Has(tx.Hash())
+	}
+	if err := h.txFetcher.Enqueue(peer.ID(), txs, direct); err != nil {
+		return err
+	}
+	for i, tx := range txs {
+		if !known[i] && h.txpool.Has(tx.Hash()) {
+			peer.Score(p2p.ScoreValidTransactions)
+			break
+		}
+	}
+	return nil
+}
+
 // handleHeaders is invoked from a peer's message handler when it transmits a batch
 // of headers for the local node to process.
 func (h *ethHandler) handleHeaders(peer *eth.Peer, headers []*types.Header) error {
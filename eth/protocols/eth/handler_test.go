@@ -17,10 +17,12 @@
 package eth
 
 import (
+	"errors"
 	"math"
 	"math/big"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
@@ -111,6 +113,7 @@ func (b *testBackend) Handle(*Peer, Packet) error {
 
 // Tests that block headers can be retrieved from a remote chain based on user queries.
 func TestGetBlockHeaders66(t *testing.T) { testGetBlockHeaders(t, ETH66) }
+func TestGetBlockHeaders68(t *testing.T) { testGetBlockHeaders(t, ETH68) }
 
 func testGetBlockHeaders(t *testing.T, protocol uint) {
 	t.Parallel()
@@ -291,6 +294,7 @@ func testGetBlockHeaders(t *testing.T, protocol uint) {
 
 // Tests that block contents can be retrieved from a remote chain based on their hashes.
 func TestGetBlockBodies66(t *testing.T) { testGetBlockBodies(t, ETH66) }
+func TestGetBlockBodies68(t *testing.T) { testGetBlockBodies(t, ETH68) }
 
 func testGetBlockBodies(t *testing.T, protocol uint) {
 	t.Parallel()
@@ -375,6 +379,30 @@ func testGetBlockBodies(t *testing.T, protocol uint) {
 // Tests that the state trie nodes can be retrieved based on hashes.
 func TestGetNodeData66(t *testing.T) { testGetNodeData(t, ETH66) }
 
+// Tests that state trie nodes can't be retrieved on eth/68.
+func TestGetNodeData68(t *testing.T) {
+	t.Parallel()
+
+	backend := newTestBackend(4)
+	defer backend.close()
+
+	peer, errc := newTestPeer("peer", ETH68, backend)
+	defer peer.close()
+
+	p2p.Send(peer.app, GetNodeDataMsg, GetNodeDataPacket66{
+		RequestId:         123,
+		GetNodeDataPacket: []common.Hash{backend.chain.CurrentBlock().Root()},
+	})
+	select {
+	case err := <-errc:
+		if !errors.Is(err, errInvalidMsgCode) {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("peer not disconnected")
+	}
+}
+
 func testGetNodeData(t *testing.T, protocol uint) {
 	t.Parallel()
 
@@ -483,6 +511,7 @@ func testGetNodeData(t *testing.T, protocol uint) {
 
 // Tests that the transaction receipts can be retrieved based on hashes.
 func TestGetBlockReceipts66(t *testing.T) { testGetBlockReceipts(t, ETH66) }
+func TestGetBlockReceipts68(t *testing.T) { testGetBlockReceipts(t, ETH68) }
 
 func testGetBlockReceipts(t *testing.T, protocol uint) {
 	t.Parallel()
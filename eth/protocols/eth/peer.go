@@ -203,14 +203,18 @@ func (p *Peer) AsyncSendTransactions(hashes []common.Hash) {
 }
 
 // sendPooledTransactionHashes sends transaction hashes to the peer and includes
-// them in its transaction hash set for future reference.
+// them in its transaction hash set for future reference. The types and sizes of
+// the transactions are only sent on eth/68 and newer.
 //
 // This method is a helper used by the async transaction announcer. Don't call it
 // directly as the queueing (memory) and transmission (bandwidth) costs should
 // not be managed directly.
-func (p *Peer) sendPooledTransactionHashes(hashes []common.Hash) error {
+func (p *Peer) sendPooledTransactionHashes(hashes []common.Hash, kinds []byte, sizes []uint32) error {
 	// Mark all the transactions as known, but ensure we don't overflow our limits
 	p.knownTxs.Add(hashes...)
+	if p.version >= ETH68 {
+		return p2p.Send(p.rw, NewPooledTransactionHashesMsg, &NewPooledTransactionHashesPacket68{Types: kinds, Sizes: sizes, Hashes: hashes})
+	}
 	return p2p.Send(p.rw, NewPooledTransactionHashesMsg, NewPooledTransactionHashesPacket(hashes))
 }
 
@@ -80,6 +80,13 @@ type fullNodeBackend interface {
 	SuggestGasTipCap(ctx context.Context) (*big.Int, error)
 }
 
+// txPoolBackend is implemented by backends which can break the transaction pool
+// contents down by origin, allowing the pending and queued counts to be reported
+// per tier.
+type txPoolBackend interface {
+	OriginStats() (pending int, queued int, localPending int, localQueued int)
+}
+
 // Service implements an Ethereum netstats reporting daemon that pushes local
 // chain statistics up to a monitoring server.
 type Service struct {
@@ -725,24 +732,33 @@ func (s *Service) reportHistory(conn *connWrapper, list []uint64) error {
 	return conn.WriteJSON(report)
 }
 
-// pendStats is the information to report about pending transactions.
+// pendStats is the information to report about pending transactions. The fields
+// beyond the pending count are optional, older netstats servers ignore them.
 type pendStats struct {
-	Pending int `json:"pending"`
+	Pending int           `json:"pending"`
+	Queued  int           `json:"queued"`
+	Tiers   []txTierStats `json:"tiers,omitempty"`
+}
+
+// txTierStats is the transaction pool breakdown of a single origin tier.
+type txTierStats struct {
+	Name    string `json:"name"`
+	Pending int    `json:"pending"`
+	Queued  int    `json:"queued"`
 }
 
 // reportPending retrieves the current number of pending transactions and reports
 // it to the stats server.
 func (s *Service) reportPending(conn *connWrapper) error {
 	// Retrieve the pending count from the local blockchain
-	pending, _ := s.backend.Stats()
+	pending := assemblePendStats(s.backend)
+
 	// Assemble the transaction stats and send it to the server
-	log.Trace("Sending pending transactions to ethstats", "count", pending)
+	log.Trace("Sending pending transactions to ethstats", "count", pending.Pending)
 
 	stats := map[string]interface{}{
-		"id": s.node,
-		"stats": &pendStats{
-			Pending: pending,
-		},
+		"id":    s.node,
+		"stats": pending,
 	}
 	report := map[string][]interface{}{
 		"emit": {"pending", stats},
@@ -750,15 +766,143 @@ func (s *Service) reportPending(conn *connWrapper) error {
 	return conn.WriteJSON(report)
 }
 
-// nodeStats is the information to report about the local node.
+// assemblePendStats retrieves the transaction pool counts, breaking them down
+// into local and remote tiers if the backend supports it. The tiers are taken
+// from a single snapshot of the pool so they always add up to the totals.
+func assemblePendStats(backend backend) *pendStats {
+	pool, ok := backend.(txPoolBackend)
+	if !ok {
+		pending, queued := backend.Stats()
+		return &pendStats{Pending: pending, Queued: queued}
+	}
+	pending, queued, localPending, localQueued := pool.OriginStats()
+	return &pendStats{
+		Pending: pending,
+		Queued:  queued,
+		Tiers: []txTierStats{
+			{Name: "local", Pending: localPending, Queued: localQueued},
+			{Name: "remote", Pending: pending - localPending, Queued: queued - localQueued},
+		},
+	}
+}
+
+// nodeStats is the information to report about the local node. The sync progress
+// and peer breakdown are optional, older netstats servers ignore them.
 type nodeStats struct {
-	Active   bool `json:"active"`
-	Syncing  bool `json:"syncing"`
-	Mining   bool `json:"mining"`
-	Hashrate int  `json:"hashrate"`
-	Peers    int  `json:"peers"`
-	GasPrice int  `json:"gasPrice"`
-	Uptime   int  `json:"uptime"`
+	Active   bool       `json:"active"`
+	Syncing  bool       `json:"syncing"`
+	Mining   bool       `json:"mining"`
+	Hashrate int        `json:"hashrate"`
+	Peers    int        `json:"peers"`
+	GasPrice int        `json:"gasPrice"`
+	Uptime   int        `json:"uptime"`
+	Progress *syncStats `json:"syncProgress,omitempty"`
+	PeerInfo *peerStats `json:"peerInfo,omitempty"`
+}
+
+// syncStats is the information to report about a running chain and state sync.
+type syncStats struct {
+	StartingBlock uint64 `json:"startingBlock"`
+	CurrentBlock  uint64 `json:"currentBlock"`
+	HighestBlock  uint64 `json:"highestBlock"`
+	PulledStates  uint64 `json:"pulledStates"`
+	KnownStates   uint64 `json:"knownStates"`
+
+	SyncedAccounts      uint64 `json:"syncedAccounts"`
+	SyncedAccountBytes  uint64 `json:"syncedAccountBytes"`
+	SyncedBytecodes     uint64 `json:"syncedBytecodes"`
+	SyncedBytecodeBytes uint64 `json:"syncedBytecodeBytes"`
+	SyncedStorage       uint64 `json:"syncedStorage"`
+	SyncedStorageBytes  uint64 `json:"syncedStorageBytes"`
+	HealedTrienodes     uint64 `json:"healedTrienodes"`
+	HealedTrienodeBytes uint64 `json:"healedTrienodeBytes"`
+	HealedBytecodes     uint64 `json:"healedBytecodes"`
+	HealedBytecodeBytes uint64 `json:"healedBytecodeBytes"`
+	HealingTrienodes    uint64 `json:"healingTrienodes"`
+	HealingBytecode     uint64 `json:"healingBytecode"`
+}
+
+// newSyncStats converts the backend sync progress into its reported form.
+func newSyncStats(progress ethereum.SyncProgress) *syncStats {
+	return &syncStats{
+		StartingBlock:       progress.StartingBlock,
+		CurrentBlock:        progress.CurrentBlock,
+		HighestBlock:        progress.HighestBlock,
+		PulledStates:        progress.PulledStates,
+		KnownStates:         progress.KnownStates,
+		SyncedAccounts:      progress.SyncedAccounts,
+		SyncedAccountBytes:  progress.SyncedAccountBytes,
+		SyncedBytecodes:     progress.SyncedBytecodes,
+		SyncedBytecodeBytes: progress.SyncedBytecodeBytes,
+		SyncedStorage:       progress.SyncedStorage,
+		SyncedStorageBytes:  progress.SyncedStorageBytes,
+		HealedTrienodes:     progress.HealedTrienodes,
+		HealedTrienodeBytes: progress.HealedTrienodeBytes,
+		HealedBytecodes:     progress.HealedBytecodes,
+		HealedBytecodeBytes: progress.HealedBytecodeBytes,
+		HealingTrienodes:    progress.HealingTrienodes,
+		HealingBytecode:     progress.HealingBytecode,
+	}
+}
+
+// peerStats is the distribution of the connected peers by client name and by
+// negotiated protocol version.
+type peerStats struct {
+	Clients   map[string]int `json:"clients"`
+	Protocols map[string]int `json:"protocols"`
+}
+
+// assemblePeerStats aggregates the given peers by client name (the first segment
+// of the client identifier) and by the negotiated versions of their protocols.
+func assemblePeerStats(protocols []p2p.Protocol, peers []*p2p.PeerInfo) *peerStats {
+	stats := &peerStats{
+		Clients:   make(map[string]int),
+		Protocols: make(map[string]int),
+	}
+	for _, peer := range peers {
+		client := peer.Name
+		if idx := strings.Index(client, "/"); idx >= 0 {
+			client = client[:idx]
+		}
+		if client == "" {
+			client = "unknown"
+		}
+		stats.Clients[client]++
+
+		for name := range peer.Protocols {
+			if version, ok := negotiatedVersion(protocols, name, peer.Caps); ok {
+				stats.Protocols[fmt.Sprintf("%s/%d", name, version)]++
+			} else {
+				stats.Protocols[name]++
+			}
+		}
+	}
+	return stats
+}
+
+// negotiatedVersion returns the version of a protocol running with a peer, which
+// is the highest version supported by both the local node and the peer's caps.
+func negotiatedVersion(protocols []p2p.Protocol, name string, caps []string) (uint, bool) {
+	var (
+		version uint
+		found   bool
+	)
+	for _, c := range caps {
+		idx := strings.LastIndex(c, "/")
+		if idx < 0 || c[:idx] != name {
+			continue
+		}
+		v, err := strconv.ParseUint(c[idx+1:], 10, 32)
+		if err != nil {
+			continue
+		}
+		for _, proto := range protocols {
+			if proto.Name == name && proto.Version == uint(v) && (!found || uint(v) > version) {
+				version, found = uint(v), true
+			}
+		}
+	}
+	return version, found
 }
 
 // reportStats retrieves various stats about the node at the networking and
@@ -768,7 +912,6 @@ func (s *Service) reportStats(conn *connWrapper) error {
 	var (
 		mining   bool
 		hashrate int
-		syncing  bool
 		gasprice int
 	)
 	// check if backend is a full node
@@ -777,17 +920,20 @@ func (s *Service) reportStats(conn *connWrapper) error {
 		mining = fullBackend.Miner().Mining()
 		hashrate = int(fullBackend.Miner().Hashrate())
 
-		sync := fullBackend.SyncProgress()
-		syncing = fullBackend.CurrentHeader().Number.Uint64() >= sync.HighestBlock
-
 		price, _ := fullBackend.SuggestGasTipCap(context.Background())
 		gasprice = int(price.Uint64())
 		if basefee := fullBackend.CurrentHeader().BaseFee; basefee != nil {
 			gasprice += int(basefee.Uint64())
 		}
-	} else {
-		sync := s.backend.SyncProgress()
-		syncing = s.backend.CurrentHeader().Number.Uint64() >= sync.HighestBlock
+	}
+	// Only report the sync progress while a sync is actually running
+	var (
+		sync     = s.backend.SyncProgress()
+		syncing  = s.backend.CurrentHeader().Number.Uint64() < sync.HighestBlock
+		progress *syncStats
+	)
+	if syncing {
+		progress = newSyncStats(sync)
 	}
 	// Assemble the node stats and send it to the server
 	log.Trace("Sending node details to ethstats")
@@ -802,6 +948,8 @@ func (s *Service) reportStats(conn *connWrapper) error {
 			GasPrice: gasprice,
 			Syncing:  syncing,
 			Uptime:   100,
+			Progress: progress,
+			PeerInfo: assemblePeerStats(s.server.Protocols, s.server.PeersInfo()),
 		},
 	}
 	report := map[string][]interface{}{
@@ -1,8 +1,27 @@
 package ethstats
 
 import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	ethproto "github.com/ethereum/go-ethereum/eth/protocols/eth"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gorilla/websocket"
 )
 
 func TestParseEthstatsURL(t *testing.T) {
@@ -65,3 +84,266 @@ func TestParseEthstatsURL(t *testing.T) {
 	}
 
 }
+
+func TestAssemblePeerStats(t *testing.T) {
+	protocols := []p2p.Protocol{
+		{Name: "eth", Version: 66},
+		{Name: "eth", Version: 65},
+		{Name: "snap", Version: 1},
+		{Name: "les", Version: 4},
+	}
+	peers := []*p2p.PeerInfo{
+		{Name: "Geth/v1.10.13-stable/linux-amd64/go1.17.3", Caps: []string{"eth/66", "eth/68", "snap/1"}, Protocols: map[string]interface{}{
+			"eth":  nil,
+			"snap": nil,
+		}},
+		{Name: "Geth/v1.10.8-stable/linux-amd64/go1.16.4", Caps: []string{"eth/64", "eth/65"}, Protocols: map[string]interface{}{
+			"eth": nil,
+		}},
+		{Name: "Nethermind/v1.11.7/linux-x64/dotnet5.0.12", Caps: []string{"eth/66", "les/x"}, Protocols: map[string]interface{}{
+			"eth": nil,
+			"les": "handshake",
+		}},
+		{Name: "", Protocols: map[string]interface{}{}},
+	}
+	stats := assemblePeerStats(protocols, peers)
+
+	wantClients := map[string]int{"Geth": 2, "Nethermind": 1, "unknown": 1}
+	if !mapsEqual(stats.Clients, wantClients) {
+		t.Errorf("client distribution mismatch: have %v, want %v", stats.Clients, wantClients)
+	}
+	wantProtocols := map[string]int{"eth/66": 2, "eth/65": 1, "snap/1": 1, "les": 1}
+	if !mapsEqual(stats.Protocols, wantProtocols) {
+		t.Errorf("protocol distribution mismatch: have %v, want %v", stats.Protocols, wantProtocols)
+	}
+}
+
+func mapsEqual(a, b map[string]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if b[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// testBackend is a light-client style ethstats backend with a fixed chain head,
+// transaction pool and sync progress.
+type testBackend struct {
+	head     *types.Header
+	progress ethereum.SyncProgress
+
+	headFeed event.Feed
+	txFeed   event.Feed
+}
+
+func (b *testBackend) SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription {
+	return b.headFeed.Subscribe(ch)
+}
+func (b *testBackend) SubscribeNewTxsEvent(ch chan<- core.NewTxsEvent) event.Subscription {
+	return b.txFeed.Subscribe(ch)
+}
+func (b *testBackend) CurrentHeader() *types.Header { return b.head }
+func (b *testBackend) HeaderByNumber(ctx context.Context, number rpc.BlockNumber) (*types.Header, error) {
+	return b.head, nil
+}
+func (b *testBackend) GetTd(ctx context.Context, hash common.Hash) *big.Int { return big.NewInt(1000) }
+func (b *testBackend) Stats() (pending int, queued int)                     { return 5, 3 }
+func (b *testBackend) OriginStats() (int, int, int, int)                    { return 5, 3, 2, 1 }
+func (b *testBackend) SyncProgress() ethereum.SyncProgress                  { return b.progress }
+
+// testStatsMsg is a single report received by the test netstats server.
+type testStatsMsg struct {
+	command string
+	payload json.RawMessage
+}
+
+// testStatsServer is a minimal netstats server, which authenticates nodes with a
+// shared secret, answers pings and forwards any other report to a channel.
+type testStatsServer struct {
+	*httptest.Server
+	t      *testing.T
+	secret string
+	msgs   chan testStatsMsg
+}
+
+func newTestStatsServer(t *testing.T, secret string) *testStatsServer {
+	srv := &testStatsServer{
+		t:      t,
+		secret: secret,
+		msgs:   make(chan testStatsMsg, 16),
+	}
+	srv.Server = httptest.NewServer(http.HandlerFunc(srv.serve))
+	return srv
+}
+
+// host returns the address of the server in the format expected by ethstats.
+func (srv *testStatsServer) host() string {
+	return "ws://" + strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (srv *testStatsServer) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		srv.t.Errorf("failed to upgrade connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	for {
+		var msg struct {
+			Emit []json.RawMessage `json:"emit"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		if len(msg.Emit) != 2 {
+			srv.t.Errorf("invalid message length: %d", len(msg.Emit))
+			return
+		}
+		var command string
+		if err := json.Unmarshal(msg.Emit[0], &command); err != nil {
+			srv.t.Errorf("invalid message command: %v", err)
+			return
+		}
+		switch command {
+		case "hello":
+			var auth authMsg
+			if err := json.Unmarshal(msg.Emit[1], &auth); err != nil || auth.Secret != srv.secret {
+				return
+			}
+			conn.WriteJSON(map[string][]interface{}{"emit": {"ready"}})
+		case "node-ping":
+			conn.WriteJSON(map[string][]interface{}{"emit": {"node-pong", map[string]string{}}})
+		default:
+			srv.msgs <- testStatsMsg{command: command, payload: msg.Emit[1]}
+		}
+	}
+}
+
+// next waits for the next report with the given command, skipping others.
+func (srv *testStatsServer) next(command string, result interface{}) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-srv.msgs:
+			if msg.command != command {
+				continue
+			}
+			if err := json.Unmarshal(msg.payload, result); err != nil {
+				srv.t.Fatalf("failed to decode %s report: %v", command, err)
+			}
+			return
+		case <-timeout:
+			srv.t.Fatalf("timeout waiting for %s report", command)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	// Start a p2p server without any connectivity, just to report node infos
+	key, _ := crypto.GenerateKey()
+	server := &p2p.Server{Config: p2p.Config{
+		PrivateKey:  key,
+		MaxPeers:    1,
+		NoDiscovery: true,
+		NoDial:      true,
+		Protocols: []p2p.Protocol{{
+			Name:     "eth",
+			Version:  66,
+			Length:   17,
+			Run:      func(*p2p.Peer, p2p.MsgReadWriter) error { return nil },
+			NodeInfo: func() interface{} { return &ethproto.NodeInfo{Network: 1} },
+		}},
+	}}
+	if err := server.Start(); err != nil {
+		t.Fatalf("failed to start p2p server: %v", err)
+	}
+	defer server.Stop()
+
+	stats := newTestStatsServer(t, "secret")
+	defer stats.Close()
+
+	backend := &testBackend{
+		head: &types.Header{Number: big.NewInt(10), Difficulty: big.NewInt(1)},
+		progress: ethereum.SyncProgress{
+			HighestBlock:     20,
+			CurrentBlock:     10,
+			SyncedAccounts:   100,
+			HealedTrienodes:  50,
+			HealingTrienodes: 7,
+		},
+	}
+	service := &Service{
+		backend: backend,
+		engine:  ethash.NewFaker(),
+		server:  server,
+		node:    "test",
+		pass:    "secret",
+		host:    stats.host(),
+		pongCh:  make(chan struct{}),
+		histCh:  make(chan []uint64, 1),
+	}
+	c, _, err := websocket.DefaultDialer.Dial(stats.host()+"/api", nil)
+	if err != nil {
+		t.Fatalf("failed to dial stats server: %v", err)
+	}
+	conn := newConnectionWrapper(c)
+	defer conn.Close()
+
+	if err := service.login(conn); err != nil {
+		t.Fatalf("failed to login: %v", err)
+	}
+	go service.readLoop(conn)
+	if err := service.report(conn); err != nil {
+		t.Fatalf("failed to report: %v", err)
+	}
+	// Check the transaction pool breakdown
+	var pending struct {
+		Stats pendStats `json:"stats"`
+	}
+	stats.next("pending", &pending)
+	if pending.Stats.Pending != 5 || pending.Stats.Queued != 3 {
+		t.Errorf("pool stats mismatch: have %d/%d, want 5/3", pending.Stats.Pending, pending.Stats.Queued)
+	}
+	wantTiers := []txTierStats{{"local", 2, 1}, {"remote", 3, 2}}
+	if len(pending.Stats.Tiers) != len(wantTiers) {
+		t.Fatalf("tier count mismatch: have %d, want %d", len(pending.Stats.Tiers), len(wantTiers))
+	}
+	for i, tier := range pending.Stats.Tiers {
+		if tier != wantTiers[i] {
+			t.Errorf("tier %d mismatch: have %+v, want %+v", i, tier, wantTiers[i])
+		}
+	}
+	// Check the sync progress and peer breakdown of the node stats
+	var node struct {
+		Stats nodeStats `json:"stats"`
+	}
+	stats.next("stats", &node)
+	if !node.Stats.Syncing {
+		t.Errorf("node not reported as syncing")
+	}
+	if node.Stats.Progress == nil {
+		t.Fatalf("sync progress missing")
+	}
+	if p := node.Stats.Progress; p.HighestBlock != 20 || p.SyncedAccounts != 100 || p.HealedTrienodes != 50 || p.HealingTrienodes != 7 {
+		t.Errorf("sync progress mismatch: %+v", p)
+	}
+	if node.Stats.PeerInfo == nil || len(node.Stats.PeerInfo.Clients) != 0 {
+		t.Errorf("peer breakdown mismatch: %+v", node.Stats.PeerInfo)
+	}
+	// Once synced, the progress should not be reported any more
+	backend.progress = ethereum.SyncProgress{HighestBlock: 10, CurrentBlock: 10}
+	if err := service.reportStats(conn); err != nil {
+		t.Fatalf("failed to report stats: %v", err)
+	}
+	node.Stats = nodeStats{}
+	stats.next("stats", &node)
+	if node.Stats.Syncing || node.Stats.Progress != nil {
+		t.Errorf("synced node reported as syncing: %+v", node.Stats)
+	}
+}
@@ -0,0 +1,330 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
+)
+
+const (
+	queueSize     = 4096            // Maximum number of finished spans waiting for export
+	maxBatchSize  = 512             // Maximum number of spans sent in a single request
+	flushInterval = 5 * time.Second // Maximum time a finished span waits for export
+	exportTimeout = 10 * time.Second
+
+	// instrumentationScope is the name reported as the source of the spans.
+	instrumentationScope = "github.com/ethereum/go-ethereum"
+)
+
+// OTLP span kind and status codes.
+const (
+	spanKindInternal = 1
+	statusCodeOk     = 1
+	statusCodeError  = 2
+)
+
+var droppedSpansMeter = metrics.NewRegisteredMeter("tracing/dropped", nil)
+
+var (
+	exporter     *Exporter // Currently installed exporter, nil if tracing is disabled
+	exporterLock sync.RWMutex
+)
+
+// Exporter batches finished spans and ships them to an OpenTelemetry collector
+// using the OTLP/HTTP protocol with JSON encoding.
+type Exporter struct {
+	endpoint string
+	service  string
+	client   *http.Client
+
+	queue chan *Span
+	quit  chan chan struct{}
+}
+
+// Setup installs a new exporter sending spans to the given OTLP/HTTP endpoint
+// (e.g. http://localhost:4318/v1/traces) and enables span recording. The given
+// service name is reported as the origin of the spans.
+func Setup(endpoint, service string) *Exporter {
+	e := &Exporter{
+		endpoint: endpoint,
+		service:  service,
+		client:   &http.Client{Timeout: exportTimeout},
+		queue:    make(chan *Span, queueSize),
+		quit:     make(chan chan struct{}),
+	}
+	go e.loop()
+
+	exporterLock.Lock()
+	exporter = e
+	atomic.StoreInt32(&enabled, 1)
+	exporterLock.Unlock()
+
+	return e
+}
+
+// Start implements node.Lifecycle. The exporter is already running after Setup,
+// so this is a no-op.
+func (e *Exporter) Start() error {
+	return nil
+}
+
+// Stop disables span recording, exports all pending spans and terminates the
+// exporter. It implements node.Lifecycle, so the exporter can be shut down with
+// the node.
+func (e *Exporter) Stop() error {
+	exporterLock.Lock()
+	if exporter == e {
+		exporter = nil
+		atomic.StoreInt32(&enabled, 0)
+	}
+	exporterLock.Unlock()
+
+	done := make(chan struct{})
+	e.quit <- done
+	<-done
+	return nil
+}
+
+// export queues a finished span for export, dropping it if the queue is full.
+func export(s *Span) {
+	exporterLock.RLock()
+	defer exporterLock.RUnlock()
+
+	if exporter == nil {
+		return
+	}
+	select {
+	case exporter.queue <- s:
+	default:
+		droppedSpansMeter.Mark(1)
+	}
+}
+
+// loop gathers finished spans into batches and exports them either when the
+// batch is full or when the flush interval elapses.
+func (e *Exporter) loop() {
+	var (
+		batch = make([]*Span, 0, maxBatchSize)
+		timer = time.NewTicker(flushInterval)
+	)
+	defer timer.Stop()
+
+	for {
+		select {
+		case s := <-e.queue:
+			if batch = append(batch, s); len(batch) >= maxBatchSize {
+				e.send(batch)
+				batch = batch[:0]
+			}
+		case <-timer.C:
+			if len(batch) > 0 {
+				e.send(batch)
+				batch = batch[:0]
+			}
+		case done := <-e.quit:
+			for len(e.queue) > 0 {
+				batch = append(batch, <-e.queue)
+			}
+			for len(batch) > 0 {
+				n := len(batch)
+				if n > maxBatchSize {
+					n = maxBatchSize
+				}
+				e.send(batch[:n])
+				batch = batch[n:]
+			}
+			close(done)
+			return
+		}
+	}
+}
+
+// send exports a batch of spans to the collector.
+func (e *Exporter) send(batch []*Span) {
+	blob, err := json.Marshal(e.encode(batch))
+	if err != nil {
+		log.Warn("Failed to encode trace spans", "err", err)
+		return
+	}
+	res, err := e.client.Post(e.endpoint, "application/json", bytes.NewReader(blob))
+	if err != nil {
+		log.Warn("Failed to export trace spans", "endpoint", e.endpoint, "spans", len(batch), "err", err)
+		return
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		log.Warn("Trace collector rejected spans", "endpoint", e.endpoint, "spans", len(batch), "status", res.Status)
+	}
+}
+
+// OTLP/JSON wire types, see opentelemetry-proto's trace_service.proto. Note,
+// the JSON mapping encodes trace and span IDs as hex and 64 bit integers as
+// strings.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              int            `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            otlpStatus     `json:"status"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string    `json:"key"`
+		Value otlpValue `json:"value"`
+	}
+	otlpValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
+
+// encode converts a batch of spans into an OTLP export request.
+func (e *Exporter) encode(batch []*Span) *otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		s.lock.Lock()
+		span := otlpSpan{
+			TraceID:           s.ctx.TraceID.String(),
+			SpanID:            s.ctx.SpanID.String(),
+			Name:              s.name,
+			Kind:              spanKindInternal,
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(s.attrs),
+			Status:            otlpStatus{Code: statusCodeOk},
+		}
+		if s.parent.IsValid() {
+			span.ParentSpanID = s.parent.String()
+		}
+		if s.err != nil {
+			span.Status = otlpStatus{Code: statusCodeError, Message: s.err.Error()}
+		}
+		s.lock.Unlock()
+
+		spans = append(spans, span)
+	}
+	return &otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: encodeAttributes([]interface{}{"service.name", e.service}),
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: instrumentationScope},
+				Spans: spans,
+			}},
+		}},
+	}
+}
+
+// encodeAttributes converts a list of key/value pairs into OTLP attributes. A
+// trailing key without a value is dropped.
+func encodeAttributes(attrs []interface{}) []otlpKeyValue {
+	kvs := make([]otlpKeyValue, 0, len(attrs)/2)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		kvs = append(kvs, otlpKeyValue{
+			Key:   fmt.Sprint(attrs[i]),
+			Value: encodeValue(attrs[i+1]),
+		})
+	}
+	return kvs
+}
+
+// encodeValue converts a single attribute value into its OTLP representation,
+// falling back to the string formatting for non-primitive types.
+func encodeValue(v interface{}) otlpValue {
+	var (
+		str  string
+		intv int64
+	)
+	switch v := v.(type) {
+	case bool:
+		return otlpValue{BoolValue: &v}
+	case float32:
+		f := float64(v)
+		return otlpValue{DoubleValue: &f}
+	case float64:
+		return otlpValue{DoubleValue: &v}
+	case int:
+		intv = int64(v)
+	case int32:
+		intv = int64(v)
+	case int64:
+		intv = v
+	case uint32:
+		intv = int64(v)
+	case uint64:
+		if v > math.MaxInt64 {
+			str = strconv.FormatUint(v, 10)
+			return otlpValue{StringValue: &str}
+		}
+		intv = int64(v)
+	case time.Duration:
+		intv = int64(v)
+	case string:
+		return otlpValue{StringValue: &v}
+	case fmt.Stringer:
+		str = v.String()
+		return otlpValue{StringValue: &str}
+	default:
+		str = fmt.Sprint(v)
+		return otlpValue{StringValue: &str}
+	}
+	str = strconv.FormatInt(intv, 10)
+	return otlpValue{IntValue: &str}
+}
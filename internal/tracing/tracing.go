@@ -0,0 +1,246 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package tracing implements lightweight, OpenTelemetry compatible span
+// instrumentation.
+//
+// Spans are only recorded if an exporter was installed via Setup, otherwise all
+// operations are no-ops on nil spans, making the instrumentation cheap enough
+// to leave in hot code paths.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// enabled is set to 1 while an exporter is installed. It is accessed atomically.
+var enabled int32
+
+// Enabled is checked by the instrumented code to decide whether spans need to
+// be recorded at all. It reports true while an exporter installed by Setup is
+// running.
+func Enabled() bool {
+	return atomic.LoadInt32(&enabled) == 1
+}
+
+// DatabaseThreshold is the minimum duration of a database read to be reported
+// as a span. Zero disables database tracing altogether.
+var DatabaseThreshold time.Duration
+
+// TraceID is the unique identifier of a trace, shared by all its spans.
+type TraceID [16]byte
+
+// String returns the hex encoding of the trace ID.
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the trace ID is non-zero.
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+// SpanID is the unique identifier of a span within a trace.
+type SpanID [8]byte
+
+// String returns the hex encoding of the span ID.
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+// IsValid reports whether the span ID is non-zero.
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext is the identifying part of a span which is propagated across
+// API and process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Flags   byte
+}
+
+// IsValid reports whether both the trace and span IDs are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Span is a single timed operation within a trace.
+type Span struct {
+	name   string
+	ctx    SpanContext
+	parent SpanID
+	start  time.Time
+	end    time.Time
+	attrs  []interface{} // Key/value pairs, in the style of the log package
+	err    error
+
+	lock sync.Mutex
+}
+
+// spanKey is the context key under which the active span context is stored.
+type spanKey struct{}
+
+// Start creates a new span as the child of the span in ctx (if any), and returns
+// a derived context carrying the new span. The optional key/value pairs are
+// attached to the span as attributes.
+//
+// If tracing is disabled, the original context and a nil span are returned. All
+// span methods are safe to call on a nil span.
+func Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, *Span) {
+	return StartAt(ctx, name, time.Now(), attrs...)
+}
+
+// StartAt is like Start, but backdates the start of the span to the given time.
+// It is useful for recording operations only deemed interesting after the fact.
+func StartAt(ctx context.Context, name string, start time.Time, attrs ...interface{}) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	span := &Span{
+		name:  name,
+		start: start,
+		attrs: attrs,
+	}
+	if parent, ok := ctx.Value(spanKey{}).(SpanContext); ok && parent.IsValid() {
+		span.ctx.TraceID = parent.TraceID
+		span.ctx.Flags = parent.Flags
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.ctx.TraceID[:])
+		span.ctx.Flags = 0x01 // sampled
+	}
+	rand.Read(span.ctx.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span.ctx), span
+}
+
+// SpanContextFromContext returns the span context stored in ctx, or an invalid
+// one if there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithSpanContext returns a derived context carrying a remote span
+// context, which spans started from it will use as their parent.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanKey{}, sc)
+}
+
+// Context returns the identifiers of the span.
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.ctx
+}
+
+// SetAttributes attaches additional key/value pairs to the span.
+func (s *Span) SetAttributes(attrs ...interface{}) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// SetError marks the span as failed. A nil error is ignored.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.err = err
+}
+
+// End finishes the span and hands it over to the exporter. Calling End more
+// than once has no effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	if !s.end.IsZero() {
+		s.lock.Unlock()
+		return
+	}
+	s.end = time.Now()
+	s.lock.Unlock()
+
+	export(s)
+}
+
+// traceparentHeader is the W3C Trace Context header carrying the parent span.
+const traceparentHeader = "traceparent"
+
+// Extract parses the W3C Trace Context headers of an incoming request and, if
+// a valid parent span is found, returns a derived context carrying it.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	if !Enabled() {
+		return ctx
+	}
+	sc, err := parseTraceparent(header.Get(traceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithSpanContext(ctx, sc)
+}
+
+// Inject writes the span context carried by ctx into the W3C Trace Context
+// headers of an outgoing request.
+func Inject(ctx context.Context, header http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		header.Set(traceparentHeader, fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, sc.Flags))
+	}
+}
+
+// parseTraceparent decodes a version 00 traceparent header value.
+func parseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if parts[0] == "ff" || len(parts[0]) != 2 || (parts[0] == "00" && len(parts) != 4) {
+		return sc, fmt.Errorf("unsupported traceparent version %q", parts[0])
+	}
+	if len(parts[1]) != 2*len(sc.TraceID) || len(parts[2]) != 2*len(sc.SpanID) || len(parts[3]) != 2 {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, err
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, err
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return sc, err
+	}
+	sc.Flags = flags[0]
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return sc, nil
+}
@@ -0,0 +1,143 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestDisabled(t *testing.T) {
+	ctx, span := Start(context.Background(), "test")
+	if span != nil {
+		t.Fatal("span created with tracing disabled")
+	}
+	// Nil spans must be usable
+	span.SetAttributes("key", "value")
+	span.SetError(errors.New("failure"))
+	span.End()
+
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		t.Fatalf("disabled span leaked into context: %+v", sc)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	tests := []struct {
+		value string
+		valid bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01", false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for i, tt := range tests {
+		sc, err := parseTraceparent(tt.value)
+		if tt.valid != (err == nil) {
+			t.Errorf("test %d: validity mismatch: have %v, want %v (err %v)", i, err == nil, tt.valid, err)
+			continue
+		}
+		if !tt.valid {
+			continue
+		}
+		header := make(http.Header)
+		Inject(ContextWithSpanContext(context.Background(), sc), header)
+		if have, want := header.Get(traceparentHeader), "00"+tt.value[2:55]; have != want {
+			t.Errorf("test %d: injected header mismatch: have %s, want %s", i, have, want)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	var (
+		lock     sync.Mutex
+		requests []otlpRequest
+	)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode export request: %v", err)
+		}
+		lock.Lock()
+		requests = append(requests, req)
+		lock.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := Setup(collector.URL, "geth-test")
+
+	// Create a span with a remote parent and a local child
+	header := make(http.Header)
+	header.Set(traceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := Extract(context.Background(), header)
+
+	ctx, parent := Start(ctx, "parent", "number", uint64(1), "ok", true)
+	_, child := Start(ctx, "child")
+	child.SetError(errors.New("failure"))
+	child.End()
+	parent.End()
+
+	exporter.Stop()
+	if Enabled() {
+		t.Fatal("tracing still enabled after stopping the exporter")
+	}
+	if len(requests) != 1 {
+		t.Fatalf("export request count mismatch: have %d, want 1", len(requests))
+	}
+	req := requests[0]
+	if len(req.ResourceSpans) != 1 || len(req.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("unexpected request layout: %+v", req)
+	}
+	if attrs := req.ResourceSpans[0].Resource.Attributes; len(attrs) != 1 || *attrs[0].Value.StringValue != "geth-test" {
+		t.Errorf("service name mismatch: %+v", attrs)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("span count mismatch: have %d, want 2", len(spans))
+	}
+	c, p := spans[0], spans[1]
+	if p.Name != "parent" || c.Name != "child" {
+		t.Fatalf("span order mismatch: have %s, %s", c.Name, p.Name)
+	}
+	if p.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || c.TraceID != p.TraceID {
+		t.Errorf("trace ID mismatch: parent %s, child %s", p.TraceID, c.TraceID)
+	}
+	if p.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("remote parent mismatch: have %s", p.ParentSpanID)
+	}
+	if c.ParentSpanID != p.SpanID {
+		t.Errorf("local parent mismatch: have %s, want %s", c.ParentSpanID, p.SpanID)
+	}
+	if len(p.Attributes) != 2 || *p.Attributes[0].Value.IntValue != "1" || !*p.Attributes[1].Value.BoolValue {
+		t.Errorf("attribute mismatch: %+v", p.Attributes)
+	}
+	if p.Status.Code != statusCodeOk || c.Status.Code != statusCodeError || c.Status.Message != "failure" {
+		t.Errorf("status mismatch: parent %+v, child %+v", p.Status, c.Status)
+	}
+}
@@ -141,6 +141,27 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			params: 2,
+			inputFormatter: [null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'denyNetwork',
+			call: 'admin_denyNetwork',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'allowNetwork',
+			call: 'admin_allowNetwork',
+			params: 1
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
@@ -203,6 +224,10 @@ web3._extend({
 			name: 'datadir',
 			getter: 'admin_datadir'
 		}),
+		new web3._extend.Property({
+			name: 'deniedNetworks',
+			getter: 'admin_deniedNetworks'
+		}),
 	]
 });
 `
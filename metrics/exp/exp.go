@@ -163,7 +163,7 @@ func (exp *exp) publishResettingTimer(name string, metric metrics.ResettingTimer
 }
 
 func (exp *exp) syncToExpvar() {
-	exp.registry.Each(func(name string, i interface{}) {
+	metrics.Flatten(exp.registry, func(name string, i interface{}) {
 		switch i := i.(type) {
 		case metrics.Counter:
 			exp.publishCounter(name, i)
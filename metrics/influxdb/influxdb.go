@@ -123,7 +123,7 @@ func (r *reporter) run() {
 func (r *reporter) send() error {
 	var pts []client.Point
 
-	r.reg.Each(func(name string, i interface{}) {
+	metrics.Flatten(r.reg, func(name string, i interface{}) {
 		now := time.Now()
 		namespace := r.namespace
 
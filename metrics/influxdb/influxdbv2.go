@@ -85,7 +85,7 @@ func (r *v2Reporter) run() {
 }
 
 func (r *v2Reporter) send() {
-	r.reg.Each(func(name string, i interface{}) {
+	metrics.Flatten(r.reg, func(name string, i interface{}) {
 		now := time.Now()
 		namespace := r.namespace
 
@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labelled is a family of metrics of the same kind sharing a name and a set of
+// label names. Every distinct combination of label values is tracked as its own
+// child metric, created on first use.
+//
+// Exporters with native label support (e.g. Prometheus) report the family as a
+// single metric with multiple labelled series, whereas all the others see the
+// children as standalone metrics via Flatten.
+type Labelled struct {
+	labels []string           // Names of the labels, in the order of the values
+	ctor   func() interface{} // Constructor for the individual child metrics
+	flat   string             // Template naming the flattened child metrics, if not the default
+
+	series map[string]*labelledSeries // Child metrics keyed by joined label values
+	lock   sync.RWMutex
+}
+
+// labelledSeries is a single child metric of a labelled family.
+type labelledSeries struct {
+	values []string
+	metric interface{}
+}
+
+// NewLabelled constructs a new labelled metric family with the given label
+// names, using ctor to create the child metric of each new label combination.
+func NewLabelled(ctor func() interface{}, labels ...string) *Labelled {
+	return &Labelled{
+		labels: labels,
+		ctor:   ctor,
+		series: make(map[string]*labelledSeries),
+	}
+}
+
+// GetOrRegisterLabelled returns an existing labelled metric family or constructs
+// and registers a new one.
+func GetOrRegisterLabelled(name string, r Registry, ctor func() interface{}, labels ...string) *Labelled {
+	if nil == r {
+		r = DefaultRegistry
+	}
+	return r.GetOrRegister(name, func() *Labelled { return NewLabelled(ctor, labels...) }).(*Labelled)
+}
+
+// NewRegisteredLabelled constructs and registers a new labelled metric family.
+func NewRegisteredLabelled(name string, r Registry, ctor func() interface{}, labels ...string) *Labelled {
+	l := NewLabelled(ctor, labels...)
+	if nil == r {
+		r = DefaultRegistry
+	}
+	r.Register(name, l)
+	return l
+}
+
+// Labels returns the names of the labels the family is partitioned by.
+func (l *Labelled) Labels() []string {
+	return l.labels
+}
+
+// SetFlatName overrides how Flatten names the child metrics of the family. The
+// template may reference the label values as {label}, e.g. the template
+// "p2p/ingress/{protocol}/{version}/{code}/packets". It is meant to keep the names
+// of metrics that were tracked individually before being grouped into a family.
+func (l *Labelled) SetFlatName(template string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.flat = template
+}
+
+// flatName returns the name of a child metric as seen by Flatten.
+func (l *Labelled) flatName(name string, values []string) string {
+	l.lock.RLock()
+	template := l.flat
+	l.lock.RUnlock()
+
+	if template == "" {
+		return name + "/" + strings.Join(values, "/")
+	}
+	replacements := make([]string, 0, 2*len(values))
+	for i, label := range l.labels {
+		replacements = append(replacements, "{"+label+"}", values[i])
+	}
+	return strings.NewReplacer(replacements...).Replace(template)
+}
+
+// With returns the child metric belonging to the given label values, creating
+// it if it doesn't exist yet. The number of values must match the number of
+// labels of the family.
+//
+// If the metrics system is disabled, a fresh (nil) metric is returned without
+// being tracked.
+func (l *Labelled) With(values ...string) interface{} {
+	if len(values) != len(l.labels) {
+		panic(fmt.Sprintf("label count mismatch: have %d, want %d", len(values), len(l.labels)))
+	}
+	if !Enabled {
+		return l.ctor()
+	}
+	key := strings.Join(values, "\x00")
+
+	l.lock.RLock()
+	s, ok := l.series[key]
+	l.lock.RUnlock()
+	if ok {
+		return s.metric
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if s, ok := l.series[key]; ok {
+		return s.metric
+	}
+	s = &labelledSeries{
+		values: append([]string(nil), values...),
+		metric: l.ctor(),
+	}
+	l.series[key] = s
+	return s.metric
+}
+
+// Each calls f for every child metric of the family, ordered by label values.
+func (l *Labelled) Each(f func(values []string, metric interface{})) {
+	l.lock.RLock()
+	keys := make([]string, 0, len(l.series))
+	for key := range l.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	series := make([]*labelledSeries, len(keys))
+	for i, key := range keys {
+		series[i] = l.series[key]
+	}
+	l.lock.RUnlock()
+
+	for _, s := range series {
+		f(s.values, s.metric)
+	}
+}
+
+// Stop stops all the child metrics which need stopping, to allow for garbage
+// collection.
+func (l *Labelled) Stop() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for key, s := range l.series {
+		if m, ok := s.metric.(Stoppable); ok {
+			m.Stop()
+		}
+		delete(l.series, key)
+	}
+}
+
+// Flatten calls f for every metric in the registry, expanding labelled families
+// into their child metrics. Children are named by appending their label values
+// to the name of the family, e.g. "rpc/duration/eth_call/success", unless the
+// family has its own naming template. It is meant for exporters without native
+// label support.
+func Flatten(r Registry, f func(string, interface{})) {
+	r.Each(func(name string, i interface{}) {
+		if l, ok := i.(*Labelled); ok {
+			l.Each(func(values []string, metric interface{}) {
+				f(l.flatName(name, values), metric)
+			})
+			return
+		}
+		f(name, i)
+	})
+}
+
+// LabelledCounter is a labelled family of Counters.
+type LabelledCounter struct{ *Labelled }
+
+// NewRegisteredLabelledCounter constructs and registers a new labelled family
+// of StandardCounters.
+func NewRegisteredLabelledCounter(name string, r Registry, labels ...string) LabelledCounter {
+	return LabelledCounter{NewRegisteredLabelled(name, r, func() interface{} { return NewCounter() }, labels...)}
+}
+
+// With returns the Counter belonging to the given label values.
+func (l LabelledCounter) With(values ...string) Counter {
+	return l.Labelled.With(values...).(Counter)
+}
+
+// LabelledGauge is a labelled family of Gauges.
+type LabelledGauge struct{ *Labelled }
+
+// NewRegisteredLabelledGauge constructs and registers a new labelled family of
+// StandardGauges.
+func NewRegisteredLabelledGauge(name string, r Registry, labels ...string) LabelledGauge {
+	return LabelledGauge{NewRegisteredLabelled(name, r, func() interface{} { return NewGauge() }, labels...)}
+}
+
+// With returns the Gauge belonging to the given label values.
+func (l LabelledGauge) With(values ...string) Gauge {
+	return l.Labelled.With(values...).(Gauge)
+}
+
+// LabelledMeter is a labelled family of Meters.
+type LabelledMeter struct{ *Labelled }
+
+// NewRegisteredLabelledMeter constructs and registers a new labelled family of
+// StandardMeters.
+func NewRegisteredLabelledMeter(name string, r Registry, labels ...string) LabelledMeter {
+	return LabelledMeter{NewRegisteredLabelled(name, r, func() interface{} { return NewMeter() }, labels...)}
+}
+
+// With returns the Meter belonging to the given label values.
+func (l LabelledMeter) With(values ...string) Meter {
+	return l.Labelled.With(values...).(Meter)
+}
+
+// LabelledHistogram is a labelled family of Histograms.
+type LabelledHistogram struct{ *Labelled }
+
+// NewRegisteredLabelledHistogram constructs and registers a new labelled family
+// of StandardHistograms, each child backed by a new Sample from s.
+func NewRegisteredLabelledHistogram(name string, r Registry, s func() Sample, labels ...string) LabelledHistogram {
+	return LabelledHistogram{NewRegisteredLabelled(name, r, func() interface{} { return NewHistogram(s()) }, labels...)}
+}
+
+// With returns the Histogram belonging to the given label values.
+func (l LabelledHistogram) With(values ...string) Histogram {
+	return l.Labelled.With(values...).(Histogram)
+}
+
+// LabelledTimer is a labelled family of Timers.
+type LabelledTimer struct{ *Labelled }
+
+// NewRegisteredLabelledTimer constructs and registers a new labelled family of
+// StandardTimers.
+func NewRegisteredLabelledTimer(name string, r Registry, labels ...string) LabelledTimer {
+	return LabelledTimer{NewRegisteredLabelled(name, r, func() interface{} { return NewTimer() }, labels...)}
+}
+
+// With returns the Timer belonging to the given label values.
+func (l LabelledTimer) With(values ...string) Timer {
+	return l.Labelled.With(values...).(Timer)
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLabelled(t *testing.T) {
+	r := NewRegistry()
+	l := NewRegisteredLabelledCounter("foo", r, "proto", "code")
+
+	l.With("eth", "0x01").Inc(1)
+	l.With("eth", "0x00").Inc(2)
+	l.With("eth", "0x01").Inc(3)
+
+	if c := l.With("eth", "0x01").Count(); c != 4 {
+		t.Fatalf("counter mismatch: have %d, want %d", c, 4)
+	}
+	var (
+		values [][]string
+		counts []int64
+	)
+	l.Each(func(vals []string, i interface{}) {
+		values = append(values, vals)
+		counts = append(counts, i.(Counter).Count())
+	})
+	if len(values) != 2 {
+		t.Fatalf("series count mismatch: have %d, want %d", len(values), 2)
+	}
+	if values[0][1] != "0x00" || counts[0] != 2 || values[1][1] != "0x01" || counts[1] != 4 {
+		t.Fatalf("series mismatch: have %v, %v", values, counts)
+	}
+	if i := r.Get("foo"); i != l.Labelled {
+		t.Fatalf("registered family mismatch: have %v", i)
+	}
+}
+
+func TestLabelledMismatch(t *testing.T) {
+	l := NewLabelled(func() interface{} { return NewCounter() }, "proto")
+	defer func() {
+		if recover() == nil {
+			t.Fatal("label count mismatch not detected")
+		}
+	}()
+	l.With("eth", "0x01")
+}
+
+func TestFlatten(t *testing.T) {
+	r := NewRegistry()
+	r.Register("bar", NewCounter())
+	l := NewRegisteredLabelledTimer("foo", r, "method", "status")
+	defer l.Stop()
+	l.With("eth_call", "success")
+	l.With("eth_call", "failure")
+
+	names := make(map[string]interface{})
+	Flatten(r, func(name string, i interface{}) {
+		names[name] = i
+	})
+	if len(names) != 3 {
+		t.Fatalf("flattened metric count mismatch: have %d, want %d", len(names), 3)
+	}
+	for _, name := range []string{"bar", "foo/eth_call/success", "foo/eth_call/failure"} {
+		if _, ok := names[name]; !ok {
+			t.Errorf("flattened metric %q missing", name)
+		}
+	}
+	if _, ok := names["foo/eth_call/success"].(Timer); !ok {
+		t.Errorf("flattened metric type mismatch: have %T", names["foo/eth_call/success"])
+	}
+}
+
+func TestFlattenTemplate(t *testing.T) {
+	r := NewRegistry()
+	r.Register("p2p/ingress", NewMeter())
+	l := NewRegisteredLabelledMeter("p2p/ingress/packets", r, "protocol", "version", "code")
+	defer l.Stop()
+	l.SetFlatName("p2p/ingress/{protocol}/{version}/{code}/packets")
+	l.With("eth", "66", "0x01")
+
+	var names []string
+	Flatten(r, func(name string, i interface{}) {
+		names = append(names, name)
+	})
+	sort.Strings(names)
+	want := []string{"p2p/ingress", "p2p/ingress/eth/66/0x01/packets"}
+	if len(names) != len(want) {
+		t.Fatalf("flattened metric count mismatch: have %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("flattened metric %d mismatch: have %q, want %q", i, names[i], want[i])
+		}
+	}
+}
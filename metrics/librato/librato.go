@@ -89,7 +89,7 @@ func (rep *Reporter) BuildRequest(now time.Time, r metrics.Registry) (snapshot B
 	snapshot.Gauges = make([]Measurement, 0)
 	snapshot.Counters = make([]Measurement, 0)
 	histogramGaugeCount := 1 + len(rep.Percentiles)
-	r.Each(func(name string, metric interface{}) {
+	metrics.Flatten(r, func(name string, metric interface{}) {
 		if rep.Namespace != "" {
 			name = fmt.Sprintf("%s.%s", rep.Namespace, name)
 		}
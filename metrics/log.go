@@ -19,7 +19,7 @@ func LogScaled(r Registry, freq time.Duration, scale time.Duration, l Logger) {
 	duSuffix := scale.String()[1:]
 
 	for range time.Tick(freq) {
-		r.Each(func(name string, i interface{}) {
+		Flatten(r, func(name string, i interface{}) {
 			switch metric := i.(type) {
 			case Counter:
 				l.Printf("counter %s\n", name)
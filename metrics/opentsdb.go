@@ -67,7 +67,7 @@ func openTSDB(c *OpenTSDBConfig) error {
 	}
 	defer conn.Close()
 	w := bufio.NewWriter(conn)
-	c.Registry.Each(func(name string, i interface{}) {
+	Flatten(c.Registry, func(name string, i interface{}) {
 		switch metric := i.(type) {
 		case Counter:
 			fmt.Fprintf(w, "put %s.%s.count %d %d host=%s\n", c.Prefix, name, now, metric.Count(), shortHostname)
@@ -19,18 +19,28 @@ package prometheus
 import (
 	"bytes"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 
+	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
 var (
-	typeGaugeTpl           = "# TYPE %s gauge\n"
-	typeCounterTpl         = "# TYPE %s counter\n"
-	typeSummaryTpl         = "# TYPE %s summary\n"
-	keyValueTpl            = "%s %v\n\n"
-	keyQuantileTagValueTpl = "%s {quantile=\"%s\"} %v\n"
+	typeGaugeTpl   = "# TYPE %s gauge\n"
+	typeCounterTpl = "# TYPE %s counter\n"
+	typeSummaryTpl = "# TYPE %s summary\n"
+	keyValueTpl    = "%s%s %v\n"
+
+	// labelValueEscaper escapes the characters not allowed in label values.
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+	// quantiles are the percentiles reported for histograms and timers.
+	quantiles = []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
+
+	// resettingQuantiles are the percentiles reported for resetting timers.
+	resettingQuantiles = []float64{50, 95, 99}
 )
 
 // collector is a collection of byte buffers that aggregate Prometheus reports
@@ -46,73 +56,164 @@ func newCollector() *collector {
 	}
 }
 
-func (c *collector) addCounter(name string, m metrics.Counter) {
-	c.writeGaugeCounter(name, m.Count())
+// add aggregates a single registry entry into the report. Plain metrics are
+// exported as a single series, labelled families as one series per child.
+func (c *collector) add(name string, i interface{}) {
+	if l, ok := i.(*metrics.Labelled); ok {
+		c.addLabelled(name, l)
+		return
+	}
+	tpl := typeTemplate(i, false)
+	if tpl == "" {
+		log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
+		return
+	}
+	name = mutateKey(name)
+	c.buff.WriteString(fmt.Sprintf(tpl, name))
+	c.addSeries(name, nil, nil, i)
+	c.buff.WriteRune('\n')
 }
 
-func (c *collector) addGauge(name string, m metrics.Gauge) {
-	c.writeGaugeCounter(name, m.Value())
-}
+// addLabelled aggregates all the child metrics of a labelled family under a
+// single metric name, distinguished by their label values.
+func (c *collector) addLabelled(name string, l *metrics.Labelled) {
+	name = mutateKey(name)
 
-func (c *collector) addGaugeFloat64(name string, m metrics.GaugeFloat64) {
-	c.writeGaugeCounter(name, m.Value())
+	var header bool
+	l.Each(func(values []string, i interface{}) {
+		if !header {
+			tpl := typeTemplate(i, true)
+			if tpl == "" {
+				log.Warn("Unknown Prometheus metric type", "type", fmt.Sprintf("%T", i))
+				return
+			}
+			c.buff.WriteString(fmt.Sprintf(tpl, name))
+			header = true
+		}
+		c.addSeries(name, l.Labels(), values, i)
+	})
+	if header {
+		c.buff.WriteRune('\n')
+	}
 }
 
-func (c *collector) addHistogram(name string, m metrics.Histogram) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
+// addSeries writes the samples of a single metric, tagged with the given labels.
+func (c *collector) addSeries(name string, labels, values []string, i interface{}) {
+	switch m := i.(type) {
+	case metrics.Counter:
+		c.addCounter(name, labels, values, m.Snapshot())
+	case metrics.Gauge:
+		c.addGauge(name, labels, values, m.Snapshot())
+	case metrics.GaugeFloat64:
+		c.addGaugeFloat64(name, labels, values, m.Snapshot())
+	case metrics.Histogram:
+		c.addHistogram(name, labels, values, m.Snapshot())
+	case metrics.Meter:
+		// Meter snapshots lag behind until the next tick, read the live count
+		c.addMeter(name, labels, values, m)
+	case metrics.Timer:
+		c.addTimer(name, labels, values, m.Snapshot())
+	case metrics.ResettingTimer:
+		c.addResettingTimer(name, labels, values, m.Snapshot())
 	}
-	c.buff.WriteRune('\n')
 }
 
-func (c *collector) addMeter(name string, m metrics.Meter) {
-	c.writeGaugeCounter(name, m.Count())
+func (c *collector) addCounter(name string, labels, values []string, m metrics.Counter) {
+	c.writeSample(name, formatLabels(labels, values), m.Count())
+}
+
+func (c *collector) addGauge(name string, labels, values []string, m metrics.Gauge) {
+	c.writeSample(name, formatLabels(labels, values), m.Value())
+}
+
+func (c *collector) addGaugeFloat64(name string, labels, values []string, m metrics.GaugeFloat64) {
+	c.writeSample(name, formatLabels(labels, values), m.Value())
 }
 
-func (c *collector) addTimer(name string, m metrics.Timer) {
-	pv := []float64{0.5, 0.75, 0.95, 0.99, 0.999, 0.9999}
-	ps := m.Percentiles(pv)
-	c.writeSummaryCounter(name, m.Count())
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	for i := range pv {
-		c.writeSummaryPercentile(name, strconv.FormatFloat(pv[i], 'f', -1, 64), ps[i])
+func (c *collector) addHistogram(name string, labels, values []string, m metrics.Histogram) {
+	// The sum is estimated from the mean, since the sample only retains a subset
+	// of the recorded values.
+	c.writeSummary(name, labels, values, quantiles, m.Percentiles(quantiles), m.Mean()*float64(m.Count()), m.Count())
+}
+
+func (c *collector) addMeter(name string, labels, values []string, m metrics.Meter) {
+	c.writeSample(name, formatLabels(labels, values), m.Count())
+}
+
+func (c *collector) addTimer(name string, labels, values []string, m metrics.Timer) {
+	c.writeSummary(name, labels, values, quantiles, m.Percentiles(quantiles), m.Mean()*float64(m.Count()), m.Count())
+}
+
+func (c *collector) addResettingTimer(name string, labels, values []string, m metrics.ResettingTimer) {
+	var (
+		vals = m.Values()
+		sum  int64
+		ps   = make([]float64, len(resettingQuantiles))
+	)
+	for _, v := range vals {
+		sum += v
 	}
-	c.buff.WriteRune('\n')
+	for i := range ps {
+		ps[i] = math.NaN() // Prometheus notation for quantiles of empty summaries
+	}
+	if len(vals) > 0 {
+		for i, p := range m.Percentiles(resettingQuantiles) {
+			ps[i] = float64(p)
+		}
+	}
+	qs := make([]float64, len(resettingQuantiles))
+	for i, q := range resettingQuantiles {
+		qs[i] = q / 100
+	}
+	c.writeSummary(name, labels, values, qs, ps, sum, len(vals))
 }
 
-func (c *collector) addResettingTimer(name string, m metrics.ResettingTimer) {
-	if len(m.Values()) <= 0 {
-		return
+// writeSummary writes the quantile, sum and count samples of a summary.
+func (c *collector) writeSummary(name string, labels, values []string, qs, ps []float64, sum, count interface{}) {
+	for i, q := range qs {
+		ls := append(append([]string{}, labels...), "quantile")
+		vs := append(append([]string{}, values...), strconv.FormatFloat(q, 'f', -1, 64))
+		c.writeSample(name, formatLabels(ls, vs), ps[i])
 	}
-	ps := m.Percentiles([]float64{50, 95, 99})
-	val := m.Values()
-	c.writeSummaryCounter(name, len(val))
-	c.buff.WriteString(fmt.Sprintf(typeSummaryTpl, mutateKey(name)))
-	c.writeSummaryPercentile(name, "0.50", ps[0])
-	c.writeSummaryPercentile(name, "0.95", ps[1])
-	c.writeSummaryPercentile(name, "0.99", ps[2])
-	c.buff.WriteRune('\n')
+	c.writeSample(name+"_sum", formatLabels(labels, values), sum)
+	c.writeSample(name+"_count", formatLabels(labels, values), count)
 }
 
-func (c *collector) writeGaugeCounter(name string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(typeGaugeTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+func (c *collector) writeSample(name, labels string, value interface{}) {
+	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, labels, value))
 }
 
-func (c *collector) writeSummaryCounter(name string, value interface{}) {
-	name = mutateKey(name + "_count")
-	c.buff.WriteString(fmt.Sprintf(typeCounterTpl, name))
-	c.buff.WriteString(fmt.Sprintf(keyValueTpl, name, value))
+// typeTemplate returns the Prometheus type declaration template of a metric, or
+// an empty string if the metric type is not supported.
+func typeTemplate(i interface{}, labelled bool) string {
+	switch i.(type) {
+	case metrics.Counter, metrics.Gauge, metrics.GaugeFloat64:
+		// Counters can be decremented, so they can't be Prometheus counters
+		return typeGaugeTpl
+	case metrics.Meter:
+		// Plain meters have always been exported as gauges, keep them so that
+		// existing queries continue to work. Labelled meters are new series.
+		if !labelled {
+			return typeGaugeTpl
+		}
+		return typeCounterTpl
+	case metrics.Histogram, metrics.Timer, metrics.ResettingTimer:
+		return typeSummaryTpl
+	}
+	return ""
 }
 
-func (c *collector) writeSummaryPercentile(name, p string, value interface{}) {
-	name = mutateKey(name)
-	c.buff.WriteString(fmt.Sprintf(keyQuantileTagValueTpl, name, p, value))
+// formatLabels formats a set of label names and values into the Prometheus
+// label set notation. An empty string is returned if there are no labels.
+func formatLabels(labels, values []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf("%s=\"%s\"", label, labelValueEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
 }
 
 func mutateKey(key string) string {
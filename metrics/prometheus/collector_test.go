@@ -18,23 +18,23 @@ func TestCollector(t *testing.T) {
 
 	counter := metrics.NewCounter()
 	counter.Inc(12345)
-	c.addCounter("test/counter", counter)
+	c.add("test/counter", counter)
 
 	gauge := metrics.NewGauge()
 	gauge.Update(23456)
-	c.addGauge("test/gauge", gauge)
+	c.add("test/gauge", gauge)
 
 	gaugeFloat64 := metrics.NewGaugeFloat64()
 	gaugeFloat64.Update(34567.89)
-	c.addGaugeFloat64("test/gauge_float64", gaugeFloat64)
+	c.add("test/gauge_float64", gaugeFloat64)
 
-	histogram := metrics.NewHistogram(&metrics.NilSample{})
-	c.addHistogram("test/histogram", histogram)
+	histogram := metrics.NewHistogram(metrics.NewUniformSample(3))
+	c.add("test/histogram", histogram)
 
 	meter := metrics.NewMeter()
 	defer meter.Stop()
 	meter.Mark(9999999)
-	c.addMeter("test/meter", meter)
+	c.add("test/meter", meter)
 
 	timer := metrics.NewTimer()
 	defer timer.Stop()
@@ -44,7 +44,7 @@ func TestCollector(t *testing.T) {
 	timer.Update(120 * time.Millisecond)
 	timer.Update(23 * time.Millisecond)
 	timer.Update(24 * time.Millisecond)
-	c.addTimer("test/timer", timer)
+	c.add("test/timer", timer)
 
 	resettingTimer := metrics.NewResettingTimer()
 	resettingTimer.Update(10 * time.Millisecond)
@@ -53,10 +53,25 @@ func TestCollector(t *testing.T) {
 	resettingTimer.Update(120 * time.Millisecond)
 	resettingTimer.Update(13 * time.Millisecond)
 	resettingTimer.Update(14 * time.Millisecond)
-	c.addResettingTimer("test/resetting_timer", resettingTimer.Snapshot())
+	c.add("test/resetting_timer", resettingTimer)
 
-	emptyResettingTimer := metrics.NewResettingTimer().Snapshot()
-	c.addResettingTimer("test/empty_resetting_timer", emptyResettingTimer)
+	emptyResettingTimer := metrics.NewResettingTimer()
+	c.add("test/empty_resetting_timer", emptyResettingTimer)
+
+	labelledMeter := metrics.NewRegisteredLabelledMeter("test/labelled_meter", metrics.NewRegistry(), "protocol", "code")
+	defer labelledMeter.Stop()
+	labelledMeter.With("eth", "0x01").Mark(10)
+	labelledMeter.With("snap", "0x00").Mark(20)
+	labelledMeter.With("eth", "0x00").Mark(30)
+	c.add("test/labelled_meter", labelledMeter.Labelled)
+
+	labelledTimer := metrics.NewRegisteredLabelledTimer("test/labelled_timer", metrics.NewRegistry(), "method")
+	defer labelledTimer.Stop()
+	labelledTimer.With(`eth_"call"`).Update(10 * time.Millisecond)
+	c.add("test/labelled_timer", labelledTimer.Labelled)
+
+	emptyLabelled := metrics.NewRegisteredLabelledGauge("test/empty_labelled", metrics.NewRegistry(), "peer")
+	c.add("test/empty_labelled", emptyLabelled.Labelled)
 
 	const expectedOutput = `# TYPE test_counter gauge
 test_counter 12345
@@ -67,38 +82,57 @@ test_gauge 23456
 # TYPE test_gauge_float64 gauge
 test_gauge_float64 34567.89
 
-# TYPE test_histogram_count counter
-test_histogram_count 0
-
 # TYPE test_histogram summary
-test_histogram {quantile="0.5"} 0
-test_histogram {quantile="0.75"} 0
-test_histogram {quantile="0.95"} 0
-test_histogram {quantile="0.99"} 0
-test_histogram {quantile="0.999"} 0
-test_histogram {quantile="0.9999"} 0
+test_histogram{quantile="0.5"} 0
+test_histogram{quantile="0.75"} 0
+test_histogram{quantile="0.95"} 0
+test_histogram{quantile="0.99"} 0
+test_histogram{quantile="0.999"} 0
+test_histogram{quantile="0.9999"} 0
+test_histogram_sum 0
+test_histogram_count 0
 
 # TYPE test_meter gauge
 test_meter 9999999
 
-# TYPE test_timer_count counter
+# TYPE test_timer summary
+test_timer{quantile="0.5"} 2.25e+07
+test_timer{quantile="0.75"} 4.8e+07
+test_timer{quantile="0.95"} 1.2e+08
+test_timer{quantile="0.99"} 1.2e+08
+test_timer{quantile="0.999"} 1.2e+08
+test_timer{quantile="0.9999"} 1.2e+08
+test_timer_sum 2.3e+08
 test_timer_count 6
 
-# TYPE test_timer summary
-test_timer {quantile="0.5"} 2.25e+07
-test_timer {quantile="0.75"} 4.8e+07
-test_timer {quantile="0.95"} 1.2e+08
-test_timer {quantile="0.99"} 1.2e+08
-test_timer {quantile="0.999"} 1.2e+08
-test_timer {quantile="0.9999"} 1.2e+08
-
-# TYPE test_resetting_timer_count counter
+# TYPE test_resetting_timer summary
+test_resetting_timer{quantile="0.5"} 1.2e+07
+test_resetting_timer{quantile="0.95"} 1.2e+08
+test_resetting_timer{quantile="0.99"} 1.2e+08
+test_resetting_timer_sum 180000000
 test_resetting_timer_count 6
 
-# TYPE test_resetting_timer summary
-test_resetting_timer {quantile="0.50"} 12000000
-test_resetting_timer {quantile="0.95"} 120000000
-test_resetting_timer {quantile="0.99"} 120000000
+# TYPE test_empty_resetting_timer summary
+test_empty_resetting_timer{quantile="0.5"} NaN
+test_empty_resetting_timer{quantile="0.95"} NaN
+test_empty_resetting_timer{quantile="0.99"} NaN
+test_empty_resetting_timer_sum 0
+test_empty_resetting_timer_count 0
+
+# TYPE test_labelled_meter counter
+test_labelled_meter{protocol="eth",code="0x00"} 30
+test_labelled_meter{protocol="eth",code="0x01"} 10
+test_labelled_meter{protocol="snap",code="0x00"} 20
+
+# TYPE test_labelled_timer summary
+test_labelled_timer{method="eth_\"call\"",quantile="0.5"} 1e+07
+test_labelled_timer{method="eth_\"call\"",quantile="0.75"} 1e+07
+test_labelled_timer{method="eth_\"call\"",quantile="0.95"} 1e+07
+test_labelled_timer{method="eth_\"call\"",quantile="0.99"} 1e+07
+test_labelled_timer{method="eth_\"call\"",quantile="0.999"} 1e+07
+test_labelled_timer{method="eth_\"call\"",quantile="0.9999"} 1e+07
+test_labelled_timer_sum{method="eth_\"call\""} 1e+07
+test_labelled_timer_count{method="eth_\"call\""} 1
 
 `
 	exp := c.buff.String()
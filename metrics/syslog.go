@@ -13,7 +13,7 @@ import (
 // the given syslogger.
 func Syslog(r Registry, d time.Duration, w *syslog.Writer) {
 	for range time.Tick(d) {
-		r.Each(func(name string, i interface{}) {
+		Flatten(r, func(name string, i interface{}) {
 			switch metric := i.(type) {
 			case Counter:
 				w.Info(fmt.Sprintf("counter %s: count: %d", name, metric.Count()))
@@ -19,7 +19,7 @@ func Write(r Registry, d time.Duration, w io.Writer) {
 // io.Writer.
 func WriteOnce(r Registry, w io.Writer) {
 	var namedMetrics namedMetricSlice
-	r.Each(func(name string, i interface{}) {
+	Flatten(r, func(name string, i interface{}) {
 		namedMetrics = append(namedMetrics, namedMetric{name, i})
 	})
 
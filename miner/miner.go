@@ -205,6 +205,14 @@ func (miner *Miner) PendingBlockAndReceipts() (*types.Block, types.Receipts) {
 	return miner.worker.pendingBlockAndReceipts()
 }
 
+// GenerateBlock synchronously assembles a block on top of the given parent,
+// filled with the executable transactions of the pool. The block is neither
+// sealed nor imported, and the ongoing mining work is left untouched. It is
+// meant for simulators which control block production explicitly.
+func (miner *Miner) GenerateBlock(parent *types.Block, timestamp uint64, coinbase common.Address) (*types.Block, types.Receipts, *state.StateDB, error) {
+	return miner.worker.generate(parent, timestamp, coinbase)
+}
+
 func (miner *Miner) SetEtherbase(addr common.Address) {
 	miner.coinbase = addr
 	miner.worker.setEtherbase(addr)
@@ -114,6 +114,23 @@ type newWorkReq struct {
 	timestamp int64
 }
 
+// generateReq represents a request for synchronously generating a block on top
+// of an arbitrary parent, outside of the regular sealing cycle.
+type generateReq struct {
+	parent    *types.Block
+	timestamp uint64
+	coinbase  common.Address
+	result    chan *generateResult
+}
+
+// generateResult is the outcome of a block generation request.
+type generateResult struct {
+	block    *types.Block
+	receipts types.Receipts
+	state    *state.StateDB
+	err      error
+}
+
 // intervalAdjust represents a resubmitting interval adjustment.
 type intervalAdjust struct {
 	ratio float64
@@ -143,6 +160,7 @@ type worker struct {
 
 	// Channels
 	newWorkCh          chan *newWorkReq
+	generateCh         chan *generateReq
 	taskCh             chan *task
 	resultCh           chan *types.Block
 	startCh            chan struct{}
@@ -207,6 +225,7 @@ func newWorker(config *Config, chainConfig *params.ChainConfig, engine consensus
 		chainHeadCh:        make(chan core.ChainHeadEvent, chainHeadChanSize),
 		chainSideCh:        make(chan core.ChainSideEvent, chainSideChanSize),
 		newWorkCh:          make(chan *newWorkReq),
+		generateCh:         make(chan *generateReq),
 		taskCh:             make(chan *task),
 		resultCh:           make(chan *types.Block, resultQueueSize),
 		exitCh:             make(chan struct{}),
@@ -463,6 +482,10 @@ func (w *worker) mainLoop() {
 		case req := <-w.newWorkCh:
 			w.commitNewWork(req.interrupt, req.noempty, req.timestamp)
 
+		case req := <-w.generateCh:
+			block, receipts, state, err := w.generateWork(req.parent, req.timestamp, req.coinbase)
+			req.result <- &generateResult{block: block, receipts: receipts, state: state, err: err}
+
 		case ev := <-w.chainSideCh:
 			// Short circuit for duplicate side blocks
 			if _, exist := w.localUncles[ev.Block.Hash()]; exist {
@@ -679,11 +702,27 @@ func (w *worker) resultLoop() {
 
 // makeCurrent creates a new environment for the current cycle.
 func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
+	env, err := w.makeEnv(parent, header)
+	if err != nil {
+		return err
+	}
+	// Swap out the old work with the new one, terminating any leftover prefetcher
+	// processes in the mean time and starting a new one.
+	if w.current != nil && w.current.state != nil {
+		w.current.state.StopPrefetcher()
+	}
+	w.current = env
+	return nil
+}
+
+// makeEnv creates a new environment for sealing a block on top of the given
+// parent.
+func (w *worker) makeEnv(parent *types.Block, header *types.Header) (*environment, error) {
 	// Retrieve the parent state to execute on top and start a prefetcher for
 	// the miner to speed block sealing up a bit
 	state, err := w.chain.StateAt(parent.Root())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	state.StartPrefetcher("miner")
 
@@ -705,14 +744,7 @@ func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	}
 	// Keep track of transactions which return errors so they can be removed
 	env.tcount = 0
-
-	// Swap out the old work with the new one, terminating any leftover prefetcher
-	// processes in the mean time and starting a new one.
-	if w.current != nil && w.current.state != nil {
-		w.current.state.StopPrefetcher()
-	}
-	w.current = env
-	return nil
+	return env, nil
 }
 
 // commitUncle adds the given block to uncle block set, returns error if failed to add.
@@ -909,52 +941,22 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 	tstart := time.Now()
 	parent := w.chain.CurrentBlock()
 
-	if parent.Time() >= uint64(timestamp) {
-		timestamp = int64(parent.Time() + 1)
-	}
-	num := parent.Number()
-	header := &types.Header{
-		ParentHash: parent.Hash(),
-		Number:     num.Add(num, common.Big1),
-		GasLimit:   core.CalcGasLimit(parent.GasLimit(), w.config.GasCeil),
-		Extra:      w.extra,
-		Time:       uint64(timestamp),
-	}
-	// Set baseFee and GasLimit if we are on an EIP-1559 chain
-	if w.chainConfig.IsLondon(header.Number) {
-		header.BaseFee = misc.CalcBaseFee(w.chainConfig, parent.Header())
-		if !w.chainConfig.IsLondon(parent.Number()) {
-			parentGasLimit := parent.GasLimit() * params.ElasticityMultiplier
-			header.GasLimit = core.CalcGasLimit(parentGasLimit, w.config.GasCeil)
-		}
-	}
 	// Only set the coinbase if our consensus engine is running (avoid spurious block rewards)
+	var coinbase common.Address
 	if w.isRunning() {
 		if w.coinbase == (common.Address{}) {
 			log.Error("Refusing to mine without etherbase")
 			return
 		}
-		header.Coinbase = w.coinbase
+		coinbase = w.coinbase
 	}
-	if err := w.engine.Prepare(w.chain, header); err != nil {
+	header, err := w.makeHeader(parent, timestamp, coinbase)
+	if err != nil {
 		log.Error("Failed to prepare header for mining", "err", err)
 		return
 	}
-	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
-	if daoBlock := w.chainConfig.DAOForkBlock; daoBlock != nil {
-		// Check whether the block is among the fork extra-override range
-		limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
-		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
-			// Depending whether we support or oppose the fork, override differently
-			if w.chainConfig.DAOForkSupport {
-				header.Extra = common.CopyBytes(params.DAOForkBlockExtra)
-			} else if bytes.Equal(header.Extra, params.DAOForkBlockExtra) {
-				header.Extra = []byte{} // If miner opposes, don't let it use the reserved extra-data
-			}
-		}
-	}
 	// Could potentially happen if starting to mine in an odd state.
-	err := w.makeCurrent(parent, header)
+	err = w.makeCurrent(parent, header)
 	if err != nil {
 		log.Error("Failed to create mining context", "err", err)
 		return
@@ -1004,6 +1006,58 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		w.updateSnapshot()
 		return
 	}
+	if w.fillTransactions(pending, w.coinbase, interrupt) {
+		return
+	}
+	w.commit(uncles, w.fullTaskHook, true, tstart)
+}
+
+// makeHeader assembles and prepares the header of a new block on top of the
+// given parent.
+func (w *worker) makeHeader(parent *types.Block, timestamp int64, coinbase common.Address) (*types.Header, error) {
+	if parent.Time() >= uint64(timestamp) {
+		timestamp = int64(parent.Time() + 1)
+	}
+	num := parent.Number()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     num.Add(num, common.Big1),
+		GasLimit:   core.CalcGasLimit(parent.GasLimit(), w.config.GasCeil),
+		Extra:      w.extra,
+		Time:       uint64(timestamp),
+		Coinbase:   coinbase,
+	}
+	// Set baseFee and GasLimit if we are on an EIP-1559 chain
+	if w.chainConfig.IsLondon(header.Number) {
+		header.BaseFee = misc.CalcBaseFee(w.chainConfig, parent.Header())
+		if !w.chainConfig.IsLondon(parent.Number()) {
+			parentGasLimit := parent.GasLimit() * params.ElasticityMultiplier
+			header.GasLimit = core.CalcGasLimit(parentGasLimit, w.config.GasCeil)
+		}
+	}
+	if err := w.engine.Prepare(w.chain, header); err != nil {
+		return nil, err
+	}
+	// If we are care about TheDAO hard-fork check whether to override the extra-data or not
+	if daoBlock := w.chainConfig.DAOForkBlock; daoBlock != nil {
+		// Check whether the block is among the fork extra-override range
+		limit := new(big.Int).Add(daoBlock, params.DAOForkExtraRange)
+		if header.Number.Cmp(daoBlock) >= 0 && header.Number.Cmp(limit) < 0 {
+			// Depending whether we support or oppose the fork, override differently
+			if w.chainConfig.DAOForkSupport {
+				header.Extra = common.CopyBytes(params.DAOForkBlockExtra)
+			} else if bytes.Equal(header.Extra, params.DAOForkBlockExtra) {
+				header.Extra = []byte{} // If miner opposes, don't let it use the reserved extra-data
+			}
+		}
+	}
+	return header, nil
+}
+
+// fillTransactions fills the current environment with the given pending
+// transactions, prioritising the ones originating from local accounts. The
+// return value indicates whether the filling was interrupted by a new head.
+func (w *worker) fillTransactions(pending map[common.Address]types.Transactions, coinbase common.Address, interrupt *int32) bool {
 	// Split the pending transactions into locals and remotes
 	localTxs, remoteTxs := make(map[common.Address]types.Transactions), pending
 	for _, account := range w.eth.TxPool().Locals() {
@@ -1013,18 +1067,70 @@ func (w *worker) commitNewWork(interrupt *int32, noempty bool, timestamp int64)
 		}
 	}
 	if len(localTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs, header.BaseFee)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
+		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, localTxs, w.current.header.BaseFee)
+		if w.commitTransactions(txs, coinbase, interrupt) {
+			return true
 		}
 	}
 	if len(remoteTxs) > 0 {
-		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs, header.BaseFee)
-		if w.commitTransactions(txs, w.coinbase, interrupt) {
-			return
+		txs := types.NewTransactionsByPriceAndNonce(w.current.signer, remoteTxs, w.current.header.BaseFee)
+		if w.commitTransactions(txs, coinbase, interrupt) {
+			return true
 		}
 	}
-	w.commit(uncles, w.fullTaskHook, true, tstart)
+	return false
+}
+
+// generate requests the synchronous generation of a block on top of the given
+// parent, independently of the ongoing sealing work.
+func (w *worker) generate(parent *types.Block, timestamp uint64, coinbase common.Address) (*types.Block, types.Receipts, *state.StateDB, error) {
+	req := &generateReq{
+		parent:    parent,
+		timestamp: timestamp,
+		coinbase:  coinbase,
+		result:    make(chan *generateResult, 1),
+	}
+	select {
+	case w.generateCh <- req:
+		res := <-req.result
+		return res.block, res.receipts, res.state, res.err
+	case <-w.exitCh:
+		return nil, nil, nil, errors.New("worker closed")
+	}
+}
+
+// generateWork assembles a block on top of the given parent, filled with all the
+// executable transactions of the pool. The block is not sealed and the current
+// sealing work is left untouched.
+func (w *worker) generateWork(parent *types.Block, timestamp uint64, coinbase common.Address) (*types.Block, types.Receipts, *state.StateDB, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	header, err := w.makeHeader(parent, int64(timestamp), coinbase)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	env, err := w.makeEnv(parent, header)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer env.state.StopPrefetcher()
+
+	if w.chainConfig.DAOForkSupport && w.chainConfig.DAOForkBlock != nil && w.chainConfig.DAOForkBlock.Cmp(header.Number) == 0 {
+		misc.ApplyDAOHardFork(env.state)
+	}
+	// Temporarily swap in the new environment to fill it with transactions,
+	// restoring the ongoing sealing work afterwards.
+	current := w.current
+	w.current = env
+	w.fillTransactions(w.eth.TxPool().Pending(true), coinbase, nil)
+	w.current = current
+
+	block, err := w.engine.FinalizeAndAssemble(w.chain, env.header, env.state, env.txs, nil, env.receipts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return block, env.receipts, env.state, nil
 }
 
 // commit runs any post-transaction state modifications, assembles the final block
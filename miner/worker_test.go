@@ -265,6 +265,36 @@ func testGenerateBlockAndImport(t *testing.T, isClique bool) {
 	}
 }
 
+func TestGenerateWork(t *testing.T) {
+	engine := ethash.NewFaker()
+	defer engine.Close()
+
+	w, b := newTestWorker(t, ethashChainConfig, engine, rawdb.NewMemoryDatabase(), 0)
+	defer w.close()
+
+	parent := b.chain.CurrentBlock()
+	block, receipts, state, err := w.generate(parent, parent.Time()+10, testUserAddress)
+	if err != nil {
+		t.Fatalf("failed to generate block: %v", err)
+	}
+	if block.NumberU64() != 1 || block.ParentHash() != parent.Hash() {
+		t.Fatalf("block position mismatch: have #%d [%x], want #1 [%x]", block.NumberU64(), block.ParentHash(), parent.Hash())
+	}
+	if block.Time() != parent.Time()+10 {
+		t.Errorf("block time mismatch: have %d, want %d", block.Time(), parent.Time()+10)
+	}
+	if len(block.Transactions()) != len(pendingTxs) || len(receipts) != len(pendingTxs) {
+		t.Fatalf("transaction count mismatch: have %d txs and %d receipts, want %d", len(block.Transactions()), len(receipts), len(pendingTxs))
+	}
+	if balance := state.GetBalance(testUserAddress); balance.Cmp(big.NewInt(1000)) <= 0 {
+		t.Errorf("coinbase balance too low: have %v", balance)
+	}
+	// The generated block is unsealed, but the faker accepts it as is
+	if _, err := b.chain.InsertChain([]*types.Block{block}); err != nil {
+		t.Fatalf("failed to import generated block: %v", err)
+	}
+}
+
 func TestEmptyWorkEthash(t *testing.T) {
 	testEmptyWork(t, ethashChainConfig, ethash.NewFaker())
 }
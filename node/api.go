@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -125,6 +126,78 @@ func (api *privateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanPeer disconnects a remote node and prevents it from connecting for the given
+// number of seconds. The default duration is one hour.
+func (api *privateAdminAPI) BanPeer(url string, seconds *uint64) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	node, err := enode.Parse(enode.ValidSchemes, url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	duration := p2p.DefaultBanDuration
+	if seconds != nil {
+		duration = time.Duration(*seconds) * time.Second
+	}
+	server.BanPeer(node, duration)
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of a remote node.
+func (api *privateAdminAPI) UnbanPeer(url string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	node, err := enode.Parse(enode.ValidSchemes, url)
+	if err != nil {
+		return false, fmt.Errorf("invalid enode: %v", err)
+	}
+	server.UnbanPeer(node)
+	return true, nil
+}
+
+// DenyNetwork prevents connections to and from a network, given as CIDR mask or
+// IP address. Peers in the network are disconnected.
+func (api *privateAdminAPI) DenyNetwork(network string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.DenyNetwork(network); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// AllowNetwork removes a network from the denylist.
+func (api *privateAdminAPI) AllowNetwork(network string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	if err := server.AllowNetwork(network); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// DeniedNetworks retrieves the networks added by DenyNetwork.
+func (api *privateAdminAPI) DeniedNetworks() ([]string, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	return server.DeniedNetworks(), nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *privateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -80,6 +80,19 @@ type Config struct {
 	// ExternalSigner specifies an external URI for a clef-type signer
 	ExternalSigner string `toml:",omitempty"`
 
+	// ExternalSignerCert and ExternalSignerKey specify the client certificate used
+	// to authenticate to an external signer requiring mutual TLS.
+	ExternalSignerCert string `toml:",omitempty"`
+	ExternalSignerKey  string `toml:",omitempty"`
+
+	// ExternalSignerCA specifies the CA file used to verify the certificate of
+	// an external signer serving over TLS, instead of the system pool.
+	ExternalSignerCA string `toml:",omitempty"`
+
+	// RemoteSigner specifies the HTTP endpoint of a remote key management service
+	// signing with keys that never touch the local disk.
+	RemoteSigner string `toml:",omitempty"`
+
 	// UseLightweightKDF lowers the memory and CPU requirements of the key store
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
@@ -17,6 +17,7 @@
 package node
 
 import (
+	"crypto/tls"
 	"net"
 	"net/http"
 	"time"
@@ -35,6 +36,22 @@ func StartHTTPEndpoint(endpoint string, timeouts rpc.HTTPTimeouts, handler http.
 	if listener, err = net.Listen("tcp", endpoint); err != nil {
 		return nil, nil, err
 	}
+	return serveHTTP(listener, timeouts, handler), listener.Addr(), err
+}
+
+// StartHTTPSEndpoint starts the HTTP RPC endpoint, serving over TLS with the
+// given configuration.
+func StartHTTPSEndpoint(endpoint string, timeouts rpc.HTTPTimeouts, handler http.Handler, config *tls.Config) (*http.Server, net.Addr, error) {
+	// start the TLS listener
+	listener, err := tls.Listen("tcp", endpoint, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return serveHTTP(listener, timeouts, handler), listener.Addr(), nil
+}
+
+// serveHTTP starts an HTTP server handling the connections of the listener.
+func serveHTTP(listener net.Listener, timeouts rpc.HTTPTimeouts, handler http.Handler) *http.Server {
 	// make sure timeout values are meaningful
 	CheckTimeouts(&timeouts)
 	// Bundle and start the HTTP server
@@ -45,7 +62,7 @@ func StartHTTPEndpoint(endpoint string, timeouts rpc.HTTPTimeouts, handler http.
 		IdleTimeout:  timeouts.IdleTimeout,
 	}
 	go httpSrv.Serve(listener)
-	return httpSrv, listener.Addr(), err
+	return httpSrv
 }
 
 // checkModuleAvailability checks that all names given in modules are actually
@@ -0,0 +1,267 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var errCaptureClosed = errors.New("message capture closed")
+
+const (
+	defaultMsgCaptureFileSize = 64 * 1024 * 1024
+	msgCaptureFiles           = 5 // number of files kept, including the current one
+)
+
+// CapturedMsg is a protocol message recorded by MsgCapture. Capture files
+// contain a sequence of RLP-encoded CapturedMsg values.
+type CapturedMsg struct {
+	Time     uint64 // unix time in nanoseconds
+	Peer     enode.ID
+	Protocol string
+	Version  uint
+	Inbound  bool   // true if the message was received from the peer
+	Code     uint64 // relative to the protocol's message code offset
+	Payload  []byte
+}
+
+// MsgCapture writes the messages of peers to a rotating set of files. When the
+// current file exceeds the size limit, it is renamed to "<file>.1", the previous
+// "<file>.1" to "<file>.2" and so on.
+//
+// Messages are written to the file unbuffered, so they aren't lost if the process
+// crashes.
+type MsgCapture struct {
+	file    string
+	maxSize int64
+
+	mu   sync.Mutex
+	fd   *os.File
+	size int64
+	err  error // first write error
+}
+
+// NewMsgCapture opens a capture file for writing. If the file exists, messages
+// are appended to it. A maxSize of zero selects the default size limit.
+func NewMsgCapture(file string, maxSize int64) (*MsgCapture, error) {
+	if maxSize <= 0 {
+		maxSize = defaultMsgCaptureFileSize
+	}
+	c := &MsgCapture{file: file, maxSize: maxSize}
+	if err := c.open(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *MsgCapture) open() error {
+	fd, err := os.OpenFile(c.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	stat, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+	c.fd, c.size = fd, stat.Size()
+	return nil
+}
+
+// rotate closes the current file, renames the existing files and opens a new file.
+func (c *MsgCapture) rotate() error {
+	if err := c.close(); err != nil {
+		return err
+	}
+	os.Remove(rotatedCaptureFile(c.file, msgCaptureFiles-1))
+	for i := msgCaptureFiles - 2; i >= 1; i-- {
+		os.Rename(rotatedCaptureFile(c.file, i), rotatedCaptureFile(c.file, i+1))
+	}
+	if err := os.Rename(c.file, rotatedCaptureFile(c.file, 1)); err != nil {
+		return err
+	}
+	return c.open()
+}
+
+func (c *MsgCapture) close() error {
+	if c.fd == nil {
+		return nil
+	}
+	err := c.fd.Close()
+	c.fd = nil
+	return err
+}
+
+// Close closes the capture file.
+func (c *MsgCapture) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.close()
+}
+
+// Write adds a message to the capture.
+func (c *MsgCapture) Write(msg *CapturedMsg) error {
+	enc, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.fd == nil {
+		return errCaptureClosed
+	}
+	if c.size > 0 && c.size+int64(len(enc)) > c.maxSize {
+		if err = c.rotate(); err != nil {
+			return c.writeErr(err)
+		}
+	}
+	if _, err := c.fd.Write(enc); err != nil {
+		return c.writeErr(err)
+	}
+	c.size += int64(len(enc))
+	return nil
+}
+
+// writeErr logs the first write error. Capturing is best-effort and errors
+// shouldn't affect the peer connection.
+func (c *MsgCapture) writeErr(err error) error {
+	if c.err == nil {
+		log.Warn("Can't write message capture", "file", c.file, "err", err)
+		c.err = err
+	}
+	return err
+}
+
+func rotatedCaptureFile(file string, i int) string {
+	return fmt.Sprintf("%s.%d", file, i)
+}
+
+// MsgCaptureFiles returns the existing files of the capture at the given path,
+// oldest first.
+func MsgCaptureFiles(file string) []string {
+	var files []string
+	for i := msgCaptureFiles - 1; i >= 1; i-- {
+		if _, err := os.Stat(rotatedCaptureFile(file, i)); err == nil {
+			files = append(files, rotatedCaptureFile(file, i))
+		}
+	}
+	if _, err := os.Stat(file); err == nil {
+		files = append(files, file)
+	}
+	return files
+}
+
+// MsgCaptureReader reads messages from a capture file.
+type MsgCaptureReader struct {
+	s *rlp.Stream
+}
+
+// NewMsgCaptureReader creates a reader for capture file contents.
+func NewMsgCaptureReader(r io.Reader) *MsgCaptureReader {
+	return &MsgCaptureReader{s: rlp.NewStream(bufio.NewReader(r), 0)}
+}
+
+// Read returns the next message. It returns io.EOF at the end of the input.
+func (r *MsgCaptureReader) Read() (*CapturedMsg, error) {
+	msg := new(CapturedMsg)
+	if err := r.s.Decode(msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// msgRecorder wraps a MsgReadWriter and records all messages in a capture.
+type msgRecorder struct {
+	MsgReadWriter
+
+	capture  *MsgCapture
+	peerID   enode.ID
+	protocol string
+	version  uint
+}
+
+func newMsgRecorder(rw MsgReadWriter, capture *MsgCapture, peerID enode.ID, proto Protocol) *msgRecorder {
+	return &msgRecorder{
+		MsgReadWriter: rw,
+		capture:       capture,
+		peerID:        peerID,
+		protocol:      proto.Name,
+		version:       proto.Version,
+	}
+}
+
+// ReadMsg reads a message from the underlying MsgReadWriter and records it.
+func (r *msgRecorder) ReadMsg() (Msg, error) {
+	msg, err := r.MsgReadWriter.ReadMsg()
+	if err != nil {
+		return msg, err
+	}
+	if err := r.record(&msg, true); err != nil {
+		return msg, err
+	}
+	return msg, nil
+}
+
+// WriteMsg records a message and writes it to the underlying MsgReadWriter.
+func (r *msgRecorder) WriteMsg(msg Msg) error {
+	if err := r.record(&msg, false); err != nil {
+		return err
+	}
+	return r.MsgReadWriter.WriteMsg(msg)
+}
+
+// record adds msg to the capture. The payload of msg is replaced because it
+// needs to be read.
+func (r *msgRecorder) record(msg *Msg, inbound bool) error {
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return err
+	}
+	msg.Payload = bytes.NewReader(payload)
+	r.capture.Write(&CapturedMsg{
+		Time:     uint64(time.Now().UnixNano()),
+		Peer:     r.peerID,
+		Protocol: r.protocol,
+		Version:  r.version,
+		Inbound:  inbound,
+		Code:     msg.Code,
+		Payload:  payload,
+	})
+	return nil
+}
+
+// Close closes the underlying MsgReadWriter if it implements the io.Closer
+// interface.
+func (r *msgRecorder) Close() error {
+	if v, ok := r.MsgReadWriter.(io.Closer); ok {
+		return v.Close()
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func readCapture(t *testing.T, file string) []*CapturedMsg {
+	var msgs []*CapturedMsg
+	for _, file := range MsgCaptureFiles(file) {
+		fd, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := NewMsgCaptureReader(fd)
+		for {
+			msg, err := r.Read()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			msgs = append(msgs, msg)
+		}
+		fd.Close()
+	}
+	return msgs
+}
+
+func TestMsgRecorder(t *testing.T) {
+	var (
+		file    = filepath.Join(t.TempDir(), "capture")
+		id      = enode.ID{1, 2, 3}
+		proto   = Protocol{Name: "test", Version: 2}
+		rw1, c2 = MsgPipe()
+	)
+	capture, err := NewMsgCapture(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := newMsgRecorder(rw1, capture, id, proto)
+	defer rec.Close()
+
+	go func() {
+		Send(c2, 5, []uint{1, 2})
+		msg, _ := c2.ReadMsg()
+		msg.Discard()
+	}()
+	msg, err := rec.ReadMsg()
+	if err != nil {
+		t.Fatal("ReadMsg error:", err)
+	}
+	// The payload must still be readable after recording.
+	var content []uint
+	if err := msg.Decode(&content); err != nil {
+		t.Fatal("can't decode recorded message:", err)
+	}
+	if err := Send(rec, 6, "reply"); err != nil {
+		t.Fatal("WriteMsg error:", err)
+	}
+	capture.Close()
+
+	msgs := readCapture(t, file)
+	if len(msgs) != 2 {
+		t.Fatalf("wrong number of captured messages %d", len(msgs))
+	}
+	want := []CapturedMsg{
+		{Peer: id, Protocol: "test", Version: 2, Inbound: true, Code: 5, Payload: []byte{0xc2, 0x01, 0x02}},
+		{Peer: id, Protocol: "test", Version: 2, Inbound: false, Code: 6, Payload: []byte("\x85reply")},
+	}
+	for i, msg := range msgs {
+		if msg.Time == 0 {
+			t.Errorf("message %d has no timestamp", i)
+		}
+		msg.Time = 0
+		if !reflect.DeepEqual(*msg, want[i]) {
+			t.Errorf("wrong message %d:\n got %+v\nwant %+v", i, *msg, want[i])
+		}
+	}
+}
+
+// Tests that captured messages are in the file before the capture is closed.
+func TestMsgCaptureUnbuffered(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture")
+	capture, err := NewMsgCapture(file, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Close()
+
+	if err := capture.Write(&CapturedMsg{Protocol: "test", Code: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if msgs := readCapture(t, file); len(msgs) != 1 {
+		t.Fatalf("wrong number of captured messages %d", len(msgs))
+	}
+}
+
+func TestMsgCaptureRotation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "capture")
+	capture, err := NewMsgCapture(file, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 40; i++ {
+		msg := &CapturedMsg{Time: uint64(i + 1), Code: uint64(i), Payload: bytes.Repeat([]byte{1}, 50)}
+		if err := capture.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	capture.Close()
+
+	files := MsgCaptureFiles(file)
+	if len(files) != msgCaptureFiles {
+		t.Fatalf("wrong number of files %d", len(files))
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) > 200 {
+			t.Errorf("file %s exceeds size limit: %d bytes", f, len(data))
+		}
+	}
+	// The remaining messages must be the most recent ones, in order.
+	msgs := readCapture(t, file)
+	if len(msgs) == 0 || msgs[len(msgs)-1].Code != 39 {
+		t.Fatal("latest message missing")
+	}
+	for i := 1; i < len(msgs); i++ {
+		if msgs[i].Code != msgs[i-1].Code+1 {
+			t.Fatalf("messages out of order: %d after %d", msgs[i].Code, msgs[i-1].Code)
+		}
+	}
+
+	// Reopening appends to the current file.
+	capture, err = NewMsgCapture(file, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	capture.Write(&CapturedMsg{Code: 40})
+	capture.Close()
+	if msgs := readCapture(t, file); msgs[len(msgs)-1].Code != 40 {
+		t.Fatal("message not appended after reopening")
+	}
+}
@@ -78,6 +78,7 @@ var (
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNetRestrict      = errors.New("not contained in netrestrict list")
+	errBanned           = errors.New("node is banned")
 	errNoPort           = errors.New("node does not provide TCP port")
 )
 
@@ -139,6 +140,7 @@ type dialConfig struct {
 	log            log.Logger
 	clock          mclock.Clock
 	rand           *mrand.Rand
+	banned         func(*enode.Node) bool // reports whether a node is banned, optional
 }
 
 func (cfg dialConfig) withDefaults() dialConfig {
@@ -407,6 +409,9 @@ func (d *dialScheduler) checkDial(n *enode.Node) error {
 	if d.history.contains(string(n.ID().Bytes())) {
 		return errRecentlyDialed
 	}
+	if d.banned != nil && d.banned(n) {
+		return errBanned
+	}
 	return nil
 }
 
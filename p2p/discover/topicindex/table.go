@@ -0,0 +1,249 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package topicindex implements the topic table of discovery v5 topic advertisement.
+//
+// Nodes advertise themselves for a topic by placing ads in the topic tables of other
+// nodes. To keep the tables fair and resistant against flooding, ads are not placed
+// right away: the registrant is assigned a waiting time, which grows with the
+// occupancy of the table, the share of ads for the topic and the share of ads from
+// the IP subnet of the registrant. The waiting time is tracked by tickets, which the
+// registrant presents on its next attempt.
+package topicindex
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	// DefaultAdLifetime is the time an ad stays in the table once placed.
+	DefaultAdLifetime = 15 * time.Minute
+
+	// DefaultTableLimit is the maximum number of ads in the table, across all topics.
+	DefaultTableLimit = 10000
+
+	// DefaultRegistrationWindow is the time after the waiting time of a ticket
+	// during which the ticket can be used.
+	DefaultRegistrationWindow = 10 * time.Second
+
+	occupancyExponent = 10   // Exponent of the occupancy term in the waiting time
+	baseModifier      = 1e-7 // Share added to the waiting time of empty tables
+)
+
+// TopicID identifies a topic.
+type TopicID [32]byte
+
+// NewTopicID creates the identifier of a topic name.
+func NewTopicID(name string) TopicID {
+	return sha256.Sum256([]byte(name))
+}
+
+// String returns the topic identifier as a hex string.
+func (id TopicID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+// Config holds settings of the topic table.
+type Config struct {
+	AdLifetime         time.Duration // time an ad stays in the table
+	TableLimit         int           // maximum number of ads in the table
+	RegistrationWindow time.Duration // time after the waiting time a ticket is valid
+	Clock              mclock.Clock
+}
+
+func (cfg Config) withDefaults() Config {
+	if cfg.AdLifetime == 0 {
+		cfg.AdLifetime = DefaultAdLifetime
+	}
+	if cfg.TableLimit == 0 {
+		cfg.TableLimit = DefaultTableLimit
+	}
+	if cfg.RegistrationWindow == 0 {
+		cfg.RegistrationWindow = DefaultRegistrationWindow
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = mclock.System{}
+	}
+	return cfg
+}
+
+// ad is an advertisement of a node for a topic.
+type ad struct {
+	topic   TopicID
+	node    *enode.Node
+	subnet  string
+	expires mclock.AbsTime
+}
+
+// TopicTable stores the ads placed by other nodes.
+type TopicTable struct {
+	cfg    Config
+	sealer *ticketSealer
+
+	queue   []*ad             // All ads, ordered by expiry
+	topics  map[TopicID][]*ad // Ads by topic, ordered by expiry
+	subnets map[string]int    // Number of ads by IP subnet
+	lock    sync.Mutex
+}
+
+// NewTopicTable creates an empty topic table.
+func NewTopicTable(cfg Config) *TopicTable {
+	return &TopicTable{
+		cfg:     cfg.withDefaults(),
+		sealer:  newTicketSealer(),
+		topics:  make(map[TopicID][]*ad),
+		subnets: make(map[string]int),
+	}
+}
+
+// Register handles an attempt of a node to place an ad for the topic, presenting
+// the ticket of its last attempt, if any. If the node has waited long enough, the
+// ad is placed. Otherwise, a ticket is issued along with the remaining time the
+// node needs to wait before its next attempt.
+//
+// Tickets which are invalid, or presented after their registration window, are
+// ignored: the waiting time of the node starts over.
+func (tab *TopicTable) Register(topic TopicID, n *enode.Node, ticket []byte) (registered bool, newTicket []byte, wait time.Duration) {
+	tab.lock.Lock()
+	defer tab.lock.Unlock()
+
+	now := tab.cfg.Clock.Now()
+	tab.expire(now)
+
+	t, err := tab.sealer.open(ticket)
+	if err != nil || !t.matches(topic, n) || now > t.LastMod.Add(t.WaitFor+tab.cfg.RegistrationWindow) {
+		t = &ticketData{Topic: topic, Node: n.ID(), IP: n.IP(), Created: now}
+	}
+	required := tab.waitTime(topic, n, now)
+	waited := now.Sub(t.Created)
+	if waited >= required {
+		tab.add(topic, n, now)
+		return true, nil, 0
+	}
+	t.LastMod, t.WaitFor = now, required-waited
+	return false, tab.sealer.seal(t), t.WaitFor
+}
+
+// WaitTime returns the time a node would need to wait before placing an ad for
+// the topic, according to the current state of the table.
+func (tab *TopicTable) WaitTime(topic TopicID, n *enode.Node) time.Duration {
+	tab.lock.Lock()
+	defer tab.lock.Unlock()
+
+	now := tab.cfg.Clock.Now()
+	tab.expire(now)
+	return tab.waitTime(topic, n, now)
+}
+
+// Nodes returns the nodes advertised for the topic, in the order of placement.
+func (tab *TopicTable) Nodes(topic TopicID) []*enode.Node {
+	tab.lock.Lock()
+	defer tab.lock.Unlock()
+
+	tab.expire(tab.cfg.Clock.Now())
+	ads := tab.topics[topic]
+	nodes := make([]*enode.Node, len(ads))
+	for i, ad := range ads {
+		nodes[i] = ad.node
+	}
+	return nodes
+}
+
+// AdLifetime returns the time ads stay in the table once placed.
+func (tab *TopicTable) AdLifetime() time.Duration {
+	return tab.cfg.AdLifetime
+}
+
+// Len returns the number of ads in the table.
+func (tab *TopicTable) Len() int {
+	tab.lock.Lock()
+	defer tab.lock.Unlock()
+
+	tab.expire(tab.cfg.Clock.Now())
+	return len(tab.queue)
+}
+
+// waitTime computes the waiting time of a node for the topic. Nodes can have a
+// single ad for a topic, and no ads are placed while the table is full: in both
+// cases, the node needs to wait until the blocking ad expires.
+func (tab *TopicTable) waitTime(topic TopicID, n *enode.Node, now mclock.AbsTime) time.Duration {
+	for _, ad := range tab.topics[topic] {
+		if ad.node.ID() == n.ID() {
+			return ad.expires.Sub(now)
+		}
+	}
+	if len(tab.queue) >= tab.cfg.TableLimit {
+		return tab.queue[0].expires.Sub(now)
+	}
+	var (
+		limit     = float64(tab.cfg.TableLimit)
+		occupancy = float64(len(tab.queue)) / limit
+		topicMod  = float64(len(tab.topics[topic])) / limit
+		ipMod     float64
+	)
+	if len(tab.queue) > 0 {
+		ipMod = float64(tab.subnets[subnet(n.IP())]) / float64(len(tab.queue))
+	}
+	wait := float64(tab.cfg.AdLifetime) * (topicMod + ipMod + baseModifier) / math.Pow(1-occupancy, occupancyExponent)
+	if wait >= math.MaxInt64 {
+		return math.MaxInt64
+	}
+	// Waiting times are communicated in milliseconds, round down
+	return time.Duration(wait).Truncate(time.Millisecond)
+}
+
+// add places an ad of the node for the topic.
+func (tab *TopicTable) add(topic TopicID, n *enode.Node, now mclock.AbsTime) {
+	ad := &ad{topic: topic, node: n, subnet: subnet(n.IP()), expires: now.Add(tab.cfg.AdLifetime)}
+	tab.queue = append(tab.queue, ad)
+	tab.topics[topic] = append(tab.topics[topic], ad)
+	tab.subnets[ad.subnet]++
+}
+
+// expire removes the ads which have expired. Since all ads have the same lifetime,
+// the expired ads are at the front of the queues.
+func (tab *TopicTable) expire(now mclock.AbsTime) {
+	for len(tab.queue) > 0 && tab.queue[0].expires <= now {
+		ad := tab.queue[0]
+		tab.queue = tab.queue[1:]
+
+		if ads := tab.topics[ad.topic][1:]; len(ads) > 0 {
+			tab.topics[ad.topic] = ads
+		} else {
+			delete(tab.topics, ad.topic)
+		}
+		if tab.subnets[ad.subnet]--; tab.subnets[ad.subnet] == 0 {
+			delete(tab.subnets, ad.subnet)
+		}
+	}
+}
+
+// subnet returns the /24 subnet of IPv4 addresses, and the /64 subnet of IPv6
+// addresses, which ads are grouped by.
+func subnet(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String()
+}
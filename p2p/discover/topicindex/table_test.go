@@ -0,0 +1,177 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package topicindex
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+var (
+	topicA = NewTopicID("a")
+	topicB = NewTopicID("b")
+)
+
+func testNode(id byte, ip string) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.ParseIP(ip)))
+	return enode.SignNull(&r, enode.ID{id})
+}
+
+// place registers n until its ad is placed.
+func place(tab *TopicTable, clock *mclock.Simulated, topic TopicID, n *enode.Node) {
+	var ticket []byte
+	for {
+		ok, newTicket, wait := tab.Register(topic, n, ticket)
+		if ok {
+			return
+		}
+		ticket = newTicket
+		clock.Run(wait)
+	}
+}
+
+func newTestTable(limit int) (*TopicTable, *mclock.Simulated) {
+	clock := new(mclock.Simulated)
+	tab := NewTopicTable(Config{AdLifetime: 10 * time.Minute, TableLimit: limit, Clock: clock})
+	return tab, clock
+}
+
+// This test checks that waiting times grow with the share of the topic and subnet.
+func TestWaitTime(t *testing.T) {
+	tab, _ := newTestTable(100)
+
+	if wait := tab.WaitTime(topicA, testNode(1, "10.0.0.1")); wait != 0 {
+		t.Fatalf("wrong waiting time in empty table: %v", wait)
+	}
+	if ok, _, _ := tab.Register(topicA, testNode(1, "10.0.0.1"), nil); !ok {
+		t.Fatal("registration in empty table failed")
+	}
+	var (
+		otherTopic = tab.WaitTime(topicB, testNode(2, "10.0.1.1"))
+		sameTopic  = tab.WaitTime(topicA, testNode(2, "10.0.1.1"))
+		sameSubnet = tab.WaitTime(topicB, testNode(2, "10.0.0.2"))
+		sameAdvert = tab.WaitTime(topicA, testNode(1, "10.0.0.1"))
+		ipv6Node   = tab.WaitTime(topicB, testNode(3, "2001:db8::1"))
+	)
+	if otherTopic != 0 {
+		t.Errorf("wrong waiting time for other topic: %v", otherTopic)
+	}
+	if sameTopic <= otherTopic || sameTopic >= sameSubnet {
+		t.Errorf("wrong waiting time for same topic: %v", sameTopic)
+	}
+	if sameSubnet < 10*time.Minute {
+		t.Errorf("wrong waiting time for same subnet: %v", sameSubnet)
+	}
+	if sameAdvert != 10*time.Minute {
+		t.Errorf("wrong waiting time for existing ad: %v", sameAdvert)
+	}
+	if ipv6Node != 0 {
+		t.Errorf("wrong waiting time for IPv6 node: %v", ipv6Node)
+	}
+}
+
+// This test checks that tickets keep track of the waiting time.
+func TestTickets(t *testing.T) {
+	tab, clock := newTestTable(100)
+	tab.Register(topicA, testNode(1, "10.0.0.1"), nil)
+
+	n := testNode(2, "10.0.1.1")
+	ok, ticket, wait := tab.Register(topicA, n, nil)
+	if ok || ticket == nil || wait == 0 {
+		t.Fatalf("registration without ticket succeeded")
+	}
+	// Early attempts get a new ticket for the remaining time.
+	clock.Run(wait / 2)
+	ok, ticket2, wait2 := tab.Register(topicA, n, ticket)
+	if ok || wait2 != wait-wait/2 {
+		t.Fatalf("wrong remaining waiting time: have %v, want %v", wait2, wait-wait/2)
+	}
+	// Tickets of other nodes are ignored.
+	if _, _, wait := tab.Register(topicA, testNode(3, "10.0.2.1"), ticket2); wait != tab.WaitTime(topicA, testNode(3, "10.0.2.1")) {
+		t.Errorf("ticket of other node accepted")
+	}
+	if _, _, wait := tab.Register(topicA, n, []byte("invalid")); wait < wait2 {
+		t.Errorf("invalid ticket accepted")
+	}
+	// Registration succeeds once the waiting time is over.
+	clock.Run(wait2)
+	if ok, _, _ := tab.Register(topicA, n, ticket2); !ok {
+		t.Fatalf("registration with ticket failed")
+	}
+	if nodes := tab.Nodes(topicA); len(nodes) != 2 || nodes[1].ID() != n.ID() {
+		t.Fatalf("wrong nodes for topic: %v", nodes)
+	}
+}
+
+// This test checks that tickets can't be used after the registration window.
+func TestTicketExpiry(t *testing.T) {
+	tab, clock := newTestTable(100)
+	tab.Register(topicA, testNode(1, "10.0.0.1"), nil)
+
+	n := testNode(2, "10.0.1.1")
+	_, ticket, wait := tab.Register(topicA, n, nil)
+	clock.Run(wait + DefaultRegistrationWindow + 1)
+	ok, _, wait2 := tab.Register(topicA, n, ticket)
+	if ok || wait2 != wait {
+		t.Fatalf("expired ticket accepted: registered %t, wait %v", ok, wait2)
+	}
+}
+
+// This test checks that ads are removed after their lifetime.
+func TestAdExpiry(t *testing.T) {
+	tab, clock := newTestTable(100)
+	tab.Register(topicA, testNode(1, "10.0.0.1"), nil)
+	clock.Run(5 * time.Minute)
+	tab.Register(topicB, testNode(2, "10.0.1.1"), nil)
+
+	clock.Run(5 * time.Minute)
+	if n := tab.Len(); n != 1 {
+		t.Fatalf("wrong number of ads after first expiry: %d", n)
+	}
+	if nodes := tab.Nodes(topicA); len(nodes) != 0 {
+		t.Fatalf("expired ad returned: %v", nodes)
+	}
+	clock.Run(5 * time.Minute)
+	if n := tab.Len(); n != 0 {
+		t.Fatalf("wrong number of ads after second expiry: %d", n)
+	}
+}
+
+// This test checks that no ads are placed while the table is full.
+func TestTableFull(t *testing.T) {
+	tab, clock := newTestTable(2)
+	tab.Register(topicA, testNode(1, "10.0.0.1"), nil)
+	clock.Run(time.Minute)
+	place(tab, clock, topicB, testNode(2, "10.0.1.1"))
+
+	// The waiting time ends when the first ad expires.
+	n := testNode(3, "10.0.2.1")
+	ok, ticket, wait := tab.Register(topicA, n, nil)
+	if ok || wait != time.Duration(mclock.AbsTime(10*time.Minute)-clock.Now()) {
+		t.Fatalf("wrong waiting time in full table: registered %t, wait %v", ok, wait)
+	}
+	clock.Run(wait)
+	if ok, _, _ := tab.Register(topicA, n, ticket); !ok {
+		t.Fatalf("registration failed after ad expiry")
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package topicindex
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var errInvalidTicket = errors.New("invalid ticket")
+
+// ticketData records the waiting time of a node for placing an ad. Tickets are
+// opaque to the registrant: they are encrypted with a key only known to the
+// topic table issuing them.
+type ticketData struct {
+	Topic   TopicID
+	Node    enode.ID
+	IP      net.IP
+	Created mclock.AbsTime // time of the first registration attempt
+	LastMod mclock.AbsTime // time of the last registration attempt
+	WaitFor time.Duration  // waiting time assigned in the last attempt
+}
+
+// matches reports whether the ticket was issued to the node for the topic.
+func (t *ticketData) matches(topic TopicID, n *enode.Node) bool {
+	return t.Topic == topic && t.Node == n.ID() && t.IP.Equal(n.IP())
+}
+
+// ticketSealer encrypts and authenticates tickets with a random key.
+type ticketSealer struct {
+	aead cipher.AEAD
+}
+
+func newTicketSealer() *ticketSealer {
+	key := make([]byte, 16)
+	if _, err := crand.Read(key); err != nil {
+		panic("can't generate ticket key: " + err.Error())
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("can't create block cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("can't create GCM: " + err.Error())
+	}
+	return &ticketSealer{aead: aead}
+}
+
+// seal encodes and encrypts a ticket.
+func (s *ticketSealer) seal(t *ticketData) []byte {
+	enc, err := rlp.EncodeToBytes([]interface{}{t.Topic, t.Node, t.IP, uint64(t.Created), uint64(t.LastMod), uint64(t.WaitFor)})
+	if err != nil {
+		panic("can't encode ticket: " + err.Error())
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	crand.Read(nonce)
+	return s.aead.Seal(nonce, nonce, enc, nil)
+}
+
+// open decrypts and decodes a ticket.
+func (s *ticketSealer) open(blob []byte) (*ticketData, error) {
+	if len(blob) < s.aead.NonceSize() {
+		return nil, errInvalidTicket
+	}
+	nonce, ciphertext := blob[:s.aead.NonceSize()], blob[s.aead.NonceSize():]
+	enc, err := s.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errInvalidTicket
+	}
+	var dec struct {
+		Topic                     TopicID
+		Node                      enode.ID
+		IP                        net.IP
+		Created, LastMod, WaitFor uint64
+	}
+	if err := rlp.DecodeBytes(enc, &dec); err != nil {
+		return nil, errInvalidTicket
+	}
+	return &ticketData{
+		Topic:   dec.Topic,
+		Node:    dec.Node,
+		IP:      dec.IP,
+		Created: mclock.AbsTime(dec.Created),
+		LastMod: mclock.AbsTime(dec.LastMod),
+		WaitFor: time.Duration(dec.WaitFor),
+	}, nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover/topicindex"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	topicRegistrarLimit   = bucketSize       // max nodes registered at concurrently, per topic
+	topicRegLookupDelay   = 1 * time.Minute  // time between registrar lookups
+	topicMaxTicketWait    = 20 * time.Minute // registrars assigning longer waiting times are dropped
+	topicSearchRoundDelay = 10 * time.Second // time between topic search rounds
+)
+
+// topicReg is a running topic registration.
+type topicReg struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RegisterTopic starts advertising the local node for the given topic. Ads are
+// placed in the topic tables of the nodes closest to the topic ID, and are renewed
+// until StopRegisterTopic is called.
+func (t *UDPv5) RegisterTopic(topic topicindex.TopicID) {
+	t.topicLock.Lock()
+	defer t.topicLock.Unlock()
+
+	if t.topicRegs == nil || t.topicRegs[topic] != nil {
+		return // closed or already registering
+	}
+	ctx, cancel := context.WithCancel(t.closeCtx)
+	reg := &topicReg{cancel: cancel, done: make(chan struct{})}
+	t.topicRegs[topic] = reg
+	go t.topicRegLoop(ctx, topic, reg.done)
+}
+
+// StopRegisterTopic stops advertising the local node for the given topic. Ads which
+// are already placed remain in the topic tables until they expire.
+func (t *UDPv5) StopRegisterTopic(topic topicindex.TopicID) {
+	t.topicLock.Lock()
+	reg := t.topicRegs[topic]
+	delete(t.topicRegs, topic)
+	t.topicLock.Unlock()
+
+	if reg != nil {
+		reg.cancel()
+		<-reg.done
+	}
+}
+
+// stopTopicRegs ends all topic registrations. It is called on shutdown.
+func (t *UDPv5) stopTopicRegs() {
+	t.topicLock.Lock()
+	regs := t.topicRegs
+	t.topicRegs = nil
+	t.topicLock.Unlock()
+
+	for _, reg := range regs {
+		reg.cancel()
+		<-reg.done
+	}
+}
+
+// topicRegLoop looks up the nodes closest to the topic and registers at them.
+func (t *UDPv5) topicRegLoop(ctx context.Context, topic topicindex.TopicID, done chan struct{}) {
+	defer close(done)
+
+	var (
+		active   = make(map[enode.ID]struct{})
+		finished = make(chan enode.ID)
+		lookup   = t.clock.NewTimer(0)
+	)
+	defer lookup.Stop()
+
+loop:
+	for {
+		select {
+		case <-lookup.C():
+			for _, n := range t.newLookup(ctx, enode.ID(topic)).run() {
+				if len(active) >= topicRegistrarLimit {
+					break
+				}
+				if _, ok := active[n.ID()]; ok {
+					continue
+				}
+				active[n.ID()] = struct{}{}
+				go t.registerAt(ctx, n, topic, finished)
+			}
+			lookup.Reset(topicRegLookupDelay)
+		case id := <-finished:
+			delete(active, id)
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	for len(active) > 0 {
+		delete(active, <-finished)
+	}
+}
+
+// registerAt keeps an ad for the topic placed at a single node. It returns when
+// the node fails to respond or assigns excessive waiting times.
+func (t *UDPv5) registerAt(ctx context.Context, n *enode.Node, topic topicindex.TopicID, finished chan<- enode.ID) {
+	defer func() { finished <- n.ID() }()
+
+	var ticket []byte
+	for {
+		req := &v5wire.Regtopic{Topic: topic, ENR: t.localNode.Node().Record(), Ticket: ticket}
+		resp := t.call(n, v5wire.TicketMsg, req)
+
+		var wait time.Duration
+		select {
+		case p := <-resp.ch:
+			switch p := p.(type) {
+			case *v5wire.Ticket:
+				ticket, wait = p.Ticket, time.Duration(p.WaitTime)*time.Millisecond
+			case *v5wire.Regconfirmation:
+				ticket, wait = nil, t.topicTable.AdLifetime()
+				t.log.Trace("Placed topic ad", "topic", topic, "id", n.ID())
+			}
+		case err := <-resp.err:
+			t.callDone(resp)
+			t.log.Trace("Topic registration failed", "topic", topic, "id", n.ID(), "err", err)
+			return
+		}
+		t.callDone(resp)
+
+		if wait > topicMaxTicketWait {
+			t.log.Trace("Dropping topic registrar", "topic", topic, "id", n.ID(), "wait", wait)
+			return
+		}
+		timer := t.clock.NewTimer(wait)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// TopicSearch returns an iterator over the nodes advertised for the given topic.
+// The nodes closest to the topic ID are found through lookups, and asked for the
+// ads in their topic table. Search rounds are repeated until the iterator is closed.
+func (t *UDPv5) TopicSearch(topic topicindex.TopicID) enode.Iterator {
+	it := &topicSearchIterator{t: t, topic: topic}
+	it.lookups = newLookupIterator(t.closeCtx, it.nextLookup)
+	return it
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic topicindex.TopicID) ([]*enode.Node, error) {
+	resp := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic})
+	return t.waitForNodes(resp, nil)
+}
+
+// topicSearchIterator queries the nodes found by lookups toward the topic ID.
+type topicSearchIterator struct {
+	t       *UDPv5
+	topic   topicindex.TopicID
+	lookups *lookupIterator
+	rounds  int
+
+	queried map[enode.ID]struct{} // nodes asked in the current round
+	seen    map[enode.ID]struct{} // results returned in the current round
+	buffer  []*enode.Node
+	cur     *enode.Node
+}
+
+// nextLookup starts a search round. Rounds after the first are delayed, and
+// return the ads found in the previous rounds again.
+func (it *topicSearchIterator) nextLookup(ctx context.Context) *lookup {
+	if it.rounds > 0 {
+		timer := it.t.clock.NewTimer(topicSearchRoundDelay)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	it.rounds++
+	it.queried = make(map[enode.ID]struct{})
+	it.seen = make(map[enode.ID]struct{})
+	return it.t.newLookup(ctx, enode.ID(it.topic))
+}
+
+// Node returns the current node.
+func (it *topicSearchIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Next moves to the next advertised node.
+func (it *topicSearchIterator) Next() bool {
+	for len(it.buffer) == 0 {
+		if !it.lookups.Next() {
+			it.cur = nil
+			return false
+		}
+		n := it.lookups.Node()
+		if _, ok := it.queried[n.ID()]; ok {
+			continue
+		}
+		it.queried[n.ID()] = struct{}{}
+		nodes, _ := it.t.topicQuery(n, it.topic)
+		for _, ad := range nodes {
+			if _, ok := it.seen[ad.ID()]; ok || ad.ID() == it.t.Self().ID() {
+				continue
+			}
+			it.seen[ad.ID()] = struct{}{}
+			it.buffer = append(it.buffer, ad)
+		}
+	}
+	it.cur, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// Close ends the iterator.
+func (it *topicSearchIterator) Close() {
+	it.lookups.Close()
+}
+
+// handleRegtopic places an ad for the sender, or issues a ticket if it needs to wait.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	if p.ENR == nil {
+		t.log.Debug("Missing record in "+p.Name(), "id", fromID, "addr", fromAddr)
+		return
+	}
+	n, err := enode.New(t.validSchemes, p.ENR)
+	if err != nil {
+		t.log.Debug("Invalid record in "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	if n.ID() != fromID || !n.IP().Equal(fromAddr.IP) || n.UDP() != fromAddr.Port {
+		t.log.Debug("Record in "+p.Name()+" does not match sender", "id", fromID, "addr", fromAddr)
+		return
+	}
+	registered, ticket, wait := t.topicTable.Register(topicindex.TopicID(p.Topic), n, p.Ticket)
+	if registered {
+		t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Topic: p.Topic})
+		return
+	}
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{
+		ReqID:    p.ReqID,
+		Ticket:   ticket,
+		WaitTime: uint(wait / time.Millisecond),
+	})
+}
+
+// handleTopicQuery returns the nodes advertised for the topic to the requester.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	var nodes []*enode.Node
+	for _, n := range t.topicTable.Nodes(topicindex.TopicID(p.Topic)) {
+		if netutil.CheckRelayIP(fromAddr.IP, n.IP()) != nil {
+			continue
+		}
+		if nodes = append(nodes, n); len(nodes) >= findnodeResultLimit {
+			break
+		}
+	}
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
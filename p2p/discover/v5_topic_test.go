@@ -0,0 +1,122 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/discover/topicindex"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// This test checks that incoming REGTOPIC and TOPICQUERY calls are handled correctly.
+func TestUDPv5_topicHandling(t *testing.T) {
+	t.Parallel()
+	test := newUDPV5Test(t)
+	defer test.close()
+
+	var (
+		topic     = topicindex.NewTopicID("foo")
+		remote    = test.getNode(test.remotekey, test.remoteaddr).Node()
+		otherKey  = newkey()
+		otherAddr = &net.UDPAddr{IP: net.IP{10, 0, 1, 100}, Port: 30303}
+		other     = test.getNode(otherKey, otherAddr).Node()
+		ticket    []byte
+	)
+	// Records not matching the sender are rejected.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("0"), Topic: topic, ENR: other.Record()})
+
+	// The table is empty, the ad is placed right away.
+	test.packetIn(&v5wire.Regtopic{ReqID: []byte("1"), Topic: topic, ENR: remote.Record()})
+	test.waitPacketOut(func(p *v5wire.Regconfirmation, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("1")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.Topic != topic {
+			t.Errorf("wrong topic in response: %x", p.Topic)
+		}
+	})
+
+	// Registrants from the same subnet need to wait.
+	test.packetInFrom(otherKey, otherAddr, &v5wire.Regtopic{ReqID: []byte("2"), Topic: topic, ENR: other.Record()})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {
+		if !bytes.Equal(p.ReqID, []byte("2")) {
+			t.Error("wrong request ID in response:", p.ReqID)
+		}
+		if p.WaitTime == 0 || len(p.Ticket) == 0 {
+			t.Errorf("invalid ticket: wait time %d, ticket %x", p.WaitTime, p.Ticket)
+		}
+		ticket = p.Ticket
+	})
+	test.packetInFrom(otherKey, otherAddr, &v5wire.Regtopic{ReqID: []byte("3"), Topic: topic, ENR: other.Record(), Ticket: ticket})
+	test.waitPacketOut(func(p *v5wire.Ticket, addr *net.UDPAddr, _ v5wire.Nonce) {})
+	if n := test.udp.topicTable.Len(); n != 1 {
+		t.Fatalf("wrong number of ads: %d", n)
+	}
+
+	// The ad is returned by TOPICQUERY.
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("4"), Topic: topic})
+	test.expectNodes([]byte("4"), 1, []*enode.Node{remote})
+	test.packetIn(&v5wire.TopicQuery{ReqID: []byte("5"), Topic: topicindex.NewTopicID("bar")})
+	test.expectNodes([]byte("5"), 1, nil)
+}
+
+// Real sockets, real crypto: this test checks that nodes advertised for a topic
+// can be found through topic search.
+func TestUDPv5_topicE2E(t *testing.T) {
+	t.Parallel()
+
+	const N = 5
+	var nodes []*UDPv5
+	for i := 0; i < N; i++ {
+		var cfg Config
+		if len(nodes) > 0 {
+			cfg.Bootnodes = []*enode.Node{nodes[0].Self()}
+		}
+		node := startLocalhostV5(t, cfg)
+		nodes = append(nodes, node)
+		defer node.Close()
+	}
+	topic := topicindex.NewTopicID("foo")
+	advertiser, searcher := nodes[1], nodes[N-1]
+	advertiser.RegisterTopic(topic)
+	defer advertiser.StopRegisterTopic(topic)
+
+	// Wait for the ad to be placed before searching.
+	for placed := false; !placed; time.Sleep(10 * time.Millisecond) {
+		for _, n := range nodes {
+			placed = placed || n.topicTable.Len() > 0
+		}
+	}
+
+	it := searcher.TopicSearch(topic)
+	defer it.Close()
+	go func() {
+		time.Sleep(10 * time.Second)
+		it.Close()
+	}()
+	for it.Next() {
+		if it.Node().ID() == advertiser.Self().ID() {
+			return
+		}
+	}
+	t.Fatal("advertised node not found")
+}
@@ -35,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 // To regenerate discv5 test vectors, run
@@ -282,6 +283,34 @@ func TestDecodeErrorsV5(t *testing.T) {
 	// - check invalid handshake data sizes
 }
 
+// This test checks that topic advertisement messages survive encoding.
+func TestTopicMessages(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	topic := [32]byte{1, 2, 3}
+	msgs := []Packet{
+		&Regtopic{ReqID: []byte("reqid"), Topic: topic, ENR: net.nodeA.n().Record(), Ticket: []byte("ticket")},
+		&Ticket{ReqID: []byte("reqid"), Ticket: []byte("ticket"), WaitTime: 1500},
+		&Regconfirmation{ReqID: []byte("reqid"), Topic: topic},
+		&TopicQuery{ReqID: []byte("reqid"), Topic: topic},
+	}
+	for _, msg := range msgs {
+		enc, err := rlp.EncodeToBytes(msg)
+		if err != nil {
+			t.Fatalf("%s: encoding failed: %v", msg.Name(), err)
+		}
+		dec, err := DecodeMessage(msg.Kind(), enc)
+		if err != nil {
+			t.Fatalf("%s: decoding failed: %v", msg.Name(), err)
+		}
+		if dec.Kind() != msg.Kind() || !bytes.Equal(dec.RequestID(), msg.RequestID()) {
+			t.Fatalf("%s: decoded message mismatch: %s", msg.Name(), spew.Sdump(dec))
+		}
+	}
+}
+
 // This test checks that all test vectors can be decoded.
 func TestTestVectorsV5(t *testing.T) {
 	var (
@@ -21,8 +21,10 @@ import (
 	"crypto/rand"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"net"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -40,6 +42,7 @@ const (
 	dbVersionKey   = "version" // Version of the database to flush if changes
 	dbNodePrefix   = "n:"      // Identifier to prefix node entries with
 	dbLocalPrefix  = "local:"
+	dbPeerPrefix   = "peer:"
 	dbDiscoverRoot = "v4"
 	dbDiscv5Root   = "v5"
 
@@ -53,11 +56,20 @@ const (
 	// Local information is keyed by ID only, the full key is "local:<ID>:seq".
 	// Use localItemKey to create those keys.
 	dbLocalSeq = "seq"
+
+	// Peer reputation is keyed by ID only, the full key is "peer:<ID>:score".
+	// Use peerItemKey to create those keys.
+	dbPeerRecord  = "enr"
+	dbPeerScore   = "score"
+	dbPeerLatency = "latency"
+	dbPeerSeen    = "seen"
+	dbPeerBan     = "ban"
 )
 
 const (
-	dbNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
+	dbNodeExpiration = 24 * time.Hour     // Time after which an unseen node should be dropped.
+	dbPeerExpiration = 7 * 24 * time.Hour // Time after which the reputation of an unseen peer is dropped.
+	dbCleanupCycle   = time.Hour          // Time period for running the expiration task.
 	dbVersion        = 9
 )
 
@@ -195,6 +207,24 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// peerItemKey returns the key of a peer reputation item.
+func peerItemKey(id ID, field string) []byte {
+	key := append([]byte(dbPeerPrefix), id[:]...)
+	key = append(key, ':')
+	key = append(key, field...)
+	return key
+}
+
+// splitPeerItemKey returns the components of a key created by peerItemKey.
+func splitPeerItemKey(key []byte) (id ID, field string) {
+	if !bytes.HasPrefix(key, []byte(dbPeerPrefix)) || len(key) < len(dbPeerPrefix)+len(id)+1 {
+		return ID{}, ""
+	}
+	item := key[len(dbPeerPrefix):]
+	copy(id[:], item[:len(id)])
+	return id, string(item[len(id)+1:])
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -315,6 +345,7 @@ func (db *DB) expirer() {
 		select {
 		case <-tick.C:
 			db.expireNodes()
+			db.expirePeers()
 		case <-db.quit:
 			return
 		}
@@ -360,6 +391,29 @@ func (db *DB) expireNodes() {
 	}
 }
 
+// expirePeers deletes the reputation of all peers that have not been connected
+// for some time, unless they are still banned.
+func (db *DB) expirePeers() {
+	var (
+		now       = time.Now()
+		threshold = now.Add(-dbPeerExpiration)
+		ids       = make(map[ID]struct{})
+	)
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbPeerPrefix)), nil)
+	for it.Next() {
+		id, _ := splitPeerItemKey(it.Key())
+		ids[id] = struct{}{}
+	}
+	it.Release()
+
+	for id := range ids {
+		seen := time.Unix(db.fetchInt64(peerItemKey(id, dbPeerSeen)), 0)
+		if seen.Before(threshold) && !db.BannedUntil(id).After(now) {
+			deleteRange(db.lvl, peerItemKey(id, ""))
+		}
+	}
+}
+
 // LastPingReceived retrieves the time of the last ping packet received from
 // a remote node.
 func (db *DB) LastPingReceived(id ID, ip net.IP) time.Time {
@@ -427,6 +481,95 @@ func (db *DB) UpdateFindFailsV5(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(v5Key(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// PeerScore retrieves the reputation score of a peer.
+func (db *DB) PeerScore(id ID) float64 {
+	return math.Float64frombits(db.fetchUint64(peerItemKey(id, dbPeerScore)))
+}
+
+// UpdatePeerScore stores the reputation score of a peer. The node record is stored
+// along with the score, making the peer available to QueryGoodPeers.
+func (db *DB) UpdatePeerScore(n *Node, score float64) error {
+	blob, err := rlp.EncodeToBytes(&n.r)
+	if err != nil {
+		return err
+	}
+	id := n.ID()
+	if err := db.lvl.Put(peerItemKey(id, dbPeerRecord), blob, nil); err != nil {
+		return err
+	}
+	if err := db.storeInt64(peerItemKey(id, dbPeerSeen), time.Now().Unix()); err != nil {
+		return err
+	}
+	return db.storeUint64(peerItemKey(id, dbPeerScore), math.Float64bits(score))
+}
+
+// PeerLatencyScore retrieves the latency score of a peer.
+func (db *DB) PeerLatencyScore(id ID) float64 {
+	return math.Float64frombits(db.fetchUint64(peerItemKey(id, dbPeerLatency)))
+}
+
+// UpdatePeerLatencyScore stores the latency score of a peer. It only affects the
+// order of the peers returned by QueryGoodPeers.
+func (db *DB) UpdatePeerLatencyScore(id ID, score float64) error {
+	return db.storeUint64(peerItemKey(id, dbPeerLatency), math.Float64bits(score))
+}
+
+// BannedUntil retrieves the time at which the ban of a peer ends. The zero
+// time is returned for peers that were never banned.
+func (db *DB) BannedUntil(id ID) time.Time {
+	if until := db.fetchInt64(peerItemKey(id, dbPeerBan)); until > 0 {
+		return time.Unix(until, 0)
+	}
+	return time.Time{}
+}
+
+// UpdateBannedUntil stores the time at which the ban of a peer ends. Passing
+// the zero time lifts the ban.
+func (db *DB) UpdateBannedUntil(id ID, until time.Time) error {
+	if until.IsZero() {
+		return db.lvl.Delete(peerItemKey(id, dbPeerBan), nil)
+	}
+	return db.storeInt64(peerItemKey(id, dbPeerBan), until.Unix())
+}
+
+// QueryGoodPeers retrieves up to n nodes with positive reputation score, best
+// peers first. Peers are ordered by their reputation and latency scores combined.
+// Currently banned peers are skipped.
+func (db *DB) QueryGoodPeers(n int) []*Node {
+	type scoredNode struct {
+		node  *Node
+		score float64
+	}
+	var (
+		now   = time.Now()
+		nodes []scoredNode
+		it    = db.lvl.NewIterator(util.BytesPrefix([]byte(dbPeerPrefix)), nil)
+	)
+	defer it.Release()
+
+	for it.Next() {
+		id, field := splitPeerItemKey(it.Key())
+		if field != dbPeerRecord {
+			continue
+		}
+		score := db.PeerScore(id)
+		if score <= 0 || db.BannedUntil(id).After(now) {
+			continue
+		}
+		score += db.PeerLatencyScore(id)
+		nodes = append(nodes, scoredNode{mustDecodeNode(id[:], it.Value()), score})
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].score > nodes[j].score })
+	if len(nodes) > n {
+		nodes = nodes[:n]
+	}
+	result := make([]*Node, len(nodes))
+	for i := range nodes {
+		result[i] = nodes[i].node
+	}
+	return result
+}
+
 // localSeq retrieves the local record sequence counter, defaulting to the current
 // timestamp if no previous exists. This ensures that wiping all data associated
 // with a node (apart from its key) will not generate already used sequence nums.
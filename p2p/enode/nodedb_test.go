@@ -26,6 +26,8 @@ import (
 	"reflect"
 	"testing"
 	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
 )
 
 var keytestID = HexID("51232b8d7821617d2b29b54b81cdefb9b3e9c37d7fd5f63270bcc9e1a6f6a439")
@@ -473,3 +475,64 @@ func TestDBExpireV5(t *testing.T) {
 	db.UpdateFindFailsV5(ID{}, ip, 4)
 	db.expireNodes()
 }
+
+// This test checks the peer reputation accessors.
+func TestDBPeerReputation(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var nodes []*Node
+	for i, score := range []float64{2.5, -10, 7, 0} {
+		key, _ := crypto.GenerateKey()
+		n := NewV4(&key.PublicKey, net.IP{127, 0, 0, byte(i)}, 30303, 30303)
+		if err := db.UpdatePeerScore(n, score); err != nil {
+			t.Fatal("can't store score:", err)
+		}
+		nodes = append(nodes, n)
+	}
+	if score := db.PeerScore(nodes[0].ID()); score != 2.5 {
+		t.Fatalf("wrong score: %v", score)
+	}
+	good := db.QueryGoodPeers(10)
+	if len(good) != 2 || good[0].ID() != nodes[2].ID() || good[1].ID() != nodes[0].ID() {
+		t.Fatalf("wrong good peers: %v", good)
+	}
+
+	// The latency score reorders good peers, but doesn't make peers good.
+	db.UpdatePeerLatencyScore(nodes[0].ID(), 5)
+	db.UpdatePeerLatencyScore(nodes[3].ID(), 5)
+	if score := db.PeerLatencyScore(nodes[0].ID()); score != 5 {
+		t.Fatalf("wrong latency score: %v", score)
+	}
+	good = db.QueryGoodPeers(10)
+	if len(good) != 2 || good[0].ID() != nodes[0].ID() || good[1].ID() != nodes[2].ID() {
+		t.Fatalf("wrong good peers with latency: %v", good)
+	}
+	db.UpdatePeerLatencyScore(nodes[0].ID(), 0)
+
+	// Banned peers are not returned.
+	until := time.Now().Add(time.Hour)
+	if err := db.UpdateBannedUntil(nodes[2].ID(), until); err != nil {
+		t.Fatal("can't store ban:", err)
+	}
+	if banned := db.BannedUntil(nodes[2].ID()); banned.Unix() != until.Unix() {
+		t.Fatalf("wrong ban time: %v", banned)
+	}
+	if good := db.QueryGoodPeers(10); len(good) != 1 || good[0].ID() != nodes[0].ID() {
+		t.Fatalf("wrong good peers with ban: %v", good)
+	}
+	db.UpdateBannedUntil(nodes[2].ID(), time.Time{})
+	if banned := db.BannedUntil(nodes[2].ID()); !banned.IsZero() {
+		t.Fatalf("ban not lifted: %v", banned)
+	}
+
+	// Reputation of peers that haven't been seen for a while is dropped.
+	db.storeInt64(peerItemKey(nodes[0].ID(), dbPeerSeen), time.Now().Add(-dbPeerExpiration-time.Hour).Unix())
+	db.expirePeers()
+	if good := db.QueryGoodPeers(10); len(good) != 1 || good[0].ID() != nodes[2].ID() {
+		t.Fatalf("wrong good peers after expiry: %v", good)
+	}
+	if score := db.PeerScore(nodes[0].ID()); score != 0 {
+		t.Fatalf("score not expired: %v", score)
+	}
+}
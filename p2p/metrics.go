@@ -19,16 +19,18 @@
 package p2p
 
 import (
+	"fmt"
 	"net"
+	"strconv"
 
 	"github.com/ethereum/go-ethereum/metrics"
 )
 
 const (
-	// ingressMeterName is the prefix of the per-packet inbound metrics.
+	// ingressMeterName is the name of the inbound traffic metrics.
 	ingressMeterName = "p2p/ingress"
 
-	// egressMeterName is the prefix of the per-packet outbound metrics.
+	// egressMeterName is the name of the outbound traffic metrics.
 	egressMeterName = "p2p/egress"
 
 	// HandleHistName is the prefix of the per-packet serving time histograms.
@@ -41,8 +43,34 @@ var (
 	egressConnectMeter  = metrics.NewRegisteredMeter("p2p/dials", nil)
 	egressTrafficMeter  = metrics.NewRegisteredMeter(egressMeterName, nil)
 	activePeerGauge     = metrics.NewRegisteredGauge("p2p/peers", nil)
+
+	// Per-packet traffic meters, labelled by protocol name, version and message
+	// code. When flattened, they keep the per-message names of the individual
+	// meters, e.g. p2p/ingress/eth/66/0x01 and p2p/ingress/eth/66/0x01/packets.
+	ingressProtoBytesMeter   = newProtoTrafficMeter(ingressMeterName+"/bytes", ingressMeterName+"/{protocol}/{version}/{code}")
+	ingressProtoPacketsMeter = newProtoTrafficMeter(ingressMeterName+"/packets", ingressMeterName+"/{protocol}/{version}/{code}/packets")
+	egressProtoBytesMeter    = newProtoTrafficMeter(egressMeterName+"/bytes", egressMeterName+"/{protocol}/{version}/{code}")
+	egressProtoPacketsMeter  = newProtoTrafficMeter(egressMeterName+"/packets", egressMeterName+"/{protocol}/{version}/{code}/packets")
 )
 
+// newProtoTrafficMeter creates a per-packet traffic meter family, named by the
+// given template when flattened.
+func newProtoTrafficMeter(name string, flatName string) metrics.LabelledMeter {
+	meter := metrics.NewRegisteredLabelledMeter(name, nil, "protocol", "version", "code")
+	meter.SetFlatName(flatName)
+	return meter
+}
+
+// meterProtoTraffic bumps the per-packet traffic meters of a subprotocol message.
+func meterProtoTraffic(bytes, packets metrics.LabelledMeter, cap Cap, code uint64, size uint32) {
+	var (
+		version = strconv.FormatUint(uint64(cap.Version), 10)
+		msgcode = fmt.Sprintf("%#02x", code)
+	)
+	bytes.With(cap.Name, version, msgcode).Mark(int64(size))
+	packets.With(cap.Name, version, msgcode).Mark(1)
+}
+
 // meteredConn is a wrapper around a net.Conn that meters both the
 // inbound and outbound network traffic.
 type meteredConn struct {
@@ -59,12 +59,21 @@ type Interface interface {
 //     "upnp"               uses the Universal Plug and Play protocol
 //     "pmp"                uses NAT-PMP with an auto-detected gateway address
 //     "pmp:192.168.0.1"    uses NAT-PMP with the given gateway address
+//     "pcp"                uses PCP with an auto-detected gateway address
+//     "pcp:192.168.0.1"    uses PCP with the given gateway address
+//     "stun:<server>"      uses the given STUN server to find the external IP
 func Parse(spec string) (Interface, error) {
 	var (
 		parts = strings.SplitN(spec, ":", 2)
 		mech  = strings.ToLower(parts[0])
 		ip    net.IP
 	)
+	if mech == "stun" {
+		if len(parts) < 2 || parts[1] == "" {
+			return nil, errors.New("missing STUN server address")
+		}
+		return STUN(parts[1]), nil
+	}
 	if len(parts) > 1 {
 		ip = net.ParseIP(parts[1])
 		if ip == nil {
@@ -85,6 +94,8 @@ func Parse(spec string) (Interface, error) {
 		return UPnP(), nil
 	case "pmp", "natpmp", "nat-pmp":
 		return PMP(ip), nil
+	case "pcp":
+		return PCP(ip), nil
 	default:
 		return nil, fmt.Errorf("unknown mechanism %q", parts[0])
 	}
@@ -94,6 +105,41 @@ const (
 	mapTimeout = 10 * time.Minute
 )
 
+// WatchExternalIP calls fn with the external IP address of m. For mechanisms
+// where the address may change at any time, like STUN, the address is checked
+// periodically and fn is called again on every change until c is closed. This
+// function is typically invoked in its own goroutine.
+func WatchExternalIP(m Interface, c <-chan struct{}, fn func(net.IP)) {
+	s, ok := m.(*stun)
+	if !ok {
+		if ip, err := m.ExternalIP(); err == nil {
+			fn(ip)
+		}
+		return
+	}
+
+	log := log.New("interface", m)
+	refresh := time.NewTimer(0)
+	defer refresh.Stop()
+	var current net.IP
+	for {
+		select {
+		case <-c:
+			return
+		case <-refresh.C:
+			ip, err := s.ExternalIP()
+			if err != nil {
+				log.Debug("Couldn't get external IP", "err", err)
+			} else if !ip.Equal(current) {
+				log.Info("External IP changed", "ip", ip, "old", current)
+				current = ip
+				fn(ip)
+			}
+			refresh.Reset(s.refresh)
+		}
+	}
+}
+
 // Map adds a port mapping on m and keeps it alive until c is closed.
 // This function is typically invoked in its own goroutine.
 func Map(m Interface, c <-chan struct{}, protocol string, extport, intport int, name string) {
@@ -143,10 +189,11 @@ func (ExtIP) DeleteMapping(string, int, int) error                     { return
 func Any() Interface {
 	// TODO: attempt to discover whether the local machine has an
 	// Internet-class address. Return ExtIP in this case.
-	return startautodisc("UPnP or NAT-PMP", func() Interface {
-		found := make(chan Interface, 2)
+	return startautodisc("UPnP, NAT-PMP or PCP", func() Interface {
+		found := make(chan Interface, 3)
 		go func() { found <- discoverUPnP() }()
 		go func() { found <- discoverPMP() }()
+		go func() { found <- discoverPCP() }()
 		for i := 0; i < cap(found); i++ {
 			if c := <-found; c != nil {
 				return c
@@ -172,6 +219,16 @@ func PMP(gateway net.IP) Interface {
 	return startautodisc("NAT-PMP", discoverPMP)
 }
 
+// PCP returns a port mapper that uses the Port Control Protocol. The provided
+// gateway address should be the IP of your router. If the given gateway address
+// is nil, PCP will attempt to auto-discover the router.
+func PCP(gateway net.IP) Interface {
+	if gateway != nil {
+		return newPCP(&net.UDPAddr{IP: gateway, Port: pcpPort})
+	}
+	return startautodisc("PCP", discoverPCP)
+}
+
 // autodisc represents a port mapping mechanism that is still being
 // auto-discovered. Calls to the Interface methods on this type will
 // wait until the discovery is done and then call the method on the
@@ -0,0 +1,245 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// This file implements the MAP operation of the Port Control Protocol (RFC 6887).
+
+const (
+	pcpPort    = 5351
+	pcpVersion = 2
+	pcpOpMap   = 1
+
+	pcpRequestSize  = 60 // header (24) + MAP opcode data (36)
+	pcpResponseFlag = 0x80
+
+	// Retransmission of requests. The RFC suggests much longer timeouts, but
+	// discovery needs to be quick and gateways are on the local network.
+	pcpInitialTimeout = 250 * time.Millisecond
+	pcpAttempts       = 4
+
+	// Lifetime of the mapping created by ExternalIP.
+	pcpProbeLifetime = 30 * time.Second
+	pcpProbePort     = 9 // discard
+)
+
+var pcpResultCodes = map[byte]string{
+	1:  "UNSUPP_VERSION",
+	2:  "NOT_AUTHORIZED",
+	3:  "MALFORMED_REQUEST",
+	4:  "UNSUPP_OPCODE",
+	5:  "UNSUPP_OPTION",
+	6:  "MALFORMED_OPTION",
+	7:  "NETWORK_FAILURE",
+	8:  "NO_RESOURCES",
+	9:  "UNSUPP_PROTOCOL",
+	10: "USER_EX_QUOTA",
+	11: "CANNOT_PROVIDE_EXTERNAL",
+	12: "ADDRESS_MISMATCH",
+	13: "EXCESSIVE_REMOTE_PEERS",
+}
+
+type pcpMappingKey struct {
+	protocol byte
+	intport  uint16
+}
+
+// pcp implements the Interface using PCP MAP requests.
+type pcp struct {
+	gw *net.UDPAddr
+
+	mu     sync.Mutex
+	nonces map[pcpMappingKey][12]byte // per mapping, required for refresh and deletion
+}
+
+func newPCP(gw *net.UDPAddr) *pcp {
+	return &pcp{gw: gw, nonces: make(map[pcpMappingKey][12]byte)}
+}
+
+func (n *pcp) String() string {
+	return fmt.Sprintf("PCP(%v)", n.gw.IP)
+}
+
+// ExternalIP creates a short-lived mapping to learn the external address.
+// PCP has no dedicated operation for this.
+func (n *pcp) ExternalIP() (net.IP, error) {
+	ip, err := n.mapPort("UDP", 0, pcpProbePort, pcpProbeLifetime)
+	if err != nil {
+		return nil, err
+	}
+	n.DeleteMapping("UDP", 0, pcpProbePort)
+	return ip, nil
+}
+
+func (n *pcp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) error {
+	if lifetime <= 0 {
+		return fmt.Errorf("lifetime must not be <= 0")
+	}
+	_, err := n.mapPort(protocol, extport, intport, lifetime)
+	return err
+}
+
+func (n *pcp) DeleteMapping(protocol string, extport, intport int) error {
+	// A mapping is deleted by requesting a lifetime of zero.
+	_, err := n.mapPort(protocol, 0, intport, 0)
+	if err == nil {
+		proto, _ := pcpProtocol(protocol)
+		n.mu.Lock()
+		delete(n.nonces, pcpMappingKey{proto, uint16(intport)})
+		n.mu.Unlock()
+	}
+	return err
+}
+
+// mapPort sends a MAP request and returns the assigned external IP.
+func (n *pcp) mapPort(protocol string, extport, intport int, lifetime time.Duration) (net.IP, error) {
+	proto, err := pcpProtocol(protocol)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialUDP("udp", nil, n.gw)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, pcpRequestSize)
+	req[0] = pcpVersion
+	req[1] = pcpOpMap
+	binary.BigEndian.PutUint32(req[4:], uint32(lifetime/time.Second))
+	copy(req[8:24], conn.LocalAddr().(*net.UDPAddr).IP.To16())
+	nonce := n.nonce(pcpMappingKey{proto, uint16(intport)})
+	copy(req[24:36], nonce[:])
+	req[36] = proto
+	binary.BigEndian.PutUint16(req[40:], uint16(intport))
+	binary.BigEndian.PutUint16(req[42:], uint16(extport))
+	copy(req[44:60], net.IPv6zero) // no suggested external address
+
+	resp, err := pcpRoundTrip(conn, req)
+	if err != nil {
+		return nil, err
+	}
+	ip := net.IP(resp[44:60])
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4, nil
+	}
+	return append(net.IP{}, ip...), nil
+}
+
+// nonce returns the mapping nonce for key, creating it if needed.
+func (n *pcp) nonce(key pcpMappingKey) [12]byte {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	nonce, ok := n.nonces[key]
+	if !ok {
+		rand.Read(nonce[:])
+		n.nonces[key] = nonce
+	}
+	return nonce
+}
+
+// pcpRoundTrip sends a MAP request until a matching response is received.
+func pcpRoundTrip(conn *net.UDPConn, req []byte) ([]byte, error) {
+	buf := make([]byte, 1100) // maximum PCP message size
+	timeout := pcpInitialTimeout
+	for i := 0; i < pcpAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			nbytes, err := conn.Read(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			resp := buf[:nbytes]
+			if len(resp) < pcpRequestSize || resp[1] != pcpResponseFlag|pcpOpMap {
+				continue // not a MAP response
+			}
+			if resp[0] != pcpVersion {
+				return nil, fmt.Errorf("unsupported PCP version %d", resp[0])
+			}
+			if !bytes.Equal(resp[24:36], req[24:36]) || resp[36] != req[36] || !bytes.Equal(resp[40:42], req[40:42]) {
+				continue // response for another mapping
+			}
+			if code := resp[3]; code != 0 {
+				if name, ok := pcpResultCodes[code]; ok {
+					return nil, fmt.Errorf("PCP error %s", name)
+				}
+				return nil, fmt.Errorf("PCP error %d", code)
+			}
+			return resp, nil
+		}
+		timeout *= 2
+	}
+	return nil, errors.New("PCP request timed out")
+}
+
+func pcpProtocol(protocol string) (byte, error) {
+	switch strings.ToLower(protocol) {
+	case "tcp":
+		return 6, nil
+	case "udp":
+		return 17, nil
+	default:
+		return 0, fmt.Errorf("unsupported protocol %q", protocol)
+	}
+}
+
+func discoverPCP() Interface {
+	// Try all potential gateways and return the one that responds first.
+	gws := potentialGateways()
+	found := make(chan *pcp, len(gws))
+	for i := range gws {
+		gw := &net.UDPAddr{IP: gws[i], Port: pcpPort}
+		go func() {
+			c := newPCP(gw)
+			if _, err := c.ExternalIP(); err != nil {
+				found <- nil
+			} else {
+				found <- c
+			}
+		}()
+	}
+	timeout := time.NewTimer(1 * time.Second)
+	defer timeout.Stop()
+	for range gws {
+		select {
+		case c := <-found:
+			if c != nil {
+				return c
+			}
+		case <-timeout.C:
+			return nil
+		}
+	}
+	return nil
+}
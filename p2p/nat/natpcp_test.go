@@ -0,0 +1,180 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPCP(t *testing.T) {
+	srv := newFakePCPServer(t, net.IP{33, 44, 55, 66})
+	defer srv.close()
+	n := newPCP(srv.addr())
+
+	ip, err := n.ExternalIP()
+	if err != nil {
+		t.Fatal("ExternalIP error:", err)
+	}
+	if !ip.Equal(net.IP{33, 44, 55, 66}) {
+		t.Fatalf("wrong external IP %v", ip)
+	}
+	if len(srv.mappings()) != 0 {
+		t.Fatalf("mapping of ExternalIP not deleted: %v", srv.mappings())
+	}
+
+	if err := n.AddMapping("TCP", 30303, 30304, "test", 10*time.Minute); err != nil {
+		t.Fatal("AddMapping error:", err)
+	}
+	want := fakePCPMapping{protocol: 6, intport: 30304, extport: 30303, lifetime: 600}
+	if m := srv.mappings(); len(m) != 1 || m[0] != want {
+		t.Fatalf("wrong mappings after add: %+v", m)
+	}
+	// Refreshing the mapping must use the same nonce.
+	if err := n.AddMapping("TCP", 30303, 30304, "test", 10*time.Minute); err != nil {
+		t.Fatal("AddMapping error on refresh:", err)
+	}
+	if err := n.DeleteMapping("TCP", 30303, 30304); err != nil {
+		t.Fatal("DeleteMapping error:", err)
+	}
+	if len(srv.mappings()) != 0 {
+		t.Fatalf("mapping not deleted: %v", srv.mappings())
+	}
+}
+
+func TestPCPError(t *testing.T) {
+	srv := newFakePCPServer(t, net.IP{33, 44, 55, 66})
+	defer srv.close()
+	srv.mu.Lock()
+	srv.result = 2 // NOT_AUTHORIZED
+	srv.mu.Unlock()
+
+	n := newPCP(srv.addr())
+	err := n.AddMapping("UDP", 30303, 30303, "test", 10*time.Minute)
+	if err == nil || err.Error() != "PCP error NOT_AUTHORIZED" {
+		t.Fatalf("wrong error: %v", err)
+	}
+}
+
+type fakePCPMapping struct {
+	protocol byte
+	intport  uint16
+	extport  uint16
+	lifetime uint32
+}
+
+// fakePCPServer implements the MAP operation of PCP.
+type fakePCPServer struct {
+	t      *testing.T
+	conn   *net.UDPConn
+	extIP  net.IP
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	result byte
+	maps   map[[12]byte]fakePCPMapping
+}
+
+func newFakePCPServer(t *testing.T, extIP net.IP) *fakePCPServer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakePCPServer{t: t, conn: conn, extIP: extIP, maps: make(map[[12]byte]fakePCPMapping)}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *fakePCPServer) addr() *net.UDPAddr {
+	return s.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (s *fakePCPServer) close() {
+	s.conn.Close()
+	s.wg.Wait()
+}
+
+func (s *fakePCPServer) mappings() []fakePCPMapping {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list []fakePCPMapping
+	for _, m := range s.maps {
+		list = append(list, m)
+	}
+	return list
+}
+
+func (s *fakePCPServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 1100)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		if n != pcpRequestSize || req[0] != pcpVersion || req[1] != pcpOpMap {
+			s.t.Errorf("invalid request %x", req)
+			continue
+		}
+		if !net.IP(req[8:24]).Equal(from.IP) {
+			s.t.Errorf("client IP %v doesn't match source address %v", net.IP(req[8:24]), from.IP)
+		}
+		var nonce [12]byte
+		copy(nonce[:], req[24:36])
+		m := fakePCPMapping{
+			protocol: req[36],
+			intport:  binary.BigEndian.Uint16(req[40:]),
+			extport:  binary.BigEndian.Uint16(req[42:]),
+			lifetime: binary.BigEndian.Uint32(req[4:]),
+		}
+
+		s.mu.Lock()
+		result := s.result
+		if result == 0 {
+			for other, om := range s.maps {
+				if other != nonce && om.protocol == m.protocol && om.intport == m.intport {
+					result = 2 // NOT_AUTHORIZED: mapping owned by different nonce
+				}
+			}
+		}
+		if result == 0 {
+			if m.lifetime == 0 {
+				delete(s.maps, nonce)
+			} else {
+				if m.extport == 0 {
+					m.extport = m.intport
+				}
+				s.maps[nonce] = m
+			}
+		}
+		s.mu.Unlock()
+
+		resp := make([]byte, pcpRequestSize)
+		resp[0] = pcpVersion
+		resp[1] = pcpResponseFlag | pcpOpMap
+		resp[3] = result
+		binary.BigEndian.PutUint32(resp[4:], m.lifetime)
+		copy(resp[24:44], req[24:44])
+		binary.BigEndian.PutUint16(resp[42:], m.extport)
+		copy(resp[44:60], s.extIP.To16())
+		s.conn.WriteToUDP(resp, from)
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// This file implements a client for the STUN Binding method (RFC 5389).
+
+const (
+	stunDefaultPort = "3478"
+	stunHeaderSize  = 20
+	stunMagicCookie = 0x2112A442
+
+	stunBindingRequest  = 0x0001
+	stunBindingResponse = 0x0101
+	stunBindingError    = 0x0111
+
+	stunAttrMappedAddress    = 0x0001
+	stunAttrXorMappedAddress = 0x0020
+
+	stunInitialTimeout = 500 * time.Millisecond
+	stunAttempts       = 3
+
+	// stunRefreshInterval is the default interval of external IP checks.
+	stunRefreshInterval = 5 * time.Minute
+)
+
+// stun learns the external IP address from a STUN server. It can't create
+// port mappings, ports must be forwarded manually.
+type stun struct {
+	server  string
+	refresh time.Duration
+}
+
+// STUN returns a NAT interface which queries the given STUN server for the
+// external IP address. The server address is host:port, or just the host name
+// when the server runs on the default port.
+func STUN(server string) Interface {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, stunDefaultPort)
+	}
+	return &stun{server: server, refresh: stunRefreshInterval}
+}
+
+func (n *stun) String() string {
+	return fmt.Sprintf("STUN(%s)", n.server)
+}
+
+// These do nothing.
+
+func (*stun) AddMapping(string, int, int, string, time.Duration) error { return nil }
+func (*stun) DeleteMapping(string, int, int) error                     { return nil }
+
+func (n *stun) ExternalIP() (net.IP, error) {
+	conn, err := net.Dial("udp", n.server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	req := make([]byte, stunHeaderSize)
+	binary.BigEndian.PutUint16(req[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(req[4:], stunMagicCookie)
+	rand.Read(req[8:20])
+
+	buf := make([]byte, 1500)
+	timeout := stunInitialTimeout
+	for i := 0; i < stunAttempts; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			nbytes, err := conn.Read(buf)
+			if err != nil {
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			resp := buf[:nbytes]
+			if len(resp) < stunHeaderSize || !bytes.Equal(resp[4:20], req[4:20]) {
+				continue // not a response to our request
+			}
+			return parseSTUNResponse(resp)
+		}
+		timeout *= 2
+	}
+	return nil, errors.New("STUN request timed out")
+}
+
+// parseSTUNResponse returns the mapped address contained in a Binding response.
+func parseSTUNResponse(resp []byte) (net.IP, error) {
+	switch binary.BigEndian.Uint16(resp[0:]) {
+	case stunBindingResponse:
+	case stunBindingError:
+		return nil, errors.New("STUN server returned error response")
+	default:
+		return nil, errors.New("invalid STUN response type")
+	}
+	length := int(binary.BigEndian.Uint16(resp[2:]))
+	if stunHeaderSize+length > len(resp) {
+		return nil, errors.New("truncated STUN response")
+	}
+	var (
+		attrs  = resp[stunHeaderSize : stunHeaderSize+length]
+		mapped net.IP
+	)
+	for len(attrs) >= 4 {
+		typ := binary.BigEndian.Uint16(attrs[0:])
+		alen := int(binary.BigEndian.Uint16(attrs[2:]))
+		if 4+alen > len(attrs) {
+			return nil, errors.New("truncated STUN attribute")
+		}
+		value := attrs[4 : 4+alen]
+		switch typ {
+		case stunAttrXorMappedAddress:
+			// XOR-MAPPED-ADDRESS takes precedence, some NATs rewrite the
+			// plain address in transit.
+			return decodeSTUNAddress(value, resp[4:20])
+		case stunAttrMappedAddress:
+			ip, err := decodeSTUNAddress(value, nil)
+			if err != nil {
+				return nil, err
+			}
+			mapped = ip
+		}
+		// Attributes are padded to a multiple of four bytes.
+		next := 4 + (alen+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	if mapped == nil {
+		return nil, errors.New("no mapped address in STUN response")
+	}
+	return mapped, nil
+}
+
+// decodeSTUNAddress decodes the value of an address attribute. The address is
+// obfuscated with key (the magic cookie and transaction ID) if it's non-nil.
+func decodeSTUNAddress(value, key []byte) (net.IP, error) {
+	if len(value) < 4 {
+		return nil, errors.New("invalid STUN address attribute")
+	}
+	var ip net.IP
+	switch family := value[1]; {
+	case family == 0x01 && len(value) == 8:
+		ip = make(net.IP, 4)
+	case family == 0x02 && len(value) == 20:
+		ip = make(net.IP, 16)
+	default:
+		return nil, errors.New("invalid STUN address attribute")
+	}
+	copy(ip, value[4:])
+	if key != nil {
+		for i := range ip {
+			ip[i] ^= key[i]
+		}
+	}
+	return ip, nil
+}
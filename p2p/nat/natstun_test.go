@@ -0,0 +1,165 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package nat
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSTUN(t *testing.T) {
+	for _, ip := range []net.IP{{33, 44, 55, 66}, net.ParseIP("2001:db8::1")} {
+		srv := newFakeSTUNServer(t, ip)
+		n := STUN(srv.addr())
+		have, err := n.ExternalIP()
+		srv.close()
+		if err != nil {
+			t.Fatalf("%v: ExternalIP error: %v", ip, err)
+		}
+		if !have.Equal(ip) {
+			t.Errorf("wrong external IP %v, want %v", have, ip)
+		}
+	}
+}
+
+func TestSTUNWatchExternalIP(t *testing.T) {
+	srv := newFakeSTUNServer(t, net.IP{33, 44, 55, 66})
+	defer srv.close()
+	n := STUN(srv.addr()).(*stun)
+	n.refresh = 10 * time.Millisecond
+
+	var (
+		quit = make(chan struct{})
+		ips  = make(chan net.IP, 10)
+		done = make(chan struct{})
+	)
+	go func() {
+		WatchExternalIP(n, quit, func(ip net.IP) { ips <- ip })
+		close(done)
+	}()
+	if ip := <-ips; !ip.Equal(net.IP{33, 44, 55, 66}) {
+		t.Fatalf("wrong initial IP %v", ip)
+	}
+	srv.setIP(net.IP{33, 44, 55, 77})
+	if ip := <-ips; !ip.Equal(net.IP{33, 44, 55, 77}) {
+		t.Fatalf("wrong IP %v after change", ip)
+	}
+	close(quit)
+	<-done
+	if len(ips) != 0 {
+		t.Fatalf("IP reported again without change: %v", <-ips)
+	}
+}
+
+func TestParseSTUN(t *testing.T) {
+	m, err := Parse("stun:stun.example.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := m.String(); s != "STUN(stun.example.org:3478)" {
+		t.Fatalf("wrong interface %s", s)
+	}
+	if _, err := Parse("stun:"); err == nil {
+		t.Fatal("no error for missing server")
+	}
+}
+
+// fakeSTUNServer answers Binding requests with a fixed mapped address.
+type fakeSTUNServer struct {
+	conn *net.UDPConn
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	ip   net.IP
+}
+
+func newFakeSTUNServer(t *testing.T, ip net.IP) *fakeSTUNServer {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSTUNServer{conn: conn, ip: ip}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+func (s *fakeSTUNServer) addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+func (s *fakeSTUNServer) setIP(ip net.IP) {
+	s.mu.Lock()
+	s.ip = ip
+	s.mu.Unlock()
+}
+
+func (s *fakeSTUNServer) close() {
+	s.conn.Close()
+	s.wg.Wait()
+}
+
+func (s *fakeSTUNServer) serve() {
+	defer s.wg.Done()
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := s.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if n < stunHeaderSize || binary.BigEndian.Uint16(buf) != stunBindingRequest {
+			continue
+		}
+		s.mu.Lock()
+		ip := s.ip
+		s.mu.Unlock()
+
+		// Encode the address as XOR-MAPPED-ADDRESS. A bogus MAPPED-ADDRESS
+		// is added as well, to check that it is ignored.
+		family, addr := byte(0x01), ip.To4()
+		if addr == nil {
+			family, addr = 0x02, ip.To16()
+		}
+		xaddr := make([]byte, len(addr))
+		for i := range addr {
+			xaddr[i] = addr[i] ^ buf[4+i]
+		}
+		var attrs []byte
+		attrs = appendSTUNAttr(attrs, stunAttrMappedAddress, append([]byte{0, 0x01, 0, 0}, 10, 0, 0, 1))
+		attrs = appendSTUNAttr(attrs, stunAttrXorMappedAddress, append([]byte{0, family, 0, 0}, xaddr...))
+
+		resp := make([]byte, stunHeaderSize, stunHeaderSize+len(attrs))
+		binary.BigEndian.PutUint16(resp, stunBindingResponse)
+		binary.BigEndian.PutUint16(resp[2:], uint16(len(attrs)))
+		copy(resp[4:20], buf[4:20])
+		s.conn.WriteToUDP(append(resp, attrs...), from)
+	}
+}
+
+func appendSTUNAttr(b []byte, typ uint16, value []byte) []byte {
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[:], typ)
+	binary.BigEndian.PutUint16(hdr[2:], uint16(len(value)))
+	b = append(b, hdr[:]...)
+	b = append(b, value...)
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
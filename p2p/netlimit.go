@@ -0,0 +1,233 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+const (
+	// Default limits for inbound peers from a single IP address and subnet.
+	defaultMaxInboundPerIP     = 4
+	defaultMaxInboundPerSubnet = 8
+
+	// Default limit of inbound connection attempts from a single subnet
+	// within inboundThrottleTime.
+	defaultMaxInboundAttemptsPerSubnet = 16
+)
+
+// subnetOf returns the IPv4 /24 or IPv6 /64 network containing ip.
+func subnetOf(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return &net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(64, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// limitOrDefault applies the convention used by the connection limit options:
+// zero selects the default, negative values disable the limit.
+func limitOrDefault(limit, def int) int {
+	if limit == 0 {
+		return def
+	}
+	return limit
+}
+
+// checkInboundIPLimits reports whether another inbound peer from ip fits into the
+// per-IP and per-subnet limits. Trusted peers and LAN addresses are exempt.
+func (srv *Server) checkInboundIPLimits(peers map[enode.ID]*Peer, ip net.IP) error {
+	if ip == nil || netutil.IsLAN(ip) {
+		return nil
+	}
+	var (
+		subnet    = subnetOf(ip)
+		ipCount   int
+		netCount  int
+		maxIP     = limitOrDefault(srv.MaxInboundPerIP, defaultMaxInboundPerIP)
+		maxSubnet = limitOrDefault(srv.MaxInboundPerSubnet, defaultMaxInboundPerSubnet)
+	)
+	for _, p := range peers {
+		if !p.Inbound() || p.rw.is(trustedConn) {
+			continue
+		}
+		pip := netutil.AddrIP(p.RemoteAddr())
+		if pip == nil {
+			continue
+		}
+		if pip.Equal(ip) {
+			ipCount++
+		}
+		if subnet.Contains(pip) {
+			netCount++
+		}
+	}
+	if maxIP > 0 && ipCount >= maxIP {
+		return fmt.Errorf("too many peers from %v", ip)
+	}
+	if maxSubnet > 0 && netCount >= maxSubnet {
+		return fmt.Errorf("too many peers from %v", subnet)
+	}
+	return nil
+}
+
+// throttleInboundSubnet counts an inbound connection attempt against the limit
+// of its subnet. This is called by listenLoop.
+func (srv *Server) throttleInboundSubnet(ip net.IP) error {
+	limit := limitOrDefault(srv.MaxInboundAttemptsPerSubnet, defaultMaxInboundAttemptsPerSubnet)
+	if limit < 0 {
+		return nil
+	}
+	now := srv.Clock.Now()
+	srv.inboundSubnetHistory.expire(now, func(subnet string) {
+		if srv.inboundSubnetAttempts[subnet]--; srv.inboundSubnetAttempts[subnet] <= 0 {
+			delete(srv.inboundSubnetAttempts, subnet)
+		}
+	})
+	subnet := subnetOf(ip).String()
+	if srv.inboundSubnetAttempts[subnet] >= limit {
+		return fmt.Errorf("too many attempts from %s", subnet)
+	}
+	if srv.inboundSubnetAttempts == nil {
+		srv.inboundSubnetAttempts = make(map[string]int)
+	}
+	srv.inboundSubnetAttempts[subnet]++
+	srv.inboundSubnetHistory.add(subnet, now.Add(inboundThrottleTime))
+	return nil
+}
+
+// netDenylist is a set of IP networks which are not allowed to connect.
+type netDenylist struct {
+	mu   sync.RWMutex
+	nets netutil.Netlist
+}
+
+// add adds a network to the list. It returns false if the network is already listed.
+func (l *netDenylist) add(n *net.IPNet) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.index(n) >= 0 {
+		return false
+	}
+	l.nets = append(l.nets, *n)
+	return true
+}
+
+// remove removes a network from the list. It returns false if it isn't listed.
+func (l *netDenylist) remove(n *net.IPNet) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	i := l.index(n)
+	if i < 0 {
+		return false
+	}
+	l.nets = append(l.nets[:i], l.nets[i+1:]...)
+	return true
+}
+
+func (l *netDenylist) index(n *net.IPNet) int {
+	for i := range l.nets {
+		if l.nets[i].String() == n.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// contains reports whether ip is in a denied network.
+func (l *netDenylist) contains(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.nets.Contains(ip)
+}
+
+// list returns the denied networks in CIDR notation.
+func (l *netDenylist) list() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := make([]string, len(l.nets))
+	for i := range l.nets {
+		list[i] = l.nets[i].String()
+	}
+	return list
+}
+
+// parseNetwork parses a CIDR mask or a single IP address.
+func parseNetwork(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid network %q", s)
+	}
+	return n, nil
+}
+
+// DenyNetwork prevents connections to and from the given network, which can be
+// specified as a CIDR mask or a single IP address. Connected peers in the network
+// are disconnected.
+func (srv *Server) DenyNetwork(network string) error {
+	n, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	if !srv.denylist.add(n) {
+		return nil
+	}
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		for _, p := range peers {
+			if n.Contains(netutil.AddrIP(p.RemoteAddr())) {
+				p.Disconnect(DiscUselessPeer)
+			}
+		}
+	})
+	return nil
+}
+
+// AllowNetwork removes a network from the denylist.
+func (srv *Server) AllowNetwork(network string) error {
+	n, err := parseNetwork(network)
+	if err != nil {
+		return err
+	}
+	if !srv.denylist.remove(n) {
+		return fmt.Errorf("network %v is not denied", n)
+	}
+	return nil
+}
+
+// DeniedNetworks returns the networks added by DenyNetwork.
+func (srv *Server) DeniedNetworks() []string {
+	return srv.denylist.list()
+}
@@ -107,16 +107,19 @@ type Peer struct {
 	rw      *conn
 	running map[string]*protoRW
 	log     log.Logger
+	clock   mclock.Clock
 	created mclock.AbsTime
 
 	wg       sync.WaitGroup
 	protoErr chan error
 	closed   chan struct{}
 	disc     chan DiscReason
+	score    peerScore
 
 	// events receives message send / receive events if set
 	events   *event.Feed
-	testPipe *MsgPipeRW // for testing
+	capture  *MsgCapture // records messages if set
+	testPipe *MsgPipeRW  // for testing
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -221,6 +224,7 @@ func newPeer(log log.Logger, conn *conn, protocols []Protocol) *Peer {
 	p := &Peer{
 		rw:       conn,
 		running:  protomap,
+		clock:    mclock.System{},
 		created:  mclock.Now(),
 		disc:     make(chan DiscReason),
 		protoErr: make(chan error, len(protomap)+1), // protocols + pingLoop
@@ -240,7 +244,12 @@ func (p *Peer) run() (remoteRequested bool, err error) {
 		writeErr   = make(chan error, 1)
 		readErr    = make(chan error, 1)
 		reason     DiscReason // sent to the peer
+		mux        = p.multiplexed()
 	)
+	if mux {
+		// Protocols write concurrently, make room for all their results.
+		writeErr = make(chan error, len(p.running)+1)
+	}
 	p.wg.Add(2)
 	go p.readLoop(readErr)
 	go p.pingLoop()
@@ -260,7 +269,9 @@ loop:
 				reason = DiscNetworkError
 				break loop
 			}
-			writeStart <- struct{}{}
+			if !mux {
+				writeStart <- struct{}{}
+			}
 		case err = <-readErr:
 			if r, ok := err.(DiscReason); ok {
 				remoteRequested = true
@@ -285,12 +296,12 @@ loop:
 }
 
 func (p *Peer) pingLoop() {
-	ping := time.NewTimer(pingInterval)
+	ping := p.clock.NewTimer(pingInterval)
 	defer p.wg.Done()
 	defer ping.Stop()
 	for {
 		select {
-		case <-ping.C:
+		case <-ping.C():
 			if err := SendItems(p.rw, pingMsg); err != nil {
 				p.protoErr <- err
 				return
@@ -339,9 +350,7 @@ func (p *Peer) handle(msg Msg) error {
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
 		if metrics.Enabled {
-			m := fmt.Sprintf("%s/%s/%d/%#02x", ingressMeterName, proto.Name, proto.Version, msg.Code-proto.offset)
-			metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
-			metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
+			meterProtoTraffic(ingressProtoBytesMeter, ingressProtoPacketsMeter, proto.cap(), msg.Code-proto.offset, msg.meterSize)
 		}
 		select {
 		case proto.in <- msg:
@@ -397,7 +406,17 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.closed = p.closed
 		proto.wstart = writeStart
 		proto.werr = writeErr
+		if p.multiplexed() {
+			// The transport sends the messages of each protocol on a separate
+			// stream, so every protocol can have one write in progress.
+			start := make(chan struct{}, 1)
+			start <- struct{}{}
+			proto.wstart, proto.wrestart = start, start
+		}
 		var rw MsgReadWriter = proto
+		if p.capture != nil {
+			rw = newMsgRecorder(rw, p.capture, p.ID(), proto.Protocol)
+		}
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name, p.Info().Network.RemoteAddress, p.Info().Network.LocalAddress)
 		}
@@ -416,6 +435,13 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 	}
 }
 
+// multiplexed reports whether the peer's transport sends the messages of each
+// protocol independently.
+func (p *Peer) multiplexed() bool {
+	m, ok := p.rw.transport.(multiplexedTransport)
+	return ok && m.multiplexed()
+}
+
 // getProto finds the protocol responsible for handling
 // the given message code.
 func (p *Peer) getProto(code uint64) (*protoRW, error) {
@@ -429,12 +455,13 @@ func (p *Peer) getProto(code uint64) (*protoRW, error) {
 
 type protoRW struct {
 	Protocol
-	in     chan Msg        // receives read messages
-	closed <-chan struct{} // receives when peer is shutting down
-	wstart <-chan struct{} // receives when write may start
-	werr   chan<- error    // for write results
-	offset uint64
-	w      MsgWriter
+	in       chan Msg        // receives read messages
+	closed   <-chan struct{} // receives when peer is shutting down
+	wstart   <-chan struct{} // receives when write may start
+	wrestart chan<- struct{} // allows the next write after success, for multiplexed transports
+	werr     chan<- error    // for write results
+	offset   uint64
+	w        MsgWriter
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
@@ -453,7 +480,20 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 		// shutdown if the error is non-nil and unblock the next write
 		// otherwise. The calling protocol code should exit for errors
 		// as well but we don't want to rely on that.
-		rw.werr <- err
+		//
+		// Peer.run stops receiving write results once it is shutting down,
+		// so don't block if the peer was closed in the meantime.
+		select {
+		case rw.werr <- err:
+		case <-rw.closed:
+			return err
+		}
+		if err == nil && rw.wrestart != nil {
+			select {
+			case rw.wrestart <- struct{}{}:
+			case <-rw.closed:
+			}
+		}
 	case <-rw.closed:
 		err = ErrShuttingDown
 	}
@@ -487,6 +527,7 @@ type PeerInfo struct {
 		Static        bool   `json:"static"`
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
+	Score     PeerScore              `json:"score"`     // Reputation of the peer
 }
 
 // Info gathers and returns a collection of metadata known about a peer.
@@ -512,6 +553,7 @@ func (p *Peer) Info() *PeerInfo {
 	info.Network.Inbound = p.rw.is(inboundConn)
 	info.Network.Trusted = p.rw.is(trustedConn)
 	info.Network.Static = p.rw.is(staticDialedConn)
+	info.Score = p.Reputation()
 
 	// Gather all the running protocol infos
 	for _, proto := range p.running {
@@ -248,6 +248,51 @@ func TestPeerDisconnectRace(t *testing.T) {
 	}
 }
 
+// discardMuxTransport is a multiplexed transport discarding all written messages.
+type discardMuxTransport struct {
+	transport
+}
+
+func (discardMuxTransport) WriteMsg(msg Msg) error { return msg.Discard() }
+func (discardMuxTransport) multiplexed() bool      { return true }
+
+// This test checks that Peer.run returns on a multiplexed transport even if a
+// protocol keeps writing after the peer was closed.
+func TestPeerMuxWriteAfterClose(t *testing.T) {
+	var (
+		fd1, fd2 = net.Pipe()
+		key      = newkey()
+		proto    = Protocol{
+			Name:   "a",
+			Length: 1,
+			Run: func(p *Peer, rw MsgReadWriter) error {
+				for {
+					if err := SendItems(rw, 0); err == ErrShuttingDown {
+						return err
+					}
+				}
+			},
+		}
+	)
+	c := &conn{fd: fd1, node: newNode(uintID(1), ""), transport: discardMuxTransport{newTestTransport(&key.PublicKey, fd1, nil)}}
+	c.caps = []Cap{proto.cap()}
+
+	peer := newPeer(log.Root(), c, []Protocol{proto})
+	errc := make(chan error, 1)
+	go func() {
+		_, err := peer.run()
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	fd2.Close()
+
+	select {
+	case <-errc:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Peer.run did not return")
+	}
+}
+
 func TestNewPeer(t *testing.T) {
 	name := "nodename"
 	caps := []Cap{{"foo", 2}, {"bar", 3}}
@@ -0,0 +1,249 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	maxPeerScore = 100
+	minPeerScore = -100
+
+	// Peers are disconnected and banned when their score drops to peerBanScore.
+	// When the ban ends, they return with peerProbationScore.
+	peerBanScore       = -50
+	peerProbationScore = -25
+
+	// Number of historically good peers offered to the dialer on startup.
+	goodPeerDialCandidates = 30
+
+	// Bound of the latency score. It is a moving average of how well the peer
+	// meets the target round trip time, kept apart from the reputation score:
+	// it only orders the historically good peers offered to the dialer and
+	// never causes a ban.
+	maxLatencyScore = 5
+)
+
+// DefaultBanDuration is the duration of bans caused by low peer score.
+const DefaultBanDuration = time.Hour
+
+// ScoreEvent is a kind of peer behavior that affects its reputation.
+type ScoreEvent int
+
+const (
+	ScoreValidBlock        ScoreEvent = iota // peer delivered a block which was imported
+	ScoreValidTransactions                   // peer delivered transactions which were added to the pool
+	ScoreInvalidMessage                      // peer sent a malformed or invalid message
+	ScoreTimeout                             // peer didn't answer a request in time
+)
+
+var scoreEventWeights = [...]float64{
+	ScoreValidBlock:        1,
+	ScoreValidTransactions: 0.1,
+	ScoreInvalidMessage:    -20,
+	ScoreTimeout:           -5,
+}
+
+// PeerScore contains the reputation of a peer.
+type PeerScore struct {
+	Score             float64 `json:"score"`
+	ValidBlocks       uint64  `json:"validBlocks"`
+	ValidTransactions uint64  `json:"validTransactions"`
+	InvalidMessages   uint64  `json:"invalidMessages"`
+	Timeouts          uint64  `json:"timeouts"`
+	Latency           string  `json:"latency,omitempty"` // average request round trip time
+	LatencyScore      float64 `json:"latencyScore"`      // dial preference from request round trip times
+}
+
+// peerScore tracks the reputation of a connected peer.
+type peerScore struct {
+	mu      sync.Mutex
+	info    PeerScore
+	latency time.Duration
+	banned  bool // set when the score drops to peerBanScore
+}
+
+// add applies a score change. It returns true when the score drops to the ban
+// threshold for the first time.
+func (s *peerScore) add(delta float64) bool {
+	s.info.Score += delta
+	if s.info.Score > maxPeerScore {
+		s.info.Score = maxPeerScore
+	}
+	if s.info.Score < minPeerScore {
+		s.info.Score = minPeerScore
+	}
+	if s.info.Score <= peerBanScore && !s.banned {
+		s.banned = true
+		return true
+	}
+	return false
+}
+
+// event records a scoring event.
+func (s *peerScore) event(ev ScoreEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch ev {
+	case ScoreValidBlock:
+		s.info.ValidBlocks++
+	case ScoreValidTransactions:
+		s.info.ValidTransactions++
+	case ScoreInvalidMessage:
+		s.info.InvalidMessages++
+	case ScoreTimeout:
+		s.info.Timeouts++
+	}
+	return s.add(scoreEventWeights[ev])
+}
+
+// roundtrip records the round trip time of a request. The latency score moves
+// towards the deviation from the target, so old measurements decay and the score
+// stays within maxLatencyScore.
+func (s *peerScore) roundtrip(rtt, target time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.latency == 0 {
+		s.latency = rtt
+	} else {
+		s.latency = (9*s.latency + rtt) / 10
+	}
+	if target <= 0 {
+		return
+	}
+	deviation := 1 - float64(rtt)/float64(target)
+	if deviation < -1 {
+		deviation = -1
+	}
+	s.info.LatencyScore = (9*s.info.LatencyScore + maxLatencyScore*deviation) / 10
+}
+
+// set initializes the score.
+func (s *peerScore) set(score, latencyScore float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.Score = score
+	s.info.LatencyScore = latencyScore
+}
+
+// get returns the current score, the latency score, and whether the peer should
+// be banned.
+func (s *peerScore) get() (float64, float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.info.Score, s.info.LatencyScore, s.banned
+}
+
+func (s *peerScore) export() PeerScore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info := s.info
+	if s.latency > 0 {
+		info.Latency = common.PrettyDuration(s.latency).String()
+	}
+	return info
+}
+
+// Score records a scoring event for the peer. Peers whose score drops too low are
+// disconnected and banned for a while. Trusted peers are never banned.
+func (p *Peer) Score(ev ScoreEvent) {
+	if p.score.event(ev) {
+		p.dropLowScore()
+	}
+}
+
+// ScoreLatency records the time the peer took to answer a request. Peers answering
+// within the target round trip time are preferred when dialing, but slow peers
+// are never dropped for their latency.
+func (p *Peer) ScoreLatency(rtt, target time.Duration) {
+	p.score.roundtrip(rtt, target)
+}
+
+// Reputation returns the reputation of the peer.
+func (p *Peer) Reputation() PeerScore {
+	return p.score.export()
+}
+
+func (p *Peer) dropLowScore() {
+	if p.rw.is(trustedConn) {
+		return
+	}
+	score, _, _ := p.score.get()
+	p.log.Debug("Dropping peer with low score", "score", score)
+	p.Disconnect(DiscUselessPeer)
+}
+
+// isBanned reports whether the node is currently banned.
+func (srv *Server) isBanned(id enode.ID) bool {
+	return srv.nodedb.BannedUntil(id).After(time.Now())
+}
+
+// dialBanned reports whether the node is banned or in a denied network.
+func (srv *Server) dialBanned(n *enode.Node) bool {
+	return srv.isBanned(n.ID()) || srv.denylist.contains(n.IP())
+}
+
+// loadPeerScore initializes the score of a new peer from the node database.
+func (srv *Server) loadPeerScore(p *Peer) {
+	score := srv.nodedb.PeerScore(p.ID())
+	if score < peerProbationScore {
+		// The ban has ended, give the peer another chance.
+		score = peerProbationScore
+	}
+	p.score.set(score, srv.nodedb.PeerLatencyScore(p.ID()))
+}
+
+// storePeerScore persists the score of a disconnected peer, banning it if
+// the score has dropped too low.
+func (srv *Server) storePeerScore(p *Peer) {
+	score, latencyScore, banned := p.score.get()
+	if banned && !p.rw.is(trustedConn) {
+		p.log.Debug("Banning peer", "score", score, "duration", DefaultBanDuration)
+		srv.nodedb.UpdateBannedUntil(p.ID(), time.Now().Add(DefaultBanDuration))
+	}
+	srv.nodedb.UpdatePeerScore(p.Node(), score)
+	srv.nodedb.UpdatePeerLatencyScore(p.ID(), latencyScore)
+}
+
+// BanPeer disconnects the given node and prevents it from connecting for the
+// given duration. Trusted nodes are banned as well.
+func (srv *Server) BanPeer(node *enode.Node, duration time.Duration) {
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		srv.nodedb.UpdateBannedUntil(node.ID(), time.Now().Add(duration))
+		if peer := peers[node.ID()]; peer != nil {
+			peer.Disconnect(DiscUselessPeer)
+		}
+	})
+}
+
+// UnbanPeer lifts the ban of the given node and resets its score.
+func (srv *Server) UnbanPeer(node *enode.Node) {
+	srv.doPeerOp(func(peers map[enode.ID]*Peer) {
+		srv.nodedb.UpdateBannedUntil(node.ID(), time.Time{})
+		if score := srv.nodedb.PeerScore(node.ID()); score < 0 {
+			srv.nodedb.UpdatePeerScore(node, 0)
+		}
+	})
+}
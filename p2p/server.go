@@ -119,6 +119,19 @@ type Config struct {
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// MaxInboundPerIP and MaxInboundPerSubnet limit the number of inbound peers
+	// from a single IP address and a single IPv4 /24 or IPv6 /64 subnet. Trusted
+	// peers and peers on LAN addresses are exempt. Zero selects the default limit,
+	// a negative value disables the limit.
+	MaxInboundPerIP     int `toml:",omitempty"`
+	MaxInboundPerSubnet int `toml:",omitempty"`
+
+	// MaxInboundAttemptsPerSubnet limits the number of inbound connection attempts
+	// accepted from a single subnet within 30 seconds. Attempts from a single IP
+	// address are always limited to one in this period. Zero selects the default
+	// limit, a negative value disables the limit.
+	MaxInboundAttemptsPerSubnet int `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
@@ -148,14 +161,29 @@ type Config struct {
 	// If NoDial is true, the server will not dial any peers.
 	NoDial bool `toml:",omitempty"`
 
+	// MuxTransport enables the experimental multiplexed transport, which sends
+	// the messages of each subprotocol on a separate stream. Support for it is
+	// advertised in the local node record, and only nodes advertising it are
+	// dialed with it. Connections to peers without support for it use plain RLPx.
+	MuxTransport bool `toml:",omitempty"`
+
 	// If EnableMsgEvents is set then the server will emit PeerEvents
 	// whenever a message is sent to or received from a peer
 	EnableMsgEvents bool
 
+	// MsgCaptureFile, if set, is the path of a file to which all protocol
+	// messages sent to and received from peers are written. The file is
+	// rotated when it exceeds MsgCaptureFileSize bytes.
+	MsgCaptureFile     string `toml:",omitempty"`
+	MsgCaptureFileSize int64  `toml:",omitempty"`
+
 	// Logger is a custom logger to use with the p2p.Server.
 	Logger log.Logger `toml:",omitempty"`
 
-	clock mclock.Clock
+	// Clock is the time source of the server. It can be set to a simulated
+	// clock in order to run the server in virtual time. The default is the
+	// system clock.
+	Clock mclock.Clock `toml:"-"`
 }
 
 // Server manages all peer connections.
@@ -176,6 +204,7 @@ type Server struct {
 	ourHandshake *protoHandshake
 	loopWG       sync.WaitGroup // loop, listenLoop
 	peerFeed     event.Feed
+	capture      *MsgCapture
 	log          log.Logger
 
 	nodedb    *enode.DB
@@ -195,8 +224,13 @@ type Server struct {
 	checkpointPostHandshake chan *conn
 	checkpointAddPeer       chan *conn
 
+	// Networks denied through DenyNetwork.
+	denylist netDenylist
+
 	// State of run loop and listenLoop.
-	inboundHistory expHeap
+	inboundHistory        expHeap
+	inboundSubnetHistory  expHeap
+	inboundSubnetAttempts map[string]int
 }
 
 type peerOpFunc func(map[enode.ID]*Peer)
@@ -403,6 +437,9 @@ func (srv *Server) Stop() {
 	close(srv.quit)
 	srv.lock.Unlock()
 	srv.loopWG.Wait()
+	if srv.capture != nil {
+		srv.capture.Close()
+	}
 }
 
 // sharedUDPConn implements a shared connection. Write sends messages to the underlying connection while read returns
@@ -444,8 +481,8 @@ func (srv *Server) Start() (err error) {
 	if srv.log == nil {
 		srv.log = log.Root()
 	}
-	if srv.clock == nil {
-		srv.clock = mclock.System{}
+	if srv.Clock == nil {
+		srv.Clock = mclock.System{}
 	}
 	if srv.NoDial && srv.ListenAddr == "" {
 		srv.log.Warn("P2P server will be useless, neither dialing nor listening")
@@ -457,6 +494,9 @@ func (srv *Server) Start() (err error) {
 	}
 	if srv.newTransport == nil {
 		srv.newTransport = newRLPX
+		if srv.MuxTransport {
+			srv.newTransport = newMuxTransport
+		}
 	}
 	if srv.listenFunc == nil {
 		srv.listenFunc = net.Listen
@@ -482,6 +522,12 @@ func (srv *Server) Start() (err error) {
 		return err
 	}
 	srv.setupDialScheduler()
+	if srv.MsgCaptureFile != "" {
+		if srv.capture, err = NewMsgCapture(srv.MsgCaptureFile, srv.MsgCaptureFileSize); err != nil {
+			return err
+		}
+		srv.log.Info("Capturing peer messages", "file", srv.MsgCaptureFile)
+	}
 
 	srv.loopWG.Add(1)
 	go srv.run()
@@ -511,6 +557,9 @@ func (srv *Server) setupLocalNode() error {
 			srv.localnode.Set(e)
 		}
 	}
+	if srv.MuxTransport {
+		srv.localnode.Set(muxEntry(muxVersion))
+	}
 	switch srv.NAT.(type) {
 	case nil:
 		// No NAT interface, do nothing.
@@ -520,13 +569,11 @@ func (srv *Server) setupLocalNode() error {
 		srv.localnode.SetStaticIP(ip)
 	default:
 		// Ask the router about the IP. This takes a while and blocks startup,
-		// do it in the background.
+		// do it in the background. Some mechanisms keep watching the IP.
 		srv.loopWG.Add(1)
 		go func() {
 			defer srv.loopWG.Done()
-			if ip, err := srv.NAT.ExternalIP(); err == nil {
-				srv.localnode.SetStaticIP(ip)
-			}
+			nat.WatchExternalIP(srv.NAT, srv.quit, srv.localnode.SetStaticIP)
 		}()
 	}
 	return nil
@@ -544,6 +591,11 @@ func (srv *Server) setupDiscovery() error {
 		}
 	}
 
+	// Add historically good peers.
+	if good := srv.nodedb.QueryGoodPeers(goodPeerDialCandidates); len(good) > 0 {
+		srv.discmix.AddSource(enode.IterNodes(good))
+	}
+
 	// Don't listen on UDP endpoint if DHT is disabled.
 	if srv.NoDiscovery && !srv.DiscoveryV5 {
 		return nil
@@ -622,7 +674,8 @@ func (srv *Server) setupDialScheduler() {
 		log:            srv.Logger,
 		netRestrict:    srv.NetRestrict,
 		dialer:         srv.Dialer,
-		clock:          srv.clock,
+		clock:          srv.Clock,
+		banned:         srv.dialBanned,
 	}
 	if srv.ntab != nil {
 		config.resolver = srv.ntab
@@ -810,6 +863,12 @@ func (srv *Server) postHandshakeChecks(peers map[enode.ID]*Peer, inboundCount in
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case srv.isBanned(c.node.ID()):
+		return DiscUselessPeer
+	case srv.denylist.contains(netutil.AddrIP(c.fd.RemoteAddr())):
+		return DiscUselessPeer
+	case !c.is(trustedConn) && c.is(inboundConn) && srv.checkInboundIPLimits(peers, netutil.AddrIP(c.fd.RemoteAddr())) != nil:
+		return DiscTooManyPeers
 	default:
 		return nil
 	}
@@ -905,12 +964,21 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 	if srv.NetRestrict != nil && !srv.NetRestrict.Contains(remoteIP) {
 		return fmt.Errorf("not in netrestrict list")
 	}
+	if srv.denylist.contains(remoteIP) {
+		return fmt.Errorf("denied network")
+	}
 	// Reject Internet peers that try too often.
-	now := srv.clock.Now()
+	now := srv.Clock.Now()
 	srv.inboundHistory.expire(now, nil)
-	if !netutil.IsLAN(remoteIP) && srv.inboundHistory.contains(remoteIP.String()) {
+	if netutil.IsLAN(remoteIP) {
+		return nil
+	}
+	if srv.inboundHistory.contains(remoteIP.String()) {
 		return fmt.Errorf("too many attempts")
 	}
+	if err := srv.throttleInboundSubnet(remoteIP); err != nil {
+		return err
+	}
 	srv.inboundHistory.add(remoteIP.String(), now.Add(inboundThrottleTime))
 	return nil
 }
@@ -920,9 +988,13 @@ func (srv *Server) checkInboundConn(remoteIP net.IP) error {
 // or the handshakes have failed.
 func (srv *Server) SetupConn(fd net.Conn, flags connFlag, dialDest *enode.Node) error {
 	c := &conn{fd: fd, flags: flags, cont: make(chan error)}
-	if dialDest == nil {
+	switch {
+	case dialDest == nil:
 		c.transport = srv.newTransport(fd, nil)
-	} else {
+	case srv.MuxTransport && !muxSupported(dialDest):
+		// Only offer multiplexing to nodes which advertise it.
+		c.transport = newRLPX(fd, dialDest.Pubkey())
+	default:
 		c.transport = srv.newTransport(fd, dialDest.Pubkey())
 	}
 
@@ -1014,11 +1086,14 @@ func (srv *Server) checkpoint(c *conn, stage chan<- *conn) error {
 
 func (srv *Server) launchPeer(c *conn) *Peer {
 	p := newPeer(srv.log, c, srv.Protocols)
+	p.clock = srv.Clock
+	srv.loadPeerScore(p)
 	if srv.EnableMsgEvents {
 		// If message events are enabled, pass the peerFeed
 		// to the peer.
 		p.events = &srv.peerFeed
 	}
+	p.capture = srv.capture
 	go srv.runPeer(p)
 	return p
 }
@@ -1038,6 +1113,10 @@ func (srv *Server) runPeer(p *Peer) {
 	// Run the per-peer main loop.
 	remoteRequested, err := p.run()
 
+	// Persist the peer's reputation. This happens before the peer is removed
+	// because the node database is closed once all peers are gone.
+	srv.storePeerScore(p)
+
 	// Announce disconnect on the main loop to update the peer set.
 	// The main loop waits for existing peers to be sent on srv.delpeer
 	// before returning, so this send should not select on srv.quit.
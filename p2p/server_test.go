@@ -27,6 +27,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/testlog"
 	"github.com/ethereum/go-ethereum/log"
@@ -233,6 +234,95 @@ func TestServerRemovePeerDisconnect(t *testing.T) {
 	}
 }
 
+// This test checks that peers with low score are disconnected and banned.
+func TestServerPeerScoreBan(t *testing.T) {
+	var (
+		remkey    = newkey()
+		remid     = enode.PubkeyToIDV4(&remkey.PublicKey)
+		node      = enode.NewV4(&remkey.PublicKey, net.IP{127, 0, 0, 1}, 30303, 0)
+		connected = make(chan *Peer, 1)
+	)
+	srv := startTestServer(t, &remkey.PublicKey, func(p *Peer) { connected <- p })
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 10)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	connect := func() error {
+		fd, _ := net.Pipe()
+		return srv.SetupConn(fd, inboundConn, nil)
+	}
+	if err := connect(); err != nil {
+		t.Fatal("connect failed:", err)
+	}
+	p := <-connected
+	p.Score(ScoreValidBlock)
+	if score := p.Info().Score; score.Score != 1 || score.ValidBlocks != 1 {
+		t.Fatalf("wrong peer score: %+v", score)
+	}
+
+	// Invalid messages drop the score below the ban threshold.
+	for i := 0; i < 3; i++ {
+		p.Score(ScoreInvalidMessage)
+	}
+	for ev := range events {
+		if ev.Type == PeerEventTypeDrop && ev.Peer == remid {
+			break
+		}
+	}
+	if !srv.isBanned(remid) {
+		t.Fatal("peer not banned after disconnect")
+	}
+	if err := connect(); err != DiscUselessPeer {
+		t.Fatalf("wrong error for banned peer: %v", err)
+	}
+
+	// After lifting the ban, the peer can connect again.
+	srv.UnbanPeer(node)
+	if err := connect(); err != nil {
+		t.Fatal("connect after unban failed:", err)
+	}
+	p = <-connected
+	if score := p.Info().Score.Score; score != 0 {
+		t.Fatalf("wrong peer score after unban: %v", score)
+	}
+
+	// Bans through the API disconnect the peer.
+	srv.BanPeer(node, time.Hour)
+	for ev := range events {
+		if ev.Type == PeerEventTypeDrop && ev.Peer == remid {
+			break
+		}
+	}
+	if srv.PeerCount() != 0 || !srv.isBanned(remid) {
+		t.Fatal("peer not banned by BanPeer")
+	}
+}
+
+// This test checks that slow peers lose dial preference, but are never banned for
+// their latency.
+func TestPeerScoreLatency(t *testing.T) {
+	var s peerScore
+	for i := 0; i < 10000; i++ {
+		s.roundtrip(10*time.Second, time.Second)
+	}
+	score, latencyScore, banned := s.get()
+	if score != 0 || banned {
+		t.Fatalf("latency affected the reputation: score %v, banned %v", score, banned)
+	}
+	if latencyScore < -maxLatencyScore || latencyScore >= 0 {
+		t.Fatalf("latency score out of range: %v", latencyScore)
+	}
+	// Fast answers restore the preference.
+	for i := 0; i < 100; i++ {
+		s.roundtrip(0, time.Second)
+	}
+	if _, latencyScore, _ = s.get(); latencyScore <= 0 || latencyScore > maxLatencyScore {
+		t.Fatalf("latency score out of range: %v", latencyScore)
+	}
+}
+
 // This test checks that connections are disconnected just after the encryption handshake
 // when the server is at capacity. Trusted connections should still be accepted.
 func TestServerAtCap(t *testing.T) {
@@ -571,6 +661,145 @@ func TestServerInboundThrottle(t *testing.T) {
 	}
 }
 
+// fakeConnSetup creates inbound connections with fake remote addresses on srv.
+type fakeConnSetup struct {
+	srv  *Server
+	keys chan *ecdsa.PublicKey
+}
+
+func startFakeConnServer(t *testing.T, config Config) *fakeConnSetup {
+	s := &fakeConnSetup{keys: make(chan *ecdsa.PublicKey, 1)}
+	config.PrivateKey = newkey()
+	config.NoDiscovery = true
+	config.Logger = testlog.Logger(t, log.LvlTrace)
+	s.srv = &Server{
+		Config: config,
+		newTransport: func(fd net.Conn, dialDest *ecdsa.PublicKey) transport {
+			return newTestTransport(<-s.keys, fd, dialDest)
+		},
+	}
+	if err := s.srv.Start(); err != nil {
+		t.Fatal("can't start:", err)
+	}
+	return s
+}
+
+// connect creates a connection from ip, returning the remote node.
+func (s *fakeConnSetup) connect(ip string) (*enode.Node, error) {
+	var (
+		key    = newkey()
+		fd, _  = net.Pipe()
+		addr   = &net.TCPAddr{IP: net.ParseIP(ip), Port: 30303}
+		remote = enode.NewV4(&key.PublicKey, addr.IP, addr.Port, 0)
+	)
+	s.keys <- &key.PublicKey
+	return remote, s.srv.SetupConn(&fakeAddrConn{fd, addr}, inboundConn, nil)
+}
+
+func TestServerInboundIPLimits(t *testing.T) {
+	s := startFakeConnServer(t, Config{MaxPeers: 50, MaxInboundPerIP: 1, MaxInboundPerSubnet: 2})
+	defer s.srv.Stop()
+
+	tests := []struct {
+		ip      string
+		wantErr error
+	}{
+		{"95.33.21.2", nil},
+		{"95.33.21.2", DiscTooManyPeers}, // IP limit
+		{"95.33.21.3", nil},
+		{"95.33.21.4", DiscTooManyPeers}, // subnet limit
+		{"95.33.22.1", nil},
+		{"2001:db8::1", nil},
+		{"2001:db8::2", nil},
+		{"2001:db8::3", DiscTooManyPeers},
+		{"2001:db8:0:1::1", nil},
+		{"192.168.0.1", nil}, // LAN addresses are not limited
+		{"192.168.0.1", nil},
+	}
+	for i, test := range tests {
+		if _, err := s.connect(test.ip); err != test.wantErr {
+			t.Errorf("test %d (%s): wrong error %v, want %v", i, test.ip, err, test.wantErr)
+		}
+	}
+}
+
+func TestServerInboundSubnetThrottle(t *testing.T) {
+	clock := new(mclock.Simulated)
+	srv := &Server{Config: Config{MaxInboundAttemptsPerSubnet: 2, Clock: clock}}
+	tests := []struct {
+		ip     string
+		wantOK bool
+	}{
+		{"95.33.21.1", true},
+		{"95.33.21.1", false}, // IP throttle
+		{"95.33.21.2", true},
+		{"95.33.21.3", false}, // subnet throttle
+		{"95.33.22.1", true},
+		{"192.168.0.1", true},
+		{"192.168.0.2", true},
+		{"192.168.0.3", true},
+	}
+	for i, test := range tests {
+		if err := srv.checkInboundConn(net.ParseIP(test.ip)); (err == nil) != test.wantOK {
+			t.Errorf("test %d (%s): wrong result %v", i, test.ip, err)
+		}
+	}
+	clock.Run(inboundThrottleTime + 1)
+	if err := srv.checkInboundConn(net.ParseIP("95.33.21.3")); err != nil {
+		t.Errorf("attempt rejected after throttle time: %v", err)
+	}
+}
+
+func TestServerDenyNetwork(t *testing.T) {
+	s := startFakeConnServer(t, Config{MaxPeers: 10})
+	defer s.srv.Stop()
+
+	remote, err := s.connect("95.33.21.2")
+	if err != nil {
+		t.Fatal("connect failed:", err)
+	}
+	if err := s.srv.DenyNetwork("95.33.0.0/16"); err != nil {
+		t.Fatal("DenyNetwork failed:", err)
+	}
+	if err := s.srv.DenyNetwork("2001:db8::1"); err != nil {
+		t.Fatal("DenyNetwork failed:", err)
+	}
+	if err := s.srv.DenyNetwork("foo"); err == nil {
+		t.Fatal("DenyNetwork accepted invalid network")
+	}
+	want := []string{"95.33.0.0/16", "2001:db8::1/128"}
+	if list := s.srv.DeniedNetworks(); !reflect.DeepEqual(list, want) {
+		t.Fatalf("wrong denied networks %v, want %v", list, want)
+	}
+
+	// The connected peer is dropped, new connections and dials are rejected.
+	for start := time.Now(); s.srv.PeerCount() > 0; time.Sleep(5 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("peer in denied network not disconnected")
+		}
+	}
+	if err := s.srv.checkInboundConn(net.ParseIP("95.33.1.1")); err == nil {
+		t.Error("inbound connection from denied network accepted")
+	}
+	if _, err := s.connect("2001:db8::1"); err != DiscUselessPeer {
+		t.Errorf("wrong error for denied network: %v", err)
+	}
+	if !s.srv.dialBanned(remote) {
+		t.Error("node in denied network can be dialed")
+	}
+
+	// Allowing the network again lifts the restrictions.
+	if err := s.srv.AllowNetwork("95.33.0.0/16"); err != nil {
+		t.Fatal("AllowNetwork failed:", err)
+	}
+	if err := s.srv.AllowNetwork("95.33.0.0/16"); err == nil {
+		t.Error("AllowNetwork succeeded for network that isn't denied")
+	}
+	if _, err := s.connect("95.33.21.2"); err != nil {
+		t.Errorf("connect after AllowNetwork failed: %v", err)
+	}
+}
+
 func listenFakeAddr(network, laddr string, remoteAddr net.Addr) (net.Listener, error) {
 	l, err := net.Listen(network, laddr)
 	if err == nil {
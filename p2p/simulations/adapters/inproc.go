@@ -17,13 +17,16 @@
 package adapters
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"math"
 	"net"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
@@ -41,6 +44,21 @@ type SimAdapter struct {
 	mtx        sync.RWMutex
 	nodes      map[enode.ID]*SimNode
 	lifecycles LifecycleConstructors
+
+	// These fields are set in virtual time mode (see NewSimAdapterWithClock).
+	clock *mclock.Simulated
+	seed  int64
+	links map[simLinkKey]*pipes.SimLink
+}
+
+// simLinkKey identifies the link between two nodes.
+type simLinkKey struct{ a, b enode.ID }
+
+func newSimLinkKey(one, other enode.ID) simLinkKey {
+	if bytes.Compare(one[:], other[:]) > 0 {
+		one, other = other, one
+	}
+	return simLinkKey{one, other}
 }
 
 // NewSimAdapter creates a SimAdapter which is capable of running in-memory
@@ -55,6 +73,19 @@ func NewSimAdapter(services LifecycleConstructors) *SimAdapter {
 	}
 }
 
+// NewSimAdapterWithClock creates a SimAdapter which runs nodes in virtual time.
+// The p2p servers of all nodes use the given clock, and nodes are connected using
+// simulated links (see SetLink) which deliver data on the clock. Random network
+// conditions like packet loss are derived from the seed, so simulations can be
+// reproduced by using the same seed.
+func NewSimAdapterWithClock(services LifecycleConstructors, clock *mclock.Simulated, seed int64) *SimAdapter {
+	s := NewSimAdapter(services)
+	s.clock = clock
+	s.seed = seed
+	s.links = make(map[simLinkKey]*pipes.SimLink)
+	return s
+}
+
 // Name returns the name of the adapter for logging purposes
 func (s *SimAdapter) Name() string {
 	return "sim-adapter"
@@ -91,14 +122,18 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 		return nil, err
 	}
 
+	p2pConfig := p2p.Config{
+		PrivateKey:      config.PrivateKey,
+		MaxPeers:        math.MaxInt32,
+		NoDiscovery:     true,
+		Dialer:          &simDialer{s, id},
+		EnableMsgEvents: config.EnableMsgEvents,
+	}
+	if s.clock != nil {
+		p2pConfig.Clock = s.clock
+	}
 	n, err := node.New(&node.Config{
-		P2P: p2p.Config{
-			PrivateKey:      config.PrivateKey,
-			MaxPeers:        math.MaxInt32,
-			NoDiscovery:     true,
-			Dialer:          s,
-			EnableMsgEvents: config.EnableMsgEvents,
-		},
+		P2P:            p2pConfig,
 		ExternalSigner: config.ExternalSigner,
 		Logger:         log.New("node.id", id.String()),
 	})
@@ -120,6 +155,10 @@ func (s *SimAdapter) NewNode(config *NodeConfig) (Node, error) {
 // Dial implements the p2p.NodeDialer interface by connecting to the node using
 // an in-memory net.Pipe
 func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn, err error) {
+	return s.dial(enode.ID{}, dest)
+}
+
+func (s *SimAdapter) dial(src enode.ID, dest *enode.Node) (conn net.Conn, err error) {
 	node, ok := s.GetNode(dest.ID())
 	if !ok {
 		return nil, fmt.Errorf("unknown node: %s", dest.ID())
@@ -128,8 +167,13 @@ func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn,
 	if srv == nil {
 		return nil, fmt.Errorf("node not running: %s", dest.ID())
 	}
-	// SimAdapter.pipe is net.Pipe (NewSimAdapter)
-	pipe1, pipe2, err := s.pipe()
+	// SimAdapter.pipe is net.Pipe (NewSimAdapter), in virtual time mode
+	// the pipe is created on the simulated link between the nodes.
+	pipe := s.pipe
+	if s.clock != nil {
+		pipe = s.link(src, dest.ID()).Pipe
+	}
+	pipe1, pipe2, err := pipe()
 	if err != nil {
 		return nil, err
 	}
@@ -140,6 +184,64 @@ func (s *SimAdapter) Dial(ctx context.Context, dest *enode.Node) (conn net.Conn,
 	return pipe2, nil
 }
 
+// simDialer dials on behalf of a node, which allows the adapter to pick the
+// link between the nodes.
+type simDialer struct {
+	adapter *SimAdapter
+	src     enode.ID
+}
+
+func (d *simDialer) Dial(ctx context.Context, dest *enode.Node) (net.Conn, error) {
+	return d.adapter.dial(d.src, dest)
+}
+
+// Clock returns the virtual clock of the adapter. It is nil unless the adapter
+// was created by NewSimAdapterWithClock.
+func (s *SimAdapter) Clock() *mclock.Simulated {
+	return s.clock
+}
+
+// link returns the simulated link between two nodes, creating it if necessary.
+func (s *SimAdapter) link(one, other enode.ID) *pipes.SimLink {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	key := newSimLinkKey(one, other)
+	l := s.links[key]
+	if l == nil {
+		h := fnv.New64a()
+		h.Write(key.a[:])
+		h.Write(key.b[:])
+		l = pipes.NewSimLink(s.clock, pipes.LinkConfig{}, s.seed^int64(h.Sum64()))
+		s.links[key] = l
+	}
+	return l
+}
+
+// SetLink sets the network conditions between two nodes. It is only supported
+// in virtual time mode.
+func (s *SimAdapter) SetLink(one, other enode.ID, config pipes.LinkConfig) error {
+	if s.clock == nil {
+		return errors.New("links can only be configured in virtual time mode")
+	}
+	s.link(one, other).SetConfig(config)
+	return nil
+}
+
+// ResetLinks restores the default network conditions, i.e. no delay, on all links.
+func (s *SimAdapter) ResetLinks() {
+	s.mtx.RLock()
+	links := make([]*pipes.SimLink, 0, len(s.links))
+	for _, l := range s.links {
+		links = append(links, l)
+	}
+	s.mtx.RUnlock()
+
+	for _, l := range links {
+		l.SetConfig(pipes.LinkConfig{})
+	}
+}
+
 // DialRPC implements the RPCDialer interface by creating an in-memory RPC
 // client of the given node
 func (s *SimAdapter) DialRPC(id enode.ID) (*rpc.Client, error) {
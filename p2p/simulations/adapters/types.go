@@ -18,6 +18,7 @@ package adapters
 
 import (
 	"crypto/ecdsa"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -26,12 +27,14 @@ import (
 	"strconv"
 
 	"github.com/docker/docker/pkg/reexec"
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/websocket"
 )
@@ -76,6 +79,21 @@ type NodeAdapter interface {
 	NewNode(config *NodeConfig) (Node, error)
 }
 
+// LinkAdapter is a NodeAdapter which simulates the network links between nodes
+// in virtual time.
+type LinkAdapter interface {
+	NodeAdapter
+
+	// Clock returns the virtual clock, or nil if the adapter runs in real time.
+	Clock() *mclock.Simulated
+
+	// SetLink sets the network conditions between two nodes.
+	SetLink(one, other enode.ID, config pipes.LinkConfig) error
+
+	// ResetLinks restores the default network conditions on all links.
+	ResetLinks()
+}
+
 // NodeConfig is the configuration used to start a node in a simulation
 // network
 type NodeConfig struct {
@@ -232,6 +250,28 @@ func RandomNodeConfig() *NodeConfig {
 	}
 }
 
+// SeededNodeConfig returns the configuration of the i'th node of a simulation.
+// The private key is derived from the seed, so the same seed always yields the
+// same nodes.
+func SeededNodeConfig(seed int64, i int) *NodeConfig {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(i))
+	prvkey, err := crypto.ToECDSA(crypto.Keccak256(buf[:]))
+	if err != nil {
+		panic("unable to derive key")
+	}
+	enodId := enode.PubkeyToIDV4(&prvkey.PublicKey)
+	return &NodeConfig{
+		PrivateKey:      prvkey,
+		ID:              enodId,
+		Name:            fmt.Sprintf("node%02d", i),
+		Port:            uint16(30303 + i),
+		EnableMsgEvents: true,
+		LogVerbosity:    log.LvlInfo,
+	}
+}
+
 func assignTCPPort() (uint16, error) {
 	l, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
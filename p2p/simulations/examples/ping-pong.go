@@ -25,6 +25,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
@@ -33,7 +34,11 @@ import (
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 )
 
-var adapterType = flag.String("adapter", "sim", `node adapter to use (one of "sim", "exec" or "docker")`)
+var (
+	adapterType = flag.String("adapter", "sim", `node adapter to use (one of "sim", "exec" or "docker")`)
+	virtualTime = flag.Bool("virtual-time", false, `run the "sim" adapter in virtual time`)
+	seed        = flag.Int64("seed", 0, "seed of the simulated network conditions in virtual time")
+)
 
 // main() starts a simulation network which contains nodes running a simple
 // ping-pong protocol
@@ -43,10 +48,19 @@ func main() {
 	// set the log level to Trace
 	log.Root().SetHandler(log.LvlFilterHandler(log.LvlTrace, log.StreamHandler(os.Stderr, log.TerminalFormat(false))))
 
+	// in virtual time, the clock is only advanced through the simulation API
+	var clock mclock.Clock = mclock.System{}
+	if *virtualTime {
+		if *adapterType != "sim" {
+			log.Crit("virtual time is only supported by the sim adapter")
+		}
+		clock = new(mclock.Simulated)
+	}
+
 	// register a single ping-pong service
 	services := map[string]adapters.LifecycleConstructor{
 		"ping-pong": func(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
-			pps := newPingPongService(ctx.Config.ID)
+			pps := newPingPongService(ctx.Config.ID, clock)
 			stack.RegisterProtocols(pps.Protocols())
 			return pps, nil
 		},
@@ -59,8 +73,13 @@ func main() {
 	switch *adapterType {
 
 	case "sim":
-		log.Info("using sim adapter")
-		adapter = adapters.NewSimAdapter(services)
+		if sim, ok := clock.(*mclock.Simulated); ok {
+			log.Info("using sim adapter in virtual time", "seed", *seed)
+			adapter = adapters.NewSimAdapterWithClock(services, sim, *seed)
+		} else {
+			log.Info("using sim adapter")
+			adapter = adapters.NewSimAdapter(services)
+		}
 
 	case "exec":
 		tmpdir, err := ioutil.TempDir("", "p2p-example")
@@ -91,13 +110,15 @@ func main() {
 type pingPongService struct {
 	id       enode.ID
 	log      log.Logger
+	clock    mclock.Clock
 	received int64
 }
 
-func newPingPongService(id enode.ID) *pingPongService {
+func newPingPongService(id enode.ID, clock mclock.Clock) *pingPongService {
 	return &pingPongService{
-		id:  id,
-		log: log.New("node.id", id),
+		id:    id,
+		log:   log.New("node.id", id),
+		clock: clock,
 	}
 }
 
@@ -141,7 +162,8 @@ func (p *pingPongService) Run(peer *p2p.Peer, rw p2p.MsgReadWriter) error {
 
 	errC := make(chan error)
 	go func() {
-		for range time.Tick(10 * time.Second) {
+		for {
+			<-p.clock.After(10 * time.Second)
 			log.Info("sending ping")
 			if err := p2p.Send(rw, pingMsgCode, "PING"); err != nil {
 				errC <- err
@@ -28,11 +28,13 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gorilla/websocket"
 	"github.com/julienschmidt/httprouter"
@@ -205,6 +207,26 @@ func (c *Client) DisconnectNode(nodeID, peerID string) error {
 	return c.Delete(fmt.Sprintf("/nodes/%s/conn/%s", nodeID, peerID))
 }
 
+// SetLink sets the network conditions between two nodes
+func (c *Client) SetLink(nodeID, peerID string, config pipes.LinkConfig) error {
+	return c.Post(fmt.Sprintf("/nodes/%s/link/%s", nodeID, peerID), config, nil)
+}
+
+// Partition splits the network into the given groups of nodes
+func (c *Client) Partition(groups ...[]string) error {
+	return c.Post("/partition", groups, nil)
+}
+
+// Heal restores the default network conditions on all links
+func (c *Client) Heal() error {
+	return c.Post("/heal", nil, nil)
+}
+
+// AdvanceTime moves the virtual clock of the network forward
+func (c *Client) AdvanceTime(d time.Duration) error {
+	return c.Post("/time", d, nil)
+}
+
 // RPCClient returns an RPC client connected to a node
 func (c *Client) RPCClient(ctx context.Context, nodeID string) (*rpc.Client, error) {
 	baseURL := strings.Replace(c.URL, "http", "ws", 1)
@@ -295,6 +317,10 @@ func NewServer(network *Network) *Server {
 	s.POST("/nodes/:nodeid/stop", s.StopNode)
 	s.POST("/nodes/:nodeid/conn/:peerid", s.ConnectNode)
 	s.DELETE("/nodes/:nodeid/conn/:peerid", s.DisconnectNode)
+	s.POST("/nodes/:nodeid/link/:peerid", s.SetLink)
+	s.POST("/partition", s.Partition)
+	s.POST("/heal", s.Heal)
+	s.POST("/time", s.AdvanceTime)
 	s.GET("/nodes/:nodeid/rpc", s.NodeRPC)
 
 	return s
@@ -642,6 +668,81 @@ func (s *Server) DisconnectNode(w http.ResponseWriter, req *http.Request) {
 	s.JSON(w, http.StatusOK, node.NodeInfo())
 }
 
+// SetLink sets the network conditions between a node and a peer node
+func (s *Server) SetLink(w http.ResponseWriter, req *http.Request) {
+	node := req.Context().Value("node").(*Node)
+	peer := req.Context().Value("peer").(*Node)
+
+	var config pipes.LinkConfig
+	if err := json.NewDecoder(req.Body).Decode(&config); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.network.SetLink(node.ID(), peer.ID(), config); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.JSON(w, http.StatusOK, config)
+}
+
+// Partition splits the network into groups of nodes, given as lists of node
+// IDs or names
+func (s *Server) Partition(w http.ResponseWriter, req *http.Request) {
+	var names [][]string
+	if err := json.NewDecoder(req.Body).Decode(&names); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	groups := make([][]enode.ID, len(names))
+	for i := range names {
+		for _, name := range names[i] {
+			node := s.findNode(name)
+			if node == nil {
+				http.Error(w, fmt.Sprintf("unknown node %q", name), http.StatusBadRequest)
+				return
+			}
+			groups[i] = append(groups[i], node.ID())
+		}
+	}
+	if err := s.network.Partition(groups...); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// Heal restores the default network conditions on all links
+func (s *Server) Heal(w http.ResponseWriter, req *http.Request) {
+	if err := s.network.Heal(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// AdvanceTime moves the virtual clock of the network forward by the duration
+// given in the request body
+func (s *Server) AdvanceTime(w http.ResponseWriter, req *http.Request) {
+	var d time.Duration
+	if err := json.NewDecoder(req.Body).Decode(&d); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if d < 0 {
+		http.Error(w, "negative duration", http.StatusBadRequest)
+		return
+	}
+	if err := s.network.AdvanceTime(d); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
 // Options responds to the OPTIONS HTTP method by returning a 200 OK response
 // with the "Access-Control-Allow-Headers" header set to "Content-Type"
 func (s *Server) Options(w http.ResponseWriter, req *http.Request) {
@@ -708,13 +809,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		ctx := req.Context()
 
 		if id := params.ByName("nodeid"); id != "" {
-			var nodeID enode.ID
-			var node *Node
-			if nodeID.UnmarshalText([]byte(id)) == nil {
-				node = s.network.GetNode(nodeID)
-			} else {
-				node = s.network.GetNodeByName(id)
-			}
+			node := s.findNode(id)
 			if node == nil {
 				http.NotFound(w, req)
 				return
@@ -723,13 +818,7 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		}
 
 		if id := params.ByName("peerid"); id != "" {
-			var peerID enode.ID
-			var peer *Node
-			if peerID.UnmarshalText([]byte(id)) == nil {
-				peer = s.network.GetNode(peerID)
-			} else {
-				peer = s.network.GetNodeByName(id)
-			}
+			peer := s.findNode(id)
 			if peer == nil {
 				http.NotFound(w, req)
 				return
@@ -740,3 +829,12 @@ func (s *Server) wrapHandler(handler http.HandlerFunc) httprouter.Handle {
 		handler(w, req.WithContext(ctx))
 	}
 }
+
+// findNode returns the node with the given ID or name
+func (s *Server) findNode(id string) *Node {
+	var nodeID enode.ID
+	if nodeID.UnmarshalText([]byte(id)) == nil {
+		return s.network.GetNode(nodeID)
+	}
+	return s.network.GetNodeByName(id)
+}
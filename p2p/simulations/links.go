@@ -0,0 +1,124 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package simulations
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+)
+
+var errNoVirtualTime = errors.New("network adapter doesn't support virtual time")
+
+// linkAdapter returns the adapter if it simulates links in virtual time.
+func (net *Network) linkAdapter() (adapters.LinkAdapter, error) {
+	la, ok := net.nodeAdapter.(adapters.LinkAdapter)
+	if !ok || la.Clock() == nil {
+		return nil, errNoVirtualTime
+	}
+	return la, nil
+}
+
+// Clock returns the virtual clock of the network, or nil if the network runs in
+// real time.
+func (net *Network) Clock() *mclock.Simulated {
+	la, err := net.linkAdapter()
+	if err != nil {
+		return nil
+	}
+	return la.Clock()
+}
+
+// AdvanceTime moves the virtual clock of the network forward, running all timers
+// which expire within d.
+func (net *Network) AdvanceTime(d time.Duration) error {
+	la, err := net.linkAdapter()
+	if err != nil {
+		return err
+	}
+	la.Clock().Run(d)
+	return nil
+}
+
+// SetLink sets the network conditions between two nodes. Setting a link down
+// closes all connections between the nodes.
+func (net *Network) SetLink(oneID, otherID enode.ID, config pipes.LinkConfig) error {
+	la, err := net.linkAdapter()
+	if err != nil {
+		return err
+	}
+	if net.GetNode(oneID) == nil {
+		return fmt.Errorf("node %v does not exist", oneID)
+	}
+	if net.GetNode(otherID) == nil {
+		return fmt.Errorf("node %v does not exist", otherID)
+	}
+	log.Debug("Setting link", "one", oneID, "other", otherID, "latency", config.Latency, "bandwidth", config.Bandwidth, "loss", config.Loss, "down", config.Down)
+	return la.SetLink(oneID, otherID, config)
+}
+
+// Partition splits the network into the given groups of nodes by taking down all
+// links between nodes of different groups. Links within a group are not changed.
+func (net *Network) Partition(groups ...[]enode.ID) error {
+	la, err := net.linkAdapter()
+	if err != nil {
+		return err
+	}
+	group := make(map[enode.ID]int)
+	for i, ids := range groups {
+		for _, id := range ids {
+			if net.GetNode(id) == nil {
+				return fmt.Errorf("node %v does not exist", id)
+			}
+			if _, ok := group[id]; ok {
+				return fmt.Errorf("node %v is in multiple groups", id)
+			}
+			group[id] = i
+		}
+	}
+	for i, ids := range groups {
+		for _, id := range ids {
+			for _, other := range groups[i+1:] {
+				for _, otherID := range other {
+					if err := la.SetLink(id, otherID, pipes.LinkConfig{Down: true}); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	log.Info("Partitioned network", "groups", len(groups))
+	return nil
+}
+
+// Heal restores the default network conditions on all links, which also ends
+// any partition.
+func (net *Network) Heal() error {
+	la, err := net.linkAdapter()
+	if err != nil {
+		return err
+	}
+	la.ResetLinks()
+	log.Info("Healed network")
+	return nil
+}
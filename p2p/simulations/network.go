@@ -39,6 +39,10 @@ var DialBanTimeout = 200 * time.Millisecond
 type NetworkConfig struct {
 	ID             string `json:"id"`
 	DefaultService string `json:"default_service,omitempty"`
+
+	// Seed initializes the random source of the network, which is used to pick
+	// random nodes. When zero, the global random source is used.
+	Seed int64 `json:"seed,omitempty"`
 }
 
 // Network models a p2p simulation network which consists of a collection of
@@ -65,11 +69,14 @@ type Network struct {
 	events      event.Feed
 	lock        sync.RWMutex
 	quitc       chan struct{}
+
+	randMu sync.Mutex
+	rand   *rand.Rand // nil unless NetworkConfig.Seed is set
 }
 
 // NewNetwork returns a Network which uses the given NodeAdapter and NetworkConfig
 func NewNetwork(nodeAdapter adapters.NodeAdapter, conf *NetworkConfig) *Network {
-	return &Network{
+	net := &Network{
 		NetworkConfig: *conf,
 		nodeAdapter:   nodeAdapter,
 		nodeMap:       make(map[enode.ID]int),
@@ -77,6 +84,10 @@ func NewNetwork(nodeAdapter adapters.NodeAdapter, conf *NetworkConfig) *Network
 		connMap:       make(map[string]int),
 		quitc:         make(chan struct{}),
 	}
+	if conf.Seed != 0 {
+		net.rand = rand.New(rand.NewSource(conf.Seed))
+	}
+	return net
 }
 
 // Events returns the output event feed of the Network.
@@ -581,7 +592,17 @@ func (net *Network) getRandomNode(ids []enode.ID, excludeIDs []enode.ID) *Node {
 	if l == 0 {
 		return nil
 	}
-	return net.getNode(filtered[rand.Intn(l)])
+	return net.getNode(filtered[net.randIntn(l)])
+}
+
+// randIntn returns a random number in [0,n) from the network's random source.
+func (net *Network) randIntn(n int) int {
+	if net.rand == nil {
+		return rand.Intn(n)
+	}
+	net.randMu.Lock()
+	defer net.randMu.Unlock()
+	return net.rand.Intn(n)
 }
 
 func filterIDs(ids []enode.ID, excludeIDs []enode.ID) []enode.ID {
@@ -27,8 +27,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/simulations/adapters"
 )
@@ -873,3 +875,113 @@ func casesNodeUnmarshalJSONConfigField() []nodeUnmarshalTestCase {
 		},
 	}
 }
+
+// This test checks that a network in virtual time can be partitioned and healed.
+func TestNetworkPartition(t *testing.T) {
+	clock := new(mclock.Simulated)
+	adapter := adapters.NewSimAdapterWithClock(adapters.LifecycleConstructors{
+		"noopwoop": func(ctx *adapters.ServiceContext, stack *node.Node) (node.Lifecycle, error) {
+			return NewNoopService(nil), nil
+		},
+	}, clock, 1)
+	network := NewNetwork(adapter, &NetworkConfig{DefaultService: "noopwoop", Seed: 1})
+	defer network.Shutdown()
+
+	ids := make([]enode.ID, 4)
+	for i := range ids {
+		node, err := network.NewNodeWithConfig(adapters.SeededNodeConfig(1, i))
+		if err != nil {
+			t.Fatalf("error creating node: %s", err)
+		}
+		if err := network.Start(node.ID()); err != nil {
+			t.Fatalf("error starting node: %s", err)
+		}
+		ids[i] = node.ID()
+	}
+	if ids[0] != adapters.SeededNodeConfig(1, 0).ID {
+		t.Fatal("node IDs are not reproducible")
+	}
+
+	events := make(chan *Event, 100)
+	sub := network.Events().Subscribe(events)
+	defer sub.Unsubscribe()
+	waitConn := func(one, other enode.ID, up bool) {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for {
+			select {
+			case ev := <-events:
+				if ev.Type == EventTypeConn && !ev.Control && ev.Conn.Up == up &&
+					((ev.Conn.One == one && ev.Conn.Other == other) || (ev.Conn.One == other && ev.Conn.Other == one)) {
+					return
+				}
+			case <-timeout:
+				t.Fatalf("timeout waiting for conn %v -> %v (up: %t)", one, other, up)
+			}
+		}
+	}
+
+	// Connect the nodes in a chain.
+	for i := 0; i < len(ids)-1; i++ {
+		if err := network.Connect(ids[i], ids[i+1]); err != nil {
+			t.Fatal(err)
+		}
+		waitConn(ids[i], ids[i+1], true)
+	}
+
+	// Watch the servers on both sides of the partition. The network learns about
+	// peer changes asynchronously, so a late report of the initial connection
+	// could mark it up again after the partition.
+	peerEvents := make(chan *p2p.PeerEvent, 100)
+	servers := make(map[enode.ID]*p2p.Server)
+	for _, id := range ids[1:3] {
+		node, _ := adapter.GetNode(id)
+		servers[id] = node.Server()
+		sub := servers[id].SubscribeEvents(peerEvents)
+		defer sub.Unsubscribe()
+	}
+	waitPeers := func(typ p2p.PeerEventType) {
+		t.Helper()
+		timeout := time.After(10 * time.Second)
+		for seen := 0; seen < 2; {
+			select {
+			case ev := <-peerEvents:
+				if ev.Type == typ && (ev.Peer == ids[1] || ev.Peer == ids[2]) {
+					seen++
+				}
+			case <-timeout:
+				t.Fatalf("timeout waiting for peer event %q", typ)
+			}
+		}
+	}
+	connected := func() bool {
+		for _, p := range servers[ids[1]].Peers() {
+			if p.ID() == ids[2] {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Split the chain in the middle.
+	if err := network.Partition(ids[:2], ids[2:]); err != nil {
+		t.Fatal(err)
+	}
+	waitPeers(p2p.PeerEventTypeDrop)
+
+	// Redials fail while the link is down. A failed redial schedules the next
+	// one on the clock.
+	clock.WaitForTimers(1)
+	network.AdvanceTime(time.Minute)
+	if connected() {
+		t.Fatal("nodes reconnected across partition")
+	}
+
+	// Healing lets the nodes reconnect on the next redial.
+	if err := network.Heal(); err != nil {
+		t.Fatal(err)
+	}
+	clock.WaitForTimers(1)
+	network.AdvanceTime(time.Minute)
+	waitPeers(p2p.PeerEventTypeAdd)
+}
@@ -0,0 +1,286 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pipes
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// minRetransmitTimeout is the minimum delay added to writes which are lost.
+const minRetransmitTimeout = 200 * time.Millisecond
+
+// ErrLinkDown is returned when creating a pipe on a link which is down.
+var ErrLinkDown = errors.New("link is down")
+
+// LinkConfig contains the network conditions simulated by a SimLink.
+type LinkConfig struct {
+	Latency   time.Duration `json:"latency,omitempty"`   // one-way delay
+	Bandwidth int64         `json:"bandwidth,omitempty"` // in bytes per second, zero means unlimited
+	Loss      float64       `json:"loss,omitempty"`      // probability that a write needs to be retransmitted
+	Down      bool          `json:"down,omitempty"`      // set when the link is cut
+}
+
+// retransmitTimeout returns the delay of a lost write.
+func (cfg LinkConfig) retransmitTimeout() time.Duration {
+	if rto := 2 * cfg.Latency; rto > minRetransmitTimeout {
+		return rto
+	}
+	return minRetransmitTimeout
+}
+
+// SimLink is a simulated network link between two nodes. Pipes created on the link
+// deliver written data after the latency and transmission time given by the link
+// configuration, measured on the link's clock. The simulated connection is
+// reliable, lost writes are delivered after a retransmission timeout.
+//
+// Random decisions are taken using generators derived from the seed, which makes
+// the delivery schedule reproducible for a given sequence of writes.
+type SimLink struct {
+	clock mclock.Clock
+	mu    sync.Mutex
+	cfg   LinkConfig
+	rand  *rand.Rand
+	conns map[*simConn]struct{}
+}
+
+// NewSimLink creates a link.
+func NewSimLink(clock mclock.Clock, cfg LinkConfig, seed int64) *SimLink {
+	return &SimLink{
+		clock: clock,
+		cfg:   cfg,
+		rand:  rand.New(rand.NewSource(seed)),
+		conns: make(map[*simConn]struct{}),
+	}
+}
+
+// Config returns the current configuration of the link.
+func (l *SimLink) Config() LinkConfig {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg
+}
+
+// SetConfig changes the network conditions. The new configuration applies to
+// subsequent writes on all pipes of the link. When the link goes down, all of its
+// pipes are closed.
+func (l *SimLink) SetConfig(cfg LinkConfig) {
+	l.mu.Lock()
+	l.cfg = cfg
+	var closing []*simConn
+	if cfg.Down {
+		for c := range l.conns {
+			closing = append(closing, c)
+		}
+	}
+	l.mu.Unlock()
+
+	for _, c := range closing {
+		c.Close()
+	}
+}
+
+// Pipe creates an in-memory full duplex connection on the link.
+func (l *SimLink) Pipe() (net.Conn, net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.Down {
+		return nil, nil, ErrLinkDown
+	}
+	var (
+		b1 = newSimBuffer()
+		b2 = newSimBuffer()
+		c1 = &simConn{link: l, in: b1, out: b2, rand: rand.New(rand.NewSource(l.rand.Int63()))}
+		c2 = &simConn{link: l, in: b2, out: b1, rand: rand.New(rand.NewSource(l.rand.Int63()))}
+	)
+	c1.peer, c2.peer = c2, c1
+	l.conns[c1] = struct{}{}
+	l.conns[c2] = struct{}{}
+	return c1, c2, nil
+}
+
+func (l *SimLink) remove(c *simConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.conns, c)
+}
+
+// simConn is one end of a pipe on a SimLink.
+type simConn struct {
+	link *SimLink
+	peer *simConn
+	in   *simBuffer // data sent by the peer
+	out  *simBuffer // data sent to the peer
+
+	wmu      sync.Mutex
+	rand     *rand.Rand
+	sendDone mclock.AbsTime // when the previous write has left the sender
+	arrival  mclock.AbsTime // arrival time of the previous write
+
+	closeOnce sync.Once
+}
+
+// Write schedules the delivery of b to the other end. It doesn't block, the link
+// buffers any amount of data.
+func (c *simConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.out.isClosed() {
+		return 0, io.ErrClosedPipe
+	}
+	var (
+		cfg   = c.link.Config()
+		clock = c.link.clock
+		now   = clock.Now()
+		start = now
+	)
+	if c.sendDone > start {
+		start = c.sendDone
+	}
+	if cfg.Bandwidth > 0 {
+		start = start.Add(time.Duration(int64(len(b)) * int64(time.Second) / cfg.Bandwidth))
+	}
+	c.sendDone = start
+	arrival := start.Add(cfg.Latency)
+	if cfg.Loss > 0 && c.rand.Float64() < cfg.Loss {
+		arrival = arrival.Add(cfg.retransmitTimeout())
+	}
+	// The connection is reliable and ordered. A retransmitted write delays all
+	// writes behind it.
+	if arrival < c.arrival {
+		arrival = c.arrival
+	}
+	c.arrival = arrival
+
+	c.out.send(arrival, append([]byte(nil), b...))
+	if arrival <= now {
+		c.out.deliver(now)
+	} else {
+		clock.AfterFunc(time.Duration(arrival-now), func() { c.out.deliver(clock.Now()) })
+	}
+	return len(b), nil
+}
+
+// Read reads data which has arrived at this end.
+func (c *simConn) Read(b []byte) (int, error) {
+	return c.in.read(b)
+}
+
+// Close closes both ends of the pipe. Data in flight is discarded.
+func (c *simConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.in.close()
+		c.out.close()
+		c.link.remove(c)
+		c.link.remove(c.peer)
+	})
+	return nil
+}
+
+func (c *simConn) LocalAddr() net.Addr  { return simAddr{} }
+func (c *simConn) RemoteAddr() net.Addr { return simAddr{} }
+
+// Deadlines are not supported because they are specified in wall-clock time.
+// Timeouts of simulated connections must be implemented using the link clock.
+func (c *simConn) SetDeadline(t time.Time) error      { return nil }
+func (c *simConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *simConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type simAddr struct{}
+
+func (simAddr) Network() string { return "sim" }
+func (simAddr) String() string  { return "sim" }
+
+// simBuffer holds the data sent in one direction of a pipe.
+type simBuffer struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending []simWrite // in order of arrival
+	data    []byte     // arrived data
+	closed  bool
+}
+
+type simWrite struct {
+	arrival mclock.AbsTime
+	data    []byte
+}
+
+func newSimBuffer() *simBuffer {
+	b := new(simBuffer)
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+func (b *simBuffer) send(arrival mclock.AbsTime, data []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pending = append(b.pending, simWrite{arrival, data})
+}
+
+// deliver makes all writes which have arrived at the given time available to the
+// reader.
+func (b *simBuffer) deliver(now mclock.AbsTime) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var n int
+	for n < len(b.pending) && b.pending[n].arrival <= now {
+		b.data = append(b.data, b.pending[n].data...)
+		n++
+	}
+	if n > 0 {
+		b.pending = append(b.pending[:0], b.pending[n:]...)
+		b.cond.Broadcast()
+	}
+}
+
+func (b *simBuffer) read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.data) == 0 && !b.closed {
+		b.cond.Wait()
+	}
+	if len(b.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func (b *simBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.pending = nil
+	b.cond.Broadcast()
+}
+
+func (b *simBuffer) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package pipes
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// available returns the number of bytes which can be read from c without blocking.
+func available(c interface{}) int {
+	b := c.(*simConn).in
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.data)
+}
+
+func TestSimLinkLatency(t *testing.T) {
+	var (
+		clock  = new(mclock.Simulated)
+		link   = NewSimLink(clock, LinkConfig{Latency: 100 * time.Millisecond, Bandwidth: 1000}, 1)
+		c1, c2 = mustPipe(t, link)
+	)
+	// 100 bytes take 100ms to send, and another 100ms to arrive.
+	c1.Write(make([]byte, 100))
+	c1.Write(make([]byte, 100))
+	for _, step := range []struct {
+		d    time.Duration
+		want int
+	}{{199 * time.Millisecond, 0}, {1 * time.Millisecond, 100}, {99 * time.Millisecond, 100}, {1 * time.Millisecond, 200}} {
+		clock.Run(step.d)
+		if n := available(c2); n != step.want {
+			t.Fatalf("at %v: got %d bytes, want %d", time.Duration(clock.Now()), n, step.want)
+		}
+	}
+	buf := make([]byte, 300)
+	if n, err := io.ReadAtLeast(c2, buf, 200); n != 200 || err != nil {
+		t.Fatalf("read error: %d bytes, %v", n, err)
+	}
+}
+
+func TestSimLinkLossReproducible(t *testing.T) {
+	schedule := func(seed int64) (arrivals []int) {
+		var (
+			clock  = new(mclock.Simulated)
+			link   = NewSimLink(clock, LinkConfig{Latency: 10 * time.Millisecond, Loss: 0.3}, seed)
+			c1, c2 = mustPipe(t, link)
+		)
+		for i := 0; i < 50; i++ {
+			c1.Write([]byte{byte(i)})
+			clock.Run(10 * time.Millisecond)
+			arrivals = append(arrivals, available(c2))
+		}
+		clock.Run(time.Second)
+		if n := available(c2); n != 50 {
+			t.Fatalf("%d bytes delivered, want 50", n)
+		}
+		return arrivals
+	}
+	s1, s2, s3 := schedule(1), schedule(1), schedule(2)
+	if !reflect.DeepEqual(s1, s2) {
+		t.Errorf("schedules differ for same seed:\n%v\n%v", s1, s2)
+	}
+	if reflect.DeepEqual(s1, s3) {
+		t.Errorf("schedules equal for different seeds:\n%v", s1)
+	}
+	if s1[len(s1)-1] == 50 {
+		t.Errorf("no write was lost")
+	}
+}
+
+func TestSimLinkDown(t *testing.T) {
+	var (
+		clock  = new(mclock.Simulated)
+		link   = NewSimLink(clock, LinkConfig{}, 1)
+		c1, c2 = mustPipe(t, link)
+	)
+	link.SetConfig(LinkConfig{Down: true})
+	if _, err := c1.Write([]byte{1}); err != io.ErrClosedPipe {
+		t.Fatalf("wrong write error: %v", err)
+	}
+	if _, err := c2.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("wrong read error: %v", err)
+	}
+	if _, _, err := link.Pipe(); err != ErrLinkDown {
+		t.Fatalf("wrong error for pipe on down link: %v", err)
+	}
+	link.SetConfig(LinkConfig{})
+	mustPipe(t, link)
+}
+
+func mustPipe(t *testing.T, link *SimLink) (io.ReadWriteCloser, io.ReadWriteCloser) {
+	c1, c2, err := link.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c1, c2
+}
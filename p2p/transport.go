@@ -98,9 +98,7 @@ func (t *rlpxTransport) WriteMsg(msg Msg) error {
 	// Set metrics.
 	msg.meterSize = size
 	if metrics.Enabled && msg.meterCap.Name != "" { // don't meter non-subprotocol messages
-		m := fmt.Sprintf("%s/%s/%d/%#02x", egressMeterName, msg.meterCap.Name, msg.meterCap.Version, msg.meterCode)
-		metrics.GetOrRegisterMeter(m, nil).Mark(int64(msg.meterSize))
-		metrics.GetOrRegisterMeter(m+"/packets", nil).Mark(1)
+		meterProtoTraffic(egressProtoBytesMeter, egressProtoPacketsMeter, msg.meterCap, msg.meterCode, msg.meterSize)
 	}
 	return nil
 }
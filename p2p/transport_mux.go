@@ -0,0 +1,326 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/metrics"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/rlpx"
+)
+
+// The multiplexed transport is an experimental variant of RLPx. It uses the RLPx
+// encryption handshake and framing, but splits messages into chunks which are sent
+// on one stream per subprotocol. Chunks of different streams are interleaved, so a
+// large message of one subprotocol doesn't delay the messages of other subprotocols.
+//
+// Support for multiplexing is advertised by the "mux" ENR entry, and negotiated
+// through the "mux" capability in the protocol handshake. When the remote end
+// doesn't announce it, the transport falls back to plain RLPx message framing.
+
+const (
+	muxVersion = 1
+
+	// muxFrameCode is the RLPx message code of chunk frames. It is in the range
+	// reserved for the base protocol.
+	muxFrameCode = 0x0f
+
+	// muxChunkSize is the maximum payload size of a chunk.
+	muxChunkSize = 16 * 1024
+
+	// Limits for reassembly of incoming messages. The total buffer allows for a
+	// message of maximum size and the smaller messages interleaved with it.
+	muxMaxMessageSize = 16 * 1024 * 1024
+	muxMaxBuffered    = 2 * muxMaxMessageSize
+
+	// Chunk flags.
+	muxFirstChunk = 1 << 0
+	muxLastChunk  = 1 << 1
+)
+
+var (
+	muxCap = Cap{Name: "mux", Version: muxVersion}
+
+	errMuxInvalidChunk  = errors.New("invalid mux chunk")
+	errMuxTooManyStream = errors.New("too many mux streams")
+	errMuxMsgTooLarge   = errors.New("mux message too large")
+	errMuxBufferFull    = errors.New("mux buffer limit exceeded")
+)
+
+// muxEntry is the ENR entry which advertises support for the multiplexed transport.
+type muxEntry uint
+
+func (muxEntry) ENRKey() string { return "mux" }
+
+// muxSupported reports whether a node advertises support for the multiplexed
+// transport in its record.
+func muxSupported(n *enode.Node) bool {
+	var version muxEntry
+	return n.Load(&version) == nil && version == muxVersion
+}
+
+// multiplexedTransport is implemented by transports which can send messages of
+// different subprotocols concurrently.
+type multiplexedTransport interface {
+	multiplexed() bool
+}
+
+// muxTransport is the multiplexed transport.
+type muxTransport struct {
+	*rlpxTransport
+	enabled bool // set by the protocol handshake
+
+	// write side
+	smu        sync.Mutex
+	streams    map[string]*muxWriteStream
+	nextStream uint64
+	wtoken     chan struct{} // chunk write token, handed to waiting streams in order
+
+	// read side, protected by rlpxTransport.rmu
+	partial    map[uint64]*muxReadStream
+	buffered   int // total size of the partial messages
+	maxStreams int // number of negotiated subprotocols, plus the base protocol
+}
+
+type muxWriteStream struct {
+	mu  sync.Mutex // held while writing a message
+	id  uint64
+	buf bytes.Buffer
+}
+
+type muxReadStream struct {
+	code uint64
+	data []byte
+}
+
+func newMuxTransport(conn net.Conn, dialDest *ecdsa.PublicKey) transport {
+	wtoken := make(chan struct{}, 1)
+	wtoken <- struct{}{}
+	return &muxTransport{
+		rlpxTransport: &rlpxTransport{conn: rlpx.NewConn(conn, dialDest)},
+		streams:       make(map[string]*muxWriteStream),
+		partial:       make(map[uint64]*muxReadStream),
+		wtoken:        wtoken,
+	}
+}
+
+func (t *muxTransport) multiplexed() bool {
+	return t.enabled
+}
+
+func (t *muxTransport) doProtoHandshake(our *protoHandshake) (*protoHandshake, error) {
+	hs := *our
+	hs.Caps = append(append([]Cap{}, our.Caps...), muxCap)
+	their, err := t.rlpxTransport.doProtoHandshake(&hs)
+	if err != nil {
+		return nil, err
+	}
+	// Remove the mux capability, it is not a subprotocol.
+	caps := their.Caps[:0]
+	for _, cap := range their.Caps {
+		if cap == muxCap {
+			t.enabled = true
+		} else {
+			caps = append(caps, cap)
+		}
+	}
+	their.Caps = caps
+
+	// Messages are only exchanged on the streams of shared subprotocols, so the
+	// remote end can't have more partial messages in flight than those.
+	shared := make(map[string]struct{})
+	for _, cap := range our.Caps {
+		for _, theirs := range their.Caps {
+			if cap == theirs {
+				shared[cap.Name] = struct{}{}
+			}
+		}
+	}
+	t.maxStreams = len(shared) + 1
+	return their, nil
+}
+
+// stream returns the write stream of a subprotocol. Base protocol messages are
+// sent on stream zero.
+func (t *muxTransport) stream(name string) *muxWriteStream {
+	t.smu.Lock()
+	defer t.smu.Unlock()
+
+	s := t.streams[name]
+	if s == nil {
+		s = new(muxWriteStream)
+		if name != "" {
+			t.nextStream++
+			s.id = t.nextStream
+		}
+		t.streams[name] = s
+	}
+	return s
+}
+
+func (t *muxTransport) WriteMsg(msg Msg) error {
+	if !t.enabled {
+		return t.rlpxTransport.WriteMsg(msg)
+	}
+	s := t.stream(msg.meterCap.Name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.buf.Reset()
+	if _, err := io.CopyN(&s.buf, msg.Payload, int64(msg.Size)); err != nil {
+		return err
+	}
+	var (
+		data  = s.buf.Bytes()
+		total uint32
+		hdr   [3*binary.MaxVarintLen64 + 1]byte
+		frame []byte
+	)
+	for first := true; first || len(data) > 0; first = false {
+		// Encode the chunk header.
+		n := binary.PutUvarint(hdr[:], s.id)
+		flags := n
+		n++
+		hdr[flags] = 0
+		if first {
+			hdr[flags] |= muxFirstChunk
+			n += binary.PutUvarint(hdr[n:], msg.Code)
+		}
+		size := len(data)
+		if size > muxChunkSize {
+			size = muxChunkSize
+		}
+		if size == len(data) {
+			hdr[flags] |= muxLastChunk
+		}
+		frame = append(append(frame[:0], hdr[:n]...), data[:size]...)
+		data = data[size:]
+
+		// Write it. The token is held for a single chunk only. Blocked receivers
+		// of a channel are served in order, so streams waiting for the token
+		// take turns with this one.
+		<-t.wtoken
+		t.wmu.Lock()
+		t.conn.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+		wsize, err := t.conn.Write(muxFrameCode, frame)
+		t.wmu.Unlock()
+		t.wtoken <- struct{}{}
+		if err != nil {
+			return err
+		}
+		total += wsize
+	}
+
+	// Set metrics.
+	msg.meterSize = total
+	if metrics.Enabled && msg.meterCap.Name != "" { // don't meter non-subprotocol messages
+		meterProtoTraffic(egressProtoBytesMeter, egressProtoPacketsMeter, msg.meterCap, msg.meterCode, msg.meterSize)
+	}
+	return nil
+}
+
+func (t *muxTransport) ReadMsg() (Msg, error) {
+	if !t.enabled {
+		return t.rlpxTransport.ReadMsg()
+	}
+	t.rmu.Lock()
+	defer t.rmu.Unlock()
+
+	var wireSize uint32
+	for {
+		t.conn.SetReadDeadline(time.Now().Add(frameReadTimeout))
+		code, data, size, err := t.conn.Read()
+		if err != nil {
+			return Msg{}, err
+		}
+		wireSize += uint32(size)
+		if code != muxFrameCode {
+			// Plain message, e.g. a disconnect sent while closing.
+			return t.newMsg(code, append([]byte{}, data...), wireSize), nil
+		}
+		msg, complete, err := t.handleChunk(data)
+		if err != nil {
+			return Msg{}, err
+		}
+		if complete {
+			return t.newMsg(msg.code, msg.data, wireSize), nil
+		}
+	}
+}
+
+// handleChunk adds a chunk to its stream. When the chunk is the last one of a message,
+// the message is returned.
+func (t *muxTransport) handleChunk(chunk []byte) (*muxReadStream, bool, error) {
+	id, n := binary.Uvarint(chunk)
+	if n <= 0 || len(chunk) < n+1 {
+		return nil, false, errMuxInvalidChunk
+	}
+	flags := chunk[n]
+	chunk = chunk[n+1:]
+
+	s := t.partial[id]
+	if flags&muxFirstChunk != 0 {
+		if s != nil {
+			return nil, false, fmt.Errorf("%w: stream %d: message not finished", errMuxInvalidChunk, id)
+		}
+		code, n := binary.Uvarint(chunk)
+		if n <= 0 {
+			return nil, false, errMuxInvalidChunk
+		}
+		chunk = chunk[n:]
+		if len(t.partial) >= t.maxStreams {
+			return nil, false, errMuxTooManyStream
+		}
+		s = &muxReadStream{code: code}
+		t.partial[id] = s
+	} else if s == nil {
+		return nil, false, fmt.Errorf("%w: stream %d: message not started", errMuxInvalidChunk, id)
+	}
+	if len(s.data)+len(chunk) > muxMaxMessageSize {
+		return nil, false, errMuxMsgTooLarge
+	}
+	if t.buffered+len(chunk) > muxMaxBuffered {
+		return nil, false, errMuxBufferFull
+	}
+	s.data = append(s.data, chunk...)
+	t.buffered += len(chunk)
+	if flags&muxLastChunk == 0 {
+		return nil, false, nil
+	}
+	delete(t.partial, id)
+	t.buffered -= len(s.data)
+	return s, true, nil
+}
+
+func (t *muxTransport) newMsg(code uint64, data []byte, wireSize uint32) Msg {
+	return Msg{
+		ReceivedAt: time.Now(),
+		Code:       code,
+		Size:       uint32(len(data)),
+		meterSize:  wireSize,
+		Payload:    bytes.NewReader(data),
+	}
+}
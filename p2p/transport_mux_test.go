@@ -0,0 +1,284 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/testlog"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/simulations/pipes"
+)
+
+// muxTestPair runs the handshakes on two transports connected by a synchronous pipe.
+func muxTestPair(t *testing.T, newT0, newT1 func(net.Conn, *ecdsa.PublicKey) transport) (transport, transport) {
+	fd0, fd1, err := pipes.NetPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		prv0, _ = crypto.GenerateKey()
+		prv1, _ = crypto.GenerateKey()
+		t0      = newT0(fd0, &prv1.PublicKey)
+		t1      = newT1(fd1, nil)
+		errc    = make(chan error, 2)
+	)
+	handshake := func(tr transport, prv *ecdsa.PrivateKey, caps []Cap) {
+		if _, err := tr.doEncHandshake(prv); err != nil {
+			errc <- err
+			return
+		}
+		pub := crypto.FromECDSAPub(&prv.PublicKey)[1:]
+		hs, err := tr.doProtoHandshake(&protoHandshake{Version: baseProtocolVersion, ID: pub, Caps: caps})
+		if _, ok := tr.(*muxTransport); ok && err == nil && len(hs.Caps) != 2 {
+			t.Errorf("wrong remote caps: %v", hs.Caps)
+		}
+		errc <- err
+	}
+	caps := []Cap{{"a", 1}, {"b", 1}}
+	go handshake(t0, prv0, caps)
+	go handshake(t1, prv1, caps)
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal("handshake failed:", err)
+		}
+	}
+	return t0, t1
+}
+
+// notifyReader closes a channel when the underlying reader is drained.
+type notifyReader struct {
+	*bytes.Reader
+	done chan struct{}
+}
+
+func (r *notifyReader) Read(b []byte) (int, error) {
+	n, err := r.Reader.Read(b)
+	if r.Len() == 0 && r.done != nil {
+		close(r.done)
+		r.done = nil
+	}
+	return n, err
+}
+
+func isMultiplexed(tr transport) bool {
+	m, ok := tr.(multiplexedTransport)
+	return ok && m.multiplexed()
+}
+
+// This test checks that a small message isn't blocked by a large message which
+// is sent on another stream.
+func TestMuxTransportInterleave(t *testing.T) {
+	t0, t1 := muxTestPair(t, newMuxTransport, newMuxTransport)
+	defer t0.close(nil)
+	defer t1.close(nil)
+	if !isMultiplexed(t0) || !isMultiplexed(t1) {
+		t.Fatal("multiplexing not enabled")
+	}
+
+	var (
+		large   = bytes.Repeat([]byte{1}, 4*1024*1024)
+		small   = []byte{2, 3, 4}
+		started = make(chan struct{})
+		errc    = make(chan error, 2)
+	)
+	send := func(proto string, code uint64, payload io.Reader, size int) {
+		msg := Msg{Code: code, Size: uint32(size), Payload: payload}
+		msg.meterCap = Cap{proto, 1}
+		errc <- t0.WriteMsg(msg)
+	}
+	// The small message is sent once the large one has been taken from its payload
+	// reader. Writing the first chunk of the large message blocks because nothing
+	// reads from the pipe yet, so the small message queues up behind it.
+	go send("a", 16, &notifyReader{bytes.NewReader(large), started}, len(large))
+	go func() {
+		<-started
+		send("b", 17, bytes.NewReader(small), len(small))
+	}()
+	<-started
+	time.Sleep(20 * time.Millisecond)
+
+	for _, want := range []struct {
+		code uint64
+		data []byte
+	}{{17, small}, {16, large}} {
+		msg, err := t1.ReadMsg()
+		if err != nil {
+			t.Fatal("read error:", err)
+		}
+		data, _ := ioutil.ReadAll(msg.Payload)
+		if msg.Code != want.code || !bytes.Equal(data, want.data) {
+			t.Fatalf("wrong message received: code %d, size %d", msg.Code, len(data))
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal("write error:", err)
+		}
+	}
+}
+
+// This test checks that the mux transport falls back to plain RLPx.
+func TestMuxTransportFallback(t *testing.T) {
+	t0, t1 := muxTestPair(t, newMuxTransport, newRLPX)
+	defer t0.close(nil)
+	defer t1.close(nil)
+	if isMultiplexed(t0) {
+		t.Fatal("multiplexing enabled with plain RLPx peer")
+	}
+
+	go Send(t0, 16, []uint{1, 2, 3})
+	if err := ExpectMsg(t1, 16, []uint{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	go Send(t1, 17, []uint{4})
+	if err := ExpectMsg(t0, 17, []uint{4}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// This test checks that the mux transport rejects invalid chunk sequences.
+func TestMuxTransportInvalidChunks(t *testing.T) {
+	tr := newMuxTransport(nil, nil).(*muxTransport)
+	tr.maxStreams = 2
+	tests := []struct {
+		chunk []byte
+		ok    bool
+	}{
+		{[]byte{}, false},                          // no header
+		{[]byte{1, 0, 'x'}, false},                 // continuation of unknown message
+		{[]byte{1, muxFirstChunk, 16, 'a'}, true},  // start of message
+		{[]byte{1, muxFirstChunk, 16, 'b'}, false}, // restart before end
+		{[]byte{2, muxFirstChunk | muxLastChunk, 17}, true},
+		{[]byte{3, muxFirstChunk, 16, 'c'}, true},  // second partial message
+		{[]byte{4, muxFirstChunk, 16, 'd'}, false}, // more streams than negotiated
+	}
+	for i, test := range tests {
+		if _, _, err := tr.handleChunk(test.chunk); (err == nil) != test.ok {
+			t.Errorf("test %d: wrong result %v", i, err)
+		}
+	}
+	if tr.buffered != 2 {
+		t.Errorf("wrong buffered size: have %d, want 2", tr.buffered)
+	}
+	// Finishing a message releases its buffer.
+	if _, done, err := tr.handleChunk([]byte{1, muxLastChunk, 'e'}); err != nil || !done {
+		t.Fatalf("message not finished: %v", err)
+	}
+	if tr.buffered != 1 {
+		t.Errorf("wrong buffered size: have %d, want 1", tr.buffered)
+	}
+}
+
+// This test checks that the total size of partial messages is limited.
+func TestMuxTransportBufferLimit(t *testing.T) {
+	tr := newMuxTransport(nil, nil).(*muxTransport)
+	tr.maxStreams = 3
+
+	chunk := make([]byte, muxMaxMessageSize)
+	for id := byte(1); id <= 2; id++ {
+		if _, _, err := tr.handleChunk(append([]byte{id, muxFirstChunk, 16}, chunk...)); err != nil {
+			t.Fatalf("stream %d: %v", id, err)
+		}
+	}
+	if _, _, err := tr.handleChunk([]byte{3, muxFirstChunk, 16, 'x'}); err != errMuxBufferFull {
+		t.Fatalf("wrong error: have %v, want %v", err, errMuxBufferFull)
+	}
+}
+
+// This test runs two servers with multiplexing enabled over loopback.
+func TestServerMuxTransport(t *testing.T) {
+	var (
+		large    = bytes.Repeat([]byte{1}, 1024*1024)
+		received = make(chan uint64, 8)
+	)
+	proto := func(name string) Protocol {
+		return Protocol{
+			Name:    name,
+			Version: 1,
+			Length:  1,
+			Run: func(p *Peer, rw MsgReadWriter) error {
+				go Send(rw, 0, large)
+				for {
+					msg, err := rw.ReadMsg()
+					if err != nil {
+						return err
+					}
+					msg.Discard()
+					received <- msg.Code
+				}
+			},
+		}
+	}
+	newServer := func(name string) *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			NoDiscovery:  true,
+			ListenAddr:   "127.0.0.1:0",
+			MuxTransport: true,
+			Protocols:    []Protocol{proto("a"), proto("b")},
+			Logger:       testlog.Logger(t, log.LvlTrace).New("server", name),
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatal("can't start:", err)
+		}
+		return srv
+	}
+	srv1, srv2 := newServer("1"), newServer("2")
+	defer srv1.Stop()
+	defer srv2.Stop()
+
+	var muxEntryValue muxEntry
+	if err := srv2.Self().Load(&muxEntryValue); err != nil || muxEntryValue != muxVersion {
+		t.Fatalf("mux entry missing from ENR: %v", err)
+	}
+	if !syncAddPeer(srv1, srv2.Self()) {
+		t.Fatal("peer not connected")
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("messages not received")
+		}
+	}
+	if p := srv1.Peers()[0]; !p.multiplexed() {
+		t.Fatal("peer connection not multiplexed")
+	}
+
+	// Nodes without the ENR entry are dialed with plain RLPx.
+	srv3 := newServer("3")
+	defer srv3.Stop()
+	plain := enode.NewV4(&srv3.PrivateKey.PublicKey, srv3.Self().IP(), srv3.Self().TCP(), 0)
+	if !syncAddPeer(srv1, plain) {
+		t.Fatal("plain peer not connected")
+	}
+	for _, p := range srv1.Peers() {
+		if p.ID() == plain.ID() && p.multiplexed() {
+			t.Fatal("peer connection multiplexed without ENR entry")
+		}
+	}
+}
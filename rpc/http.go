@@ -29,6 +29,8 @@ import (
 	"net/url"
 	"sync"
 	"time"
+
+	"github.com/ethereum/go-ethereum/internal/tracing"
 )
 
 const (
@@ -179,6 +181,7 @@ func (hc *httpConn) doRequest(ctx context.Context, msg interface{}) (io.ReadClos
 	hc.mu.Lock()
 	req.Header = hc.headers.Clone()
 	hc.mu.Unlock()
+	tracing.Inject(ctx, req.Header)
 
 	// do request
 	resp, err := hc.client.Do(req)
@@ -249,6 +252,10 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if origin := r.Header.Get("Origin"); origin != "" {
 		ctx = context.WithValue(ctx, "Origin", origin)
 	}
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		ctx = context.WithValue(ctx, "TLS-Subject", r.TLS.PeerCertificates[0].Subject.String())
+	}
+	ctx = tracing.Extract(ctx, r.Header)
 
 	w.Header().Set("content-type", contentType)
 	codec := newHTTPServerConn(r, w)
@@ -17,7 +17,7 @@
 package rpc
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/ethereum/go-ethereum/metrics"
 )
@@ -27,13 +27,18 @@ var (
 	successfulRequestGauge = metrics.NewRegisteredGauge("rpc/success", nil)
 	failedReqeustGauge     = metrics.NewRegisteredGauge("rpc/failure", nil)
 	rpcServingTimer        = metrics.NewRegisteredTimer("rpc/duration/all", nil)
+
+	// rpcServingTimers tracks the serving times of the individual methods,
+	// labelled by method name and whether the call succeeded or failed. When
+	// flattened, the timers are named rpc/duration/<method>/<status>.
+	rpcServingTimers = metrics.NewRegisteredLabelledTimer("rpc/duration", nil, "method", "status")
 )
 
-func newRPCServingTimer(method string, valid bool) metrics.Timer {
+// updateServingTime records the serving time of a method call.
+func updateServingTime(method string, valid bool, start time.Time) {
 	flag := "success"
 	if !valid {
 		flag = "failure"
 	}
-	m := fmt.Sprintf("rpc/duration/%s/%s", method, flag)
-	return metrics.GetOrRegisterTimer(m, nil)
+	rpcServingTimers.With(method, flag).UpdateSince(start)
 }
@@ -26,11 +26,14 @@ import (
 	"reflect"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/hdwallet"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/remote"
 	"github.com/ethereum/go-ethereum/accounts/scwallet"
 	"github.com/ethereum/go-ethereum/accounts/usbwallet"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
@@ -41,9 +44,9 @@ const (
 	// numberOfAccountsToDerive For hardware wallets, the number of accounts to derive
 	numberOfAccountsToDerive = 10
 	// ExternalAPIVersion -- see extapi_changelog.md
-	ExternalAPIVersion = "6.1.0"
+	ExternalAPIVersion = "6.2.0"
 	// InternalAPIVersion -- see intapi_changelog.md
-	InternalAPIVersion = "7.0.1"
+	InternalAPIVersion = "7.1.1"
 )
 
 // ExternalAPI defines the external API through which signing requests are made.
@@ -57,13 +60,15 @@ type ExternalAPI interface {
 	// SignData - request to sign the given data (plus prefix)
 	SignData(ctx context.Context, contentType string, addr common.MixedcaseAddress, data interface{}) (hexutil.Bytes, error)
 	// SignTypedData - request to sign the given structured data (plus prefix)
-	SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data TypedData) (hexutil.Bytes, error)
+	SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data apitypes.TypedData) (hexutil.Bytes, error)
 	// EcRecover - recover public key from given message and signature
 	EcRecover(ctx context.Context, data hexutil.Bytes, sig hexutil.Bytes) (common.Address, error)
 	// Version info about the APIs
 	Version(ctx context.Context) (string, error)
 	// SignGnosisSafeTransaction signs/confirms a gnosis-safe multisig transaction
 	SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error)
+	// SignGnosisSafeExec signs a gnosis-safe multisig transaction with multiple owners, returning the execTransaction call
+	SignGnosisSafeExec(ctx context.Context, signers []common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeExecTx, error)
 }
 
 // UIClientAPI specifies what method a UI needs to implement to be able to be used as a
@@ -95,6 +100,14 @@ type UIClientAPI interface {
 	RegisterUIServer(api *UIServerAPI)
 }
 
+// SignTxFailureHandler may be implemented by a UI to be notified about approved
+// transactions which could not be signed, e.g. to release the value reserved
+// against spend limits on approval.
+type SignTxFailureHandler interface {
+	// OnSignTxFailed notifies the UI about an approved transaction having failed to sign.
+	OnSignTxFailed(tx apitypes.SendTxArgs)
+}
+
 // Validator defines the methods required to validate a transaction against some
 // sanity defaults as well as any underlying 4byte method database.
 //
@@ -125,6 +138,10 @@ type Metadata struct {
 	Scheme    string `json:"scheme"`
 	UserAgent string `json:"User-Agent"`
 	Origin    string `json:"Origin"`
+
+	// TLSSubject is the subject of the client certificate of requests received
+	// over mutually authenticated TLS.
+	TLSSubject string `json:"TLS-Subject,omitempty"`
 }
 
 func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath string) *accounts.Manager {
@@ -138,6 +155,7 @@ func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath str
 	// support password based accounts
 	if len(ksLocation) > 0 {
 		backends = append(backends, keystore.NewKeyStore(ksLocation, n, p))
+		backends = append(backends, hdwallet.NewBackend(ksLocation, n, p))
 	}
 	if !nousb {
 		// Start a USB hub for Ledger hardware wallets
@@ -188,7 +206,7 @@ func StartClefAccountManager(ksLocation string, nousb, lightKDF bool, scpath str
 
 // MetadataFromContext extracts Metadata from a given context.Context
 func MetadataFromContext(ctx context.Context) Metadata {
-	m := Metadata{"NA", "NA", "NA", "", ""} // batman
+	m := Metadata{"NA", "NA", "NA", "", "", ""} // batman
 
 	if v := ctx.Value("remote"); v != nil {
 		m.Remote = v.(string)
@@ -205,6 +223,9 @@ func MetadataFromContext(ctx context.Context) Metadata {
 	if v := ctx.Value("User-Agent"); v != nil {
 		m.UserAgent = v.(string)
 	}
+	if v := ctx.Value("TLS-Subject"); v != nil {
+		m.TLSSubject = v.(string)
+	}
 	return m
 }
 
@@ -235,7 +256,7 @@ type (
 		ContentType string                    `json:"content_type"`
 		Address     common.MixedcaseAddress   `json:"address"`
 		Rawdata     []byte                    `json:"raw_data"`
-		Messages    []*NameValueType          `json:"messages"`
+		Messages    []*apitypes.NameValueType `json:"messages"`
 		Callinfo    []apitypes.ValidationInfo `json:"call_info"`
 		Hash        hexutil.Bytes             `json:"hash"`
 		Meta        Metadata                  `json:"meta"`
@@ -537,6 +558,12 @@ func (api *SignerAPI) lookupOrQueryPassword(address common.Address, title, promp
 	return pwResp.Text, nil
 }
 
+// needsPassword reports whether signing with the wallet requires a password.
+// Keys held by remote signers are unlocked by the signer itself.
+func needsPassword(wallet accounts.Wallet) bool {
+	return wallet.URL().Scheme != remote.Scheme
+}
+
 // SignTransaction signs the given Transaction and returns it both as json and rlp-encoded form
 func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxArgs, methodSelector *string) (*ethapi.SignTransactionResult, error) {
 	var (
@@ -574,6 +601,12 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 	if !result.Approved {
 		return nil, ErrRequestDenied
 	}
+	// Let the UI know if the approved transaction could not be signed
+	defer func() {
+		if handler, ok := api.UI.(SignTxFailureHandler); ok && err != nil {
+			handler.OnSignTxFailed(result.Transaction)
+		}
+	}()
 	// Log changes made by the UI to the signing-request
 	logDiff(&req, &result)
 	var (
@@ -588,10 +621,13 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 	// Convert fields into a real transaction
 	var unsignedTx = result.Transaction.ToTransaction()
 	// Get the password for the transaction
-	pw, err := api.lookupOrQueryPassword(acc.Address, "Account password",
-		fmt.Sprintf("Please enter the password for account %s", acc.Address.String()))
-	if err != nil {
-		return nil, err
+	var pw string
+	if needsPassword(wallet) {
+		pw, err = api.lookupOrQueryPassword(acc.Address, "Account password",
+			fmt.Sprintf("Please enter the password for account %s", acc.Address.String()))
+		if err != nil {
+			return nil, err
+		}
 	}
 	// The one to sign is the one that was returned from the UI
 	signedTx, err := wallet.SignTxWithPassphrase(acc, pw, unsignedTx, api.chainID)
@@ -614,6 +650,39 @@ func (api *SignerAPI) SignTransaction(ctx context.Context, args apitypes.SendTxA
 }
 
 func (api *SignerAPI) SignGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error) {
+	msgs, err := api.validateGnosisSafeTx(&gnosisTx, methodSelector)
+	if err != nil {
+		return nil, err
+	}
+	return api.signGnosisSafeTx(ctx, signerAddress, gnosisTx, msgs)
+}
+
+// SignGnosisSafeExec signs a gnosis-safe multisig transaction with each of the
+// given owners, asking for approval of every signature. The signatures are then
+// aggregated with the confirmations already present in the transaction into an
+// execTransaction call, ready to be submitted.
+func (api *SignerAPI) SignGnosisSafeExec(ctx context.Context, signers []common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeExecTx, error) {
+	msgs, err := api.validateGnosisSafeTx(&gnosisTx, methodSelector)
+	if err != nil {
+		return nil, err
+	}
+	confirmations := append([]GnosisSafeConfirmation{}, gnosisTx.Confirmations...)
+	for _, signer := range signers {
+		signed, err := api.signGnosisSafeTx(ctx, signer, gnosisTx, msgs)
+		if err != nil {
+			return nil, err
+		}
+		confirmations = append(confirmations, GnosisSafeConfirmation{
+			Owner:     signed.Sender,
+			Signature: signed.Signature,
+		})
+	}
+	return gnosisTx.ExecTransaction(confirmations)
+}
+
+// validateGnosisSafeTx runs the usual transaction validations on the call made
+// by a gnosis-safe multisig transaction, filling in the chain id if needed.
+func (api *SignerAPI) validateGnosisSafeTx(gnosisTx *GnosisSafeTx, methodSelector *string) (*apitypes.ValidationMessages, error) {
 	// Do the usual validations, but on the last-stage transaction
 	args := gnosisTx.ArgsForValidation()
 	msgs, err := api.validator.ValidateTransaction(methodSelector, args)
@@ -626,7 +695,26 @@ func (api *SignerAPI) SignGnosisSafeTx(ctx context.Context, signerAddress common
 			return nil, err
 		}
 	}
-	typedData := gnosisTx.ToTypedData()
+	if gnosisTx.ChainId == nil {
+		gnosisTx.ChainId = (*math.HexOrDecimal256)(new(big.Int).Set(api.chainID))
+	}
+	// Make sure the transaction matches the hash expected by the caller, if any
+	hash, err := gnosisTx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if gnosisTx.InputExpHash != (common.Hash{}) && gnosisTx.InputExpHash != hash {
+		return nil, fmt.Errorf("safe transaction hash mismatch: have %x, want %x", hash, gnosisTx.InputExpHash)
+	}
+	return msgs, nil
+}
+
+// signGnosisSafeTx signs a validated gnosis-safe multisig transaction.
+func (api *SignerAPI) signGnosisSafeTx(ctx context.Context, signerAddress common.MixedcaseAddress, gnosisTx GnosisSafeTx, msgs *apitypes.ValidationMessages) (*GnosisSafeTx, error) {
+	typedData, err := gnosisTx.ToTypedData()
+	if err != nil {
+		return nil, err
+	}
 	signature, preimage, err := api.signTypedData(ctx, signerAddress, typedData, msgs)
 	if err != nil {
 		return nil, err
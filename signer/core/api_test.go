@@ -29,9 +29,11 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/accounts/remote"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/internal/ethapi"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/signer/core"
@@ -324,3 +326,45 @@ func TestSignTx(t *testing.T) {
 	}
 
 }
+
+// Tests that transactions can be signed with keys held by a remote signer,
+// without requesting a password for them.
+func TestSignTxRemote(t *testing.T) {
+	db, err := fourbyte.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := remote.NewMemorySigner()
+	signer.Add("ops", key)
+	backend, err := remote.NewBackend("test", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	control := &headlessUi{make(chan string, 20), make(chan string, 20)}
+	am := core.StartClefAccountManager(tmpDirName(t), true, true, "")
+	am.AddBackend(backend)
+	api := core.NewSignerAPI(am, 1337, true, control, db, true, &storage.NoStorage{})
+
+	addr := crypto.PubkeyToAddress(key.PublicKey)
+	methodSig := "test(uint)"
+	control.approveCh <- "Y"
+	control.inputCh <- "unused"
+	res, err := api.SignTransaction(context.Background(), mkTestTx(common.NewMixedcaseAddress(addr)), &methodSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(control.inputCh) != 1 {
+		t.Error("password requested for remote account")
+	}
+	sender, err := types.Sender(types.LatestSignerForChainID(big.NewInt(1337)), res.Tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender != addr {
+		t.Errorf("sender mismatch: have %x, want %x", sender, addr)
+	}
+}
@@ -14,7 +14,7 @@
 // You should have received a copy of the GNU Lesser General Public License
 // along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
 
-package core
+package apitypes
 
 import (
 	"bytes"
@@ -125,6 +125,11 @@ func TestParseInteger(t *testing.T) {
 		{"int32", "-123", big.NewInt(-123)},
 		{"uint32", "0xff", big.NewInt(0xff)},
 		{"int8", "0xffff", nil},
+		{"int8", "127", big.NewInt(127)},
+		{"int8", "128", nil},
+		{"int8", "-128", big.NewInt(-128)},
+		{"int8", "-129", nil},
+		{"uint8", "255", big.NewInt(255)},
 	} {
 		res, err := parseInteger(tt.t, tt.v)
 		if tt.exp == nil && res == nil {
@@ -143,3 +148,33 @@ func TestParseInteger(t *testing.T) {
 		}
 	}
 }
+
+func TestPrimitiveTypeValidity(t *testing.T) {
+	for _, tt := range []struct {
+		typ   string
+		valid bool
+	}{
+		{"address", true},
+		{"bool[]", true},
+		{"bytes", true},
+		{"bytes1", true},
+		{"bytes32[2][]", true},
+		{"bytes0", false},
+		{"bytes33", false},
+		{"bytes01", false},
+		{"int", true},
+		{"uint8[]", true},
+		{"int256[][3]", true},
+		{"uint7", false},
+		{"int264", false},
+		{"uint08", false},
+		{"uint256[0]", false},
+		{"uint256[", false},
+		{"string[][]", true},
+		{"float", false},
+	} {
+		if have := isPrimitiveTypeValid(tt.typ); have != tt.valid {
+			t.Errorf("type %q: validity mismatch: have %v, want %v", tt.typ, have, tt.valid)
+		}
+	}
+}
@@ -19,6 +19,7 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -33,9 +34,10 @@ type AuditLogger struct {
 }
 
 func (l *AuditLogger) List(ctx context.Context) ([]common.Address, error) {
-	l.log.Info("List", "type", "request", "metadata", MetadataFromContext(ctx).String())
+	logger := l.logger(ctx)
+	logger.Info("List", "type", "request", "metadata", MetadataFromContext(ctx).String())
 	res, e := l.api.List(ctx)
-	l.log.Info("List", "type", "response", "data", res)
+	logger.Info("List", "type", "response", "data", res)
 
 	return res, e
 }
@@ -45,74 +47,112 @@ func (l *AuditLogger) New(ctx context.Context) (common.Address, error) {
 }
 
 func (l *AuditLogger) SignTransaction(ctx context.Context, args apitypes.SendTxArgs, methodSelector *string) (*ethapi.SignTransactionResult, error) {
+	logger := l.logger(ctx)
 	sel := "<nil>"
 	if methodSelector != nil {
 		sel = *methodSelector
 	}
-	l.log.Info("SignTransaction", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+	logger.Info("SignTransaction", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"tx", args.String(),
 		"methodSelector", sel)
 
 	res, e := l.api.SignTransaction(ctx, args, methodSelector)
 	if res != nil {
-		l.log.Info("SignTransaction", "type", "response", "data", common.Bytes2Hex(res.Raw), "error", e)
+		logger.Info("SignTransaction", "type", "response", "data", common.Bytes2Hex(res.Raw), "error", e)
 	} else {
-		l.log.Info("SignTransaction", "type", "response", "data", res, "error", e)
+		logger.Info("SignTransaction", "type", "response", "data", res, "error", e)
 	}
 	return res, e
 }
 
 func (l *AuditLogger) SignData(ctx context.Context, contentType string, addr common.MixedcaseAddress, data interface{}) (hexutil.Bytes, error) {
+	logger := l.logger(ctx)
 	marshalledData, _ := json.Marshal(data) // can ignore error, marshalling what we just unmarshalled
-	l.log.Info("SignData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+	logger.Info("SignData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.String(), "data", marshalledData, "content-type", contentType)
 	b, e := l.api.SignData(ctx, contentType, addr, data)
-	l.log.Info("SignData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
+	logger.Info("SignData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
 	return b, e
 }
 
 func (l *AuditLogger) SignGnosisSafeTx(ctx context.Context, addr common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeTx, error) {
+	logger := l.logger(ctx)
 	sel := "<nil>"
 	if methodSelector != nil {
 		sel = *methodSelector
 	}
 	data, _ := json.Marshal(gnosisTx) // can ignore error, marshalling what we just unmarshalled
-	l.log.Info("SignGnosisSafeTx", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+	logger.Info("SignGnosisSafeTx", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.String(), "data", string(data), "selector", sel)
 	res, e := l.api.SignGnosisSafeTx(ctx, addr, gnosisTx, methodSelector)
 	if res != nil {
 		data, _ := json.Marshal(res) // can ignore error, marshalling what we just unmarshalled
-		l.log.Info("SignGnosisSafeTx", "type", "response", "data", string(data), "error", e)
+		logger.Info("SignGnosisSafeTx", "type", "response", "data", string(data), "error", e)
 	} else {
-		l.log.Info("SignGnosisSafeTx", "type", "response", "data", res, "error", e)
+		logger.Info("SignGnosisSafeTx", "type", "response", "data", res, "error", e)
 	}
 	return res, e
 }
 
-func (l *AuditLogger) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data TypedData) (hexutil.Bytes, error) {
-	l.log.Info("SignTypedData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+func (l *AuditLogger) SignGnosisSafeExec(ctx context.Context, signers []common.MixedcaseAddress, gnosisTx GnosisSafeTx, methodSelector *string) (*GnosisSafeExecTx, error) {
+	logger := l.logger(ctx)
+	sel := "<nil>"
+	if methodSelector != nil {
+		sel = *methodSelector
+	}
+	addrs := make([]string, len(signers))
+	for i, signer := range signers {
+		addrs[i] = signer.String()
+	}
+	data, _ := json.Marshal(gnosisTx) // can ignore error, marshalling what we just unmarshalled
+	logger.Info("SignGnosisSafeExec", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+		"signers", strings.Join(addrs, ","), "data", string(data), "selector", sel)
+	res, e := l.api.SignGnosisSafeExec(ctx, signers, gnosisTx, methodSelector)
+	if res != nil {
+		data, _ := json.Marshal(res) // can ignore error, marshalling what we just unmarshalled
+		logger.Info("SignGnosisSafeExec", "type", "response", "data", string(data), "error", e)
+	} else {
+		logger.Info("SignGnosisSafeExec", "type", "response", "data", res, "error", e)
+	}
+	return res, e
+}
+
+func (l *AuditLogger) SignTypedData(ctx context.Context, addr common.MixedcaseAddress, data apitypes.TypedData) (hexutil.Bytes, error) {
+	logger := l.logger(ctx)
+	logger.Info("SignTypedData", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"addr", addr.String(), "data", data)
 	b, e := l.api.SignTypedData(ctx, addr, data)
-	l.log.Info("SignTypedData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
+	logger.Info("SignTypedData", "type", "response", "data", common.Bytes2Hex(b), "error", e)
 	return b, e
 }
 
 func (l *AuditLogger) EcRecover(ctx context.Context, data hexutil.Bytes, sig hexutil.Bytes) (common.Address, error) {
-	l.log.Info("EcRecover", "type", "request", "metadata", MetadataFromContext(ctx).String(),
+	logger := l.logger(ctx)
+	logger.Info("EcRecover", "type", "request", "metadata", MetadataFromContext(ctx).String(),
 		"data", common.Bytes2Hex(data), "sig", common.Bytes2Hex(sig))
 	b, e := l.api.EcRecover(ctx, data, sig)
-	l.log.Info("EcRecover", "type", "response", "address", b.String(), "error", e)
+	logger.Info("EcRecover", "type", "response", "address", b.String(), "error", e)
 	return b, e
 }
 
 func (l *AuditLogger) Version(ctx context.Context) (string, error) {
-	l.log.Info("Version", "type", "request", "metadata", MetadataFromContext(ctx).String())
+	logger := l.logger(ctx)
+	logger.Info("Version", "type", "request", "metadata", MetadataFromContext(ctx).String())
 	data, err := l.api.Version(ctx)
-	l.log.Info("Version", "type", "response", "data", data, "error", err)
+	logger.Info("Version", "type", "response", "data", data, "error", err)
 	return data, err
 
 }
 
+// logger returns the logger for auditing a request, tagging all entries with the
+// subject of the client certificate for requests received over mutual TLS.
+func (l *AuditLogger) logger(ctx context.Context) log.Logger {
+	if subject := MetadataFromContext(ctx).TLSSubject; subject != "" {
+		return l.log.New("subject", subject)
+	}
+	return l.log
+}
+
 func NewAuditLogger(path string, api ExternalAPI) (*AuditLogger, error) {
 	l := log.New("api", "signer")
 	handler, err := log.FileHandler(path, log.LogfmtFormat())
@@ -1,15 +1,26 @@
 package core
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
+// gnosisSafeExecABI is the ABI of the execTransaction method of the Safe, which
+// is identical across all versions of the contract.
+const gnosisSafeExecABI = `[{"type":"function","name":"execTransaction","stateMutability":"payable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}],"outputs":[{"name":"success","type":"bool"}]}]`
+
 // GnosisSafeTx is a type to parse the safe-tx returned by the relayer,
 // it also conforms to the API required by the Gnosis Safe tx relay service.
 // See 'SafeMultisigTransaction' on https://safe-transaction.mainnet.gnosis.io/
@@ -19,60 +30,165 @@ type GnosisSafeTx struct {
 	SafeTxHash common.Hash             `json:"contractTransactionHash"`
 	Sender     common.MixedcaseAddress `json:"sender"`
 	// These fields are used both on input and output
-	Safe           common.MixedcaseAddress `json:"safe"`
-	To             common.MixedcaseAddress `json:"to"`
-	Value          math.Decimal256         `json:"value"`
-	GasPrice       math.Decimal256         `json:"gasPrice"`
-	Data           *hexutil.Bytes          `json:"data"`
-	Operation      uint8                   `json:"operation"`
-	GasToken       common.Address          `json:"gasToken"`
-	RefundReceiver common.Address          `json:"refundReceiver"`
-	BaseGas        big.Int                 `json:"baseGas"`
-	SafeTxGas      big.Int                 `json:"safeTxGas"`
-	Nonce          big.Int                 `json:"nonce"`
-	InputExpHash   common.Hash             `json:"safeTxHash"`
+	Safe           common.MixedcaseAddress  `json:"safe"`
+	To             common.MixedcaseAddress  `json:"to"`
+	Value          math.Decimal256          `json:"value"`
+	GasPrice       math.Decimal256          `json:"gasPrice"`
+	Data           *hexutil.Bytes           `json:"data"`
+	Operation      uint8                    `json:"operation"`
+	GasToken       common.Address           `json:"gasToken"`
+	RefundReceiver common.Address           `json:"refundReceiver"`
+	BaseGas        big.Int                  `json:"baseGas"`
+	SafeTxGas      big.Int                  `json:"safeTxGas"`
+	Nonce          big.Int                  `json:"nonce"`
+	InputExpHash   common.Hash              `json:"safeTxHash"`
+	Confirmations  []GnosisSafeConfirmation `json:"confirmations,omitempty"`
+	// These fields are only used on input, selecting the hashing scheme of the
+	// Safe contract. Versions before 1.3.0 don't need the chain id.
+	Version string                `json:"version,omitempty"`
+	ChainId *math.HexOrDecimal256 `json:"chainId,omitempty"`
+}
+
+// GnosisSafeConfirmation is the signature of a Safe transaction by one of the
+// owners of the Safe, as listed by the Gnosis Safe tx relay service.
+type GnosisSafeConfirmation struct {
+	Owner     common.MixedcaseAddress `json:"owner"`
+	Signature hexutil.Bytes           `json:"signature"`
+}
+
+// GnosisSafeExecTx is a call of execTransaction on a Safe, carrying the signatures
+// of its owners. The call is ready to be submitted by any account, provided that
+// the signatures reach the threshold of the Safe.
+type GnosisSafeExecTx struct {
+	SafeTxHash common.Hash             `json:"safeTxHash"`
+	Owners     []common.Address        `json:"owners"`     // Owners whose signatures are included, in order
+	Signatures hexutil.Bytes           `json:"signatures"` // Aggregated signatures of the owners
+	To         common.MixedcaseAddress `json:"to"`         // Address of the Safe
+	Data       hexutil.Bytes           `json:"data"`       // Calldata of the execTransaction call
+}
+
+// NewGnosisSafeTx creates a Safe transaction executing a call with the given
+// calldata, without gas refunds to the submitter.
+func NewGnosisSafeTx(safe, to common.Address, value *big.Int, data []byte, operation uint8, nonce uint64) *GnosisSafeTx {
+	calldata := hexutil.Bytes(common.CopyBytes(data))
+	tx := &GnosisSafeTx{
+		Safe:      common.NewMixedcaseAddress(safe),
+		To:        common.NewMixedcaseAddress(to),
+		Data:      &calldata,
+		Operation: operation,
+	}
+	if value != nil {
+		tx.Value = math.Decimal256(*new(big.Int).Set(value))
+	}
+	tx.Nonce.SetUint64(nonce)
+	return tx
+}
+
+// hashScheme returns how the version of the Safe contract computes transaction
+// hashes: whether the gas field is still named dataGas (before 1.0.0), and whether
+// the domain includes the chain id (since 1.3.0). Without a version, the scheme
+// of versions 1.0.0 to 1.2.0 is used.
+func (tx *GnosisSafeTx) hashScheme() (legacy bool, chainID bool, err error) {
+	if tx.Version == "" {
+		return false, false, nil
+	}
+	version := strings.TrimPrefix(tx.Version, "v")
+	if i := strings.IndexAny(version, "+-"); i >= 0 {
+		version = version[:i] // Strip variants like 1.3.0+L2
+	}
+	parts := strings.Split(version, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return false, false, fmt.Errorf("invalid Safe version %q", tx.Version)
+	}
+	nums := make([]int, len(parts))
+	for i, part := range parts {
+		if nums[i], err = strconv.Atoi(part); err != nil || nums[i] < 0 {
+			return false, false, fmt.Errorf("invalid Safe version %q", tx.Version)
+		}
+	}
+	switch major, minor := nums[0], nums[1]; {
+	case major < 1:
+		return true, false, nil
+	case major == 1 && minor < 3:
+		return false, false, nil
+	default:
+		return false, true, nil
+	}
 }
 
 // ToTypedData converts the tx to a EIP-712 Typed Data structure for signing
-func (tx *GnosisSafeTx) ToTypedData() TypedData {
+func (tx *GnosisSafeTx) ToTypedData() (apitypes.TypedData, error) {
+	legacy, withChainID, err := tx.hashScheme()
+	if err != nil {
+		return apitypes.TypedData{}, err
+	}
 	var data hexutil.Bytes
 	if tx.Data != nil {
 		data = *tx.Data
 	}
-	gnosisTypedData := TypedData{
-		Types: Types{
-			"EIP712Domain": []Type{{Name: "verifyingContract", Type: "address"}},
-			"SafeTx": []Type{
+	baseGas := "baseGas"
+	if legacy {
+		baseGas = "dataGas"
+	}
+	domainType := []apitypes.Type{{Name: "verifyingContract", Type: "address"}}
+	domain := apitypes.TypedDataDomain{
+		VerifyingContract: tx.Safe.Address().Hex(),
+	}
+	if withChainID {
+		if tx.ChainId == nil {
+			return apitypes.TypedData{}, fmt.Errorf("chain id required for Safe version %s", tx.Version)
+		}
+		domainType = []apitypes.Type{
+			{Name: "chainId", Type: "uint256"},
+			{Name: "verifyingContract", Type: "address"},
+		}
+		domain.ChainId = tx.ChainId
+	}
+	gnosisTypedData := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": domainType,
+			"SafeTx": []apitypes.Type{
 				{Name: "to", Type: "address"},
 				{Name: "value", Type: "uint256"},
 				{Name: "data", Type: "bytes"},
 				{Name: "operation", Type: "uint8"},
 				{Name: "safeTxGas", Type: "uint256"},
-				{Name: "baseGas", Type: "uint256"},
+				{Name: baseGas, Type: "uint256"},
 				{Name: "gasPrice", Type: "uint256"},
 				{Name: "gasToken", Type: "address"},
 				{Name: "refundReceiver", Type: "address"},
 				{Name: "nonce", Type: "uint256"},
 			},
 		},
-		Domain: TypedDataDomain{
-			VerifyingContract: tx.Safe.Address().Hex(),
-		},
+		Domain:      domain,
 		PrimaryType: "SafeTx",
-		Message: TypedDataMessage{
+		Message: apitypes.TypedDataMessage{
 			"to":             tx.To.Address().Hex(),
 			"value":          tx.Value.String(),
 			"data":           data,
 			"operation":      fmt.Sprintf("%d", tx.Operation),
 			"safeTxGas":      fmt.Sprintf("%#d", &tx.SafeTxGas),
-			"baseGas":        fmt.Sprintf("%#d", &tx.BaseGas),
+			baseGas:          fmt.Sprintf("%#d", &tx.BaseGas),
 			"gasPrice":       tx.GasPrice.String(),
 			"gasToken":       tx.GasToken.Hex(),
 			"refundReceiver": tx.RefundReceiver.Hex(),
 			"nonce":          fmt.Sprintf("%d", tx.Nonce.Uint64()),
 		},
 	}
-	return gnosisTypedData
+	return gnosisTypedData, nil
+}
+
+// Hash returns the hash of the Safe transaction signed by the owners.
+func (tx *GnosisSafeTx) Hash() (common.Hash, error) {
+	typedData, err := tx.ToTypedData()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	sighash, _, err := apitypes.TypedDataAndHash(typedData)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(sighash), nil
 }
 
 // ArgsForValidation returns a SendTxArgs struct, which can be used for the
@@ -91,3 +207,67 @@ func (tx *GnosisSafeTx) ArgsForValidation() *apitypes.SendTxArgs {
 	}
 	return args
 }
+
+// ExecTransaction aggregates the signatures of the given confirmations into a
+// call of execTransaction. Every signature is verified against its owner, and
+// the signatures are ordered by owner address, as required by the Safe. Whether
+// the signatures reach the threshold of the Safe is not checked.
+func (tx *GnosisSafeTx) ExecTransaction(confirmations []GnosisSafeConfirmation) (*GnosisSafeExecTx, error) {
+	hash, err := tx.Hash()
+	if err != nil {
+		return nil, err
+	}
+	if len(confirmations) == 0 {
+		return nil, errors.New("no confirmations")
+	}
+	signatures := make(map[common.Address][]byte)
+	for _, conf := range confirmations {
+		owner := conf.Owner.Address()
+		if len(conf.Signature) != crypto.SignatureLength {
+			return nil, fmt.Errorf("invalid signature length %d of owner %s", len(conf.Signature), owner.Hex())
+		}
+		if v := conf.Signature[crypto.RecoveryIDOffset]; v != 27 && v != 28 {
+			return nil, fmt.Errorf("unsupported signature type %d of owner %s", v, owner.Hex())
+		}
+		sig := common.CopyBytes(conf.Signature)
+		sig[crypto.RecoveryIDOffset] -= 27
+		pubkey, err := crypto.SigToPub(hash[:], sig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signature of owner %s: %v", owner.Hex(), err)
+		}
+		if signer := crypto.PubkeyToAddress(*pubkey); signer != owner {
+			return nil, fmt.Errorf("signature of owner %s made by %s", owner.Hex(), signer.Hex())
+		}
+		signatures[owner] = conf.Signature
+	}
+	owners := make([]common.Address, 0, len(signatures))
+	for owner := range signatures {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool { return bytes.Compare(owners[i][:], owners[j][:]) < 0 })
+
+	var aggregate []byte
+	for _, owner := range owners {
+		aggregate = append(aggregate, signatures[owner]...)
+	}
+	safeABI, err := abi.JSON(strings.NewReader(gnosisSafeExecABI))
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if tx.Data != nil {
+		data = *tx.Data
+	}
+	calldata, err := safeABI.Pack("execTransaction", tx.To.Address(), (*big.Int)(&tx.Value), data, tx.Operation,
+		&tx.SafeTxGas, &tx.BaseGas, (*big.Int)(&tx.GasPrice), tx.GasToken, tx.RefundReceiver, aggregate)
+	if err != nil {
+		return nil, err
+	}
+	return &GnosisSafeExecTx{
+		SafeTxHash: hash,
+		Owners:     owners,
+		Signatures: aggregate,
+		To:         tx.Safe,
+		Data:       calldata,
+	}, nil
+}
@@ -0,0 +1,207 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package core_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core"
+)
+
+// Tests that Safe transactions are hashed with the type definitions of the
+// requested Safe contract version.
+func TestGnosisSafeVersions(t *testing.T) {
+	var tx core.GnosisSafeTx
+	if err := json.Unmarshal([]byte(gnosisTx), &tx); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		version    string
+		domainHash string
+		safeTxHash string
+	}{
+		{"", "0x035aff83d86937d35b32e04f0ddc6ff469290eef2f1b692d8a815c89404d4749", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+		{"0.1.0", "0x035aff83d86937d35b32e04f0ddc6ff469290eef2f1b692d8a815c89404d4749", "0x14d461bc7412367e924637b363c7bf29b8f47e2f84869f4426e5633d8af47b20"},
+		{"1.1.1", "0x035aff83d86937d35b32e04f0ddc6ff469290eef2f1b692d8a815c89404d4749", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+		{"1.3.0", "0x47e79534a245952e8b16893a336b85a3d9ea9fa8c573f3d803afb92a79469218", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+		{"v1.3.0+L2", "0x47e79534a245952e8b16893a336b85a3d9ea9fa8c573f3d803afb92a79469218", "0xbb8310d486368db6bd6f849402fdd73ad53d316b5a4b2644ad6efe0f941286d8"},
+	}
+	hashes := make(map[string]common.Hash)
+	for _, tt := range tests {
+		tx.Version = tt.version
+		tx.ChainId = math.NewHexOrDecimal256(1)
+
+		td, err := tx.ToTypedData()
+		if err != nil {
+			t.Fatalf("version %q: %v", tt.version, err)
+		}
+		if have := td.TypeHash("EIP712Domain").String(); have != tt.domainHash {
+			t.Errorf("version %q: domain type hash mismatch: have %s, want %s", tt.version, have, tt.domainHash)
+		}
+		if have := td.TypeHash("SafeTx").String(); have != tt.safeTxHash {
+			t.Errorf("version %q: SafeTx type hash mismatch: have %s, want %s", tt.version, have, tt.safeTxHash)
+		}
+		hash, err := tx.Hash()
+		if err != nil {
+			t.Fatalf("version %q: %v", tt.version, err)
+		}
+		hashes[tt.version] = hash
+	}
+	// The relay computed the hash with the scheme of 1.1.1, the default
+	if hashes[""] != tx.InputExpHash || hashes["1.1.1"] != tx.InputExpHash {
+		t.Errorf("relay hash %x not matched by default scheme", tx.InputExpHash)
+	}
+	if hashes["0.1.0"] == tx.InputExpHash || hashes["1.3.0"] == tx.InputExpHash || hashes["1.3.0"] != hashes["v1.3.0+L2"] {
+		t.Errorf("hash schemes not distinct: %x", hashes)
+	}
+	// Versions since 1.3.0 need the chain id, invalid versions are rejected
+	tx.Version, tx.ChainId = "1.3.0", nil
+	if _, err := tx.Hash(); err == nil {
+		t.Errorf("hashed without chain id")
+	}
+	for _, version := range []string{"1", "one.two", "1.3.0.1"} {
+		tx.Version = version
+		if _, err := tx.Hash(); err == nil {
+			t.Errorf("version %q accepted", version)
+		}
+	}
+}
+
+// Tests that confirmations are verified and aggregated into an execTransaction call.
+func TestGnosisSafeExecTransaction(t *testing.T) {
+	var tx core.GnosisSafeTx
+	if err := json.Unmarshal([]byte(gnosisTx), &tx); err != nil {
+		t.Fatal(err)
+	}
+	// The confirmation made through the relay must be valid
+	relayOwner := common.HexToAddress("0xAd2e180019FCa9e55CADe76E4487F126Fd08DA34")
+	exec, err := tx.ExecTransaction(tx.Confirmations)
+	if err != nil {
+		t.Fatalf("failed to aggregate relay confirmation: %v", err)
+	}
+	if len(exec.Owners) != 1 || exec.Owners[0] != relayOwner {
+		t.Errorf("owners mismatch: have %x, want %x", exec.Owners, relayOwner)
+	}
+	if exec.SafeTxHash != tx.InputExpHash {
+		t.Errorf("hash mismatch: have %x, want %x", exec.SafeTxHash, tx.InputExpHash)
+	}
+	// Tampered or misattributed signatures must be rejected
+	tampered := []core.GnosisSafeConfirmation{{Owner: common.NewMixedcaseAddress(common.Address{1}), Signature: tx.Confirmations[0].Signature}}
+	if _, err := tx.ExecTransaction(tampered); err == nil {
+		t.Errorf("accepted signature of wrong owner")
+	}
+	sig := common.CopyBytes(tx.Confirmations[0].Signature)
+	sig[64] = 31 // eth_sign signature type
+	if _, err := tx.ExecTransaction([]core.GnosisSafeConfirmation{{Owner: tx.Confirmations[0].Owner, Signature: sig}}); err == nil {
+		t.Errorf("accepted unsupported signature type")
+	}
+	if _, err := tx.ExecTransaction(nil); err == nil {
+		t.Errorf("aggregated without confirmations")
+	}
+}
+
+// Tests that clef signs Safe transactions with multiple managed owners, and
+// produces an execTransaction call with the signatures ordered by owner.
+func TestSignGnosisSafeExec(t *testing.T) {
+	api, control := setup(t)
+	createAccount(control, api, t)
+	createAccount(control, api, t)
+	control.approveCh <- "A"
+	list, err := api.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		safe     = common.HexToAddress("0x25a6c4BBd32B2424A9c99aEB0584Ad12045382B3")
+		to       = common.HexToAddress("0x9eE457023bB3De16D51A003a247BaEaD7fce313D")
+		calldata = common.FromHex("0xa9059cbb000000000000000000000000ad2e180019fca9e55cade76e4487f126fd08da340000000000000000000000000000000000000000000000000000000000000001")
+		signers  = []common.MixedcaseAddress{common.NewMixedcaseAddress(list[1]), common.NewMixedcaseAddress(list[0])}
+	)
+	tx := core.NewGnosisSafeTx(safe, to, big.NewInt(0), calldata, 0, 7)
+	tx.Version = "1.3.0"
+
+	for range signers {
+		control.approveCh <- "Y"
+		control.inputCh <- "a_long_password"
+	}
+	exec, err := api.SignGnosisSafeExec(context.Background(), signers, *tx, nil)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	// The chain id of clef must have been used for hashing
+	tx.ChainId = math.NewHexOrDecimal256(1337)
+	hash, err := tx.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exec.SafeTxHash != hash {
+		t.Errorf("hash mismatch: have %x, want %x", exec.SafeTxHash, hash)
+	}
+	if exec.To.Address() != safe {
+		t.Errorf("call target mismatch: have %x, want %x", exec.To.Address(), safe)
+	}
+	if len(exec.Owners) != 2 || bytes.Compare(exec.Owners[0][:], exec.Owners[1][:]) >= 0 {
+		t.Fatalf("owners not sorted: %x", exec.Owners)
+	}
+	for i, owner := range exec.Owners {
+		sig := common.CopyBytes(exec.Signatures[i*65 : (i+1)*65])
+		sig[64] -= 27
+		pubkey, err := crypto.SigToPub(hash[:], sig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signer := crypto.PubkeyToAddress(*pubkey); signer != owner {
+			t.Errorf("signature %d: signer mismatch: have %x, want %x", i, signer, owner)
+		}
+	}
+	// Decode the execTransaction call and check its arguments
+	if !bytes.Equal(exec.Data[:4], common.FromHex("0x6a761202")) {
+		t.Fatalf("method selector mismatch: have %x", exec.Data[:4])
+	}
+	safeABI, err := abi.JSON(strings.NewReader(`[{"type":"function","name":"execTransaction","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"},{"name":"data","type":"bytes"},{"name":"operation","type":"uint8"},{"name":"safeTxGas","type":"uint256"},{"name":"baseGas","type":"uint256"},{"name":"gasPrice","type":"uint256"},{"name":"gasToken","type":"address"},{"name":"refundReceiver","type":"address"},{"name":"signatures","type":"bytes"}]}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	args, err := safeABI.Methods["execTransaction"].Inputs.Unpack(exec.Data[4:])
+	if err != nil {
+		t.Fatalf("failed to decode call: %v", err)
+	}
+	if args[0].(common.Address) != to || !bytes.Equal(args[2].([]byte), calldata) || !bytes.Equal(args[9].([]byte), exec.Signatures) {
+		t.Errorf("call arguments mismatch: %v", args)
+	}
+	// Denying any of the signatures aborts the execution
+	control.approveCh <- "Y"
+	control.inputCh <- "a_long_password"
+	control.approveCh <- "N"
+	if _, err := api.SignGnosisSafeExec(context.Background(), signers, *tx, nil); err != core.ErrRequestDenied {
+		t.Errorf("denied signature: have %v, want %v", err, core.ErrRequestDenied)
+	}
+	// Transactions not matching the expected hash are rejected
+	tx.InputExpHash = common.Hash{1}
+	if _, err := api.SignGnosisSafeExec(context.Background(), signers, *tx, nil); err == nil {
+		t.Errorf("signed transaction with mismatching hash")
+	}
+}
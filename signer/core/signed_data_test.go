@@ -32,9 +32,10 @@ import (
 	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 )
 
-var typesStandard = core.Types{
+var typesStandard = apitypes.Types{
 	"EIP712Domain": {
 		{
 			Name: "name",
@@ -153,12 +154,12 @@ var jsonTypedData = `
 
 const primaryType = "Mail"
 
-var domainStandard = core.TypedDataDomain{
-	"Ether Mail",
-	"1",
-	math.NewHexOrDecimal256(1),
-	"0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
-	"",
+var domainStandard = apitypes.TypedDataDomain{
+	Name:              "Ether Mail",
+	Version:           "1",
+	ChainId:           math.NewHexOrDecimal256(1),
+	VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	Salt:              "",
 }
 
 var messageStandard = map[string]interface{}{
@@ -173,7 +174,7 @@ var messageStandard = map[string]interface{}{
 	"contents": "Hello, Bob!",
 }
 
-var typedData = core.TypedData{
+var typedData = apitypes.TypedData{
 	Types:       typesStandard,
 	PrimaryType: primaryType,
 	Domain:      domainStandard,
@@ -232,13 +233,13 @@ func TestSignData(t *testing.T) {
 }
 
 func TestDomainChainId(t *testing.T) {
-	withoutChainID := core.TypedData{
-		Types: core.Types{
-			"EIP712Domain": []core.Type{
+	withoutChainID := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": []apitypes.Type{
 				{Name: "name", Type: "string"},
 			},
 		},
-		Domain: core.TypedDataDomain{
+		Domain: apitypes.TypedDataDomain{
 			Name: "test",
 		},
 	}
@@ -250,14 +251,14 @@ func TestDomainChainId(t *testing.T) {
 	if _, err := withoutChainID.HashStruct("EIP712Domain", withoutChainID.Domain.Map()); err != nil {
 		t.Errorf("Expected the typedData to encode the domain successfully, got %v", err)
 	}
-	withChainID := core.TypedData{
-		Types: core.Types{
-			"EIP712Domain": []core.Type{
+	withChainID := apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": []apitypes.Type{
 				{Name: "name", Type: "string"},
 				{Name: "chainId", Type: "uint256"},
 			},
 		},
-		Domain: core.TypedDataDomain{
+		Domain: apitypes.TypedDataDomain{
 			Name:    "test",
 			ChainId: math.NewHexOrDecimal256(1),
 		},
@@ -322,8 +323,75 @@ func TestEncodeData(t *testing.T) {
 	}
 }
 
+// Tests the encoding of arrays of structs against the reference vectors of the
+// EIP-712 v4 implementation in eth-sig-util.
+func TestTypedDataArrayOfStructs(t *testing.T) {
+	data, err := ioutil.ReadFile(path.Join("testdata", "eip712_v4.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var td apitypes.TypedData
+	if err := json.Unmarshal(data, &td); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(td.EncodeType("Mail")), "Mail(Person from,Person[] to,string contents)Person(string name,address[] wallets)"; have != want {
+		t.Errorf("encodeType mismatch: have %s, want %s", have, want)
+	}
+	if have, want := string(td.EncodeType("Group")), "Group(string name,Person[] members)Person(string name,address[] wallets)"; have != want {
+		t.Errorf("encodeType mismatch: have %s, want %s", have, want)
+	}
+	if have, want := td.TypeHash("Person").String(), "0xfabfe1ed996349fc6027709802be19d047da1aa5d6894ff5f6486d92db2e6860"; have != want {
+		t.Errorf("typeHash mismatch: have %s, want %s", have, want)
+	}
+	if have, want := td.TypeHash("Mail").String(), "0x4bd8a9a2b93427bb184aca81e24beb30ffa3c747e2a33d4225ec08bf12e2e753"; have != want {
+		t.Errorf("typeHash mismatch: have %s, want %s", have, want)
+	}
+	hash, err := td.HashStruct(td.PrimaryType, td.Message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := hash.String(), "0xeb4221181ff3f1a83ea7313993ca9218496e424604ba9492bb4052c03d5c3df8"; have != want {
+		t.Errorf("hashStruct mismatch: have %s, want %s", have, want)
+	}
+	sighash, _, err := apitypes.TypedDataAndHash(td)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, want := hexutil.Encode(sighash), "0xa85c2e2b118698e88db68a8105b794a8cc7cec074e89ef991cb4f5f533819cc2"; have != want {
+		t.Errorf("signing hash mismatch: have %s, want %s", have, want)
+	}
+}
+
+// Tests that recursive types are encoded, with unset references hashed as zero.
+func TestTypedDataRecursive(t *testing.T) {
+	data, err := ioutil.ReadFile(path.Join("testdata", "recursive.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var td apitypes.TypedData
+	if err := json.Unmarshal(data, &td); err != nil {
+		t.Fatal(err)
+	}
+	if have, want := string(td.EncodeType("Node")), "Node(uint256 value,Node next,Node[] children)"; have != want {
+		t.Errorf("encodeType mismatch: have %s, want %s", have, want)
+	}
+	// The leaf node { value: 4 } is encoded as typeHash ‖ 4 ‖ 0 ‖ keccak256()
+	leaf := td.Message["children"].([]interface{})[0].(map[string]interface{})["next"].(map[string]interface{})
+	enc, err := td.EncodeData("Node", leaf, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte{}, td.TypeHash("Node")...)
+	want = append(want, common.LeftPadBytes([]byte{4}, 32)...)
+	want = append(want, make([]byte, 32)...)
+	want = append(want, crypto.Keccak256()...)
+	if !bytes.Equal(enc, want) {
+		t.Errorf("encodeData mismatch: have %x, want %x", enc, want)
+	}
+}
+
 func TestFormatter(t *testing.T) {
-	var d core.TypedData
+	var d apitypes.TypedData
 	err := json.Unmarshal([]byte(jsonTypedData), &d)
 	if err != nil {
 		t.Fatalf("unmarshalling failed '%v'", err)
@@ -337,7 +405,7 @@ func TestFormatter(t *testing.T) {
 	t.Logf("'%v'\n", string(j))
 }
 
-func sign(typedData core.TypedData) ([]byte, []byte, error) {
+func sign(typedData apitypes.TypedData) ([]byte, []byte, error) {
 	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
 	if err != nil {
 		return nil, nil, err
@@ -366,7 +434,7 @@ func TestJsonFiles(t *testing.T) {
 			t.Errorf("Failed to read file %v: %v", fInfo.Name(), err)
 			continue
 		}
-		var typedData core.TypedData
+		var typedData apitypes.TypedData
 		err = json.Unmarshal(data, &typedData)
 		if err != nil {
 			t.Errorf("Test %d, file %v, json unmarshalling failed: %v", i, fInfo.Name(), err)
@@ -398,7 +466,7 @@ func TestFuzzerFiles(t *testing.T) {
 			t.Errorf("Failed to read file %v: %v", fInfo.Name(), err)
 			continue
 		}
-		var typedData core.TypedData
+		var typedData apitypes.TypedData
 		err = json.Unmarshal(data, &typedData)
 		if err != nil {
 			t.Errorf("Test %d, file %v, json unmarshalling failed: %v", i, fInfo.Name(), err)
@@ -498,7 +566,7 @@ var gnosisTx = `
 // TestGnosisTypedData tests the scenario where a user submits a full EIP-712
 // struct without using the gnosis-specific endpoint
 func TestGnosisTypedData(t *testing.T) {
-	var td core.TypedData
+	var td apitypes.TypedData
 	err := json.Unmarshal([]byte(gnosisTypedData), &td)
 	if err != nil {
 		t.Fatalf("unmarshalling failed '%v'", err)
@@ -521,7 +589,10 @@ func TestGnosisCustomData(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	var td = tx.ToTypedData()
+	td, err := tx.ToTypedData()
+	if err != nil {
+		t.Fatal(err)
+	}
 	_, sighash, err := sign(td)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,522 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/storage"
+	"gopkg.in/yaml.v2"
+)
+
+// Policy is a declarative ruleset, approving the requests matching all of its
+// constraints without evaluating any user code. Policies are written in YAML,
+// or equivalently in JSON:
+//
+//	listing: true
+//	transactions:
+//	  chainIds: [1]
+//	  recipients: ["0x0000000000000000000000000000000000000001"]
+//	  methods: ["transfer(address,uint256)", "0x095ea7b3"]
+//	  limits:
+//	    - period: 24h
+//	      value: 1000000000000000000
+//	data:
+//	  domains:
+//	    - name: Ether Mail
+//	      version: "1"
+//	      chainId: 1
+//
+// Requests not matching the policy are rejected, or forwarded to the next UI
+// for manual processing if Manual is set.
+type Policy struct {
+	Listing      bool        `json:"listing" yaml:"listing"`           // Whether to approve account listings
+	Manual       bool        `json:"manual" yaml:"manual"`             // Whether to forward unmatched requests instead of rejecting them
+	Transactions *TxPolicy   `json:"transactions" yaml:"transactions"` // Constraints of approved transactions, none approved if nil
+	Data         *DataPolicy `json:"data" yaml:"data"`                 // Constraints of approved data signatures, none approved if nil
+}
+
+// TxPolicy contains the constraints a transaction must satisfy to be approved.
+// Empty constraints are not enforced.
+type TxPolicy struct {
+	ChainIDs   []*math.HexOrDecimal256 `json:"chainIds" yaml:"chainIds"`     // Chain IDs transactions must be explicitly signed for
+	Recipients []common.Address        `json:"recipients" yaml:"recipients"` // Recipients transactions may be sent to
+	Create     bool                    `json:"create" yaml:"create"`         // Whether contract creations are approved
+	Methods    []string                `json:"methods" yaml:"methods"`       // Method signatures or 4byte selectors calls may invoke
+	Limits     []SpendLimit            `json:"limits" yaml:"limits"`         // Limits on the value sent by each account
+
+	selectors map[[4]byte]string // Parsed method allowlist
+}
+
+// SpendLimit caps the total value an account may send over a sliding period.
+type SpendLimit struct {
+	Period time.Duration         `json:"period" yaml:"period"`
+	Value  *math.HexOrDecimal256 `json:"value" yaml:"value"`
+}
+
+// DataPolicy contains the constraints a data signing request must satisfy to
+// be approved.
+type DataPolicy struct {
+	ContentTypes []string `json:"contentTypes" yaml:"contentTypes"` // Content types other than typed data approved for signing
+	Domains      []Domain `json:"domains" yaml:"domains"`           // EIP-712 domains typed data may be signed for
+
+	separators [][]byte // Domain separators of the allowed domains
+}
+
+// Domain is an EIP-712 domain typed data may be signed for. Only the set fields
+// are part of the domain.
+type Domain struct {
+	Name              string                `json:"name" yaml:"name"`
+	Version           string                `json:"version" yaml:"version"`
+	ChainID           *math.HexOrDecimal256 `json:"chainId" yaml:"chainId"`
+	VerifyingContract *common.Address       `json:"verifyingContract" yaml:"verifyingContract"`
+	Salt              *common.Hash          `json:"salt" yaml:"salt"`
+}
+
+// LoadPolicy parses and validates a policy from the given YAML or JSON file.
+func LoadPolicy(path string) (*Policy, error) {
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParsePolicy(blob)
+}
+
+// ParsePolicy parses and validates a policy in YAML or JSON format. Unknown
+// fields are rejected to avoid silently ignoring misspelled constraints.
+func ParsePolicy(blob []byte) (*Policy, error) {
+	policy := new(Policy)
+	if err := yaml.UnmarshalStrict(blob, policy); err != nil {
+		return nil, err
+	}
+	if txs := policy.Transactions; txs != nil {
+		txs.selectors = make(map[[4]byte]string)
+		for _, method := range txs.Methods {
+			id, err := parseMethod(method)
+			if err != nil {
+				return nil, err
+			}
+			txs.selectors[id] = method
+		}
+		for i, limit := range txs.Limits {
+			if limit.Period <= 0 {
+				return nil, fmt.Errorf("spend limit %d: invalid period %v", i, limit.Period)
+			}
+			if limit.Value == nil || (*big.Int)(limit.Value).Sign() < 0 {
+				return nil, fmt.Errorf("spend limit %d: missing or negative value", i)
+			}
+		}
+	}
+	if data := policy.Data; data != nil {
+		for i := range data.Domains {
+			separator, err := domainSeparator(&data.Domains[i])
+			if err != nil {
+				return nil, fmt.Errorf("domain %d: %v", i, err)
+			}
+			data.separators = append(data.separators, separator)
+		}
+	}
+	return policy, nil
+}
+
+// parseMethod parses an allowlisted method, given either as a 0x-prefixed 4byte
+// selector or as a method signature.
+func parseMethod(method string) ([4]byte, error) {
+	var id [4]byte
+	if strings.HasPrefix(method, "0x") {
+		blob, err := hexutil.Decode(method)
+		if err != nil || len(blob) != 4 {
+			return id, fmt.Errorf("invalid method selector %q", method)
+		}
+		copy(id[:], blob)
+		return id, nil
+	}
+	if !strings.Contains(method, "(") || !strings.HasSuffix(method, ")") || strings.ContainsAny(method, " \t") {
+		return id, fmt.Errorf("invalid method signature %q", method)
+	}
+	copy(id[:], crypto.Keccak256([]byte(method)))
+	return id, nil
+}
+
+// domainSeparator computes the EIP-712 separator of a domain, assuming the
+// domain type declares the set fields in the canonical order.
+func domainSeparator(domain *Domain) ([]byte, error) {
+	var (
+		fields []apitypes.Type
+		typed  apitypes.TypedDataDomain
+	)
+	if domain.Name != "" {
+		fields = append(fields, apitypes.Type{Name: "name", Type: "string"})
+		typed.Name = domain.Name
+	}
+	if domain.Version != "" {
+		fields = append(fields, apitypes.Type{Name: "version", Type: "string"})
+		typed.Version = domain.Version
+	}
+	if domain.ChainID != nil {
+		fields = append(fields, apitypes.Type{Name: "chainId", Type: "uint256"})
+		typed.ChainId = domain.ChainID
+	}
+	if domain.VerifyingContract != nil {
+		fields = append(fields, apitypes.Type{Name: "verifyingContract", Type: "address"})
+		typed.VerifyingContract = domain.VerifyingContract.Hex()
+	}
+	if domain.Salt != nil {
+		fields = append(fields, apitypes.Type{Name: "salt", Type: "bytes32"})
+		typed.Salt = domain.Salt.Hex()
+	}
+	if len(fields) == 0 {
+		return nil, errors.New("empty domain")
+	}
+	typedData := apitypes.TypedData{
+		Types:  apitypes.Types{"EIP712Domain": fields},
+		Domain: typed,
+	}
+	return typedData.HashStruct("EIP712Domain", typed.Map())
+}
+
+// checkTx verifies that a transaction satisfies the static constraints of the
+// policy, returning the reason of the rejection otherwise.
+func (p *TxPolicy) checkTx(tx *core.SignTxRequest, db SelectorDB) error {
+	args := &tx.Transaction
+	if len(p.ChainIDs) > 0 {
+		if args.ChainID == nil {
+			return errors.New("chain id not specified")
+		}
+		var found bool
+		for _, id := range p.ChainIDs {
+			if (*big.Int)(id).Cmp((*big.Int)(args.ChainID)) == 0 {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("chain id %v not allowed", (*big.Int)(args.ChainID))
+		}
+	}
+	if args.To == nil {
+		if !p.Create {
+			return errors.New("contract creation not allowed")
+		}
+	} else if len(p.Recipients) > 0 {
+		var found bool
+		for _, addr := range p.Recipients {
+			if addr == args.To.Address() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("recipient %v not allowed", args.To.Address())
+		}
+	}
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+	if len(p.Methods) > 0 && args.To != nil && len(data) > 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("invalid call data %x", data)
+		}
+		var id [4]byte
+		copy(id[:], data)
+		if _, ok := p.selectors[id]; !ok {
+			if db != nil {
+				if method, err := db.Selector(id[:]); err == nil {
+					return fmt.Errorf("method %s (%x) not allowed", method, id)
+				}
+			}
+			return fmt.Errorf("method %x not allowed", id)
+		}
+	}
+	return nil
+}
+
+// checkData verifies that a data signing request satisfies the constraints of
+// the policy, returning the reason of the rejection otherwise.
+func (p *DataPolicy) checkData(req *core.SignDataRequest) error {
+	if req.ContentType != core.DataTyped.Mime {
+		for _, typ := range p.ContentTypes {
+			if typ == req.ContentType {
+				return nil
+			}
+		}
+		return fmt.Errorf("content type %s not allowed", req.ContentType)
+	}
+	// Typed data is signed as keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message))
+	if len(req.Rawdata) != 66 || !bytes.HasPrefix(req.Rawdata, []byte{0x19, 0x01}) {
+		return errors.New("malformed typed data")
+	}
+	for _, separator := range p.separators {
+		if bytes.Equal(req.Rawdata[2:34], separator) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %x not allowed", req.Rawdata[2:34])
+}
+
+// SelectorDB resolves 4byte method selectors to method signatures, such as the
+// signer/fourbyte database.
+type SelectorDB interface {
+	Selector(id []byte) (string, error)
+}
+
+// spendRecord is a transfer tracked in the storage for enforcing spend limits.
+type spendRecord struct {
+	Time  int64        `json:"time"`
+	Value *hexutil.Big `json:"value"`
+}
+
+// policyUI provides an implementation of UIClientAPI that evaluates requests
+// against a declarative policy.
+type policyUI struct {
+	next    core.UIClientAPI // The next handler, for manual processing
+	policy  *Policy
+	storage storage.Storage // Storage tracking the value sent by each account
+	db      SelectorDB      // Database for decoding called methods, may be nil
+
+	now  func() time.Time // Clock used for enforcing spend limits
+	lock sync.Mutex       // Lock serializing spend limit checks and updates
+}
+
+// NewPolicyEvaluator creates a UI approving the requests matching the given
+// policy, tracking the spent value in the given storage.
+func NewPolicyEvaluator(next core.UIClientAPI, policy *Policy, storage storage.Storage, db SelectorDB) *policyUI {
+	return &policyUI{
+		next:    next,
+		policy:  policy,
+		storage: storage,
+		db:      db,
+		now:     time.Now,
+	}
+}
+
+func (r *policyUI) RegisterUIServer(api *core.UIServerAPI) {
+	r.next.RegisterUIServer(api)
+}
+
+// spentKey returns the storage key tracking the value sent by an account.
+func spentKey(addr common.Address) string {
+	return "policy-spent-" + addr.Hex()
+}
+
+// spent returns the value transfers of an account tracked so far.
+func (r *policyUI) spent(addr common.Address) []spendRecord {
+	blob, err := r.storage.Get(spentKey(addr))
+	if err != nil {
+		return nil
+	}
+	var records []spendRecord
+	if err := json.Unmarshal([]byte(blob), &records); err != nil {
+		log.Warn("Failed to decode spend records", "addr", addr, "err", err)
+		return nil
+	}
+	return records
+}
+
+// checkLimits verifies that sending value from an account stays within all the
+// spend limits of the policy.
+func (r *policyUI) checkLimits(addr common.Address, value *big.Int) error {
+	records, now := r.spent(addr), r.now()
+	for _, limit := range r.policy.Transactions.Limits {
+		total := new(big.Int).Set(value)
+		for _, record := range records {
+			if now.Sub(time.Unix(record.Time, 0)) < limit.Period {
+				total.Add(total, record.Value.ToInt())
+			}
+		}
+		if total.Cmp((*big.Int)(limit.Value)) > 0 {
+			return fmt.Errorf("spend limit of %v per %v exceeded", (*big.Int)(limit.Value), limit.Period)
+		}
+	}
+	return nil
+}
+
+// reserve tracks the value sent from an account against the spend limits of
+// the policy, dropping the records outside of all limit periods. The policy
+// lock must be held.
+func (r *policyUI) reserve(addr common.Address, value *big.Int) {
+	if r.policy.Transactions == nil || len(r.policy.Transactions.Limits) == 0 || value.Sign() == 0 {
+		return
+	}
+	var (
+		now    = r.now()
+		period time.Duration
+	)
+	for _, limit := range r.policy.Transactions.Limits {
+		if limit.Period > period {
+			period = limit.Period
+		}
+	}
+	var records []spendRecord
+	for _, record := range r.spent(addr) {
+		if now.Sub(time.Unix(record.Time, 0)) < period {
+			records = append(records, record)
+		}
+	}
+	records = append(records, spendRecord{Time: now.Unix(), Value: (*hexutil.Big)(new(big.Int).Set(value))})
+	r.store(addr, records)
+}
+
+// release drops the latest record of the given value sent from an account,
+// undoing its reservation. The policy lock must be held.
+func (r *policyUI) release(addr common.Address, value *big.Int) {
+	records := r.spent(addr)
+	for i := len(records) - 1; i >= 0; i-- {
+		if records[i].Value.ToInt().Cmp(value) == 0 {
+			r.store(addr, append(records[:i], records[i+1:]...))
+			return
+		}
+	}
+}
+
+// store persists the value transfers tracked for an account.
+func (r *policyUI) store(addr common.Address, records []spendRecord) {
+	blob, err := json.Marshal(records)
+	if err != nil {
+		log.Warn("Failed to encode spend records", "err", err)
+		return
+	}
+	r.storage.Put(spentKey(addr), string(blob))
+}
+
+// reject either rejects a request or forwards it for manual processing, based
+// on the policy.
+func (r *policyUI) reject(reason error) bool {
+	if r.policy.Manual {
+		log.Info("Request not matching policy, going to manual", "reason", reason)
+		return false
+	}
+	log.Info("Request rejected by policy", "reason", reason)
+	return true
+}
+
+func (r *policyUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	err := errors.New("transactions not allowed")
+	if txs := r.policy.Transactions; txs != nil {
+		if err = txs.checkTx(request, r.db); err == nil {
+			// Check and reserve the value atomically, so concurrent requests
+			// can't exceed the limits together
+			from, value := request.Transaction.From.Address(), request.Transaction.Value.ToInt()
+
+			r.lock.Lock()
+			if err = r.checkLimits(from, value); err == nil {
+				r.reserve(from, value)
+			}
+			r.lock.Unlock()
+		}
+	}
+	if err == nil {
+		log.Info("Transaction approved by policy")
+		return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+	}
+	if r.reject(err) {
+		return core.SignTxResponse{Approved: false}, nil
+	}
+	resp, err := r.next.ApproveTx(request)
+	if err == nil && resp.Approved {
+		// Track manually approved transfers against the limits too
+		r.lock.Lock()
+		r.reserve(resp.Transaction.From.Address(), resp.Transaction.Value.ToInt())
+		r.lock.Unlock()
+	}
+	return resp, err
+}
+
+func (r *policyUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	err := errors.New("data signing not allowed")
+	if r.policy.Data != nil {
+		err = r.policy.Data.checkData(request)
+	}
+	if err == nil {
+		log.Info("Data signing approved by policy")
+		return core.SignDataResponse{Approved: true}, nil
+	}
+	if r.reject(err) {
+		return core.SignDataResponse{Approved: false}, nil
+	}
+	return r.next.ApproveSignData(request)
+}
+
+func (r *policyUI) ApproveListing(request *core.ListRequest) (core.ListResponse, error) {
+	if r.policy.Listing {
+		log.Info("Listing approved by policy")
+		return core.ListResponse{Accounts: request.Accounts}, nil
+	}
+	if r.reject(errors.New("listing not allowed")) {
+		return core.ListResponse{}, nil
+	}
+	return r.next.ApproveListing(request)
+}
+
+// OnInputRequired not handled by policies
+func (r *policyUI) OnInputRequired(info core.UserInputRequest) (core.UserInputResponse, error) {
+	return r.next.OnInputRequired(info)
+}
+
+func (r *policyUI) ApproveNewAccount(request *core.NewAccountRequest) (core.NewAccountResponse, error) {
+	// This cannot be handled by policies, requires setting a password
+	return r.next.ApproveNewAccount(request)
+}
+
+func (r *policyUI) ShowError(message string) {
+	log.Error(message)
+	r.next.ShowError(message)
+}
+
+func (r *policyUI) ShowInfo(message string) {
+	log.Info(message)
+	r.next.ShowInfo(message)
+}
+
+func (r *policyUI) OnSignerStartup(info core.StartupInfo) {
+	r.next.OnSignerStartup(info)
+}
+
+func (r *policyUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	r.next.OnApprovedTx(tx)
+}
+
+// OnSignTxFailed releases the value reserved for an approved transaction which
+// could not be signed.
+func (r *policyUI) OnSignTxFailed(tx apitypes.SendTxArgs) {
+	r.lock.Lock()
+	r.release(tx.From.Address(), tx.Value.ToInt())
+	r.lock.Unlock()
+
+	if handler, ok := r.next.(core.SignTxFailureHandler); ok {
+		handler.OnSignTxFailed(tx)
+	}
+}
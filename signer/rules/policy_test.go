@@ -0,0 +1,374 @@
+// Copyright 2021 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rules
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/ethapi"
+	"github.com/ethereum/go-ethereum/signer/core"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/ethereum/go-ethereum/signer/storage"
+)
+
+const testPolicy = `
+listing: true
+transactions:
+  chainIds: [1, 0x5]
+  recipients:
+    - 0x0000000000000000000000000000000000001337
+    - 0x000000000000000000000000000000000000dead
+  methods:
+    - transfer(address,uint256)
+    - 0x095ea7b3
+  limits:
+    - period: 24h
+      value: 1000
+    - period: 168h
+      value: 0x7d0
+data:
+  contentTypes: [text/plain]
+  domains:
+    - name: Ether Mail
+      version: "1"
+      chainId: 1
+      verifyingContract: 0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC
+`
+
+const testPolicyJSON = `{
+	"listing": true,
+	"transactions": {
+		"chainIds": [1, "0x5"],
+		"recipients": ["0x0000000000000000000000000000000000001337", "0x000000000000000000000000000000000000dead"],
+		"methods": ["transfer(address,uint256)", "0x095ea7b3"],
+		"limits": [{"period": "24h", "value": 1000}, {"period": "168h", "value": "0x7d0"}]
+	},
+	"data": {
+		"contentTypes": ["text/plain"],
+		"domains": [{"name": "Ether Mail", "version": "1", "chainId": 1, "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"}]
+	}
+}`
+
+// approvingUI is a UI approving all requests, recording the signed transactions.
+type approvingUI struct {
+	alwaysDenyUI
+	signed []ethapi.SignTransactionResult
+}
+
+func (ui *approvingUI) ApproveTx(request *core.SignTxRequest) (core.SignTxResponse, error) {
+	return core.SignTxResponse{Transaction: request.Transaction, Approved: true}, nil
+}
+
+func (ui *approvingUI) ApproveSignData(request *core.SignDataRequest) (core.SignDataResponse, error) {
+	return core.SignDataResponse{Approved: true}, nil
+}
+
+func (ui *approvingUI) OnApprovedTx(tx ethapi.SignTransactionResult) {
+	ui.signed = append(ui.signed, tx)
+}
+
+// testSelectors is a SelectorDB knowing a single method.
+type testSelectors struct{}
+
+func (testSelectors) Selector(id []byte) (string, error) {
+	if common.Bytes2Hex(id) == "23b872dd" {
+		return "transferFrom(address,address,uint256)", nil
+	}
+	return "", fmt.Errorf("signature %x not found", id)
+}
+
+func newTestPolicyUI(t *testing.T, next core.UIClientAPI, manual bool) *policyUI {
+	policy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse policy: %v", err)
+	}
+	policy.Manual = manual
+	return NewPolicyEvaluator(next, policy, storage.NewEphemeralStorage(), testSelectors{})
+}
+
+func policyTx(to string, value int64, chainID int64, data string) *core.SignTxRequest {
+	from, _ := mixAddr("0x000000000000000000000000000000000000f00d")
+	args := apitypes.SendTxArgs{
+		From:  *from,
+		Value: hexutil.Big(*big.NewInt(value)),
+	}
+	if to != "" {
+		args.To, _ = mixAddr(to)
+	}
+	if chainID != 0 {
+		args.ChainID = (*hexutil.Big)(big.NewInt(chainID))
+	}
+	if data != "" {
+		input := hexutil.Bytes(common.FromHex(data))
+		args.Input = &input
+	}
+	return &core.SignTxRequest{Transaction: args}
+}
+
+// Tests that YAML and JSON policies are parsed identically, and that invalid
+// policies are rejected.
+func TestPolicyParsing(t *testing.T) {
+	yamlPolicy, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("failed to parse YAML policy: %v", err)
+	}
+	jsonPolicy, err := ParsePolicy([]byte(testPolicyJSON))
+	if err != nil {
+		t.Fatalf("failed to parse JSON policy: %v", err)
+	}
+	if !reflect.DeepEqual(yamlPolicy, jsonPolicy) {
+		t.Errorf("policy mismatch:\nyaml: %+v\njson: %+v", yamlPolicy, jsonPolicy)
+	}
+	if limit := yamlPolicy.Transactions.Limits[1]; limit.Period != 7*24*time.Hour || (*big.Int)(limit.Value).Int64() != 2000 {
+		t.Errorf("spend limit mismatch: have %v/%v, want %v/%v", limit.Value, limit.Period, 2000, 7*24*time.Hour)
+	}
+	invalid := []string{
+		"listings: true",
+		"transactions: {methods: [transfer]}",
+		"transactions: {methods: [0x1234]}",
+		"transactions: {limits: [{period: 0s, value: 1}]}",
+		"transactions: {limits: [{period: 1h}]}",
+		"transactions: {recipients: [0x1234]}",
+		"data: {domains: [{}]}",
+	}
+	for _, policy := range invalid {
+		if _, err := ParsePolicy([]byte(policy)); err == nil {
+			t.Errorf("invalid policy %q accepted", policy)
+		}
+	}
+}
+
+// Tests that transactions are approved only if matching the policy.
+func TestPolicyTransactions(t *testing.T) {
+	tests := []struct {
+		tx       *core.SignTxRequest
+		approved bool
+	}{
+		{policyTx("0x0000000000000000000000000000000000001337", 10, 1, ""), true},
+		{policyTx("0x000000000000000000000000000000000000dead", 10, 5, ""), true},
+		{policyTx("0x0000000000000000000000000000000000001337", 10, 0, ""), false},            // Missing chain id
+		{policyTx("0x0000000000000000000000000000000000001337", 10, 3, ""), false},            // Invalid chain id
+		{policyTx("0x0000000000000000000000000000000000000001", 10, 1, ""), false},            // Invalid recipient
+		{policyTx("", 0, 1, "0x6080"), false},                                                 // Contract creation
+		{policyTx("0x0000000000000000000000000000000000001337", 0, 1, "0xa9059cbb00"), true},  // transfer(address,uint256)
+		{policyTx("0x0000000000000000000000000000000000001337", 0, 1, "0x095ea7b300"), true},  // approve(address,uint256)
+		{policyTx("0x0000000000000000000000000000000000001337", 0, 1, "0x23b872dd00"), false}, // transferFrom(address,address,uint256)
+		{policyTx("0x0000000000000000000000000000000000001337", 0, 1, "0x23b8"), false},       // Truncated selector
+		{policyTx("0x0000000000000000000000000000000000001337", 1001, 1, ""), false},          // Over the spend limit
+	}
+	for i, tt := range tests {
+		for _, manual := range []bool{false, true} {
+			r := newTestPolicyUI(t, new(approvingUI), manual)
+			resp, err := r.ApproveTx(tt.tx)
+			if err != nil {
+				t.Fatalf("test %d: approval failed: %v", i, err)
+			}
+			if want := tt.approved || manual; resp.Approved != want {
+				t.Errorf("test %d, manual %v: approval mismatch: have %v, want %v", i, manual, resp.Approved, want)
+			}
+		}
+	}
+}
+
+// Tests that the value sent by signed transactions is tracked against the spend
+// limits over their periods.
+func TestPolicySpendLimits(t *testing.T) {
+	var (
+		next = new(approvingUI)
+		r    = newTestPolicyUI(t, next, false)
+		now  = time.Unix(1000000, 0)
+		key  = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+	)
+	r.now = func() time.Time { return now }
+
+	privkey, _ := crypto.HexToECDSA(key)
+	from := common.NewMixedcaseAddress(crypto.PubkeyToAddress(privkey.PublicKey))
+
+	send := func(value int64) bool {
+		req := policyTx("0x0000000000000000000000000000000000001337", value, 1, "")
+		req.Transaction.From = from
+
+		resp, err := r.ApproveTx(req)
+		if err != nil {
+			t.Fatalf("approval failed: %v", err)
+		}
+		if !resp.Approved {
+			return false
+		}
+		signer := types.LatestSignerForChainID(big.NewInt(1))
+		tx, _ := types.SignNewTx(privkey, signer, &types.DynamicFeeTx{
+			ChainID:   big.NewInt(1),
+			Value:     big.NewInt(value),
+			GasTipCap: new(big.Int),
+			GasFeeCap: new(big.Int),
+		})
+		r.OnApprovedTx(ethapi.SignTransactionResult{Tx: tx})
+		return true
+	}
+	if !send(600) {
+		t.Fatalf("transfer within daily limit rejected")
+	}
+	if send(600) {
+		t.Fatalf("transfer over daily limit approved")
+	}
+	if !send(400) {
+		t.Fatalf("transfer up to daily limit rejected")
+	}
+	// Move past the daily limit, still within the weekly one
+	now = now.Add(25 * time.Hour)
+	if !send(1000) {
+		t.Fatalf("transfer within weekly limit rejected")
+	}
+	now = now.Add(25 * time.Hour)
+	if send(1) {
+		t.Fatalf("transfer over weekly limit approved")
+	}
+	// Move past the weekly limit, expiring the first transfers
+	now = now.Add(6 * 24 * time.Hour)
+	if !send(1000) {
+		t.Fatalf("transfer after weekly limit rejected")
+	}
+	if len(next.signed) != 4 {
+		t.Errorf("signed transactions not forwarded: have %d, want %d", len(next.signed), 4)
+	}
+	if records := r.spent(from.Address()); len(records) != 1 {
+		t.Errorf("expired spend records not dropped: have %d, want %d", len(records), 1)
+	}
+}
+
+// Tests that concurrent transactions can't exceed the spend limits together, as
+// the value is reserved on approval.
+func TestPolicySpendLimitsConcurrent(t *testing.T) {
+	r := newTestPolicyUI(t, new(approvingUI), false)
+
+	var (
+		approved int32
+		pend     sync.WaitGroup
+	)
+	for i := 0; i < 25; i++ {
+		pend.Add(1)
+		go func() {
+			defer pend.Done()
+
+			resp, err := r.ApproveTx(policyTx("0x0000000000000000000000000000000000001337", 100, 1, ""))
+			if err != nil {
+				t.Errorf("approval failed: %v", err)
+				return
+			}
+			if resp.Approved {
+				atomic.AddInt32(&approved, 1)
+			}
+		}()
+	}
+	pend.Wait()
+
+	if approved != 10 {
+		t.Errorf("approved transactions mismatch: have %d, want %d", approved, 10)
+	}
+}
+
+// Tests that the value reserved for a transaction is released if it fails to
+// be signed.
+func TestPolicySpendLimitsRelease(t *testing.T) {
+	r := newTestPolicyUI(t, new(approvingUI), false)
+
+	req := policyTx("0x0000000000000000000000000000000000001337", 1000, 1, "")
+	if resp, _ := r.ApproveTx(req); !resp.Approved {
+		t.Fatalf("transfer within daily limit rejected")
+	}
+	if resp, _ := r.ApproveTx(req); resp.Approved {
+		t.Fatalf("transfer over daily limit approved")
+	}
+	r.OnSignTxFailed(req.Transaction)
+	if resp, _ := r.ApproveTx(req); !resp.Approved {
+		t.Fatalf("transfer rejected after failed signing")
+	}
+}
+
+// Tests that data signing is approved only for the allowed content types and
+// typed data domains.
+func TestPolicySignData(t *testing.T) {
+	r := newTestPolicyUI(t, new(approvingUI), false)
+
+	typedData := func(domain apitypes.TypedDataDomain) *core.SignDataRequest {
+		data := apitypes.TypedData{
+			Types: apitypes.Types{
+				"EIP712Domain": []apitypes.Type{
+					{Name: "name", Type: "string"},
+					{Name: "version", Type: "string"},
+					{Name: "chainId", Type: "uint256"},
+					{Name: "verifyingContract", Type: "address"},
+				},
+				"Mail": []apitypes.Type{{Name: "contents", Type: "string"}},
+			},
+			PrimaryType: "Mail",
+			Domain:      domain,
+			Message:     apitypes.TypedDataMessage{"contents": "Hello, Bob!"},
+		}
+		separator, err := data.HashStruct("EIP712Domain", domain.Map())
+		if err != nil {
+			t.Fatalf("failed to hash domain: %v", err)
+		}
+		hash, err := data.HashStruct("Mail", data.Message)
+		if err != nil {
+			t.Fatalf("failed to hash message: %v", err)
+		}
+		return &core.SignDataRequest{
+			ContentType: core.DataTyped.Mime,
+			Rawdata:     append(append([]byte{0x19, 0x01}, separator...), hash...),
+		}
+	}
+	domain := apitypes.TypedDataDomain{
+		Name:              "Ether Mail",
+		Version:           "1",
+		ChainId:           math.NewHexOrDecimal256(1),
+		VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+	}
+	other := domain
+	other.ChainId = math.NewHexOrDecimal256(5)
+
+	tests := []struct {
+		req      *core.SignDataRequest
+		approved bool
+	}{
+		{&core.SignDataRequest{ContentType: core.TextPlain.Mime}, true},
+		{&core.SignDataRequest{ContentType: core.ApplicationClique.Mime}, false},
+		{typedData(domain), true},
+		{typedData(other), false},
+		{&core.SignDataRequest{ContentType: core.DataTyped.Mime, Rawdata: []byte{0x19, 0x01}}, false},
+	}
+	for i, tt := range tests {
+		resp, err := r.ApproveSignData(tt.req)
+		if err != nil {
+			t.Fatalf("test %d: approval failed: %v", i, err)
+		}
+		if resp.Approved != tt.approved {
+			t.Errorf("test %d: approval mismatch: have %v, want %v", i, resp.Approved, tt.approved)
+		}
+	}
+}